@@ -2,13 +2,20 @@ package handler
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
+	"todo-backend/caldav"
 	"todo-backend/config"
 	"todo-backend/controllers"
 	"todo-backend/middleware"
+	"todo-backend/notify"
+	"todo-backend/quickadd"
 	"todo-backend/repositories"
 	"todo-backend/services"
+	"todo-backend/storage"
 	"todo-backend/utils"
 
 	"github.com/gin-contrib/cors"
@@ -16,12 +23,27 @@ import (
 )
 
 var (
-	app                 *gin.Engine
-	authController      *controllers.AuthController
-	taskController      *controllers.TaskController
-	habitController     *controllers.HabitController
-	logController       *controllers.LogController
-	analyticsController *controllers.AnalyticsController
+	app                   *gin.Engine
+	authController        *controllers.AuthController
+	taskController        *controllers.TaskController
+	habitController       *controllers.HabitController
+	logController         *controllers.LogController
+	analyticsController   *controllers.AnalyticsController
+	trashController       *controllers.TrashController
+	timeController        *controllers.TimeTrackingController
+	templateController    *controllers.TemplateController
+	projectController     *controllers.ProjectController
+	attachmentController  *controllers.AttachmentController
+	commentController     *controllers.CommentController
+	reminderController    *controllers.ReminderController
+	plannerController     *controllers.PlannerController
+	boardController       *controllers.BoardController
+	calendarController    *controllers.CalendarFeedController
+	appPasswordController *controllers.AppPasswordController
+	calDAVController      *controllers.CalDAVController
+
+	// appPasswordService signs CalDAV clients in
+	appPasswordService services.AppPasswordService
 )
 
 func init() {
@@ -53,6 +75,26 @@ func init() {
 	// Set JWT secret
 	utils.SetJWTSecret(cfg.JWTSecret)
 
+	// Register extra quick-add locales
+	if cfg.QuickAddLocales != "" {
+		if n, err := quickadd.LoadLocaleFile(cfg.QuickAddLocales); err != nil {
+			log.Printf("Warning: failed to load quick-add locales: %v", err)
+		} else {
+			log.Printf("Loaded %d quick-add locales from %s", n, cfg.QuickAddLocales)
+		}
+	}
+
+	// Open attachment storage; serverless instances may only be able to
+	// write to the temp directory, so a local store falls back to it
+	attachmentStore, err := storage.Open(cfg.AttachmentStorage)
+	if err != nil && cfg.AttachmentStorage.Backend == storage.BackendLocal {
+		log.Printf("Warning: failed to open attachment storage, using the temp directory: %v", err)
+		attachmentStore, err = storage.NewLocalStore(filepath.Join(os.TempDir(), "attachments"))
+	}
+	if err != nil {
+		log.Fatal("Failed to open attachment storage:", err)
+	}
+
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(config.DB)
 	taskRepo := repositories.NewTaskRepository(config.DB)
@@ -60,19 +102,61 @@ func init() {
 	logRepo := repositories.NewLogRepository(config.DB)
 	pomodoroRepo := repositories.NewPomodoroRepository(config.DB)
 	goalRepo := repositories.NewGoalRepository(config.DB)
+	tagRepo := repositories.NewTagRepository(config.DB)
+	trashRepo := repositories.NewTrashRepository(config.DB)
+	revisionRepo := repositories.NewRevisionRepository(config.DB)
+	timeEntryRepo := repositories.NewTimeEntryRepository(config.DB)
+	templateRepo := repositories.NewTemplateRepository(config.DB)
+	projectRepo := repositories.NewProjectRepository(config.DB)
+	attachmentRepo := repositories.NewAttachmentRepository(config.DB)
+	commentRepo := repositories.NewCommentRepository(config.DB)
+	reminderRepo := repositories.NewReminderRepository(config.DB)
+	notificationRepo := repositories.NewNotificationRepository(config.DB)
+	plannerRepo := repositories.NewPlannerRepository(config.DB)
+	calendarFeedRepo := repositories.NewCalendarFeedRepository(config.DB)
+	appPasswordRepo := repositories.NewAppPasswordRepository(config.DB)
+	boardRepo := repositories.NewBoardRepository(config.DB)
 
 	// Initialize services
 	authService := services.NewAuthService(userRepo, logRepo)
-	taskService := services.NewTaskService(taskRepo, logRepo)
-	habitService := services.NewHabitService(habitRepo, logRepo, pomodoroRepo, goalRepo)
+	taskService := services.NewTaskService(taskRepo, logRepo, tagRepo, revisionRepo, projectRepo, cfg.SubtaskPolicy, cfg.BlockedCompletion, cfg.Matrix)
+	habitService := services.NewHabitService(habitRepo, logRepo, pomodoroRepo, goalRepo, revisionRepo, taskRepo)
 	logService := services.NewLogService(logRepo)
+	attachmentService := services.NewAttachmentService(attachmentRepo, taskRepo, logRepo, attachmentStore, cfg.AttachmentLimits)
+	trashService := services.NewTrashService(trashRepo, logRepo, attachmentService, cfg.TrashRetentionDays)
+	timeService := services.NewTimeTrackingService(timeEntryRepo, taskRepo, logRepo)
+	templateService := services.NewTemplateService(templateRepo, taskRepo, logRepo, revisionRepo)
+	projectService := services.NewProjectService(projectRepo, taskRepo, logRepo, revisionRepo)
+	boardService := services.NewBoardService(boardRepo, taskRepo, projectRepo, taskService, logRepo)
+	commentService := services.NewCommentService(commentRepo, taskRepo, logRepo)
+	reminderService := services.NewReminderService(reminderRepo, notificationRepo, taskRepo, habitRepo, logRepo, notify.Open(cfg.Notifiers)...)
+	plannerService := services.NewPlannerService(plannerRepo, taskRepo, logRepo)
+	calendarFeedService := services.NewCalendarFeedService(calendarFeedRepo, taskRepo, goalRepo, logRepo)
+	appPasswordService = services.NewAppPasswordService(appPasswordRepo, logRepo)
+	calDAVService := services.NewCalDAVService(taskService, taskRepo)
 
 	// Initialize controllers
 	authController = controllers.NewAuthController(authService)
-	taskController = controllers.NewTaskController(taskService)
+	taskController = controllers.NewTaskController(taskService, plannerService)
 	habitController = controllers.NewHabitController(habitService)
 	logController = controllers.NewLogController(logService)
-	analyticsController = controllers.NewAnalyticsController(authService, taskService, habitService)
+	analyticsController = controllers.NewAnalyticsController(authService, taskService, habitService, timeService)
+	trashController = controllers.NewTrashController(trashService)
+	timeController = controllers.NewTimeTrackingController(timeService)
+	templateController = controllers.NewTemplateController(templateService)
+	projectController = controllers.NewProjectController(projectService)
+	boardController = controllers.NewBoardController(boardService)
+	attachmentController = controllers.NewAttachmentController(attachmentService, cfg.AttachmentLimits)
+	commentController = controllers.NewCommentController(commentService)
+	reminderController = controllers.NewReminderController(reminderService)
+	plannerController = controllers.NewPlannerController(plannerService)
+	calendarController = controllers.NewCalendarFeedController(calendarFeedService)
+	appPasswordController = controllers.NewAppPasswordController(appPasswordService)
+	calDAVController = controllers.NewCalDAVController(calDAVService)
+
+	// Purge items that outlived the trash retention period while this instance is warm
+	go trashService.RunPurger(time.Hour)
+	go reminderService.RunScheduler(time.Duration(cfg.ReminderPollSeconds) * time.Second)
 
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{
@@ -261,6 +345,9 @@ func setupRoutes() {
 	app.POST("/api/v1/login", authController.Login)
 	app.GET("/api/v1/check-username", authController.CheckUsername)
 
+	// Calendar subscriptions authenticate with the feed token in the URL
+	app.GET("/api/v1/calendar/feed/:token", calendarController.Feed)
+
 	// Protected routes (authentication required)
 	protected := app.Group("/api/v1")
 	protected.Use(middleware.AuthMiddleware())
@@ -300,11 +387,30 @@ func setupRoutes() {
 		{"GET", "/tasks/overdue", taskController.GetOverdueTasks},
 		{"GET", "/tasks/search", taskController.SearchTasks},
 		{"PATCH", "/tasks/:id/status", taskController.UpdateTaskStatus},
+		{"GET", "/tasks/:id/status-history", taskController.GetTaskStatusHistory},
 		{"POST", "/tasks/:id/duplicate", taskController.DuplicateTask},
+		{"POST", "/tasks/bulk", taskController.BulkUpdateTasks},
+		{"POST", "/tasks/quick", taskController.QuickAddTask},
 		{"GET", "/tasks/calendar/day/:date", taskController.GetTasksByDate},
 		{"GET", "/tasks/calendar/week/:date", taskController.GetTasksForWeek},
 		{"GET", "/tasks/calendar/month/:date", taskController.GetTasksForMonth},
 		{"PATCH", "/tasks/:id/reschedule", taskController.RescheduleTask},
+		{"GET", "/tasks/:id/reschedules", taskController.GetTaskReschedules},
+		{"GET", "/tasks/:id/revisions", taskController.GetTaskRevisions},
+		{"POST", "/tasks/:id/revisions/:rev/restore", taskController.RestoreTaskRevision},
+		{"GET", "/tasks/matrix", taskController.GetTaskMatrix},
+		{"PUT", "/tasks/:id/importance", taskController.SetTaskImportance},
+		{"GET", "/tasks/:id/blockers", taskController.GetTaskBlockers},
+		{"POST", "/tasks/:id/blockers", taskController.AddTaskBlocker},
+		{"DELETE", "/tasks/:id/blockers/:blockerId", taskController.RemoveTaskBlocker},
+		{"GET", "/tasks/:id/dependents", taskController.GetTaskDependents},
+		{"GET", "/tasks/dependencies/graph", taskController.GetDependencyGraph},
+		{"GET", "/tasks/:id/occurrences", taskController.GetTaskOccurrences},
+		{"POST", "/tasks/recurrence/preview", taskController.PreviewRecurrence},
+		{"GET", "/tags", taskController.GetTags},
+		{"PATCH", "/tags/:id", taskController.RenameTag},
+		{"DELETE", "/tags/:id", taskController.DeleteTag},
+		{"POST", "/tags/merge", taskController.MergeTags},
 		{"GET", "/dashboard/summary", taskController.GetDashboardSummary},
 		{"GET", "/dashboard/upcoming", taskController.GetUpcomingTasks},
 		{"GET", "/dashboard/recent", taskController.GetRecentActivity},
@@ -327,6 +433,8 @@ func setupRoutes() {
 		{"DELETE", "/habits/:id", habitController.DeleteHabit},
 		{"PATCH", "/habits/:id/track", habitController.TrackHabit},
 		{"PATCH", "/habits/:id/achieve", habitController.MarkHabitAchieved},
+		{"GET", "/habits/:id/revisions", habitController.GetHabitRevisions},
+		{"POST", "/habits/:id/revisions/:rev/restore", habitController.RestoreHabitRevision},
 		{"GET", "/habits/export", habitController.ExportHabits},
 		{"POST", "/habits/import", habitController.ImportHabits},
 		{"GET", "/habits/:id/streak", habitController.GetHabitStreak},
@@ -348,6 +456,7 @@ func setupRoutes() {
 		{"GET", "/analytics/performance/monthly", analyticsController.GetMonthlyPerformance},
 		{"GET", "/analytics/time-allocation", analyticsController.GetTimeAllocation},
 		{"GET", "/analytics/productivity-trends", analyticsController.GetProductivityTrends},
+		{"GET", "/analytics/postponements", analyticsController.GetPostponements},
 	}
 	for _, r := range analyticsRoutes {
 		protected.Handle(r.method, r.path, r.handler)
@@ -377,11 +486,196 @@ func setupRoutes() {
 		{"PATCH", "/goals/:id", habitController.UpdateGoal},
 		{"DELETE", "/goals/:id", habitController.DeleteGoal},
 		{"PATCH", "/goals/:id/progress", habitController.UpdateGoalProgress},
+		{"GET", "/goals/:id/revisions", habitController.GetGoalRevisions},
+		{"POST", "/goals/:id/revisions/:rev/restore", habitController.RestoreGoalRevision},
 	}
 	for _, r := range goalRoutes {
 		protected.Handle(r.method, r.path, r.handler)
 	}
 
+	// Trash routes
+	trashRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/trash", trashController.GetTrash},
+		{"DELETE", "/trash", trashController.EmptyTrash},
+		{"POST", "/trash/:type/:id/restore", trashController.RestoreItem},
+		{"DELETE", "/trash/:type/:id", trashController.PurgeItem},
+	}
+	for _, r := range trashRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Time tracking routes
+	timeRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"POST", "/tasks/:id/timer/start", timeController.StartTimer},
+		{"POST", "/tasks/:id/timer/stop", timeController.StopTimer},
+		{"GET", "/timer", timeController.GetRunningTimer},
+		{"GET", "/tasks/:id/time", timeController.GetTaskTime},
+		{"POST", "/tasks/:id/time-entries", timeController.AddTimeEntry},
+		{"PATCH", "/time-entries/:id", timeController.UpdateTimeEntry},
+		{"DELETE", "/time-entries/:id", timeController.DeleteTimeEntry},
+	}
+	for _, r := range timeRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Task template routes
+	templateRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/templates", templateController.GetTemplates},
+		{"POST", "/templates", templateController.CreateTemplate},
+		{"POST", "/templates/from-tasks", templateController.CreateTemplateFromTasks},
+		{"GET", "/templates/:id", templateController.GetTemplate},
+		{"PATCH", "/templates/:id", templateController.UpdateTemplate},
+		{"DELETE", "/templates/:id", templateController.DeleteTemplate},
+		{"POST", "/templates/:id/instantiate", templateController.InstantiateTemplate},
+	}
+	for _, r := range templateRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Project routes
+	projectRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/projects", projectController.GetProjects},
+		{"POST", "/projects", projectController.CreateProject},
+		{"GET", "/projects/:id", projectController.GetProject},
+		{"PATCH", "/projects/:id", projectController.UpdateProject},
+		{"DELETE", "/projects/:id", projectController.DeleteProject},
+		{"GET", "/projects/:id/tasks", projectController.GetProjectTasks},
+		{"PATCH", "/tasks/:id/move", projectController.MoveTask},
+	}
+	for _, r := range projectRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Board routes
+	boardRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/boards", boardController.GetBoards},
+		{"POST", "/boards", boardController.CreateBoard},
+		{"GET", "/boards/:id", boardController.GetBoard},
+		{"PATCH", "/boards/:id", boardController.UpdateBoard},
+		{"DELETE", "/boards/:id", boardController.DeleteBoard},
+		{"POST", "/boards/:id/cards/:task_id/move", boardController.MoveCard},
+	}
+	for _, r := range boardRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Attachment routes
+	attachmentRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/tasks/:id/attachments", attachmentController.GetTaskAttachments},
+		{"POST", "/tasks/:id/attachments", attachmentController.UploadAttachment},
+		{"GET", "/tasks/:id/attachments/:attachment_id", attachmentController.DownloadAttachment},
+		{"DELETE", "/tasks/:id/attachments/:attachment_id", attachmentController.DeleteAttachment},
+	}
+	for _, r := range attachmentRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Comment routes
+	commentRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/tasks/:id/comments", commentController.GetTaskComments},
+		{"POST", "/tasks/:id/comments", commentController.AddComment},
+		{"PATCH", "/tasks/:id/comments/:comment_id", commentController.UpdateComment},
+		{"DELETE", "/tasks/:id/comments/:comment_id", commentController.DeleteComment},
+	}
+	for _, r := range commentRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Reminder and notification routes
+	reminderRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/reminders", reminderController.GetReminders},
+		{"DELETE", "/reminders/:id", reminderController.DeleteReminder},
+		{"POST", "/reminders/:id/snooze", reminderController.SnoozeReminder},
+		{"POST", "/reminders/:id/dismiss", reminderController.DismissReminder},
+		{"GET", "/tasks/:id/reminders", reminderController.GetTaskReminders},
+		{"POST", "/tasks/:id/reminders", reminderController.CreateTaskReminder},
+		{"GET", "/habits/:id/reminders", reminderController.GetHabitReminders},
+		{"POST", "/habits/:id/reminders", reminderController.CreateHabitReminder},
+		{"GET", "/notifications", reminderController.GetNotifications},
+		{"POST", "/notifications/read-all", reminderController.MarkAllNotificationsRead},
+		{"POST", "/notifications/:id/read", reminderController.MarkNotificationRead},
+	}
+	for _, r := range reminderRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Planner routes
+	plannerRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/planner/working-hours", plannerController.GetWorkingHours},
+		{"PUT", "/planner/working-hours", plannerController.SetWorkingHours},
+		{"GET", "/planner/blocks", plannerController.GetTimeBlocks},
+		{"POST", "/planner/blocks", plannerController.CreateTimeBlock},
+		{"DELETE", "/planner/blocks/:id", plannerController.DeleteTimeBlock},
+		{"POST", "/planner/plan", plannerController.PlanDay},
+		{"POST", "/planner/plan/accept", plannerController.AcceptPlan},
+	}
+	for _, r := range plannerRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// Calendar feed routes
+	calendarRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/calendar/feeds", calendarController.GetFeeds},
+		{"POST", "/calendar/feeds", calendarController.CreateFeed},
+		{"DELETE", "/calendar/feeds/:id", calendarController.RevokeFeed},
+	}
+	for _, r := range calendarRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// App password routes
+	appPasswordRoutes := []struct {
+		method, path string
+		handler      gin.HandlerFunc
+	}{
+		{"GET", "/user/app-passwords", appPasswordController.GetAppPasswords},
+		{"POST", "/user/app-passwords", appPasswordController.CreateAppPassword},
+		{"DELETE", "/user/app-passwords/:id", appPasswordController.RevokeAppPassword},
+	}
+	for _, r := range appPasswordRoutes {
+		protected.Handle(r.method, r.path, r.handler)
+	}
+
+	// CalDAV task sync; clients sign in with an app password, not a JWT
+	app.GET("/.well-known/caldav", calDAVController.WellKnown)
+	app.Handle("PROPFIND", "/.well-known/caldav", calDAVController.WellKnown)
+	app.OPTIONS("/caldav/*path", calDAVController.Options)
+	calDAV := app.Group("/caldav")
+	calDAV.Use(middleware.AppPasswordMiddleware(appPasswordService.Authenticate))
+	for _, method := range caldav.Methods {
+		calDAV.Handle(method, "/*path", calDAVController.Serve)
+	}
+
 	// Catch all route for debugging
 	app.NoRoute(func(c *gin.Context) {
 		c.JSON(404, gin.H{
@@ -0,0 +1,58 @@
+#!/bin/bash
+
+# CalDAV smoke test: syncs one task through the /caldav endpoint like a
+# client would. Create an app password first with
+#   POST /api/v1/user/app-passwords {"name": "smoke test"}
+#
+# Usage: ./caldav-smoke.sh <username> <app-password> [base-url]
+
+set -euo pipefail
+
+USERNAME="${1:?usage: $0 <username> <app-password> [base-url]}"
+PASSWORD="${2:?usage: $0 <username> <app-password> [base-url]}"
+BASE_URL="${3:-http://localhost:8080}"
+COLLECTION="$BASE_URL/caldav/calendars/$USERNAME/tasks/"
+RESOURCE="${COLLECTION}smoke-$$.ics"
+
+dav() {
+    curl -sS -u "$USERNAME:$PASSWORD" -o /tmp/caldav-smoke.out -D /tmp/caldav-smoke.headers -w "%{http_code}" "$@"
+}
+
+expect() {
+    local step="$1" want="$2" got="$3"
+    if [ "$got" != "$want" ]; then
+        echo "❌ $step: expected $want, got $got"
+        cat /tmp/caldav-smoke.out
+        exit 1
+    fi
+    echo "✅ $step ($got)"
+}
+
+etag() {
+    grep -i '^etag:' /tmp/caldav-smoke.headers | cut -d' ' -f2 | tr -d '\r'
+}
+
+vtodo() {
+    printf 'BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//smoke//EN\r\nBEGIN:VTODO\r\nUID:smoke-%s@example.com\r\nSUMMARY:%s\r\nDUE;VALUE=DATE:20300101\r\nSTATUS:%s\r\nEND:VTODO\r\nEND:VCALENDAR\r\n' "$$" "$1" "$2"
+}
+
+echo "🔎 Discovering the task collection on $BASE_URL..."
+expect "OPTIONS" 200 "$(dav -X OPTIONS "$BASE_URL/caldav/")"
+grep -qi '^dav:.*calendar-access' /tmp/caldav-smoke.headers || { echo "❌ DAV header lacks calendar-access"; exit 1; }
+expect "PROPFIND principal" 207 "$(dav -X PROPFIND -H 'Depth: 0' --data '<d:propfind xmlns:d="DAV:"><d:prop><d:current-user-principal/></d:prop></d:propfind>' "$BASE_URL/caldav/")"
+expect "PROPFIND collection" 207 "$(dav -X PROPFIND -H 'Depth: 1' "$COLLECTION")"
+
+echo "📝 Creating, updating and deleting a task..."
+expect "PUT new task" 201 "$(dav -X PUT -H 'If-None-Match: *' -H 'Content-Type: text/calendar' --data-binary "$(vtodo 'CalDAV smoke test' NEEDS-ACTION)" "$RESOURCE")"
+expect "PUT existing with If-None-Match" 412 "$(dav -X PUT -H 'If-None-Match: *' -H 'Content-Type: text/calendar' --data-binary "$(vtodo 'CalDAV smoke test' NEEDS-ACTION)" "$RESOURCE")"
+expect "GET task" 200 "$(dav "$RESOURCE")"
+ETAG="$(etag)"
+expect "REPORT multiget" 207 "$(dav -X REPORT -H 'Depth: 1' --data "<c:calendar-multiget xmlns:d=\"DAV:\" xmlns:c=\"urn:ietf:params:xml:ns:caldav\"><d:prop><d:getetag/><c:calendar-data/></d:prop><d:href>${RESOURCE#$BASE_URL}</d:href></c:calendar-multiget>" "$COLLECTION")"
+expect "PUT stale If-Match" 412 "$(dav -X PUT -H 'If-Match: "stale"' -H 'Content-Type: text/calendar' --data-binary "$(vtodo 'CalDAV smoke test' COMPLETED)" "$RESOURCE")"
+expect "PUT completed" 204 "$(dav -X PUT -H "If-Match: $ETAG" -H 'Content-Type: text/calendar' --data-binary "$(vtodo 'CalDAV smoke test' COMPLETED)" "$RESOURCE")"
+expect "GET completed task" 200 "$(dav "$RESOURCE")"
+grep -q 'STATUS:COMPLETED' /tmp/caldav-smoke.out || { echo "❌ task is not completed"; exit 1; }
+expect "DELETE task" 204 "$(dav -X DELETE -H "If-Match: $(etag)" "$RESOURCE")"
+expect "GET deleted task" 404 "$(dav "$RESOURCE")"
+
+echo "✅ CalDAV smoke test passed"
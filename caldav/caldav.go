@@ -0,0 +1,625 @@
+// Package caldav serves each user's tasks as one CalDAV calendar collection
+// of VTODO resources (RFC 4791). It speaks the WebDAV subset that task apps
+// sync with: OPTIONS, PROPFIND, REPORT (calendar-query and
+// calendar-multiget), GET, PUT and DELETE, with strong ETags and the
+// If-Match and If-None-Match preconditions. Storage is left to a Backend.
+//
+// The server is laid out under its prefix as
+//
+//	/                                  discovery: current-user-principal
+//	/principals/<user>/                the user's principal
+//	/calendars/<user>/                 calendar home
+//	/calendars/<user>/tasks/           the task collection
+//	/calendars/<user>/tasks/<name>.ics a task
+package caldav
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"todo-backend/ical"
+)
+
+// Errors a Backend returns
+var (
+	ErrNotFound             = errors.New("caldav: resource not found")
+	ErrUIDConflict          = errors.New("caldav: UID is used by another resource")
+	ErrUnsupportedComponent = errors.New("caldav: only VTODO resources are supported")
+	ErrInvalidData          = errors.New("caldav: invalid calendar data")
+)
+
+// Methods are the HTTP methods to route to Handler besides OPTIONS, which
+// needs no authentication
+var Methods = []string{"PROPFIND", "PROPPATCH", "REPORT", "GET", "HEAD", "PUT", "DELETE"}
+
+// maxBody limits request bodies
+const maxBody = 1 << 20
+
+// collectionName is the one collection in each calendar home
+const collectionName = "tasks"
+
+// Object is a calendar resource: a VCALENDAR holding one VTODO
+type Object struct {
+	Name string // within the collection, e.g. "task-12.ics"
+	ETag string // quoted, see ETag
+	Data []byte
+}
+
+// ETag returns a strong entity tag for data
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Backend keeps the objects of each user's collection
+type Backend interface {
+	Objects(userID int64) ([]*Object, error)
+	// Object returns ErrNotFound for a name the collection does not have
+	Object(userID int64, name string) (*Object, error)
+	// PutObject creates or replaces the object name with cal, a VCALENDAR
+	// whose VTODOs share one UID, and reports whether it was created
+	PutObject(userID int64, name string, cal *ical.Component) (bool, error)
+	DeleteObject(userID int64, name string) error
+	// WithLock runs fn holding a lock on the user's collection, on every
+	// instance of the server. PUT and DELETE check their preconditions and
+	// write inside it, so that no other write comes between the two.
+	WithLock(userID int64, fn func() error) error
+}
+
+// errPreconditionFailed ends a write whose If-Match or If-None-Match failed
+var errPreconditionFailed = errors.New("caldav: precondition failed")
+
+// User is the authenticated user of a request
+type User struct {
+	ID   int64
+	Name string
+}
+
+// Handler serves CalDAV requests
+type Handler struct {
+	Backend Backend
+	// Prefix is the path the server is mounted at, e.g. "/caldav"
+	Prefix string
+	// DisplayName is shown by clients for the task collection
+	DisplayName string
+}
+
+// resource kinds
+const (
+	kindRoot = iota
+	kindPrincipal
+	kindHome
+	kindCollection
+	kindObject
+)
+
+// target is the resource a request path names
+type target struct {
+	kind int
+	name string // object name
+}
+
+// resolve maps a request path onto a resource of user, or reports false
+func (h *Handler) resolve(path string, user User) (target, bool) {
+	rest := strings.Trim(strings.TrimPrefix(path, h.Prefix), "/")
+	var parts []string
+	if rest != "" {
+		parts = strings.Split(rest, "/")
+	}
+	if len(parts) >= 2 && parts[1] != user.Name {
+		return target{}, false
+	}
+	switch {
+	case len(parts) == 0:
+		return target{kind: kindRoot}, true
+	case len(parts) == 2 && parts[0] == "principals":
+		return target{kind: kindPrincipal}, true
+	case len(parts) == 2 && parts[0] == "calendars":
+		return target{kind: kindHome}, true
+	case len(parts) == 3 && parts[0] == "calendars" && parts[2] == collectionName:
+		return target{kind: kindCollection}, true
+	case len(parts) == 4 && parts[0] == "calendars" && parts[2] == collectionName && parts[3] != "":
+		return target{kind: kindObject, name: parts[3]}, true
+	}
+	return target{}, false
+}
+
+func (h *Handler) principalHref(user User) string {
+	return h.Prefix + "/principals/" + url.PathEscape(user.Name) + "/"
+}
+
+func (h *Handler) homeHref(user User) string {
+	return h.Prefix + "/calendars/" + url.PathEscape(user.Name) + "/"
+}
+
+func (h *Handler) collectionHref(user User) string {
+	return h.homeHref(user) + collectionName + "/"
+}
+
+func (h *Handler) objectHref(user User, name string) string {
+	return h.collectionHref(user) + url.PathEscape(name)
+}
+
+// Options answers OPTIONS, which clients send before authenticating
+func (h *Handler) Options(w http.ResponseWriter) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	w.Header().Set("Allow", "OPTIONS, "+strings.Join(Methods, ", "))
+	w.WriteHeader(http.StatusOK)
+}
+
+// ServeHTTP answers a request of user
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, user User) {
+	t, ok := h.resolve(r.URL.Path, user)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBody {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		h.Options(w)
+	case "PROPFIND":
+		h.propfind(w, r, user, t, body)
+	case "PROPPATCH":
+		h.proppatch(w, r, body)
+	case "REPORT":
+		h.report(w, user, t, body)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, user, t)
+	case http.MethodPut:
+		h.put(w, r, user, t, body)
+	case http.MethodDelete:
+		h.delete(w, r, user, t)
+	default:
+		w.Header().Set("Allow", "OPTIONS, "+strings.Join(Methods, ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// backendError writes the response for a Backend error
+func backendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, ErrUIDConflict):
+		writeError(w, http.StatusConflict, xml.Name{Space: nsCalDAV, Local: "no-uid-conflict"})
+	case errors.Is(err, ErrUnsupportedComponent):
+		writeError(w, http.StatusForbidden, xml.Name{Space: nsCalDAV, Local: "supported-calendar-component"})
+	case errors.Is(err, ErrInvalidData):
+		writeError(w, http.StatusForbidden, xml.Name{Space: nsCalDAV, Local: "valid-calendar-data"})
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// ctag changes whenever an object of the collection does
+func ctag(objects []*Object) string {
+	sorted := append([]*Object(nil), objects...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	var b strings.Builder
+	for _, o := range sorted {
+		b.WriteString(o.Name + " " + o.ETag + "\n")
+	}
+	return ETag([]byte(b.String()))
+}
+
+// properties returns every property of a resource. objects is only needed
+// for the collection, object for an object. calendar-data, which is only
+// returned when asked for, is left to the caller.
+func (h *Handler) properties(user User, t target, objects []*Object, object *Object) []property {
+	dav := func(local string) xml.Name { return xml.Name{Space: nsDAV, Local: local} }
+	cal := func(local string) xml.Name { return xml.Name{Space: nsCalDAV, Local: local} }
+	principal := hrefElement(h.principalHref(user))
+	privileges := "<d:privilege><d:read/></d:privilege><d:privilege><d:read-current-user-privilege-set/></d:privilege>"
+	if t.kind == kindCollection || t.kind == kindObject {
+		privileges += "<d:privilege><d:write/></d:privilege><d:privilege><d:write-content/></d:privilege>" +
+			"<d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>"
+	}
+	props := []property{
+		{dav("current-user-principal"), principal},
+		{dav("current-user-privilege-set"), privileges},
+	}
+
+	switch t.kind {
+	case kindRoot:
+		props = append(props, property{dav("resourcetype"), "<d:collection/>"})
+	case kindPrincipal:
+		props = append(props,
+			property{dav("resourcetype"), "<d:principal/>"},
+			property{dav("displayname"), escape(user.Name)},
+			property{dav("principal-URL"), principal},
+			property{cal("calendar-home-set"), hrefElement(h.homeHref(user))},
+		)
+	case kindHome:
+		props = append(props,
+			property{dav("resourcetype"), "<d:collection/>"},
+			property{dav("owner"), principal},
+		)
+	case kindCollection:
+		props = append(props,
+			property{dav("resourcetype"), "<d:collection/><c:calendar/>"},
+			property{dav("displayname"), escape(h.DisplayName)},
+			property{dav("owner"), principal},
+			property{cal("supported-calendar-component-set"), `<c:comp name="VTODO"/>`},
+			property{cal("supported-calendar-data"), `<c:calendar-data content-type="text/calendar" version="2.0"/>`},
+			property{dav("supported-report-set"),
+				"<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+					"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"},
+			property{xml.Name{Space: nsCS, Local: "getctag"}, escape(ctag(objects))},
+		)
+	case kindObject:
+		props = append(props,
+			property{dav("resourcetype"), ""},
+			property{dav("owner"), principal},
+			property{dav("getetag"), escape(object.ETag)},
+			property{dav("getcontenttype"), "text/calendar; charset=utf-8; component=vtodo"},
+			property{dav("getcontentlength"), strconv.Itoa(len(object.Data))},
+		)
+	}
+	return props
+}
+
+// respond builds the response for a resource from the properties asked for
+func (h *Handler) respond(href string, props []property, req propRequest, calendarData []byte) response {
+	r := response{href: href}
+	if req.allProp || req.propName {
+		for _, p := range props {
+			if req.propName {
+				p.inner = ""
+			}
+			r.found = append(r.found, p)
+		}
+		return r
+	}
+	for _, name := range req.names {
+		if name.Space == nsCalDAV && name.Local == "calendar-data" && calendarData != nil {
+			r.found = append(r.found, property{name, escape(string(calendarData))})
+			continue
+		}
+		found := false
+		for _, p := range props {
+			if p.name == name {
+				r.found = append(r.found, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.missing = append(r.missing, name)
+		}
+	}
+	return r
+}
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, user User, t target, body []byte) {
+	root, err := parseXML(body)
+	if err != nil || (root != nil && !root.is(nsDAV, "propfind")) {
+		http.Error(w, "Invalid PROPFIND body", http.StatusBadRequest)
+		return
+	}
+	req := parsePropRequest(root)
+	depth := r.Header.Get("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+
+	var responses []response
+	switch t.kind {
+	case kindObject:
+		object, err := h.Backend.Object(user.ID, t.name)
+		if err != nil {
+			backendError(w, err)
+			return
+		}
+		responses = append(responses, h.respond(h.objectHref(user, object.Name), h.properties(user, t, nil, object), req, nil))
+	case kindCollection:
+		objects, err := h.Backend.Objects(user.ID)
+		if err != nil {
+			backendError(w, err)
+			return
+		}
+		responses = append(responses, h.respond(h.collectionHref(user), h.properties(user, t, objects, nil), req, nil))
+		if depth != "0" {
+			for _, object := range objects {
+				objectTarget := target{kind: kindObject, name: object.Name}
+				responses = append(responses, h.respond(h.objectHref(user, object.Name), h.properties(user, objectTarget, nil, object), req, nil))
+			}
+		}
+	case kindHome:
+		responses = append(responses, h.respond(h.homeHref(user), h.properties(user, t, nil, nil), req, nil))
+		if depth != "0" {
+			objects, err := h.Backend.Objects(user.ID)
+			if err != nil {
+				backendError(w, err)
+				return
+			}
+			collection := target{kind: kindCollection}
+			responses = append(responses, h.respond(h.collectionHref(user), h.properties(user, collection, objects, nil), req, nil))
+		}
+	case kindPrincipal:
+		responses = append(responses, h.respond(h.principalHref(user), h.properties(user, t, nil, nil), req, nil))
+	default:
+		responses = append(responses, h.respond(h.Prefix+"/", h.properties(user, t, nil, nil), req, nil))
+	}
+	writeMultistatus(w, responses)
+}
+
+// proppatch refuses every change: collection properties such as the
+// display name are fixed
+func (h *Handler) proppatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	root, err := parseXML(body)
+	if err != nil || !root.is(nsDAV, "propertyupdate") {
+		http.Error(w, "Invalid PROPPATCH body", http.StatusBadRequest)
+		return
+	}
+	resp := response{href: r.URL.Path}
+	for _, change := range root.children {
+		for _, prop := range change.children {
+			if prop.is(nsDAV, "prop") {
+				for _, p := range prop.children {
+					resp.forbidden = append(resp.forbidden, p.name)
+				}
+			}
+		}
+	}
+	writeMultistatus(w, []response{resp})
+}
+
+func (h *Handler) report(w http.ResponseWriter, user User, t target, body []byte) {
+	root, err := parseXML(body)
+	if err != nil || root == nil {
+		http.Error(w, "Invalid REPORT body", http.StatusBadRequest)
+		return
+	}
+	if t.kind != kindCollection && t.kind != kindObject {
+		writeError(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "supported-report"})
+		return
+	}
+	req := parsePropRequest(root)
+
+	switch {
+	case root.is(nsCalDAV, "calendar-multiget"):
+		var responses []response
+		for _, c := range root.children {
+			if !c.is(nsDAV, "href") {
+				continue
+			}
+			href := strings.TrimSpace(c.text)
+			if parsed, err := url.Parse(href); err == nil {
+				href = parsed.Path
+			}
+			hrefTarget, ok := h.resolve(href, user)
+			if !ok || hrefTarget.kind != kindObject {
+				responses = append(responses, response{href: href, status: http.StatusNotFound})
+				continue
+			}
+			object, err := h.Backend.Object(user.ID, hrefTarget.name)
+			if errors.Is(err, ErrNotFound) {
+				responses = append(responses, response{href: href, status: http.StatusNotFound})
+				continue
+			}
+			if err != nil {
+				backendError(w, err)
+				return
+			}
+			responses = append(responses, h.respond(h.objectHref(user, object.Name), h.properties(user, hrefTarget, nil, object), req, object.Data))
+		}
+		writeMultistatus(w, responses)
+
+	case root.is(nsCalDAV, "calendar-query"):
+		var objects []*Object
+		if t.kind == kindObject {
+			object, err := h.Backend.Object(user.ID, t.name)
+			if err != nil {
+				backendError(w, err)
+				return
+			}
+			objects = []*Object{object}
+		} else if objects, err = h.Backend.Objects(user.ID); err != nil {
+			backendError(w, err)
+			return
+		}
+
+		var filter *compFilter
+		if f := root.child(nsCalDAV, "filter").child(nsCalDAV, "comp-filter"); f != nil {
+			parsed := parseCompFilter(f)
+			filter = &parsed
+		}
+		responses := []response{}
+		for _, object := range objects {
+			if filter != nil {
+				components, err := ical.Parse(object.Data)
+				if err != nil || len(components) == 0 || !matchCalendar(*filter, components[0]) {
+					continue
+				}
+			}
+			objectTarget := target{kind: kindObject, name: object.Name}
+			responses = append(responses, h.respond(h.objectHref(user, object.Name), h.properties(user, objectTarget, nil, object), req, object.Data))
+		}
+		writeMultistatus(w, responses)
+
+	default:
+		writeError(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "supported-report"})
+	}
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, user User, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	object, err := h.Backend.Object(user.ID, t.name)
+	if err != nil {
+		backendError(w, err)
+		return
+	}
+	w.Header().Set("ETag", object.ETag)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, object.ETag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(object.Data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(object.Data)
+	}
+}
+
+// etagMatches checks an If-Match or If-None-Match header against etag.
+// If-Match needs the strong comparison of RFC 7232, under which a weak tag
+// never matches; If-None-Match uses the weak one.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditions checks If-Match and If-None-Match against the current
+// object, nil when there is none
+func preconditions(r *http.Request, current *Object) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if current == nil || !etagMatches(match, current.ETag, false) {
+			return false
+		}
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && current != nil && etagMatches(match, current.ETag, true) {
+		return false
+	}
+	return true
+}
+
+// current returns the object name, nil when there is none
+func (h *Handler) current(userID int64, name string) (*Object, error) {
+	object, err := h.Backend.Object(userID, name)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return object, err
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, user User, t target, body []byte) {
+	if t.kind != kindObject {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cal, err := parseObject(body)
+	if err != nil {
+		backendError(w, err)
+		return
+	}
+	var created bool
+	err = h.Backend.WithLock(user.ID, func() error {
+		current, err := h.current(user.ID, t.name)
+		if err != nil {
+			return err
+		}
+		if !preconditions(r, current) {
+			return errPreconditionFailed
+		}
+		created, err = h.Backend.PutObject(user.ID, t.name, cal)
+		return err
+	})
+	if errors.Is(err, errPreconditionFailed) {
+		http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		backendError(w, err)
+		return
+	}
+	// No ETag: the stored task is not byte for byte what was sent, so
+	// clients fetch it again
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseObject checks that a PUT body is one VCALENDAR holding VTODOs that
+// share a UID, the master and any overridden occurrences
+func parseObject(body []byte) (*ical.Component, error) {
+	components, err := ical.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidData, err)
+	}
+	if len(components) != 1 || components[0].Name != "VCALENDAR" {
+		return nil, fmt.Errorf("%w: expected one VCALENDAR", ErrInvalidData)
+	}
+	cal := components[0]
+	uid := ""
+	todos := 0
+	for _, c := range cal.Components {
+		switch c.Name {
+		case "VTIMEZONE":
+		case "VTODO":
+			todos++
+			if uid == "" {
+				uid = c.GetText("UID")
+			}
+			if c.GetText("UID") == "" || c.GetText("UID") != uid {
+				return nil, fmt.Errorf("%w: every VTODO needs the same UID", ErrInvalidData)
+			}
+		default:
+			return nil, ErrUnsupportedComponent
+		}
+	}
+	if todos == 0 {
+		return nil, ErrUnsupportedComponent
+	}
+	return cal, nil
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, user User, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	err := h.Backend.WithLock(user.ID, func() error {
+		current, err := h.current(user.ID, t.name)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return ErrNotFound
+		}
+		if !preconditions(r, current) {
+			return errPreconditionFailed
+		}
+		return h.Backend.DeleteObject(user.ID, t.name)
+	})
+	if errors.Is(err, errPreconditionFailed) {
+		http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		backendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
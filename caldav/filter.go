@@ -0,0 +1,211 @@
+package caldav
+
+import (
+	"strings"
+	"time"
+	"todo-backend/ical"
+)
+
+// compFilter is a CALDAV:comp-filter of a calendar-query
+type compFilter struct {
+	name         string
+	isNotDefined bool
+	timeRange    *timeRange
+	props        []propFilter
+	comps        []compFilter
+}
+
+// propFilter is a CALDAV:prop-filter
+type propFilter struct {
+	name         string
+	isNotDefined bool
+	timeRange    *timeRange
+	textMatch    *textMatch
+}
+
+// textMatch is a CALDAV:text-match; the default collation, i;ascii-casemap,
+// ignores case and i;octet does not
+type textMatch struct {
+	text       string
+	negate     bool
+	ignoreCase bool
+}
+
+// timeRange is a CALDAV:time-range; either end may be open
+type timeRange struct {
+	start, end *time.Time
+}
+
+func parseCompFilter(n *node) compFilter {
+	f := compFilter{name: strings.ToUpper(n.attr("name"))}
+	for _, c := range n.children {
+		switch {
+		case c.is(nsCalDAV, "is-not-defined"):
+			f.isNotDefined = true
+		case c.is(nsCalDAV, "time-range"):
+			f.timeRange = parseTimeRange(c)
+		case c.is(nsCalDAV, "prop-filter"):
+			f.props = append(f.props, parsePropFilter(c))
+		case c.is(nsCalDAV, "comp-filter"):
+			f.comps = append(f.comps, parseCompFilter(c))
+		}
+	}
+	return f
+}
+
+func parsePropFilter(n *node) propFilter {
+	f := propFilter{name: strings.ToUpper(n.attr("name"))}
+	for _, c := range n.children {
+		switch {
+		case c.is(nsCalDAV, "is-not-defined"):
+			f.isNotDefined = true
+		case c.is(nsCalDAV, "time-range"):
+			f.timeRange = parseTimeRange(c)
+		case c.is(nsCalDAV, "text-match"):
+			f.textMatch = &textMatch{
+				text:       c.text,
+				negate:     c.attr("negate-condition") == "yes",
+				ignoreCase: c.attr("collation") != "i;octet",
+			}
+		}
+	}
+	return f
+}
+
+func parseTimeRange(n *node) *timeRange {
+	tr := &timeRange{}
+	if t, err := time.Parse("20060102T150405Z", n.attr("start")); err == nil {
+		tr.start = &t
+	}
+	if t, err := time.Parse("20060102T150405Z", n.attr("end")); err == nil {
+		tr.end = &t
+	}
+	return tr
+}
+
+// overlaps reports whether the stretch from start to end meets the range.
+// An instant (start equal to end) counts when it lies inside it.
+func (tr *timeRange) overlaps(start, end time.Time) bool {
+	if tr.start != nil && !end.After(*tr.start) && !(start.Equal(end) && start.Equal(*tr.start)) {
+		return false
+	}
+	if tr.end != nil && !start.Before(*tr.end) {
+		return false
+	}
+	return true
+}
+
+// matchCalendar reports whether a VCALENDAR passes the filter, whose top
+// comp-filter must name VCALENDAR
+func matchCalendar(f compFilter, cal *ical.Component) bool {
+	if f.name != cal.Name {
+		return false
+	}
+	return matchComp(f, cal, ical.NewTimezones(cal, nil))
+}
+
+// matchComp checks everything below f against a component that has its name
+func matchComp(f compFilter, c *ical.Component, tz *ical.Timezones) bool {
+	if f.timeRange != nil && !componentInRange(c, f.timeRange, tz) {
+		return false
+	}
+	for _, pf := range f.props {
+		if !matchProp(pf, c, tz) {
+			return false
+		}
+	}
+	for _, cf := range f.comps {
+		children := c.Children(cf.name)
+		if cf.isNotDefined {
+			if len(children) > 0 {
+				return false
+			}
+			continue
+		}
+		matched := false
+		for _, child := range children {
+			if matchComp(cf, child, tz) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchProp(f propFilter, c *ical.Component, tz *ical.Timezones) bool {
+	props := c.All(f.name)
+	if f.isNotDefined {
+		return len(props) == 0
+	}
+	if len(props) == 0 {
+		return false
+	}
+	for _, p := range props {
+		if f.timeRange != nil {
+			t, _, err := tz.Time(&p)
+			if err != nil || !f.timeRange.overlaps(t, t) {
+				continue
+			}
+		}
+		if f.textMatch != nil {
+			value, text := ical.Unescape(p.Value), f.textMatch.text
+			if f.textMatch.ignoreCase {
+				value, text = strings.ToLower(value), strings.ToLower(text)
+			}
+			if strings.Contains(value, text) == f.textMatch.negate {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// componentInRange applies a time-range to a VTODO or VEVENT roughly as
+// RFC 4791 section 9.9 does; components without times always match
+func componentInRange(c *ical.Component, tr *timeRange, tz *ical.Timezones) bool {
+	get := func(name string) (time.Time, bool) {
+		p := c.Get(name)
+		if p == nil {
+			return time.Time{}, false
+		}
+		t, _, err := tz.Time(p)
+		return t, err == nil
+	}
+	start, hasStart := get("DTSTART")
+	if c.Name == "VEVENT" {
+		if !hasStart {
+			return true
+		}
+		end, hasEnd := get("DTEND")
+		if !hasEnd {
+			end = start
+		}
+		return tr.overlaps(start, end)
+	}
+
+	due, hasDue := get("DUE")
+	switch {
+	case hasStart && hasDue:
+		return tr.overlaps(start, due)
+	case hasStart:
+		return tr.overlaps(start, start)
+	case hasDue:
+		return tr.overlaps(due, due)
+	}
+	completed, hasCompleted := get("COMPLETED")
+	created, hasCreated := get("CREATED")
+	switch {
+	case hasCompleted && hasCreated:
+		return tr.overlaps(created, completed)
+	case hasCompleted:
+		return tr.overlaps(completed, completed)
+	case hasCreated:
+		return tr.end == nil || created.Before(*tr.end)
+	}
+	return true
+}
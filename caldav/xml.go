@@ -0,0 +1,224 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// XML namespaces
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+// prefixes are declared once on the multistatus element
+var prefixes = map[string]string{
+	nsDAV:    "d",
+	nsCalDAV: "c",
+	nsCS:     "cs",
+}
+
+// node is an element of a request body
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*node
+	text     string
+}
+
+// parseXML reads a request body into a tree of elements. An empty body
+// gives a nil node.
+func parseXML(body []byte) (*node, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var root *node
+	var stack []*node
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no root element")
+	}
+	return root, nil
+}
+
+func (n *node) is(space, local string) bool {
+	return n != nil && n.name.Space == space && n.name.Local == local
+}
+
+// child returns the first child element with the given name, or nil
+func (n *node) child(space, local string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.is(space, local) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) attr(local string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// propRequest is what a PROPFIND or REPORT asks for
+type propRequest struct {
+	allProp  bool
+	propName bool
+	names    []xml.Name
+}
+
+// parsePropRequest reads the allprop, propname or prop element under n. No
+// such element means allprop, as an empty PROPFIND body does.
+func parsePropRequest(n *node) propRequest {
+	if prop := n.child(nsDAV, "prop"); prop != nil {
+		req := propRequest{}
+		for _, c := range prop.children {
+			req.names = append(req.names, c.name)
+		}
+		return req
+	}
+	if n.child(nsDAV, "propname") != nil {
+		return propRequest{propName: true}
+	}
+	return propRequest{allProp: true}
+}
+
+// property is a property value as inner XML, using the prefixes above
+type property struct {
+	name  xml.Name
+	inner string
+}
+
+// response is one resource in a multistatus. A response with status set
+// has no properties, e.g. an unknown href in a multiget.
+type response struct {
+	href      string
+	status    int
+	found     []property
+	missing   []xml.Name
+	forbidden []xml.Name
+}
+
+// element writes an element named name around inner XML
+func element(name xml.Name, inner string) string {
+	var open, close string
+	if prefix, ok := prefixes[name.Space]; ok {
+		open = prefix + ":" + name.Local
+		close = open
+	} else {
+		var ns bytes.Buffer
+		xml.EscapeText(&ns, []byte(name.Space))
+		open = fmt.Sprintf(`x:%s xmlns:x="%s"`, name.Local, ns.String())
+		close = "x:" + name.Local
+		if name.Space == "" {
+			open, close = name.Local+` xmlns=""`, name.Local
+		}
+	}
+	if inner == "" {
+		return "<" + open + "/>"
+	}
+	return "<" + open + ">" + inner + "</" + close + ">"
+}
+
+// escape returns text escaped for XML character data
+func escape(text string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+func hrefElement(href string) string {
+	return "<d:href>" + escape(href) + "</d:href>"
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("<d:status>HTTP/1.1 %d %s</d:status>", code, http.StatusText(code))
+}
+
+// writeMultistatus writes a 207 Multi-Status response
+func writeMultistatus(w http.ResponseWriter, responses []response) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCS + `">`)
+	for _, r := range responses {
+		b.WriteString("<d:response>")
+		b.WriteString(hrefElement(r.href))
+		if r.status != 0 {
+			b.WriteString(statusLine(r.status))
+		}
+		if len(r.found) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, p := range r.found {
+				b.WriteString(element(p.name, p.inner))
+			}
+			b.WriteString("</d:prop>" + statusLine(http.StatusOK) + "</d:propstat>")
+		}
+		for _, group := range []struct {
+			names []xml.Name
+			code  int
+		}{{r.missing, http.StatusNotFound}, {r.forbidden, http.StatusForbidden}} {
+			if len(group.names) == 0 {
+				continue
+			}
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range group.names {
+				b.WriteString(element(name, ""))
+			}
+			b.WriteString("</d:prop>" + statusLine(group.code) + "</d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>\n")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, b.String())
+}
+
+// writeError writes a WebDAV error body naming the precondition that
+// failed, e.g. CALDAV:no-uid-conflict
+func writeError(w http.ResponseWriter, code int, condition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+
+		`<d:error xmlns:d="DAV:" xmlns:c="`+nsCalDAV+`">`+element(condition, "")+"</d:error>\n")
+}
@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/notify"
+	"todo-backend/storage"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -18,6 +23,41 @@ type Config struct {
 	SupabaseKey string
 	JWTSecret   string
 	Port        string
+
+	// SubtaskPolicy decides what happens to subtasks when their parent is
+	// completed, deleted or duplicated
+	SubtaskPolicy models.SubtaskPolicy
+
+	// BlockedCompletion is "warn" or "refuse" and decides whether a task
+	// whose blockers are still open can be completed
+	BlockedCompletion string
+
+	// TrashRetentionDays is how long deleted items stay in the trash before
+	// they are purged; 0 disables purging
+	TrashRetentionDays int
+
+	// QuickAddLocales is the path of a JSON file with extra quick-add
+	// locales; English is always available
+	QuickAddLocales string
+
+	// AttachmentStorage says where attachment contents are kept: a local
+	// directory or an S3-compatible bucket
+	AttachmentStorage storage.Config
+
+	// AttachmentLimits bound the size of one upload, each user's total and
+	// the accepted content types
+	AttachmentLimits models.AttachmentLimits
+
+	// Matrix decides which open tasks count as urgent and important in the
+	// Eisenhower matrix
+	Matrix models.MatrixSettings
+
+	// ReminderPollSeconds is how often due reminders are looked for
+	ReminderPollSeconds int
+
+	// Notifiers configures the webhook and email channels for reminders;
+	// the in-app inbox needs no settings
+	Notifiers notify.Config
 }
 
 func LoadConfig() *Config {
@@ -32,6 +72,42 @@ func LoadConfig() *Config {
 		SupabaseKey: getEnv("SUPABASE_ANON_KEY", ""),
 		JWTSecret:   getEnv("JWT_SECRET", "default-secret"),
 		Port:        getEnv("PORT", "8080"),
+		SubtaskPolicy: models.SubtaskPolicy{
+			OnComplete:  getEnv("SUBTASK_ON_COMPLETE", models.DefaultSubtaskPolicy.OnComplete),
+			OnDelete:    getEnv("SUBTASK_ON_DELETE", models.DefaultSubtaskPolicy.OnDelete),
+			OnDuplicate: getEnv("SUBTASK_ON_DUPLICATE", models.DefaultSubtaskPolicy.OnDuplicate),
+		},
+		BlockedCompletion:  getEnv("BLOCKED_COMPLETION", models.BlockedCompletionWarn),
+		TrashRetentionDays: getEnvInt("TRASH_RETENTION_DAYS", 30),
+		QuickAddLocales:    getEnv("QUICKADD_LOCALES", ""),
+		AttachmentStorage: storage.Config{
+			Backend:   getEnv("ATTACHMENT_STORAGE", storage.BackendLocal),
+			Dir:       getEnv("ATTACHMENT_DIR", "./data/attachments"),
+			Endpoint:  getEnv("S3_ENDPOINT", ""),
+			Region:    getEnv("S3_REGION", "us-east-1"),
+			Bucket:    getEnv("S3_BUCKET", ""),
+			AccessKey: getEnv("S3_ACCESS_KEY", ""),
+			SecretKey: getEnv("S3_SECRET_KEY", ""),
+		},
+		AttachmentLimits: models.AttachmentLimits{
+			MaxBytes:     int64(getEnvInt("ATTACHMENT_MAX_MB", 10)) << 20,
+			QuotaBytes:   int64(getEnvInt("ATTACHMENT_QUOTA_MB", 100)) << 20,
+			AllowedTypes: getEnvList("ATTACHMENT_ALLOWED_TYPES"),
+		},
+		Matrix: models.MatrixSettings{
+			UrgentDays:        getEnvInt("MATRIX_URGENT_DAYS", 2),
+			ImportantPriority: int16(getEnvInt("MATRIX_IMPORTANT_PRIORITY", 3)),
+		},
+		ReminderPollSeconds: getEnvInt("REMINDER_POLL_SECONDS", 30),
+		Notifiers: notify.Config{
+			WebhookURL:    getEnv("REMINDER_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("REMINDER_WEBHOOK_SECRET", ""),
+			SMTPHost:      getEnv("SMTP_HOST", ""),
+			SMTPPort:      getEnvInt("SMTP_PORT", 587),
+			SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+			SMTPFrom:      getEnv("SMTP_FROM", ""),
+		},
 	}
 }
 
@@ -42,6 +118,25 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList reads a comma-separated list, dropping blank entries
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func InitDatabase(databaseURL string) {
 	var err error
 	DB, err = sql.Open("postgres", databaseURL)
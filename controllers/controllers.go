@@ -2,13 +2,18 @@ package controllers
 
 import (
 	"database/sql"
+	"errors"
+	"mime"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"todo-backend/caldav"
+	"todo-backend/filter"
 	"todo-backend/middleware"
 	"todo-backend/models"
+	"todo-backend/pagination"
 	"todo-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -171,12 +176,14 @@ func (ctrl *AuthController) CheckUsername(c *gin.Context) {
 
 // Task Controller
 type TaskController struct {
-	taskService services.TaskService
+	taskService    services.TaskService
+	plannerService services.PlannerService
 }
 
-func NewTaskController(taskService services.TaskService) *TaskController {
+func NewTaskController(taskService services.TaskService, plannerService services.PlannerService) *TaskController {
 	return &TaskController{
-		taskService: taskService,
+		taskService:    taskService,
+		plannerService: plannerService,
 	}
 }
 
@@ -198,6 +205,11 @@ func (ctrl *TaskController) CreateTask(c *gin.Context) {
 
 	task, err := ctrl.taskService.CreateTask(userID, &req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidRecurrence) || errors.Is(err, services.ErrInvalidParent) || errors.Is(err, services.ErrInvalidTag) ||
+			errors.Is(err, services.ErrInvalidProject) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create task",
 			"details": err.Error(),
@@ -215,6 +227,13 @@ func (ctrl *TaskController) GetTasks(c *gin.Context) {
 		return
 	}
 
+	// Without ?page= the list is keyset paginated: ?sort=due_date,-priority
+	// orders it and ?cursor= continues from a next_cursor or prev_cursor
+	if _, offsetMode := c.GetQuery("page"); !offsetMode {
+		ctrl.getTasksByCursor(c, userID)
+		return
+	}
+
 	// Pagination params
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("pageSize", "100")
@@ -229,8 +248,33 @@ func (ctrl *TaskController) GetTasks(c *gin.Context) {
 
 	// Pagination refactor: category and priorityStr are unused
 
-	// Always use paginated fallback for all queries
-	tasks, total, svcErr := ctrl.taskService.GetUserTasks(userID, page, pageSize)
+	// ?filter=... takes a filter expression such as priority>=2 AND category:Work;
+	// ?tags=a,b filters by tags, with tag_match=all requiring every tag instead of any
+	var tasks []*models.Task
+	var total int64
+	var svcErr error
+	if filterParam := c.Query("filter"); filterParam != "" {
+		tasks, total, svcErr = ctrl.taskService.GetTasksByFilter(userID, filterParam, page, pageSize)
+		var filterErr *filter.Error
+		if errors.As(svcErr, &filterErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    filterErr.Error(),
+				"position": filterErr.Pos,
+				"filter":   filterParam,
+			})
+			return
+		}
+	} else if tagsParam := c.Query("tags"); tagsParam != "" {
+		matchAll := c.DefaultQuery("tag_match", "any") == "all"
+		tasks, total, svcErr = ctrl.taskService.GetTasksByTags(userID, strings.Split(tagsParam, ","), matchAll, page, pageSize)
+		if errors.Is(svcErr, services.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": svcErr.Error()})
+			return
+		}
+	} else {
+		// Always use paginated fallback for all queries
+		tasks, total, svcErr = ctrl.taskService.GetUserTasks(userID, page, pageSize)
+	}
 	if svcErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks"})
 		return
@@ -242,6 +286,74 @@ func (ctrl *TaskController) GetTasks(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+func (ctrl *TaskController) getTasksByCursor(c *gin.Context, userID int64) {
+	var tags []string
+	if tagsParam := c.Query("tags"); tagsParam != "" {
+		tags = strings.Split(tagsParam, ",")
+	}
+	matchAll := c.DefaultQuery("tag_match", "any") == "all"
+	filterParam := c.Query("filter")
+
+	tasks, page, err := ctrl.taskService.GetTasksByCursor(userID, filterParam, tags, matchAll, cursorRequest(c))
+	if err != nil {
+		var filterErr *filter.Error
+		switch {
+		case errors.As(err, &filterErr):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    filterErr.Error(),
+				"position": filterErr.Pos,
+				"filter":   filterParam,
+			})
+		case errors.Is(err, pagination.ErrInvalidSort) || errors.Is(err, pagination.ErrInvalidCursor) || errors.Is(err, services.ErrInvalidTag):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TaskResponse{
+		Tasks:      tasks,
+		Total:      int(page.Total),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		Links:      pageLinks(c, page),
+	})
+}
+
+// cursorRequest reads ?sort=, ?cursor= and ?limit= (or the legacy ?pageSize=)
+func cursorRequest(c *gin.Context) *models.CursorRequest {
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		limitStr = c.Query("pageSize")
+	}
+	limit, _ := strconv.Atoi(limitStr)
+	return &models.CursorRequest{
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+		Limit:  limit,
+	}
+}
+
+// pageLinks builds the URLs of the neighbouring pages from the current
+// request, keeping every parameter except the cursor
+func pageLinks(c *gin.Context, page *models.CursorPage) *models.PageLinks {
+	if page.NextCursor == "" && page.PrevCursor == "" {
+		return nil
+	}
+	link := func(cursor string) string {
+		if cursor == "" {
+			return ""
+		}
+		query := c.Request.URL.Query()
+		query.Set("cursor", cursor)
+		// The cursor carries the sort order
+		query.Del("sort")
+		return c.Request.URL.Path + "?" + query.Encode()
+	}
+	return &models.PageLinks{Next: link(page.NextCursor), Prev: link(page.PrevCursor)}
+}
+
 func (ctrl *TaskController) GetTask(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -256,9 +368,9 @@ func (ctrl *TaskController) GetTask(c *gin.Context) {
 		return
 	}
 
-	task, err := ctrl.taskService.GetTaskByID(taskID, userID)
+	task, err := ctrl.taskService.GetTaskTree(taskID, userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 			return
 		}
@@ -295,6 +407,14 @@ func (ctrl *TaskController) UpdateTask(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 			return
 		}
+		if errors.Is(err, services.ErrInvalidRecurrence) || errors.Is(err, services.ErrInvalidParent) || errors.Is(err, services.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStatusTransition) || errors.Is(err, services.ErrOpenSubtasks) || errors.Is(err, services.ErrTaskBlocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
 		return
 	}
@@ -351,12 +471,16 @@ func (ctrl *TaskController) MarkTaskCompleted(c *gin.Context) {
 		return
 	}
 
-	err = ctrl.taskService.MarkTaskCompleted(taskID, userID, req.IsCompleted)
+	result, err := ctrl.taskService.MarkTaskCompleted(taskID, userID, req.IsCompleted)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 			return
 		}
+		if errors.Is(err, services.ErrInvalidStatusTransition) || errors.Is(err, services.ErrOpenSubtasks) || errors.Is(err, services.ErrTaskBlocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
 		return
 	}
@@ -367,8 +491,11 @@ func (ctrl *TaskController) MarkTaskCompleted(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":      "Task marked as " + status,
-		"is_completed": req.IsCompleted,
+		"message":         "Task marked as " + status,
+		"is_completed":    req.IsCompleted,
+		"task":            result.Task,
+		"next_occurrence": result.NextOccurrence,
+		"warnings":        result.Warnings,
 	})
 }
 
@@ -436,7 +563,12 @@ func (ctrl *TaskController) GetTasksByStatus(c *gin.Context) {
 		return
 	}
 
-	status := c.Param("status")
+	status := models.TaskStatus(c.Param("status"))
+	if !status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use pending, in_progress, completed or cancelled"})
+		return
+	}
+
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("pageSize", "100")
 	page, err := strconv.Atoi(pageStr)
@@ -448,31 +580,23 @@ func (ctrl *TaskController) GetTasksByStatus(c *gin.Context) {
 		pageSize = 100
 	}
 
-	tasks, total, svcErr := ctrl.taskService.GetUserTasks(userID, page, pageSize)
+	tasks, total, svcErr := ctrl.taskService.GetTasksByStatus(userID, status, page, pageSize)
 	if svcErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks"})
 		return
 	}
-
-	var filteredTasks []*models.Task
-	for _, task := range tasks {
-		switch status {
-		case "completed":
-			if task.IsCompleted {
-				filteredTasks = append(filteredTasks, task)
-			}
-		case "pending":
-			if !task.IsCompleted {
-				filteredTasks = append(filteredTasks, task)
-			}
-		}
+	if tasks == nil {
+		tasks = []*models.Task{}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"tasks":  filteredTasks,
-		"count":  len(filteredTasks),
-		"total":  int(total),
-		"status": status,
+		"tasks":      tasks,
+		"count":      len(tasks),
+		"total":      int(total),
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (int(total) + pageSize - 1) / pageSize,
+		"status":     status,
 	})
 }
 
@@ -656,86 +780,86 @@ func (ctrl *TaskController) SearchTasks(c *gin.Context) {
 	category := c.Query("category")
 	priority := c.Query("priority")
 	status := c.Query("status")
+	dueFrom := c.Query("due_from")
+	dueTo := c.Query("due_to")
 
-	// Get all tasks first
-	tasks, _, svcErr := ctrl.taskService.GetUserTasks(userID, 1, 10000) // Get all for filtering
-	if svcErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search tasks"})
-		return
+	req := &models.SearchTasksRequest{
+		Query:    query,
+		Page:     page,
+		PageSize: pageSize,
 	}
-
-	var filteredTasks []*models.Task
-	for _, task := range tasks {
-		// Text search in task name and description
-		if query != "" {
-			if !strings.Contains(strings.ToLower(task.TaskName), strings.ToLower(query)) {
-				if task.Description == nil || !strings.Contains(strings.ToLower(*task.Description), strings.ToLower(query)) {
-					continue
-				}
-			}
+	if category != "" {
+		req.Category = &category
+	}
+	if priority != "" {
+		p, err := strconv.ParseInt(priority, 10, 16)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority"})
+			return
 		}
-
-		// Category filter
-		if category != "" && (task.Category == nil || *task.Category != category) {
-			continue
+		p16 := int16(p)
+		req.Priority = &p16
+	}
+	if status != "" {
+		req.Status = &status
+	}
+	// due_from and due_to accept YYYY-MM-DD or RFC3339; a plain due_to date includes that whole day
+	if dueFrom != "" {
+		from, _, err := parseSearchDate(dueFrom)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_from. Use YYYY-MM-DD or RFC3339"})
+			return
 		}
-
-		// Priority filter
-		if priority != "" {
-			if p, err := strconv.ParseInt(priority, 10, 16); err == nil {
-				if task.Priority != int16(p) {
-					continue
-				}
-			}
+		req.DueDateFrom = &from
+	}
+	if dueTo != "" {
+		to, dateOnly, err := parseSearchDate(dueTo)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_to. Use YYYY-MM-DD or RFC3339"})
+			return
 		}
-
-		// Status filter
-		if status != "" {
-			switch status {
-			case "completed":
-				if !task.IsCompleted {
-					continue
-				}
-			case "pending":
-				if task.IsCompleted {
-					continue
-				}
-			}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
 		}
-
-		filteredTasks = append(filteredTasks, task)
+		req.DueDateTo = &to
 	}
 
-	// Apply pagination to filtered results
-	totalFiltered := len(filteredTasks)
-	totalPages := (totalFiltered + pageSize - 1) / pageSize
-	startIndex := (page - 1) * pageSize
-	endIndex := startIndex + pageSize
-
-	if startIndex >= totalFiltered {
-		filteredTasks = []*models.Task{}
-	} else {
-		if endIndex > totalFiltered {
-			endIndex = totalFiltered
+	results, total, err := ctrl.taskService.SearchTasks(userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		filteredTasks = filteredTasks[startIndex:endIndex]
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search tasks"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"tasks":      filteredTasks,
-		"total":      totalFiltered,
-		"page":       page,
-		"pageSize":   pageSize,
-		"totalPages": totalPages,
+		"tasks":      results,
+		"total":      total,
+		"page":       req.Page,
+		"pageSize":   req.PageSize,
+		"totalPages": (int(total) + req.PageSize - 1) / req.PageSize,
 		"query":      query,
 		"filters": gin.H{
 			"category": category,
 			"priority": priority,
 			"status":   status,
+			"due_from": dueFrom,
+			"due_to":   dueTo,
 		},
 	})
 }
 
+// parseSearchDate parses a YYYY-MM-DD or RFC3339 value and reports whether it was a plain date
+func parseSearchDate(value string) (time.Time, bool, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
 func (ctrl *TaskController) UpdateTaskStatus(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -755,22 +879,31 @@ func (ctrl *TaskController) UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
-	// For now, map to existing completion logic
-	isCompleted := req.Status == models.TaskStatusCompleted
-	err = ctrl.taskService.MarkTaskCompleted(taskID, userID, isCompleted)
+	result, err := ctrl.taskService.UpdateTaskStatus(taskID, userID, req.Status)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStatusTransition) || errors.Is(err, services.ErrOpenSubtasks) || errors.Is(err, services.ErrTaskBlocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Task status updated successfully",
-		"task_id": taskID,
-		"status":  req.Status,
+		"message":         "Task status updated successfully",
+		"task_id":         taskID,
+		"status":          result.Task.Status,
+		"task":            result.Task,
+		"next_occurrence": result.NextOccurrence,
+		"warnings":        result.Warnings,
 	})
 }
 
-func (ctrl *TaskController) DuplicateTask(c *gin.Context) {
+func (ctrl *TaskController) GetTaskStatusHistory(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -783,30 +916,53 @@ func (ctrl *TaskController) DuplicateTask(c *gin.Context) {
 		return
 	}
 
-	// Get original task
-	originalTask, err := ctrl.taskService.GetTaskByID(taskID, userID)
+	history, err := ctrl.taskService.GetTaskStatusHistory(taskID, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get status history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id": taskID,
+		"history": history,
+		"count":   len(history),
+	})
+}
+
+func (ctrl *TaskController) DuplicateTask(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Create duplicate
-	duplicateReq := &models.CreateTaskRequest{
-		TaskName:           originalTask.TaskName + " (Copy)",
-		Description:        originalTask.Description,
-		Category:           originalTask.Category,
-		Priority:           originalTask.Priority,
-		DueDate:            originalTask.DueDate,
-		IsRecurring:        originalTask.IsRecurring,
-		RecurringFrequency: originalTask.RecurringFrequency,
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
 	}
 
-	newTask, err := ctrl.taskService.CreateTask(userID, duplicateReq)
+	// Duplicate the task together with its subtasks, as the subtask policy allows
+	duplicate, err := ctrl.taskService.DuplicateTask(taskID, userID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to duplicate task"})
 		return
 	}
 
+	newTask, err := ctrl.taskService.GetTaskTree(duplicate.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get duplicated task"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message":          "Task duplicated successfully",
 		"original_task_id": taskID,
@@ -835,17 +991,36 @@ func (ctrl *TaskController) GetTasksByDate(c *gin.Context) {
 		return
 	}
 
+	// Time blocks are placed in ?timezone=, UTC by default
+	blocks, err := ctrl.plannerService.GetTimeBlocks(userID, dateStr, c.Query("timezone"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPlan) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get time blocks"})
+		return
+	}
+	planned := make(map[int64]bool, len(blocks))
+	for _, block := range blocks {
+		if block.TaskID != nil {
+			planned[*block.TaskID] = true
+		}
+	}
+
+	// Tasks due that day, and tasks planned into it
 	var dayTasks []*models.Task
 	for _, task := range tasks {
-		if task.DueDate != nil && task.DueDate.Format("2006-01-02") == dateStr {
+		if (task.DueDate != nil && task.DueDate.Format("2006-01-02") == dateStr) || planned[task.ID] {
 			dayTasks = append(dayTasks, task)
 		}
 	}
 
 	calendarView := &models.CalendarView{
-		Date:  dateStr,
-		Tasks: dayTasks,
-		Count: len(dayTasks),
+		Date:   dateStr,
+		Tasks:  dayTasks,
+		Count:  len(dayTasks),
+		Blocks: blocks,
 	}
 
 	c.JSON(http.StatusOK, calendarView)
@@ -977,327 +1152,2844 @@ func (ctrl *TaskController) RescheduleTask(c *gin.Context) {
 		return
 	}
 
-	// Update task with new due date
-	customTime := models.CustomTime{Time: req.NewDueDate.Time}
-	updateReq := &models.UpdateTaskRequest{
-		DueDate: &customTime,
-	}
-
-	updatedTask, err := ctrl.taskService.UpdateTask(taskID, userID, updateReq)
+	updatedTask, reschedule, err := ctrl.taskService.RescheduleTask(taskID, userID, &req)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule task"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Task rescheduled successfully",
-		"task":    updatedTask,
-		"reason":  req.Reason,
+		"message":    "Task rescheduled successfully",
+		"task":       updatedTask,
+		"reason":     reschedule.Reason,
+		"reschedule": reschedule,
 	})
 }
 
-// Dashboard Methods
-func (ctrl *TaskController) GetDashboardSummary(c *gin.Context) {
+func (ctrl *TaskController) GetTaskReschedules(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	tasks, _, err := ctrl.taskService.GetUserTasks(userID, 1, 10000)
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard summary"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	now := time.Now()
-	today := now.Format("2006-01-02")
-
-	var summary models.DashboardSummary
-	summary.TotalTasks = int64(len(tasks))
-
-	for _, task := range tasks {
-		if task.IsCompleted {
-			if task.CreatedAt.Format("2006-01-02") == today {
-				summary.CompletedToday++
-			}
-		} else {
-			if task.DueDate != nil {
-				if task.DueDate.Format("2006-01-02") == today {
-					summary.DueToday++
-				}
-				if task.DueDate.Before(now) {
-					summary.Overdue++
-				}
-			}
+	reschedules, err := ctrl.taskService.GetTaskReschedules(taskID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reschedules"})
+		return
 	}
 
-	if summary.TotalTasks > 0 {
-		completedTasks := summary.TotalTasks - (summary.DueToday + summary.Overdue)
-		summary.CompletionRate = float64(completedTasks) / float64(summary.TotalTasks) * 100
-	}
-
-	summary.ProductivityTrend = "stable" // This would be calculated based on historical data
-
-	c.JSON(http.StatusOK, summary)
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":     taskID,
+		"reschedules": reschedules,
+	})
 }
 
-func (ctrl *TaskController) GetUpcomingTasks(c *gin.Context) {
+func (ctrl *TaskController) GetTaskRevisions(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Pagination parameters
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("pageSize", "10")
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 || pageSize > 100 {
-		pageSize = 10
-	}
-
-	// Legacy limit parameter for backward compatibility
-	limit := pageSize
-	if limitParam := c.Query("limit"); limitParam != "" {
-		if l, err := strconv.Atoi(limitParam); err == nil && l > 0 {
-			limit = l
-			pageSize = l // Use limit as pageSize for backward compatibility
-		}
-	}
-
-	tasks, _, err := ctrl.taskService.GetUserTasks(userID, 1, 10000)
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get upcoming tasks"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	now := time.Now()
-	var allUpcomingTasks []*models.UpcomingTask
-
-	for _, task := range tasks {
-		if !task.IsCompleted && task.DueDate != nil && task.DueDate.Time.After(now) {
-			daysLeft := int(task.DueDate.Time.Sub(now).Hours() / 24)
-			upcomingTask := &models.UpcomingTask{
-				ID:       task.ID,
-				TaskName: task.TaskName,
-				Category: task.Category,
-				Priority: task.Priority,
-				DueDate:  task.DueDate,
-				DaysLeft: daysLeft,
-				IsUrgent: daysLeft <= 3, // Tasks due within 3 days are urgent
-			}
-			allUpcomingTasks = append(allUpcomingTasks, upcomingTask)
+	revisions, err := ctrl.taskService.GetTaskRevisions(taskID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revisions"})
+		return
 	}
 
-	// Sort by due date (closest first)
-	sort.Slice(allUpcomingTasks, func(i, j int) bool {
-		return allUpcomingTasks[i].DueDate.Time.Before(allUpcomingTasks[j].DueDate.Time)
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":   taskID,
+		"revisions": revisions,
 	})
+}
 
-	// Apply pagination
-	total := len(allUpcomingTasks)
-	startIndex := (page - 1) * pageSize
-	endIndex := startIndex + pageSize
+func (ctrl *TaskController) RestoreTaskRevision(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-	var paginatedTasks []*models.UpcomingTask
-	if startIndex >= total {
-		paginatedTasks = []*models.UpcomingTask{}
-	} else {
-		if endIndex > total {
-			endIndex = total
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	number, ok := revisionNumber(c)
+	if !ok {
+		return
+	}
+
+	task, revision, err := ctrl.taskService.RestoreTaskRevision(taskID, userID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRevisionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidStatusTransition), errors.Is(err, services.ErrOpenSubtasks), errors.Is(err, services.ErrTaskBlocked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidParent), errors.Is(err, services.ErrInvalidTag):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
 		}
-		paginatedTasks = allUpcomingTasks[startIndex:endIndex]
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"upcoming_tasks": paginatedTasks,
-		"count":          len(paginatedTasks),
-		"total":          total,
-		"page":           page,
-		"page_size":      pageSize,
-		"total_pages":    (total + pageSize - 1) / pageSize,
-		"limit":          limit, // For backward compatibility
+		"message":  "Task restored successfully",
+		"task":     task,
+		"revision": revision,
 	})
 }
 
-func (ctrl *TaskController) GetRecentActivity(c *gin.Context) {
+// Dependency Methods
+func (ctrl *TaskController) GetTaskBlockers(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Pagination parameters
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("pageSize", "20")
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
 	}
 
-	// Legacy limit parameter for backward compatibility
-	limit := pageSize
-	if limitParam := c.Query("limit"); limitParam != "" {
-		if l, err := strconv.Atoi(limitParam); err == nil && l > 0 {
-			limit = l
-			pageSize = l // Use limit as pageSize for backward compatibility
+	blockers, err := ctrl.taskService.GetTaskBlockers(taskID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get blockers"})
+		return
+	}
+	if blockers == nil {
+		blockers = []*models.Task{}
 	}
 
-	tasks, _, err := ctrl.taskService.GetUserTasks(userID, 1, 10000)
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":  taskID,
+		"blockers": blockers,
+		"count":    len(blockers),
+	})
+}
+
+func (ctrl *TaskController) GetTaskDependents(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recent activity"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	var allActivities []*models.RecentActivity
+	dependents, err := ctrl.taskService.GetTaskDependents(taskID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dependents"})
+		return
+	}
+	if dependents == nil {
+		dependents = []*models.Task{}
+	}
 
-	// Add task creation activities
-	for _, task := range tasks {
-		activity := &models.RecentActivity{
-			ID:          task.ID,
-			Type:        "task_created",
-			Description: "Created task: " + task.TaskName,
-			TaskName:    &task.TaskName,
-			CreatedAt:   task.CreatedAt,
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":    taskID,
+		"dependents": dependents,
+		"count":      len(dependents),
+	})
+}
+
+func (ctrl *TaskController) AddTaskBlocker(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.AddDependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctrl.taskService.AddTaskDependency(taskID, req.BlockedByID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
 		}
-		allActivities = append(allActivities, activity)
+		if errors.Is(err, services.ErrDependencyCycle) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dependency"})
+		return
 	}
 
-	// Sort by creation time (newest first)
-	sort.Slice(allActivities, func(i, j int) bool {
-		return allActivities[i].CreatedAt.After(allActivities[j].CreatedAt)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "Dependency added successfully",
+		"task_id":       taskID,
+		"blocked_by_id": req.BlockedByID,
 	})
+}
 
-	// Apply pagination
-	total := len(allActivities)
-	startIndex := (page - 1) * pageSize
-	endIndex := startIndex + pageSize
+func (ctrl *TaskController) RemoveTaskBlocker(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-	var paginatedActivities []*models.RecentActivity
-	if startIndex >= total {
-		paginatedActivities = []*models.RecentActivity{}
-	} else {
-		if endIndex > total {
-			endIndex = total
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	blockerID, err := strconv.ParseInt(c.Param("blockerId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocker ID"})
+		return
+	}
+
+	if err := ctrl.taskService.RemoveTaskDependency(taskID, blockerID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dependency not found"})
+			return
 		}
-		paginatedActivities = allActivities[startIndex:endIndex]
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove dependency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed successfully"})
+}
+
+func (ctrl *TaskController) GetDependencyGraph(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	graph, err := ctrl.taskService.GetDependencyGraph(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dependency graph"})
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// Tag Methods
+func (ctrl *TaskController) GetTags(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tags, err := ctrl.taskService.GetUserTags(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
+func (ctrl *TaskController) RenameTag(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tagID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var req models.RenameTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := ctrl.taskService.RenameTag(tagID, userID, req.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrTagExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func (ctrl *TaskController) MergeTags(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := ctrl.taskService.MergeTags(userID, &req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tags merged successfully",
+		"tag":     tag,
+	})
+}
+
+func (ctrl *TaskController) DeleteTag(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tagID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	if err := ctrl.taskService.DeleteTag(tagID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// QuickAddTask creates a task from one line of text and returns the task
+// together with what the parser recognised in the line
+func (ctrl *TaskController) QuickAddTask(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.QuickAddRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, parsed, err := ctrl.taskService.QuickAddTask(userID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuickAdd) || errors.Is(err, services.ErrInvalidRecurrence) || errors.Is(err, services.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create task",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Task created successfully",
+		"task":    task,
+		"parsed":  parsed,
+	})
+}
+
+// Bulk Methods
+func (ctrl *TaskController) BulkUpdateTasks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BulkTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := ctrl.taskService.BulkUpdateTasks(userID, &req)
+	if err != nil {
+		var filterErr *filter.Error
+		switch {
+		case errors.As(err, &filterErr):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    filterErr.Error(),
+				"position": filterErr.Pos,
+				"filter":   req.Filter,
+			})
+		case errors.Is(err, services.ErrInvalidBulkRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply bulk operation"})
+		}
+		return
+	}
+
+	// Nothing was applied because at least one task failed validation
+	if !result.Applied {
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Recurrence Methods
+func (ctrl *TaskController) GetTaskOccurrences(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	count, err := strconv.Atoi(c.DefaultQuery("count", "5"))
+	if err != nil || count < 1 {
+		count = 5
+	}
+
+	preview, err := ctrl.taskService.PreviewTaskOccurrences(taskID, userID, count)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidRecurrence) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview occurrences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+func (ctrl *TaskController) PreviewRecurrence(c *gin.Context) {
+	if _, exists := middleware.GetUserID(c); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.PreviewRecurrenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := ctrl.taskService.PreviewRecurrence(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// Dashboard Methods
+func (ctrl *TaskController) GetDashboardSummary(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tasks, _, err := ctrl.taskService.GetUserTasks(userID, 1, 10000)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard summary"})
+		return
+	}
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+
+	var summary models.DashboardSummary
+	summary.TotalTasks = int64(len(tasks))
+
+	for _, task := range tasks {
+		if task.IsCompleted {
+			if task.CreatedAt.Format("2006-01-02") == today {
+				summary.CompletedToday++
+			}
+		} else {
+			if task.DueDate != nil {
+				if task.DueDate.Format("2006-01-02") == today {
+					summary.DueToday++
+				}
+				if task.DueDate.Before(now) {
+					summary.Overdue++
+				}
+			}
+		}
+	}
+
+	if summary.TotalTasks > 0 {
+		completedTasks := summary.TotalTasks - (summary.DueToday + summary.Overdue)
+		summary.CompletionRate = float64(completedTasks) / float64(summary.TotalTasks) * 100
+	}
+
+	summary.ProductivityTrend = "stable" // This would be calculated based on historical data
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetTaskMatrix buckets open tasks into the Eisenhower quadrants.
+// ?urgent_days= and ?important_priority= override the configured thresholds.
+func (ctrl *TaskController) GetTaskMatrix(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var urgentDays *int
+	if value := c.Query("urgent_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid urgent_days"})
+			return
+		}
+		urgentDays = &days
+	}
+	var importantPriority *int16
+	if value := c.Query("important_priority"); value != "" {
+		priority, err := strconv.ParseInt(value, 10, 16)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid important_priority"})
+			return
+		}
+		p := int16(priority)
+		importantPriority = &p
+	}
+
+	matrix, err := ctrl.taskService.GetTaskMatrix(userID, urgentDays, importantPriority)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMatrixSettings) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task matrix"})
+		return
+	}
+
+	c.JSON(http.StatusOK, matrix)
+}
+
+// SetTaskImportance flags a task important or not without changing its
+// priority; {"is_important": null} goes back to the priority threshold
+func (ctrl *TaskController) SetTaskImportance(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.SetImportanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := ctrl.taskService.SetTaskImportance(taskID, userID, req.IsImportant)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set importance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task importance updated successfully",
+		"task":    task,
+	})
+}
+
+func (ctrl *TaskController) GetUpcomingTasks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Pagination parameters
+	pageStr := c.DefaultQuery("page", "1")
+	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	// Legacy limit parameter for backward compatibility
+	limit := pageSize
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if l, err := strconv.Atoi(limitParam); err == nil && l > 0 {
+			limit = l
+			pageSize = l // Use limit as pageSize for backward compatibility
+		}
+	}
+
+	// The service returns every upcoming task sorted by due date and flags the blocked ones
+	allUpcomingTasks, err := ctrl.taskService.GetUpcomingTasks(userID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get upcoming tasks"})
+		return
+	}
+
+	// Apply pagination
+	total := len(allUpcomingTasks)
+	startIndex := (page - 1) * pageSize
+	endIndex := startIndex + pageSize
+
+	var paginatedTasks []*models.UpcomingTask
+	if startIndex >= total {
+		paginatedTasks = []*models.UpcomingTask{}
+	} else {
+		if endIndex > total {
+			endIndex = total
+		}
+		paginatedTasks = allUpcomingTasks[startIndex:endIndex]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upcoming_tasks": paginatedTasks,
+		"count":          len(paginatedTasks),
+		"total":          total,
+		"page":           page,
+		"page_size":      pageSize,
+		"total_pages":    (total + pageSize - 1) / pageSize,
+		"limit":          limit, // For backward compatibility
+	})
+}
+
+func (ctrl *TaskController) GetRecentActivity(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Pagination parameters
+	pageStr := c.DefaultQuery("page", "1")
+	pageSizeStr := c.DefaultQuery("pageSize", "20")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// Legacy limit parameter for backward compatibility
+	limit := pageSize
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if l, err := strconv.Atoi(limitParam); err == nil && l > 0 {
+			limit = l
+			pageSize = l // Use limit as pageSize for backward compatibility
+		}
+	}
+
+	tasks, _, err := ctrl.taskService.GetUserTasks(userID, 1, 10000)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recent activity"})
+		return
+	}
+
+	var allActivities []*models.RecentActivity
+
+	// Add task creation activities
+	for _, task := range tasks {
+		activity := &models.RecentActivity{
+			ID:          task.ID,
+			Type:        "task_created",
+			Description: "Created task: " + task.TaskName,
+			TaskName:    &task.TaskName,
+			CreatedAt:   task.CreatedAt,
+		}
+		allActivities = append(allActivities, activity)
+	}
+
+	// Sort by creation time (newest first)
+	sort.Slice(allActivities, func(i, j int) bool {
+		return allActivities[i].CreatedAt.After(allActivities[j].CreatedAt)
+	})
+
+	// Apply pagination
+	total := len(allActivities)
+	startIndex := (page - 1) * pageSize
+	endIndex := startIndex + pageSize
+
+	var paginatedActivities []*models.RecentActivity
+	if startIndex >= total {
+		paginatedActivities = []*models.RecentActivity{}
+	} else {
+		if endIndex > total {
+			endIndex = total
+		}
+		paginatedActivities = allActivities[startIndex:endIndex]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recent_activities": paginatedActivities,
+		"count":             len(paginatedActivities),
+		"total":             total,
+		"page":              page,
+		"page_size":         pageSize,
+		"total_pages":       (total + pageSize - 1) / pageSize,
+		"limit":             limit, // For backward compatibility
+	})
+}
+
+func (ctrl *TaskController) GetTasksByCategory(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	category := c.Param("category")
+	if category == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category parameter is required"})
+		return
+	}
+
+	tasks, err := ctrl.taskService.GetTasksByCategory(userID, category)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks by category"})
+		return
+	}
+
+	response := models.TaskResponse{
+		Tasks: tasks,
+		Total: len(tasks),
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (ctrl *TaskController) GetTasksByPriority(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	priorityStr := c.Param("priority")
+	priority, err := strconv.ParseInt(priorityStr, 10, 16)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority value"})
+		return
+	}
+
+	tasks, err := ctrl.taskService.GetTasksByPriority(userID, int16(priority))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks by priority"})
+		return
+	}
+
+	response := models.TaskResponse{
+		Tasks: tasks,
+		Total: len(tasks),
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Log Controller
+type LogController struct {
+	logService services.LogService
+}
+
+func NewLogController(logService services.LogService) *LogController {
+	return &LogController{
+		logService: logService,
+	}
+}
+
+func (ctrl *LogController) GetUserLogs(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100 // Cap at 100
+	}
+
+	logs, err := ctrl.logService.GetUserLogs(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":  logs,
+		"total": len(logs),
+	})
+}
+
+// Trash Controller
+type TrashController struct {
+	trashService services.TrashService
+}
+
+func NewTrashController(trashService services.TrashService) *TrashController {
+	return &TrashController{
+		trashService: trashService,
+	}
+}
+
+// GetTrash lists deleted tasks, habits and goals; ?type=task narrows the list
+func (ctrl *TrashController) GetTrash(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	items, err := ctrl.trashService.GetTrash(userID, c.Query("type"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTrashType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":          items,
+		"total":          len(items),
+		"retention_days": ctrl.trashService.RetentionDays(),
+	})
+}
+
+func (ctrl *TrashController) RestoreItem(c *gin.Context) {
+	ctrl.handleItem(c, ctrl.trashService.RestoreItem, "restored")
+}
+
+func (ctrl *TrashController) PurgeItem(c *gin.Context) {
+	ctrl.handleItem(c, ctrl.trashService.PurgeItem, "permanently deleted")
+}
+
+// handleItem runs a trash action on the /trash/:type/:id item
+func (ctrl *TrashController) handleItem(c *gin.Context, action func(itemType string, itemID, userID int64) error, done string) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	itemType := c.Param("type")
+	itemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	if err := action(itemType, itemID, userID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTrashType):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update trash"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Item " + done + " successfully",
+		"type":    itemType,
+		"id":      itemID,
+	})
+}
+
+func (ctrl *TrashController) EmptyTrash(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	purged, err := ctrl.trashService.EmptyTrash(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to empty trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trash emptied successfully",
+		"purged":  purged,
+	})
+}
+
+// Time Tracking Controller
+type TimeTrackingController struct {
+	timeService services.TimeTrackingService
+}
+
+func NewTimeTrackingController(timeService services.TimeTrackingService) *TimeTrackingController {
+	return &TimeTrackingController{
+		timeService: timeService,
+	}
+}
+
+func (ctrl *TimeTrackingController) StartTimer(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	// The body is optional
+	var req models.StartTimerRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	entry, err := ctrl.timeService.StartTimer(taskID, userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTimerRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start timer"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Timer started",
+		"time_entry": entry,
+	})
+}
+
+func (ctrl *TimeTrackingController) StopTimer(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	entry, err := ctrl.timeService.StopTimer(taskID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNoRunningTimer) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop timer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Timer stopped",
+		"time_entry": entry,
+	})
+}
+
+// GetRunningTimer returns the user's running timer; it is null when none is running
+func (ctrl *TimeTrackingController) GetRunningTimer(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entry, err := ctrl.timeService.GetRunningTimer(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get running timer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"running": entry})
+}
+
+// GetTaskTime reports a task's estimate against its time entries and pomodoros
+func (ctrl *TimeTrackingController) GetTaskTime(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	report, err := ctrl.timeService.GetTaskTimeReport(taskID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (ctrl *TimeTrackingController) AddTimeEntry(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.CreateTimeEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := ctrl.timeService.AddTimeEntry(taskID, userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTimeEntry):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add time entry"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (ctrl *TimeTrackingController) UpdateTimeEntry(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time entry ID"})
+		return
+	}
+
+	var req models.UpdateTimeEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := ctrl.timeService.UpdateTimeEntry(entryID, userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTimeEntry):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Time entry not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update time entry"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (ctrl *TimeTrackingController) DeleteTimeEntry(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time entry ID"})
+		return
+	}
+
+	if err := ctrl.timeService.DeleteTimeEntry(entryID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Time entry not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete time entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time entry deleted successfully"})
+}
+
+// Task Template Controller
+type TemplateController struct {
+	templateService services.TemplateService
+}
+
+func NewTemplateController(templateService services.TemplateService) *TemplateController {
+	return &TemplateController{
+		templateService: templateService,
+	}
+}
+
+// templateError writes the response for a template service error
+func templateError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+	case errors.Is(err, services.ErrTemplateNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTemplate), errors.Is(err, services.ErrInvalidTag), errors.Is(err, services.ErrInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (ctrl *TemplateController) GetTemplates(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	templates, err := ctrl.templateService.GetTemplates(userID)
+	if err != nil {
+		templateError(c, err, "get templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"total":     len(templates),
+	})
+}
+
+func (ctrl *TemplateController) CreateTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateTaskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := ctrl.templateService.CreateTemplate(userID, &req)
+	if err != nil {
+		templateError(c, err, "create template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// CreateTemplateFromTasks saves existing tasks as a new template
+func (ctrl *TemplateController) CreateTemplateFromTasks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.TemplateFromTasksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := ctrl.templateService.CreateTemplateFromTasks(userID, &req)
+	if err != nil {
+		templateError(c, err, "create template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func (ctrl *TemplateController) GetTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	templateID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	template, err := ctrl.templateService.GetTemplate(templateID, userID)
+	if err != nil {
+		templateError(c, err, "get template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (ctrl *TemplateController) UpdateTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	templateID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	var req models.UpdateTaskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := ctrl.templateService.UpdateTemplate(templateID, userID, &req)
+	if err != nil {
+		templateError(c, err, "update template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (ctrl *TemplateController) DeleteTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	templateID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	if err := ctrl.templateService.DeleteTemplate(templateID, userID); err != nil {
+		templateError(c, err, "delete template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// InstantiateTemplate creates the template's tasks with the given variable
+// values and anchor date
+func (ctrl *TemplateController) InstantiateTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	templateID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	var req models.InstantiateTemplateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	instance, err := ctrl.templateService.InstantiateTemplate(templateID, userID, &req)
+	if err != nil {
+		templateError(c, err, "instantiate template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, instance)
+}
+
+// Project Controller
+type ProjectController struct {
+	projectService services.ProjectService
+}
+
+func NewProjectController(projectService services.ProjectService) *ProjectController {
+	return &ProjectController{
+		projectService: projectService,
+	}
+}
+
+// projectError writes the response for a project service error
+func projectError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	case errors.Is(err, services.ErrProjectNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidProject), errors.Is(err, services.ErrInvalidMove):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// projectID reads the :id route parameter and answers 400 when it is not a number
+func projectID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetProjects lists the user's projects; ?include_archived=true adds archived ones
+func (ctrl *ProjectController) GetProjects(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	includeArchived := c.Query("include_archived") == "true"
+	projects, err := ctrl.projectService.GetProjects(userID, includeArchived)
+	if err != nil {
+		projectError(c, err, "get projects")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+		"total":    len(projects),
+	})
+}
+
+func (ctrl *ProjectController) CreateProject(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := ctrl.projectService.CreateProject(userID, &req)
+	if err != nil {
+		projectError(c, err, "create project")
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func (ctrl *ProjectController) GetProject(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := projectID(c)
+	if !ok {
+		return
+	}
+
+	project, err := ctrl.projectService.GetProject(id, userID)
+	if err != nil {
+		projectError(c, err, "get project")
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := projectID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := ctrl.projectService.UpdateProject(id, userID, &req)
+	if err != nil {
+		projectError(c, err, "update project")
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := projectID(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.projectService.DeleteProject(id, userID); err != nil {
+		projectError(c, err, "delete project")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
+}
+
+// GetProjectTasks lists a project's tasks in their manual order
+func (ctrl *ProjectController) GetProjectTasks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := projectID(c)
+	if !ok {
+		return
+	}
+
+	tasks, err := ctrl.projectService.GetProjectTasks(id, userID)
+	if err != nil {
+		projectError(c, err, "get project tasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tasks": tasks,
+		"total": len(tasks),
+	})
+}
+
+// MoveTask changes a task's project and its position within the project
+func (ctrl *ProjectController) MoveTask(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.MoveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := ctrl.projectService.MoveTask(taskID, userID, &req)
+	if err != nil {
+		projectError(c, err, "move task")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task moved successfully",
+		"task":    task,
+	})
+}
+
+// Board Controller
+type BoardController struct {
+	boardService services.BoardService
+}
+
+func NewBoardController(boardService services.BoardService) *BoardController {
+	return &BoardController{
+		boardService: boardService,
+	}
+}
+
+// boardError writes the response for a board service error
+func boardError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrBoardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Board not found"})
+	case errors.Is(err, services.ErrWIPLimitExceeded),
+		errors.Is(err, services.ErrInvalidStatusTransition), errors.Is(err, services.ErrOpenSubtasks), errors.Is(err, services.ErrTaskBlocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBoard), errors.Is(err, services.ErrInvalidProject), errors.Is(err, services.ErrInvalidMove):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// boardID reads the :id route parameter and answers 400 when it is not a number
+func boardID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetBoards lists the user's boards with their columns and task counts
+func (ctrl *BoardController) GetBoards(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	boards, err := ctrl.boardService.GetBoards(userID)
+	if err != nil {
+		boardError(c, err, "get boards")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"boards": boards,
+		"total":  len(boards),
+	})
+}
+
+func (ctrl *BoardController) CreateBoard(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	board, err := ctrl.boardService.CreateBoard(userID, &req)
+	if err != nil {
+		boardError(c, err, "create board")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Board created successfully",
+		"board":   board,
+	})
+}
+
+// GetBoard returns a board with its columns and their ordered cards;
+// ?card_limit= caps the cards per column
+func (ctrl *BoardController) GetBoard(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := boardID(c)
+	if !ok {
+		return
+	}
+	cardLimit, _ := strconv.Atoi(c.Query("card_limit"))
+
+	board, err := ctrl.boardService.GetBoard(id, userID, cardLimit)
+	if err != nil {
+		boardError(c, err, "get board")
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
+
+func (ctrl *BoardController) UpdateBoard(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := boardID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	board, err := ctrl.boardService.UpdateBoard(id, userID, &req)
+	if err != nil {
+		boardError(c, err, "update board")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Board updated successfully",
+		"board":   board,
+	})
+}
+
+func (ctrl *BoardController) DeleteBoard(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := boardID(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.boardService.DeleteBoard(id, userID); err != nil {
+		boardError(c, err, "delete board")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Board deleted successfully"})
+}
+
+// MoveCard moves a task's card to a column and position on the board,
+// changing the task's status to the column's; 409 when the column's WIP
+// limit or the status rules do not allow it
+func (ctrl *BoardController) MoveCard(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := boardID(c)
+	if !ok {
+		return
+	}
+	taskID, err := strconv.ParseInt(c.Param("task_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.MoveCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := ctrl.boardService.MoveCard(id, taskID, userID, &req)
+	if err != nil {
+		boardError(c, err, "move card")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Attachment Controller
+type AttachmentController struct {
+	attachmentService services.AttachmentService
+	limits            models.AttachmentLimits
+}
+
+func NewAttachmentController(attachmentService services.AttachmentService, limits models.AttachmentLimits) *AttachmentController {
+	return &AttachmentController{
+		attachmentService: attachmentService,
+		limits:            limits,
+	}
+}
+
+// attachmentError writes the response for an attachment service error
+func attachmentError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+	case errors.Is(err, services.ErrAttachmentTooLarge), errors.Is(err, services.ErrAttachmentQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// attachmentIDs reads the :id and :attachment_id route parameters and
+// answers 400 when they are not numbers
+func attachmentIDs(c *gin.Context) (taskID, attachmentID int64, ok bool) {
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, 0, false
+	}
+	attachmentID, err = strconv.ParseInt(c.Param("attachment_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return 0, 0, false
+	}
+	return taskID, attachmentID, true
+}
+
+// GetTaskAttachments lists a task's attachments together with the user's
+// storage usage
+func (ctrl *AttachmentController) GetTaskAttachments(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	attachments, usage, err := ctrl.attachmentService.GetTaskAttachments(taskID, userID)
+	if err != nil {
+		attachmentError(c, err, "get attachments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attachments": attachments,
+		"total":       len(attachments),
+		"usage":       usage,
+	})
+}
+
+// UploadAttachment attaches the multipart form file "file" to a task
+func (ctrl *AttachmentController) UploadAttachment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	// Leave room for the multipart headers around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ctrl.limits.MaxBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrAttachmentTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required in the 'file' form field"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := ctrl.attachmentService.UploadAttachment(taskID, userID, header.Filename, header.Size, file)
+	if err != nil {
+		attachmentError(c, err, "upload attachment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Attachment uploaded successfully",
+		"attachment": attachment,
+	})
+}
+
+// DownloadAttachment sends an attachment's content. It is always sent as a
+// download with the sniffed type, so uploaded HTML cannot run in the page.
+func (ctrl *AttachmentController) DownloadAttachment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, attachmentID, ok := attachmentIDs(c)
+	if !ok {
+		return
+	}
+
+	attachment, content, err := ctrl.attachmentService.OpenAttachment(attachmentID, taskID, userID)
+	if err != nil {
+		attachmentError(c, err, "download attachment")
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.SizeBytes, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+		"ETag":                   `"` + attachment.Checksum + `"`,
+	})
+}
+
+func (ctrl *AttachmentController) DeleteAttachment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, attachmentID, ok := attachmentIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.attachmentService.DeleteAttachment(attachmentID, taskID, userID); err != nil {
+		attachmentError(c, err, "delete attachment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// Comment Controller
+type CommentController struct {
+	commentService services.CommentService
+}
+
+func NewCommentController(commentService services.CommentService) *CommentController {
+	return &CommentController{
+		commentService: commentService,
+	}
+}
+
+// commentError writes the response for a comment service error
+func commentError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+	case errors.Is(err, services.ErrInvalidComment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// commentIDs reads the :id and :comment_id route parameters and answers 400
+// when they are not numbers
+func commentIDs(c *gin.Context) (taskID, commentID int64, ok bool) {
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, 0, false
+	}
+	commentID, err = strconv.ParseInt(c.Param("comment_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return 0, 0, false
+	}
+	return taskID, commentID, true
+}
+
+// GetTaskComments lists a task's comments, oldest first
+func (ctrl *CommentController) GetTaskComments(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	comments, err := ctrl.commentService.GetTaskComments(taskID, userID)
+	if err != nil {
+		commentError(c, err, "get comments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments": comments,
+		"total":    len(comments),
+	})
+}
+
+func (ctrl *CommentController) AddComment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := ctrl.commentService.AddComment(taskID, userID, &req)
+	if err != nil {
+		commentError(c, err, "add comment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Comment added successfully",
+		"comment": comment,
+	})
+}
+
+func (ctrl *CommentController) UpdateComment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, commentID, ok := commentIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := ctrl.commentService.UpdateComment(commentID, taskID, userID, &req)
+	if err != nil {
+		commentError(c, err, "update comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Comment updated successfully",
+		"comment": comment,
+	})
+}
+
+func (ctrl *CommentController) DeleteComment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, commentID, ok := commentIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.commentService.DeleteComment(commentID, taskID, userID); err != nil {
+		commentError(c, err, "delete comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// Reminder Controller
+type ReminderController struct {
+	reminderService services.ReminderService
+}
+
+func NewReminderController(reminderService services.ReminderService) *ReminderController {
+	return &ReminderController{
+		reminderService: reminderService,
+	}
+}
+
+// reminderError writes the response for a reminder service error; target
+// names what a missing parent row was, "Task" or "Habit"
+func reminderError(c *gin.Context, err error, action, target string) {
+	switch {
+	case errors.Is(err, services.ErrReminderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+	case errors.Is(err, services.ErrNotificationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+	case errors.Is(err, services.ErrInvalidReminder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": target + " not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// GetReminders lists all of the user's reminders, next to fire first
+func (ctrl *ReminderController) GetReminders(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reminders, err := ctrl.reminderService.GetReminders(userID)
+	if err != nil {
+		reminderError(c, err, "get reminders", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reminders": reminders,
+		"total":     len(reminders),
+	})
+}
+
+func (ctrl *ReminderController) GetTaskReminders(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	reminders, err := ctrl.reminderService.GetTaskReminders(taskID, userID)
+	if err != nil {
+		reminderError(c, err, "get reminders", "Task")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reminders": reminders,
+		"total":     len(reminders),
+	})
+}
+
+// CreateTaskReminder sets a reminder at remind_at, or offset_minutes from
+// the task's due date (negative for before it)
+func (ctrl *ReminderController) CreateTaskReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.CreateTaskReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reminder, err := ctrl.reminderService.CreateTaskReminder(taskID, userID, &req)
+	if err != nil {
+		reminderError(c, err, "create reminder", "Task")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Reminder created successfully",
+		"reminder": reminder,
+	})
+}
+
+func (ctrl *ReminderController) GetHabitReminders(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	reminders, err := ctrl.reminderService.GetHabitReminders(habitID, userID)
+	if err != nil {
+		reminderError(c, err, "get reminders", "Habit")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reminders": reminders,
+		"total":     len(reminders),
+	})
+}
+
+// CreateHabitReminder sets a daily cue for a habit
+func (ctrl *ReminderController) CreateHabitReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	var req models.CreateHabitReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reminder, err := ctrl.reminderService.CreateHabitReminder(habitID, userID, &req)
+	if err != nil {
+		reminderError(c, err, "create reminder", "Habit")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Reminder created successfully",
+		"reminder": reminder,
+	})
+}
+
+func (ctrl *ReminderController) DeleteReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reminderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder ID"})
+		return
+	}
+
+	if err := ctrl.reminderService.DeleteReminder(reminderID, userID); err != nil {
+		reminderError(c, err, "delete reminder", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reminder deleted successfully"})
+}
+
+// SnoozeReminder puts a reminder off by the given number of minutes
+func (ctrl *ReminderController) SnoozeReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reminderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder ID"})
+		return
+	}
+
+	var req models.SnoozeReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reminder, err := ctrl.reminderService.SnoozeReminder(reminderID, userID, req.Minutes)
+	if err != nil {
+		reminderError(c, err, "snooze reminder", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Reminder snoozed successfully",
+		"reminder": reminder,
+	})
+}
+
+// DismissReminder turns a task reminder off; a habit reminder skips its
+// next cue
+func (ctrl *ReminderController) DismissReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reminderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder ID"})
+		return
+	}
+
+	reminder, err := ctrl.reminderService.DismissReminder(reminderID, userID)
+	if err != nil {
+		reminderError(c, err, "dismiss reminder", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Reminder dismissed successfully",
+		"reminder": reminder,
+	})
+}
+
+// GetNotifications lists the in-app inbox, newest first; ?unread=true leaves
+// out the read ones
+func (ctrl *ReminderController) GetNotifications(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, unread, err := ctrl.reminderService.GetNotifications(userID, unreadOnly, limit)
+	if err != nil {
+		reminderError(c, err, "get notifications", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"total":         len(notifications),
+		"unread":        unread,
+	})
+}
+
+func (ctrl *ReminderController) MarkNotificationRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	if err := ctrl.reminderService.MarkNotificationRead(notificationID, userID); err != nil {
+		reminderError(c, err, "mark notification as read", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+func (ctrl *ReminderController) MarkAllNotificationsRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	marked, err := ctrl.reminderService.MarkAllNotificationsRead(userID)
+	if err != nil {
+		reminderError(c, err, "mark notifications as read", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifications marked as read",
+		"marked":  marked,
+	})
+}
+
+// Planner Controller
+type PlannerController struct {
+	plannerService services.PlannerService
+}
+
+func NewPlannerController(plannerService services.PlannerService) *PlannerController {
+	return &PlannerController{
+		plannerService: plannerService,
+	}
+}
+
+// plannerError writes the response for a planner service error
+func plannerError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrTimeBlockNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Time block not found"})
+	case errors.Is(err, services.ErrInvalidPlan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlanConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (ctrl *PlannerController) GetWorkingHours(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	hours, err := ctrl.plannerService.GetWorkingHours(userID)
+	if err != nil {
+		plannerError(c, err, "get working hours")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hours": hours})
+}
+
+func (ctrl *PlannerController) SetWorkingHours(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.SetWorkingHoursRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hours, err := ctrl.plannerService.SetWorkingHours(userID, &req)
+	if err != nil {
+		plannerError(c, err, "set working hours")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Working hours updated successfully",
+		"hours":   hours,
+	})
+}
+
+// GetTimeBlocks lists the blocks on ?date= in ?timezone=
+func (ctrl *PlannerController) GetTimeBlocks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	blocks, err := ctrl.plannerService.GetTimeBlocks(userID, c.Query("date"), c.Query("timezone"))
+	if err != nil {
+		plannerError(c, err, "get time blocks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocks": blocks,
+		"total":  len(blocks),
+	})
+}
+
+func (ctrl *PlannerController) CreateTimeBlock(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateTimeBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	block, err := ctrl.plannerService.CreateTimeBlock(userID, &req)
+	if err != nil {
+		plannerError(c, err, "create time block")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Time block created successfully",
+		"block":   block,
+	})
+}
+
+func (ctrl *PlannerController) DeleteTimeBlock(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	blockID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time block ID"})
+		return
+	}
+
+	if err := ctrl.plannerService.DeleteTimeBlock(blockID, userID); err != nil {
+		plannerError(c, err, "delete time block")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time block deleted successfully"})
+}
+
+// PlanDay proposes a timeline for ?date= in ?timezone= without saving it
+func (ctrl *PlannerController) PlanDay(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := ctrl.plannerService.PlanDay(userID, c.Query("date"), c.Query("timezone"))
+	if err != nil {
+		plannerError(c, err, "plan day")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// AcceptPlan saves the blocks of a plan so they show in the calendar
+func (ctrl *PlannerController) AcceptPlan(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.AcceptPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	blocks, err := ctrl.plannerService.AcceptPlan(userID, &req)
+	if err != nil {
+		plannerError(c, err, "accept plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Plan accepted successfully",
+		"blocks":  blocks,
+	})
+}
+
+// Calendar Feed Controller
+type CalendarFeedController struct {
+	feedService services.CalendarFeedService
+}
+
+func NewCalendarFeedController(feedService services.CalendarFeedService) *CalendarFeedController {
+	return &CalendarFeedController{
+		feedService: feedService,
+	}
+}
+
+// calendarFeedError writes the response for a calendar feed service error
+func calendarFeedError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrCalendarFeedNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+	case errors.Is(err, services.ErrInvalidCalendarFeed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// feedURL is the subscription URL of a feed token as seen by the client
+func feedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	} else if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api/v1/calendar/feed/" + token + ".ics"
+}
+
+func (ctrl *CalendarFeedController) GetFeeds(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	feeds, err := ctrl.feedService.GetFeeds(userID)
+	if err != nil {
+		calendarFeedError(c, err, "get calendar feeds")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feeds": feeds,
+		"total": len(feeds),
+	})
+}
+
+// CreateFeed makes a feed; the response is the only time its token and URL
+// are shown
+func (ctrl *CalendarFeedController) CreateFeed(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateCalendarFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	feed, token, err := ctrl.feedService.CreateFeed(userID, &req)
+	if err != nil {
+		calendarFeedError(c, err, "create calendar feed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Calendar feed created successfully",
+		"feed":    feed,
+		"token":   token,
+		"url":     feedURL(c, token),
+	})
+}
+
+func (ctrl *CalendarFeedController) RevokeFeed(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	feedID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar feed ID"})
+		return
+	}
+
+	if err := ctrl.feedService.RevokeFeed(feedID, userID); err != nil {
+		calendarFeedError(c, err, "revoke calendar feed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked successfully"})
+}
+
+// Feed serves a feed by its token, with or without the .ics suffix. It is
+// public: calendar apps subscribe to the URL without a JWT.
+func (ctrl *CalendarFeedController) Feed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	data, err := ctrl.feedService.RenderFeed(token)
+	if err != nil {
+		if errors.Is(err, services.ErrCalendarFeedNotFound) {
+			c.String(http.StatusNotFound, "Calendar feed not found")
+			return
+		}
+		c.String(http.StatusInternalServerError, "Failed to render calendar feed")
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", data)
+}
+
+// App Password Controller
+type AppPasswordController struct {
+	passwordService services.AppPasswordService
+}
+
+func NewAppPasswordController(passwordService services.AppPasswordService) *AppPasswordController {
+	return &AppPasswordController{
+		passwordService: passwordService,
+	}
+}
+
+// appPasswordError writes the response for an app password service error
+func appPasswordError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrAppPasswordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "App password not found"})
+	case errors.Is(err, services.ErrInvalidAppPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (ctrl *AppPasswordController) GetAppPasswords(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	passwords, err := ctrl.passwordService.GetAppPasswords(userID)
+	if err != nil {
+		appPasswordError(c, err, "get app passwords")
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"recent_activities": paginatedActivities,
-		"count":             len(paginatedActivities),
-		"total":             total,
-		"page":              page,
-		"page_size":         pageSize,
-		"total_pages":       (total + pageSize - 1) / pageSize,
-		"limit":             limit, // For backward compatibility
+		"app_passwords": passwords,
+		"total":         len(passwords),
 	})
 }
 
-func (ctrl *TaskController) GetTasksByCategory(c *gin.Context) {
+// CreateAppPassword makes an app password; the response is the only time
+// the password is shown
+func (ctrl *AppPasswordController) CreateAppPassword(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	category := c.Param("category")
-	if category == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Category parameter is required"})
+	var req models.CreateAppPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	tasks, err := ctrl.taskService.GetTasksByCategory(userID, category)
+	password, token, err := ctrl.passwordService.CreateAppPassword(userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks by category"})
+		appPasswordError(c, err, "create app password")
 		return
 	}
 
-	response := models.TaskResponse{
-		Tasks: tasks,
-		Total: len(tasks),
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "App password created successfully",
+		"app_password": password,
+		"password":     token,
+	})
 }
 
-func (ctrl *TaskController) GetTasksByPriority(c *gin.Context) {
+func (ctrl *AppPasswordController) RevokeAppPassword(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	priorityStr := c.Param("priority")
-	priority, err := strconv.ParseInt(priorityStr, 10, 16)
+	passwordID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority value"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app password ID"})
 		return
 	}
 
-	tasks, err := ctrl.taskService.GetTasksByPriority(userID, int16(priority))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks by priority"})
+	if err := ctrl.passwordService.RevokeAppPassword(passwordID, userID); err != nil {
+		appPasswordError(c, err, "revoke app password")
 		return
 	}
 
-	response := models.TaskResponse{
-		Tasks: tasks,
-		Total: len(tasks),
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, gin.H{"message": "App password revoked successfully"})
 }
 
-// Log Controller
-type LogController struct {
-	logService services.LogService
+// CalDAV Controller
+type CalDAVController struct {
+	handler *caldav.Handler
 }
 
-func NewLogController(logService services.LogService) *LogController {
-	return &LogController{
-		logService: logService,
+func NewCalDAVController(calDAVService services.CalDAVService) *CalDAVController {
+	return &CalDAVController{
+		handler: &caldav.Handler{
+			Backend:     calDAVService,
+			Prefix:      "/caldav",
+			DisplayName: "Tasks",
+		},
 	}
 }
 
-func (ctrl *LogController) GetUserLogs(c *gin.Context) {
+// Serve answers a CalDAV request of a client signed in with an app password
+func (ctrl *CalDAVController) Serve(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.String(http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	limitStr := c.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 50
-	}
-	if limit > 100 {
-		limit = 100 // Cap at 100
-	}
+	ctrl.handler.ServeHTTP(c.Writer, c.Request, caldav.User{ID: userID, Name: c.GetString("username")})
+}
 
-	logs, err := ctrl.logService.GetUserLogs(userID, limit)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get logs"})
-		return
-	}
+// Options answers OPTIONS without authentication, as clients probe for
+// CalDAV support before they sign in
+func (ctrl *CalDAVController) Options(c *gin.Context) {
+	ctrl.handler.Options(c.Writer)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"logs":  logs,
-		"total": len(logs),
-	})
+// WellKnown points clients that are given only the host at the server
+// (RFC 6764)
+func (ctrl *CalDAVController) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, ctrl.handler.Prefix+"/")
 }
 
 // Habit Controller
@@ -1340,6 +4032,34 @@ func (ctrl *HabitController) GetHabits(c *gin.Context) {
 		return
 	}
 
+	// Keyset pagination unless ?page= asks for the offset-based list
+	if _, offsetMode := c.GetQuery("page"); !offsetMode {
+		habits, page, err := ctrl.habitService.GetHabitsByCursor(userID, cursorRequest(c))
+		if err != nil {
+			if errors.Is(err, pagination.ErrInvalidSort) || errors.Is(err, pagination.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get habits"})
+			return
+		}
+		response := gin.H{
+			"habits": habits,
+			"total":  page.Total,
+		}
+		if page.NextCursor != "" {
+			response["next_cursor"] = page.NextCursor
+		}
+		if page.PrevCursor != "" {
+			response["prev_cursor"] = page.PrevCursor
+		}
+		if links := pageLinks(c, page); links != nil {
+			response["links"] = links
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("pageSize", "100")
 	page, err := strconv.Atoi(pageStr)
@@ -1639,6 +4359,12 @@ func (ctrl *HabitController) GetPomodoroStats(c *gin.Context) {
 }
 
 // Goals Management Methods
+
+// isGoalNotFound reports whether a goal error means the goal does not exist for the user
+func isGoalNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || errors.Is(err, services.ErrGoalNotFound)
+}
+
 func (ctrl *HabitController) CreateGoal(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -1652,14 +4378,10 @@ func (ctrl *HabitController) CreateGoal(c *gin.Context) {
 		return
 	}
 
-	goal := &models.Goal{
-		UserID:      userID,
-		Title:       req.Title,
-		Description: req.Description,
-		Category:    req.Category,
-		TargetValue: req.TargetValue,
-		Unit:        req.Unit,
-		DueDate:     req.DueDate,
+	goal, err := ctrl.habitService.CreateGoal(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -1669,14 +4391,20 @@ func (ctrl *HabitController) CreateGoal(c *gin.Context) {
 }
 
 func (ctrl *HabitController) GetGoals(c *gin.Context) {
-	_, exists := middleware.GetUserID(c)
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Return user's goals (placeholder)
-	goals := []models.Goal{}
+	goals, err := ctrl.habitService.GetGoals(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goals"})
+		return
+	}
+	if goals == nil {
+		goals = []*models.Goal{}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"goals": goals,
@@ -1697,18 +4425,21 @@ func (ctrl *HabitController) GetGoal(c *gin.Context) {
 		return
 	}
 
-	// Get goal by ID (placeholder)
-	goal := &models.Goal{
-		ID:     goalID,
-		UserID: userID,
-		Title:  "Sample Goal",
+	goal, err := ctrl.habitService.GetGoal(goalID, userID)
+	if err != nil {
+		if isGoalNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goal"})
+		return
 	}
 
 	c.JSON(http.StatusOK, goal)
 }
 
 func (ctrl *HabitController) UpdateGoal(c *gin.Context) {
-	_, exists := middleware.GetUserID(c)
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -1726,14 +4457,25 @@ func (ctrl *HabitController) UpdateGoal(c *gin.Context) {
 		return
 	}
 
+	goal, err := ctrl.habitService.UpdateGoal(goalID, userID, &req)
+	if err != nil {
+		if isGoalNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Goal updated successfully",
 		"goal_id": goalID,
+		"goal":    goal,
 	})
 }
 
 func (ctrl *HabitController) DeleteGoal(c *gin.Context) {
-	_, exists := middleware.GetUserID(c)
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -1745,6 +4487,15 @@ func (ctrl *HabitController) DeleteGoal(c *gin.Context) {
 		return
 	}
 
+	if err := ctrl.habitService.DeleteGoal(goalID, userID); err != nil {
+		if isGoalNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Goal deleted successfully",
 		"goal_id": goalID,
@@ -1752,7 +4503,7 @@ func (ctrl *HabitController) DeleteGoal(c *gin.Context) {
 }
 
 func (ctrl *HabitController) UpdateGoalProgress(c *gin.Context) {
-	_, exists := middleware.GetUserID(c)
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -1770,10 +4521,165 @@ func (ctrl *HabitController) UpdateGoalProgress(c *gin.Context) {
 		return
 	}
 
+	goal, err := ctrl.habitService.UpdateGoalProgress(goalID, userID, &req)
+	if err != nil {
+		if isGoalNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal progress"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":  "Goal progress updated",
 		"goal_id":  goalID,
 		"progress": req.Progress,
+		"goal":     goal,
+	})
+}
+
+// Revision Methods
+
+// revisionNumber reads the :rev path parameter
+func revisionNumber(c *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return 0, false
+	}
+	return number, true
+}
+
+func (ctrl *HabitController) GetHabitRevisions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	revisions, err := ctrl.habitService.GetHabitRevisions(habitID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"habit_id":  habitID,
+		"revisions": revisions,
+	})
+}
+
+func (ctrl *HabitController) RestoreHabitRevision(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+	number, ok := revisionNumber(c)
+	if !ok {
+		return
+	}
+
+	habit, revision, err := ctrl.habitService.RestoreHabitRevision(habitID, userID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRevisionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Habit restored successfully",
+		"habit":    habit,
+		"revision": revision,
+	})
+}
+
+func (ctrl *HabitController) GetGoalRevisions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	goalID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	revisions, err := ctrl.habitService.GetGoalRevisions(goalID, userID)
+	if err != nil {
+		if isGoalNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal_id":   goalID,
+		"revisions": revisions,
+	})
+}
+
+func (ctrl *HabitController) RestoreGoalRevision(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	goalID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+	number, ok := revisionNumber(c)
+	if !ok {
+		return
+	}
+
+	goal, revision, err := ctrl.habitService.RestoreGoalRevision(goalID, userID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRevisionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case isGoalNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Goal restored successfully",
+		"goal":     goal,
+		"revision": revision,
 	})
 }
 
@@ -1856,13 +4762,15 @@ type AnalyticsController struct {
 	authService  services.AuthService
 	taskService  services.TaskService
 	habitService services.HabitService
+	timeService  services.TimeTrackingService
 }
 
-func NewAnalyticsController(authService services.AuthService, taskService services.TaskService, habitService services.HabitService) *AnalyticsController {
+func NewAnalyticsController(authService services.AuthService, taskService services.TaskService, habitService services.HabitService, timeService services.TimeTrackingService) *AnalyticsController {
 	return &AnalyticsController{
 		authService:  authService,
 		taskService:  taskService,
 		habitService: habitService,
+		timeService:  timeService,
 	}
 }
 
@@ -2020,6 +4928,8 @@ func (ctrl *AnalyticsController) GetMonthlyPerformance(c *gin.Context) {
 	c.JSON(http.StatusOK, report)
 }
 
+// GetTimeAllocation reports the minutes spent per category and per task,
+// from time entries and completed pomodoros
 func (ctrl *AnalyticsController) GetTimeAllocation(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -2027,13 +4937,24 @@ func (ctrl *AnalyticsController) GetTimeAllocation(c *gin.Context) {
 		return
 	}
 
-	tasks, _, err := ctrl.taskService.GetUserTasks(userID, 1, 10000)
+	// ?days=30 limits the report to recent time; without it all time is counted
+	var since *time.Time
+	if c.Query("days") != "" {
+		days, err := strconv.Atoi(c.Query("days"))
+		if err != nil || days < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive number"})
+			return
+		}
+		start := time.Now().AddDate(0, 0, -days)
+		since = &start
+	}
+
+	allocation, err := ctrl.timeService.GetTimeAllocation(userID, since)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get time allocation"})
 		return
 	}
 
-	allocation := ctrl.calculateTimeAllocation(tasks)
 	c.JSON(http.StatusOK, gin.H{"time_allocation": allocation})
 }
 
@@ -2054,6 +4975,36 @@ func (ctrl *AnalyticsController) GetProductivityTrends(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"productivity_trends": trends})
 }
 
+// GetPostponements surfaces chronically postponed tasks (?min=3 times or
+// more) and the average slip per category over the last ?days=90 days
+func (ctrl *AnalyticsController) GetPostponements(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	minCount, err := strconv.Atoi(c.DefaultQuery("min", "3"))
+	if err != nil || minCount < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min must be a positive number"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive number"})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	report, err := ctrl.taskService.GetPostponementReport(userID, minCount, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get postponements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // Helper methods for analytics calculations
 func (ctrl *AnalyticsController) calculatePerformanceReport(tasks []*models.Task, habits []*models.Habit, period string) *models.PerformanceReport {
 	var tasksCompleted, tasksCreated int64
@@ -2091,36 +5042,6 @@ func (ctrl *AnalyticsController) calculatePerformanceReport(tasks []*models.Task
 	}
 }
 
-func (ctrl *AnalyticsController) calculateTimeAllocation(tasks []*models.Task) []*models.TimeAllocation {
-	priorityCount := make(map[int16]int64)
-	categoryCount := make(map[string]int64)
-	total := int64(len(tasks))
-
-	for _, task := range tasks {
-		priorityCount[task.Priority]++
-		if task.Category != nil {
-			categoryCount[*task.Category]++
-		} else {
-			categoryCount["Uncategorized"]++
-		}
-	}
-
-	var allocation []*models.TimeAllocation
-
-	// Add priority-based allocation
-	for priority, count := range priorityCount {
-		percentage := float64(count) / float64(total) * 100
-		allocation = append(allocation, &models.TimeAllocation{
-			Category:   "Priority " + strconv.Itoa(int(priority)),
-			Priority:   priority,
-			TaskCount:  count,
-			Percentage: percentage,
-		})
-	}
-
-	return allocation
-}
-
 func (ctrl *AnalyticsController) calculateProductivityTrends(tasks []*models.Task) map[string]interface{} {
 	trends := make(map[string]interface{})
 
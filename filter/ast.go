@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Node is a node of a parsed filter expression: *Binary, *Not or *Comparison
+type Node interface {
+	node()
+}
+
+// Binary joins two expressions with AND or OR
+type Binary struct {
+	Op    string // "AND" or "OR"
+	Left  Node
+	Right Node
+}
+
+// Not negates an expression
+type Not struct {
+	Expr Node
+}
+
+// Comparison is a single field test such as priority>=2 or category:Work
+type Comparison struct {
+	Field Field
+	Op    Operator
+	Value Value
+	Pos   int // 1-based position of the field name in the expression
+}
+
+func (*Binary) node()     {}
+func (*Not) node()        {}
+func (*Comparison) node() {}
+
+// Operator is a comparison operator. "=" is accepted as a synonym of ":".
+type Operator string
+
+const (
+	OpEq  Operator = ":"
+	OpNe  Operator = "!="
+	OpLt  Operator = "<"
+	OpLte Operator = "<="
+	OpGt  Operator = ">"
+	OpGte Operator = ">="
+)
+
+// Field is a filterable task attribute
+type Field string
+
+const (
+	FieldPriority  Field = "priority"
+	FieldCategory  Field = "category"
+	FieldStatus    Field = "status"
+	FieldDue       Field = "due"
+	FieldCreated   Field = "created"
+	FieldName      Field = "name"
+	FieldTag       Field = "tag"
+	FieldCompleted Field = "completed"
+	FieldRecurring Field = "recurring"
+)
+
+// Kind is the type of a field and of the values compared against it
+type Kind int
+
+const (
+	KindInt Kind = iota
+	KindString
+	KindStatus
+	KindText // matched as a substring
+	KindDate
+	KindBool
+)
+
+type fieldSpec struct {
+	kind     Kind
+	nullable bool // accepts the value "none"
+}
+
+var fields = map[Field]fieldSpec{
+	FieldPriority:  {kind: KindInt},
+	FieldCategory:  {kind: KindString, nullable: true},
+	FieldStatus:    {kind: KindStatus},
+	FieldDue:       {kind: KindDate, nullable: true},
+	FieldCreated:   {kind: KindDate},
+	FieldName:      {kind: KindText},
+	FieldTag:       {kind: KindString},
+	FieldCompleted: {kind: KindBool},
+	FieldRecurring: {kind: KindBool},
+}
+
+// aliases lets expressions use the column names as well
+var aliases = map[string]Field{
+	"due_date":   FieldDue,
+	"created_at": FieldCreated,
+	"task_name":  FieldName,
+	"tags":       FieldTag,
+}
+
+// KindOf returns the type of a field
+func KindOf(f Field) Kind {
+	return fields[f].kind
+}
+
+// Value is a validated comparison operand. Exactly one of the typed fields
+// is meaningful, depending on the field's Kind, unless Null is set.
+type Value struct {
+	Null bool
+	Int  int64
+	Str  string
+	Bool bool
+	Time time.Time
+	// DateOnly marks a calendar date, which compares as the whole day
+	DateOnly bool
+}
+
+// Error is a parse or validation error at a 1-based position in the expression
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("filter error at position %d: %s", e.Pos, e.Msg)
+}
@@ -0,0 +1,352 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todo-backend/models"
+	"unicode"
+)
+
+// maxDepth bounds nesting of parentheses and NOT so hostile input cannot
+// exhaust the stack
+const maxDepth = 32
+
+// Parse parses and validates a filter expression such as
+//
+//	priority>=2 AND category:Work AND due<2026-11-01 AND NOT status:cancelled
+//
+// NOT binds tighter than AND, which binds tighter than OR; parentheses group.
+// Keywords are case-insensitive. Values containing spaces are written in
+// double quotes. Relative dates (today, tomorrow, yesterday, +3d, -2w) are
+// resolved against now.
+func Parse(expr string, now time.Time) (Node, error) {
+	p := &parser{src: expr, now: now}
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf(p.pos, "expression is empty")
+	}
+
+	node, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf(p.pos, "unexpected %q", p.word())
+	}
+	return node, nil
+}
+
+type parser struct {
+	src string
+	pos int
+	now time.Time
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// word returns the run of non-space characters at the current position, for error messages
+func (p *parser) word() string {
+	end := p.pos
+	for end < len(p.src) && !unicode.IsSpace(rune(p.src[end])) {
+		end++
+	}
+	return p.src[p.pos:end]
+}
+
+// keyword consumes kw if it is the next word
+func (p *parser) keyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if end > len(p.src) || !strings.EqualFold(p.src[p.pos:end], kw) {
+		return false
+	}
+	if end < len(p.src) && isIdentChar(p.src[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *parser) parseOr(depth int) (Node, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (Node, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary(depth int) (Node, error) {
+	if depth > maxDepth {
+		return nil, p.errorf(p.pos, "expression is nested too deeply")
+	}
+	if p.keyword("NOT") {
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *parser) parsePrimary(depth int) (Node, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf(p.pos, "unexpected end of expression, expected a condition")
+	}
+
+	if p.src[p.pos] == '(' {
+		open := p.pos
+		p.pos++
+		node, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf(open, "unclosed parenthesis")
+		}
+		p.pos++
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+var operators = []Operator{OpGte, OpLte, OpNe, OpEq, OpLt, OpGt}
+
+func (p *parser) parseComparison() (Node, error) {
+	start := p.pos
+	for p.pos < len(p.src) && isIdentChar(p.src[p.pos]) {
+		p.pos++
+	}
+	name := strings.ToLower(p.src[start:p.pos])
+	if name == "" {
+		return nil, p.errorf(start, "expected a field name, found %q", p.word())
+	}
+
+	field := Field(name)
+	if alias, ok := aliases[name]; ok {
+		field = alias
+	}
+	spec, ok := fields[field]
+	if !ok {
+		return nil, p.errorf(start, "unknown field %q (known fields: %s)", name, knownFields())
+	}
+
+	p.skipSpace()
+	opPos := p.pos
+	var op Operator
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "="):
+		op = OpEq
+		p.pos++
+	default:
+		for _, candidate := range operators {
+			if strings.HasPrefix(p.src[p.pos:], string(candidate)) {
+				op = candidate
+				p.pos += len(candidate)
+				break
+			}
+		}
+	}
+	if op == "" {
+		return nil, p.errorf(opPos, "expected an operator (:, !=, <, <=, >, >=) after %q", name)
+	}
+	if !operatorAllowed(spec.kind, op) {
+		return nil, p.errorf(opPos, "operator %s cannot be used with %s", op, name)
+	}
+
+	p.skipSpace()
+	valuePos := p.pos
+	raw, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	value, msg := p.convert(spec, op, raw)
+	if msg != "" {
+		return nil, p.errorf(valuePos, "%s", msg)
+	}
+
+	return &Comparison{Field: field, Op: op, Value: value, Pos: start + 1}, nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	if p.pos == len(p.src) {
+		return "", p.errorf(p.pos, "unexpected end of expression, expected a value")
+	}
+
+	if p.src[p.pos] == '"' {
+		open := p.pos
+		p.pos++
+		var b strings.Builder
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			switch {
+			case c == '\\' && p.pos+1 < len(p.src):
+				b.WriteByte(p.src[p.pos+1])
+				p.pos += 2
+			case c == '"':
+				p.pos++
+				return b.String(), nil
+			default:
+				b.WriteByte(c)
+				p.pos++
+			}
+		}
+		return "", p.errorf(open, "unterminated quoted value")
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !unicode.IsSpace(rune(p.src[p.pos])) && p.src[p.pos] != '(' && p.src[p.pos] != ')' {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf(start, "expected a value")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func operatorAllowed(kind Kind, op Operator) bool {
+	switch kind {
+	case KindInt, KindDate:
+		return true
+	default:
+		return op == OpEq || op == OpNe
+	}
+}
+
+// convert validates raw against the field type and returns an error message when it does not fit
+func (p *parser) convert(spec fieldSpec, op Operator, raw string) (Value, string) {
+	if spec.nullable && strings.EqualFold(raw, "none") {
+		if op != OpEq && op != OpNe {
+			return Value{}, "none can only be compared with : or !="
+		}
+		return Value{Null: true}, ""
+	}
+
+	switch spec.kind {
+	case KindInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Sprintf("%q is not a number", raw)
+		}
+		return Value{Int: n}, ""
+
+	case KindStatus:
+		status := models.TaskStatus(strings.ToLower(raw))
+		if !status.IsValid() {
+			return Value{}, fmt.Sprintf("%q is not a status (pending, in_progress, completed, cancelled)", raw)
+		}
+		return Value{Str: string(status)}, ""
+
+	case KindBool:
+		switch strings.ToLower(raw) {
+		case "true", "yes":
+			return Value{Bool: true}, ""
+		case "false", "no":
+			return Value{Bool: false}, ""
+		}
+		return Value{}, fmt.Sprintf("%q is not true or false", raw)
+
+	case KindDate:
+		t, dateOnly, ok := parseDate(raw, p.now)
+		if !ok {
+			return Value{}, fmt.Sprintf("%q is not a date (YYYY-MM-DD, RFC3339, today, tomorrow, yesterday, +3d, -2w)", raw)
+		}
+		return Value{Time: t, DateOnly: dateOnly}, ""
+
+	default:
+		if raw == "" {
+			return Value{}, "value is empty"
+		}
+		return Value{Str: raw}, ""
+	}
+}
+
+// parseDate resolves absolute and relative dates. Relative values are
+// calendar dates in now's location.
+func parseDate(raw string, now time.Time) (time.Time, bool, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch strings.ToLower(raw) {
+	case "today":
+		return today, true, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true, true
+	}
+
+	if len(raw) >= 3 && (raw[0] == '+' || raw[0] == '-') {
+		n, err := strconv.Atoi(raw[1 : len(raw)-1])
+		if err == nil {
+			if raw[0] == '-' {
+				n = -n
+			}
+			switch raw[len(raw)-1] {
+			case 'd', 'D':
+				return today.AddDate(0, 0, n), true, true
+			case 'w', 'W':
+				return today.AddDate(0, 0, 7*n), true, true
+			}
+		}
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", raw, now.Location()); err == nil {
+		return t, true, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, true
+	}
+	return time.Time{}, false, false
+}
+
+func knownFields() string {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, string(field))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
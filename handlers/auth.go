@@ -147,7 +147,7 @@ func GetUserInfo(c *gin.Context) {
 
 	// Get task statistics
 	err = config.DB.QueryRow(
-		"SELECT COUNT(*) FROM tasks WHERE user_id = $1",
+		"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.TotalTasks)
 	if err != nil {
@@ -155,7 +155,7 @@ func GetUserInfo(c *gin.Context) {
 	}
 
 	err = config.DB.QueryRow(
-		"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND is_completed = true",
+		"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND is_completed = true AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.CompletedTasks)
 	if err != nil {
@@ -166,7 +166,7 @@ func GetUserInfo(c *gin.Context) {
 
 	// Get habit statistics
 	err = config.DB.QueryRow(
-		"SELECT COUNT(*) FROM habits WHERE user_id = $1",
+		"SELECT COUNT(*) FROM habits WHERE user_id = $1 AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.TotalHabits)
 	if err != nil {
@@ -174,7 +174,7 @@ func GetUserInfo(c *gin.Context) {
 	}
 
 	err = config.DB.QueryRow(
-		"SELECT COUNT(*) FROM habits WHERE user_id = $1 AND is_achieved = true",
+		"SELECT COUNT(*) FROM habits WHERE user_id = $1 AND is_achieved = true AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.AchievedHabits)
 	if err != nil {
@@ -0,0 +1,198 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parse reads iCalendar data into its top-level components, normally a
+// single VCALENDAR. Folded lines are joined and LF line endings accepted.
+// Property names and parameter names are upper-cased; parameter values lose
+// their quotes and property values are left escaped, see Unescape.
+func Parse(data []byte) ([]*Component, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var top []*Component
+	var stack []*Component
+	for i, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch prop.Name {
+		case "BEGIN":
+			stack = append(stack, NewComponent(strings.ToUpper(prop.Value)))
+		case "END":
+			name := strings.ToUpper(prop.Value)
+			if len(stack) == 0 || stack[len(stack)-1].Name != name {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, name)
+			}
+			done := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				top = append(top, done)
+			} else {
+				stack[len(stack)-1].AddComponent(done)
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property %s outside of a component", i+1, prop.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	if len(top) == 0 {
+		return nil, errors.New("no iCalendar components found")
+	}
+	return top, nil
+}
+
+// parseLine splits a content line into name, parameters and value. Colons
+// and semicolons inside quoted parameter values do not count.
+func parseLine(line string) (Property, error) {
+	var prop Property
+	var fields []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';':
+			fields = append(fields, line[start:i])
+			start = i + 1
+		case c == ':':
+			fields = append(fields, line[start:i])
+			prop.Value = line[i+1:]
+			prop.Name = strings.ToUpper(strings.TrimSpace(fields[0]))
+			if prop.Name == "" {
+				return prop, errors.New("missing property name")
+			}
+			for _, field := range fields[1:] {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					return prop, fmt.Errorf("malformed parameter %q", field)
+				}
+				prop.Params = append(prop.Params, strings.ToUpper(kv[0])+"="+strings.ReplaceAll(kv[1], `"`, ""))
+			}
+			return prop, nil
+		}
+	}
+	return prop, fmt.Errorf("malformed content line %q", line)
+}
+
+// Param returns the value of the named parameter, or "" without it
+func (p Property) Param(name string) string {
+	name = strings.ToUpper(name) + "="
+	for _, param := range p.Params {
+		if strings.HasPrefix(param, name) {
+			return param[len(name):]
+		}
+	}
+	return ""
+}
+
+// Get returns the first property named name, or nil
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// GetText returns the unescaped value of the first property named name
+func (c *Component) GetText(name string) string {
+	if p := c.Get(name); p != nil {
+		return Unescape(p.Value)
+	}
+	return ""
+}
+
+// All returns every property named name
+func (c *Component) All(name string) []Property {
+	var props []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Children returns the nested components named name
+func (c *Component) Children(name string) []*Component {
+	var children []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Unescape reverses Text
+func Unescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// SplitText splits a list of TEXT values on the commas that are not
+// escaped and unescapes each one
+func SplitText(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, Unescape(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, Unescape(value[start:]))
+}
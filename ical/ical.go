@@ -0,0 +1,105 @@
+// Package ical reads and writes iCalendar data as described by RFC 5545:
+// components made of properties, with text escaped, lines folded at 75
+// octets and CRLF line endings.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Property is one content line. Params are NAME=VALUE pairs written as they
+// are, e.g. "VALUE=DATE"; Value must already be escaped where the value type
+// needs it, see Text.
+type Property struct {
+	Name   string
+	Params []string
+	Value  string
+}
+
+// Component is a BEGIN/END block such as VCALENDAR, VTODO or VEVENT
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// NewComponent returns an empty component named name
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Add appends a property with a raw value
+func (c *Component) Add(name, value string, params ...string) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// AddText appends a TEXT property, escaping the value
+func (c *Component) AddText(name, value string, params ...string) {
+	c.Add(name, Text(value), params...)
+}
+
+// AddComponent nests a component
+func (c *Component) AddComponent(child *Component) {
+	c.Components = append(c.Components, child)
+}
+
+// Text escapes a TEXT value: backslashes, semicolons, commas and newlines
+func Text(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(value)
+}
+
+// Date formats the calendar day of t as a DATE value
+func Date(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// DateTime formats t as a DATE-TIME value in UTC
+func DateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// Encode writes the component and everything below it to w
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		line := p.Name
+		for _, param := range p.Params {
+			line += ";" + param
+		}
+		writeLine(w, line+":"+p.Value)
+	}
+	for _, child := range c.Components {
+		child.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// writeLine folds a content line into lines of at most 75 octets, never
+// splitting a UTF-8 sequence; continuation lines start with a space
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the next line's length
+		limit = 74
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,282 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timezones turns DATE and DATE-TIME values into times. A TZID is looked up
+// in the tz database first, then in the calendar's own VTIMEZONE
+// definitions, which covers the Windows zone names some clients write.
+type Timezones struct {
+	locations map[string]*time.Location
+	defs      map[string][]observance
+	// Floating is the zone of times with neither a TZID nor a Z
+	Floating *time.Location
+}
+
+// observance is a STANDARD or DAYLIGHT part of a VTIMEZONE: from each onset
+// on, local time is offset seconds ahead of UTC (its TZOFFSETTO)
+type observance struct {
+	start    time.Time // first onset, in wall-clock time kept in UTC
+	offset   int
+	rdates   []time.Time
+	month    time.Month // of the yearly rule; 0 without one
+	weekday  time.Weekday
+	ordinal  int // nth weekday of the month, negative counting from its end
+	byDay    bool
+	monthDay []int
+	until    *time.Time
+}
+
+// NewTimezones reads the VTIMEZONE components of cal. floating may be nil
+// for UTC.
+func NewTimezones(cal *Component, floating *time.Location) *Timezones {
+	if floating == nil {
+		floating = time.UTC
+	}
+	tz := &Timezones{locations: map[string]*time.Location{}, defs: map[string][]observance{}, Floating: floating}
+	for _, vtz := range cal.Children("VTIMEZONE") {
+		id := vtz.GetText("TZID")
+		if id == "" {
+			continue
+		}
+		var observances []observance
+		for _, child := range vtz.Components {
+			if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+				continue
+			}
+			if o, ok := parseObservance(child); ok {
+				observances = append(observances, o)
+			}
+		}
+		if len(observances) > 0 {
+			tz.defs[id] = observances
+		}
+		// Prefer the tz database when the definition names its zone
+		if location, err := loadLocation(vtz.GetText("X-LIC-LOCATION")); err == nil {
+			tz.locations[id] = location
+		}
+	}
+	return tz
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+func parseObservance(c *Component) (observance, bool) {
+	var o observance
+	start := c.Get("DTSTART")
+	if start == nil {
+		return o, false
+	}
+	wall, err := time.Parse("20060102T150405", start.Value)
+	if err != nil {
+		return o, false
+	}
+	o.start = wall
+	offset, ok := parseOffset(c.GetText("TZOFFSETTO"))
+	if !ok {
+		return o, false
+	}
+	o.offset = offset
+	for _, p := range c.All("RDATE") {
+		for _, value := range strings.Split(p.Value, ",") {
+			if t, err := time.Parse("20060102T150405", value); err == nil {
+				o.rdates = append(o.rdates, t)
+			}
+		}
+	}
+	if rrule := c.Get("RRULE"); rrule != nil {
+		o.parseRule(rrule.Value)
+	}
+	return o, true
+}
+
+// parseRule reads the yearly rules VTIMEZONE uses, such as
+// FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU
+func (o *observance) parseRule(value string) {
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			if !strings.EqualFold(kv[1], "YEARLY") {
+				return
+			}
+		case "BYMONTH":
+			if n, err := strconv.Atoi(kv[1]); err == nil && n >= 1 && n <= 12 {
+				o.month = time.Month(n)
+			}
+		case "BYDAY":
+			day := strings.ToUpper(kv[1])
+			if len(day) < 2 {
+				continue
+			}
+			weekday, ok := weekdays[day[len(day)-2:]]
+			if !ok {
+				continue
+			}
+			o.weekday, o.byDay = weekday, true
+			if prefix := day[:len(day)-2]; prefix != "" {
+				o.ordinal, _ = strconv.Atoi(prefix)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(kv[1], ",") {
+				if n, err := strconv.Atoi(item); err == nil {
+					o.monthDay = append(o.monthDay, n)
+				}
+			}
+		case "UNTIL":
+			if t, err := time.Parse("20060102T150405Z", kv[1]); err == nil {
+				o.until = &t
+			}
+		}
+	}
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseOffset reads a UTC offset such as +0100, -0500 or +013000
+func parseOffset(value string) (int, bool) {
+	if len(value) != 5 && len(value) != 7 {
+		return 0, false
+	}
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, false
+	}
+	digits := value[1:] + "00"
+	h, err1 := strconv.Atoi(digits[0:2])
+	m, err2 := strconv.Atoi(digits[2:4])
+	s, err3 := strconv.Atoi(digits[4:6])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	return sign * (h*3600 + m*60 + s), true
+}
+
+// onset returns when the yearly rule starts the observance in year
+func (o *observance) onset(year int) (time.Time, bool) {
+	if o.month == 0 {
+		return time.Time{}, false
+	}
+	h, m, s := o.start.Clock()
+	at := func(day int) time.Time {
+		return time.Date(year, o.month, day, h, m, s, 0, time.UTC)
+	}
+	var t time.Time
+	switch {
+	case o.byDay && o.ordinal > 0:
+		t = at(1)
+		for t.Weekday() != o.weekday {
+			t = t.AddDate(0, 0, 1)
+		}
+		t = t.AddDate(0, 0, 7*(o.ordinal-1))
+	case o.byDay && o.ordinal < 0:
+		t = at(1).AddDate(0, 1, -1)
+		for t.Weekday() != o.weekday {
+			t = t.AddDate(0, 0, -1)
+		}
+		t = t.AddDate(0, 0, 7*(o.ordinal+1))
+	case len(o.monthDay) > 0:
+		found := false
+		for _, day := range o.monthDay {
+			t = at(day)
+			if !o.byDay || t.Weekday() == o.weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return time.Time{}, false
+		}
+	default:
+		t = at(o.start.Day())
+	}
+	if t.Month() != o.month || t.Before(o.start) || (o.until != nil && t.After(*o.until)) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// offsetAt returns the UTC offset of the definition at wall-clock time wall:
+// that of the observance with the latest onset not after it
+func offsetAt(observances []observance, wall time.Time) int {
+	var latest time.Time
+	offset := observances[0].offset
+	found := false
+	consider := func(onset time.Time, o observance) {
+		if onset.After(wall) || (found && !onset.After(latest)) {
+			return
+		}
+		latest, offset, found = onset, o.offset, true
+	}
+	for _, o := range observances {
+		consider(o.start, o)
+		for _, rdate := range o.rdates {
+			consider(rdate, o)
+		}
+		for _, year := range []int{wall.Year() - 1, wall.Year()} {
+			if onset, ok := o.onset(year); ok {
+				consider(onset, o)
+			}
+		}
+	}
+	return offset
+}
+
+// Time parses a DATE or DATE-TIME property. A DATE comes back as midnight
+// UTC with dateOnly set.
+func (tz *Timezones) Time(p *Property) (t time.Time, dateOnly bool, err error) {
+	value := strings.TrimSpace(p.Value)
+	if strings.EqualFold(p.Param("VALUE"), "DATE") || len(value) == 8 {
+		t, err = time.Parse("20060102", value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	wall, err := time.Parse("20060102T150405", value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	id := p.Param("TZID")
+	if id == "" {
+		return inLocation(wall, tz.Floating), false, nil
+	}
+	if location, err := loadLocation(id); err == nil {
+		return inLocation(wall, location), false, nil
+	}
+	if location, ok := tz.locations[id]; ok {
+		return inLocation(wall, location), false, nil
+	}
+	observances, ok := tz.defs[id]
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("unknown TZID %q", id)
+	}
+	return wall.Add(-time.Duration(offsetAt(observances, wall)) * time.Second), false, nil
+}
+
+// inLocation reads the clock of wall, kept in UTC, as time in location
+func inLocation(wall time.Time, location *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, location)
+}
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"log"
 	"time"
+	"todo-backend/caldav"
 	"todo-backend/config"
 	"todo-backend/controllers"
 	"todo-backend/middleware"
+	"todo-backend/notify"
+	"todo-backend/quickadd"
 	"todo-backend/repositories"
 	"todo-backend/services"
+	"todo-backend/storage"
 	"todo-backend/utils"
 
 	"github.com/gin-contrib/cors"
@@ -26,6 +30,21 @@ func main() {
 	// Set JWT secret
 	utils.SetJWTSecret(cfg.JWTSecret)
 
+	// Register extra quick-add locales
+	if cfg.QuickAddLocales != "" {
+		if n, err := quickadd.LoadLocaleFile(cfg.QuickAddLocales); err != nil {
+			log.Printf("Warning: failed to load quick-add locales: %v", err)
+		} else {
+			log.Printf("Loaded %d quick-add locales from %s", n, cfg.QuickAddLocales)
+		}
+	}
+
+	// Open attachment storage
+	attachmentStore, err := storage.Open(cfg.AttachmentStorage)
+	if err != nil {
+		log.Fatal("Failed to open attachment storage:", err)
+	}
+
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(config.DB)
 	taskRepo := repositories.NewTaskRepository(config.DB)
@@ -33,19 +52,61 @@ func main() {
 	logRepo := repositories.NewLogRepository(config.DB)
 	pomodoroRepo := repositories.NewPomodoroRepository(config.DB)
 	goalRepo := repositories.NewGoalRepository(config.DB)
+	tagRepo := repositories.NewTagRepository(config.DB)
+	trashRepo := repositories.NewTrashRepository(config.DB)
+	revisionRepo := repositories.NewRevisionRepository(config.DB)
+	timeEntryRepo := repositories.NewTimeEntryRepository(config.DB)
+	templateRepo := repositories.NewTemplateRepository(config.DB)
+	projectRepo := repositories.NewProjectRepository(config.DB)
+	attachmentRepo := repositories.NewAttachmentRepository(config.DB)
+	commentRepo := repositories.NewCommentRepository(config.DB)
+	reminderRepo := repositories.NewReminderRepository(config.DB)
+	notificationRepo := repositories.NewNotificationRepository(config.DB)
+	plannerRepo := repositories.NewPlannerRepository(config.DB)
+	calendarFeedRepo := repositories.NewCalendarFeedRepository(config.DB)
+	appPasswordRepo := repositories.NewAppPasswordRepository(config.DB)
+	boardRepo := repositories.NewBoardRepository(config.DB)
 
 	// Initialize services
 	authService := services.NewAuthService(userRepo, logRepo)
-	taskService := services.NewTaskService(taskRepo, logRepo)
-	habitService := services.NewHabitService(habitRepo, logRepo, pomodoroRepo, goalRepo)
+	taskService := services.NewTaskService(taskRepo, logRepo, tagRepo, revisionRepo, projectRepo, cfg.SubtaskPolicy, cfg.BlockedCompletion, cfg.Matrix)
+	habitService := services.NewHabitService(habitRepo, logRepo, pomodoroRepo, goalRepo, revisionRepo, taskRepo)
 	logService := services.NewLogService(logRepo)
+	attachmentService := services.NewAttachmentService(attachmentRepo, taskRepo, logRepo, attachmentStore, cfg.AttachmentLimits)
+	trashService := services.NewTrashService(trashRepo, logRepo, attachmentService, cfg.TrashRetentionDays)
+	timeService := services.NewTimeTrackingService(timeEntryRepo, taskRepo, logRepo)
+	templateService := services.NewTemplateService(templateRepo, taskRepo, logRepo, revisionRepo)
+	projectService := services.NewProjectService(projectRepo, taskRepo, logRepo, revisionRepo)
+	boardService := services.NewBoardService(boardRepo, taskRepo, projectRepo, taskService, logRepo)
+	commentService := services.NewCommentService(commentRepo, taskRepo, logRepo)
+	reminderService := services.NewReminderService(reminderRepo, notificationRepo, taskRepo, habitRepo, logRepo, notify.Open(cfg.Notifiers)...)
+	plannerService := services.NewPlannerService(plannerRepo, taskRepo, logRepo)
+	calendarFeedService := services.NewCalendarFeedService(calendarFeedRepo, taskRepo, goalRepo, logRepo)
+	appPasswordService := services.NewAppPasswordService(appPasswordRepo, logRepo)
+	calDAVService := services.NewCalDAVService(taskService, taskRepo)
 
 	// Initialize controllers
 	authController := controllers.NewAuthController(authService)
-	taskController := controllers.NewTaskController(taskService)
+	taskController := controllers.NewTaskController(taskService, plannerService)
 	habitController := controllers.NewHabitController(habitService)
 	logController := controllers.NewLogController(logService)
-	analyticsController := controllers.NewAnalyticsController(authService, taskService, habitService)
+	analyticsController := controllers.NewAnalyticsController(authService, taskService, habitService, timeService)
+	trashController := controllers.NewTrashController(trashService)
+	timeController := controllers.NewTimeTrackingController(timeService)
+	templateController := controllers.NewTemplateController(templateService)
+	projectController := controllers.NewProjectController(projectService)
+	boardController := controllers.NewBoardController(boardService)
+	attachmentController := controllers.NewAttachmentController(attachmentService, cfg.AttachmentLimits)
+	commentController := controllers.NewCommentController(commentService)
+	reminderController := controllers.NewReminderController(reminderService)
+	plannerController := controllers.NewPlannerController(plannerService)
+	calendarController := controllers.NewCalendarFeedController(calendarFeedService)
+	appPasswordController := controllers.NewAppPasswordController(appPasswordService)
+	calDAVController := controllers.NewCalDAVController(calDAVService)
+
+	// Purge items that outlived the trash retention period
+	go trashService.RunPurger(time.Hour)
+	go reminderService.RunScheduler(time.Duration(cfg.ReminderPollSeconds) * time.Second)
 
 	// Initialize Gin router
 	gin.SetMode(gin.ReleaseMode) // Set to release mode for production
@@ -94,6 +155,9 @@ func main() {
 		public.POST("/register", authController.Register)
 		public.POST("/login", authController.Login)
 		public.GET("/check-username", authController.CheckUsername)
+
+		// Calendar subscriptions authenticate with the feed token in the URL
+		public.GET("/calendar/feed/:token", calendarController.Feed)
 	}
 
 	// Protected routes (authentication required)
@@ -127,13 +191,38 @@ func main() {
 		protected.GET("/tasks/overdue", taskController.GetOverdueTasks)
 		protected.GET("/tasks/search", taskController.SearchTasks)
 		protected.PATCH("/tasks/:id/status", taskController.UpdateTaskStatus)
+		protected.GET("/tasks/:id/status-history", taskController.GetTaskStatusHistory)
 		protected.POST("/tasks/:id/duplicate", taskController.DuplicateTask)
+		protected.POST("/tasks/bulk", taskController.BulkUpdateTasks)
+		protected.POST("/tasks/quick", taskController.QuickAddTask)
 
 		// Calendar & Views
 		protected.GET("/tasks/calendar/day/:date", taskController.GetTasksByDate)
 		protected.GET("/tasks/calendar/week/:date", taskController.GetTasksForWeek)
 		protected.GET("/tasks/calendar/month/:date", taskController.GetTasksForMonth)
 		protected.PATCH("/tasks/:id/reschedule", taskController.RescheduleTask)
+		protected.GET("/tasks/:id/reschedules", taskController.GetTaskReschedules)
+		protected.GET("/tasks/:id/revisions", taskController.GetTaskRevisions)
+		protected.POST("/tasks/:id/revisions/:rev/restore", taskController.RestoreTaskRevision)
+		protected.GET("/tasks/matrix", taskController.GetTaskMatrix)
+		protected.PUT("/tasks/:id/importance", taskController.SetTaskImportance)
+
+		// Dependencies
+		protected.GET("/tasks/:id/blockers", taskController.GetTaskBlockers)
+		protected.POST("/tasks/:id/blockers", taskController.AddTaskBlocker)
+		protected.DELETE("/tasks/:id/blockers/:blockerId", taskController.RemoveTaskBlocker)
+		protected.GET("/tasks/:id/dependents", taskController.GetTaskDependents)
+		protected.GET("/tasks/dependencies/graph", taskController.GetDependencyGraph)
+
+		// Recurrence
+		protected.GET("/tasks/:id/occurrences", taskController.GetTaskOccurrences)
+		protected.POST("/tasks/recurrence/preview", taskController.PreviewRecurrence)
+
+		// Tags
+		protected.GET("/tags", taskController.GetTags)
+		protected.PATCH("/tags/:id", taskController.RenameTag)
+		protected.DELETE("/tags/:id", taskController.DeleteTag)
+		protected.POST("/tags/merge", taskController.MergeTags)
 
 		// Dashboard & Summary
 		protected.GET("/dashboard/summary", taskController.GetDashboardSummary)
@@ -150,6 +239,8 @@ func main() {
 		// Habit specific actions
 		protected.PATCH("/habits/:id/track", habitController.TrackHabit)
 		protected.PATCH("/habits/:id/achieve", habitController.MarkHabitAchieved)
+		protected.GET("/habits/:id/revisions", habitController.GetHabitRevisions)
+		protected.POST("/habits/:id/revisions/:rev/restore", habitController.RestoreHabitRevision)
 
 		// Export/Import routes
 		protected.GET("/tasks/export", taskController.ExportTasks)
@@ -167,6 +258,7 @@ func main() {
 		protected.GET("/analytics/performance/monthly", analyticsController.GetMonthlyPerformance)
 		protected.GET("/analytics/time-allocation", analyticsController.GetTimeAllocation)
 		protected.GET("/analytics/productivity-trends", analyticsController.GetProductivityTrends)
+		protected.GET("/analytics/postponements", analyticsController.GetPostponements)
 
 		// Focus Tools routes
 		protected.POST("/focus/pomodoro/start", habitController.StartPomodoroSession)
@@ -180,10 +272,111 @@ func main() {
 		protected.PATCH("/goals/:id", habitController.UpdateGoal)
 		protected.DELETE("/goals/:id", habitController.DeleteGoal)
 		protected.PATCH("/goals/:id/progress", habitController.UpdateGoalProgress)
+		protected.GET("/goals/:id/revisions", habitController.GetGoalRevisions)
+		protected.POST("/goals/:id/revisions/:rev/restore", habitController.RestoreGoalRevision)
 
 		// Habit Streaks & Consistency
 		protected.GET("/habits/:id/streak", habitController.GetHabitStreak)
 		protected.GET("/habits/consistency-report", habitController.GetHabitsConsistencyReport)
+
+		// Trash
+		protected.GET("/trash", trashController.GetTrash)
+		protected.DELETE("/trash", trashController.EmptyTrash)
+		protected.POST("/trash/:type/:id/restore", trashController.RestoreItem)
+		protected.DELETE("/trash/:type/:id", trashController.PurgeItem)
+
+		// Time Tracking
+		protected.POST("/tasks/:id/timer/start", timeController.StartTimer)
+		protected.POST("/tasks/:id/timer/stop", timeController.StopTimer)
+		protected.GET("/timer", timeController.GetRunningTimer)
+		protected.GET("/tasks/:id/time", timeController.GetTaskTime)
+		protected.POST("/tasks/:id/time-entries", timeController.AddTimeEntry)
+		protected.PATCH("/time-entries/:id", timeController.UpdateTimeEntry)
+		protected.DELETE("/time-entries/:id", timeController.DeleteTimeEntry)
+
+		// Task Templates
+		protected.GET("/templates", templateController.GetTemplates)
+		protected.POST("/templates", templateController.CreateTemplate)
+		protected.POST("/templates/from-tasks", templateController.CreateTemplateFromTasks)
+		protected.GET("/templates/:id", templateController.GetTemplate)
+		protected.PATCH("/templates/:id", templateController.UpdateTemplate)
+		protected.DELETE("/templates/:id", templateController.DeleteTemplate)
+		protected.POST("/templates/:id/instantiate", templateController.InstantiateTemplate)
+
+		// Projects
+		protected.GET("/projects", projectController.GetProjects)
+		protected.POST("/projects", projectController.CreateProject)
+		protected.GET("/projects/:id", projectController.GetProject)
+		protected.PATCH("/projects/:id", projectController.UpdateProject)
+		protected.DELETE("/projects/:id", projectController.DeleteProject)
+		protected.GET("/projects/:id/tasks", projectController.GetProjectTasks)
+		protected.PATCH("/tasks/:id/move", projectController.MoveTask)
+
+		// Kanban boards
+		protected.GET("/boards", boardController.GetBoards)
+		protected.POST("/boards", boardController.CreateBoard)
+		protected.GET("/boards/:id", boardController.GetBoard)
+		protected.PATCH("/boards/:id", boardController.UpdateBoard)
+		protected.DELETE("/boards/:id", boardController.DeleteBoard)
+		protected.POST("/boards/:id/cards/:task_id/move", boardController.MoveCard)
+
+		// Attachments
+		protected.GET("/tasks/:id/attachments", attachmentController.GetTaskAttachments)
+		protected.POST("/tasks/:id/attachments", attachmentController.UploadAttachment)
+		protected.GET("/tasks/:id/attachments/:attachment_id", attachmentController.DownloadAttachment)
+		protected.DELETE("/tasks/:id/attachments/:attachment_id", attachmentController.DeleteAttachment)
+
+		// Comments
+		protected.GET("/tasks/:id/comments", commentController.GetTaskComments)
+		protected.POST("/tasks/:id/comments", commentController.AddComment)
+		protected.PATCH("/tasks/:id/comments/:comment_id", commentController.UpdateComment)
+		protected.DELETE("/tasks/:id/comments/:comment_id", commentController.DeleteComment)
+
+		// Reminders
+		protected.GET("/reminders", reminderController.GetReminders)
+		protected.DELETE("/reminders/:id", reminderController.DeleteReminder)
+		protected.POST("/reminders/:id/snooze", reminderController.SnoozeReminder)
+		protected.POST("/reminders/:id/dismiss", reminderController.DismissReminder)
+		protected.GET("/tasks/:id/reminders", reminderController.GetTaskReminders)
+		protected.POST("/tasks/:id/reminders", reminderController.CreateTaskReminder)
+		protected.GET("/habits/:id/reminders", reminderController.GetHabitReminders)
+		protected.POST("/habits/:id/reminders", reminderController.CreateHabitReminder)
+
+		// Notifications
+		protected.GET("/notifications", reminderController.GetNotifications)
+		protected.POST("/notifications/read-all", reminderController.MarkAllNotificationsRead)
+		protected.POST("/notifications/:id/read", reminderController.MarkNotificationRead)
+
+		// Planner
+		protected.GET("/planner/working-hours", plannerController.GetWorkingHours)
+		protected.PUT("/planner/working-hours", plannerController.SetWorkingHours)
+		protected.GET("/planner/blocks", plannerController.GetTimeBlocks)
+		protected.POST("/planner/blocks", plannerController.CreateTimeBlock)
+		protected.DELETE("/planner/blocks/:id", plannerController.DeleteTimeBlock)
+		protected.POST("/planner/plan", plannerController.PlanDay)
+		protected.POST("/planner/plan/accept", plannerController.AcceptPlan)
+
+		// Calendar feeds
+		protected.GET("/calendar/feeds", calendarController.GetFeeds)
+		protected.POST("/calendar/feeds", calendarController.CreateFeed)
+		protected.DELETE("/calendar/feeds/:id", calendarController.RevokeFeed)
+
+		// App passwords
+		protected.GET("/user/app-passwords", appPasswordController.GetAppPasswords)
+		protected.POST("/user/app-passwords", appPasswordController.CreateAppPassword)
+		protected.DELETE("/user/app-passwords/:id", appPasswordController.RevokeAppPassword)
+	}
+
+	// CalDAV task sync; clients sign in with an app password, not a JWT
+	r.GET("/.well-known/caldav", calDAVController.WellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", calDAVController.WellKnown)
+	r.OPTIONS("/caldav/*path", calDAVController.Options)
+	calDAV := r.Group("/caldav")
+	calDAV.Use(middleware.AppPasswordMiddleware(appPasswordService.Authenticate))
+	{
+		for _, method := range caldav.Methods {
+			calDAV.Handle(method, "/*path", calDAVController.Serve)
+		}
 	}
 
 	// Health check endpoint
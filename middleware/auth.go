@@ -50,6 +50,38 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AppPasswordMiddleware signs clients that cannot use a JWT, such as CalDAV
+// apps, in with an app password: as the password of HTTP Basic
+// authentication or as a bearer token. authenticate returns the user's ID
+// and name.
+func AppPasswordMiddleware(authenticate func(username, password string) (int64, string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			tokenParts := strings.Split(c.GetHeader("Authorization"), " ")
+			if len(tokenParts) == 2 && tokenParts[0] == "Bearer" {
+				username, password, ok = "", tokenParts[1], true
+			}
+		}
+
+		var userID int64
+		var err error
+		if ok {
+			userID, username, err = authenticate(username, password)
+		}
+		if !ok || err != nil {
+			c.Header("WWW-Authenticate", `Basic realm="todo-backend", charset="UTF-8"`)
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", userID)
+		c.Set("username", username)
+		c.Next()
+	}
+}
+
 // GetUserID extracts user ID from gin context
 func GetUserID(c *gin.Context) (int64, bool) {
 	userID, exists := c.Get("user_id")
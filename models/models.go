@@ -100,6 +100,24 @@ type Task struct {
 	IsRecurring        bool        `json:"is_recurring" db:"is_recurring"`
 	RecurringFrequency *string     `json:"recurring_frequency" db:"recurring_frequency"`
 	CreatedAt          time.Time   `json:"created_at" db:"created_at"`
+	RecurrenceParentID *int64      `json:"recurrence_parent_id" db:"recurrence_parent_id"` // first occurrence of the series
+	RecurrenceIndex    int32       `json:"recurrence_index" db:"recurrence_index"`         // 1-based position in the series
+	Status             TaskStatus  `json:"status" db:"status"`
+	ParentID           *int64      `json:"parent_id" db:"parent_id"`
+	PostponeCount      int32       `json:"postpone_count" db:"postpone_count"` // reschedules that moved the due date later
+	EstimateMinutes    *int32      `json:"estimate_minutes" db:"estimate_minutes"`
+	ActualMinutes      int64       `json:"actual_minutes" db:"actual_minutes"` // time entries plus completed pomodoros
+	ProjectID          *int64      `json:"project_id" db:"project_id"`
+	ProjectName        *string     `json:"project_name" db:"project_name"`
+	Position           *string     `json:"position" db:"position"` // order within the project
+	CommentCount       int64       `json:"comment_count" db:"comment_count"`
+	IsImportant        *bool       `json:"is_important" db:"is_important"` // nil follows the priority
+	Tags               []string    `json:"tags" db:"tags"`
+
+	// Attachments is only filled in by the JSON export
+	Attachments []*TaskAttachment `json:"attachments,omitempty"`
+	// ICalUID is only filled in by the ICS import, to match a re-import
+	ICalUID string `json:"-"`
 }
 
 type Habit struct {
@@ -180,6 +198,11 @@ type CreateTaskRequest struct {
 	DueDate            *CustomTime `json:"due_date"`
 	IsRecurring        bool        `json:"is_recurring"`
 	RecurringFrequency *string     `json:"recurring_frequency"`
+	ParentID           *int64      `json:"parent_id"`
+	Tags               []string    `json:"tags"`
+	EstimateMinutes    *int32      `json:"estimate_minutes" binding:"omitempty,min=1"`
+	ProjectID          *int64      `json:"project_id"` // the task is added at the end of the project
+	IsImportant        *bool       `json:"is_important"`
 }
 
 type UpdateTaskRequest struct {
@@ -191,11 +214,39 @@ type UpdateTaskRequest struct {
 	IsCompleted        *bool       `json:"is_completed"`
 	IsRecurring        *bool       `json:"is_recurring"`
 	RecurringFrequency *string     `json:"recurring_frequency"`
+	Status             *TaskStatus `json:"status" binding:"omitempty,oneof=pending in_progress completed cancelled"`
+	ParentID           *int64      `json:"parent_id"`                                  // 0 moves the task back to the top level
+	Tags               *[]string   `json:"tags"`                                       // replaces the task's tags; [] removes them all
+	EstimateMinutes    *int32      `json:"estimate_minutes" binding:"omitempty,min=0"` // 0 removes the estimate
+	IsImportant        *bool       `json:"is_important"`                               // PUT /tasks/:id/importance also clears it
 }
 
 type TaskResponse struct {
-	Tasks []*Task `json:"tasks"`
-	Total int     `json:"total"`
+	Tasks      []*Task    `json:"tasks"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+	Links      *PageLinks `json:"links,omitempty"`
+}
+
+// CursorRequest holds the keyset pagination parameters of a list endpoint
+type CursorRequest struct {
+	Sort   string // e.g. "due_date,-priority"; empty uses the endpoint's default order
+	Cursor string // next_cursor or prev_cursor of a previous page
+	Limit  int
+}
+
+// PageLinks are the URLs of the neighbouring pages of a cursor-paginated list
+type PageLinks struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// CursorPage describes where a cursor-paginated page sits in the full list
+type CursorPage struct {
+	Total      int64
+	NextCursor string
+	PrevCursor string
 }
 
 // Habit request/response models
@@ -214,11 +265,11 @@ type UpdateHabitRequest struct {
 
 // Export/Import models
 type ExportRequest struct {
-	Format string `json:"format" binding:"required,oneof=json csv"`
+	Format string `json:"format" binding:"required,oneof=json csv ics"`
 }
 
 type ImportRequest struct {
-	Format string `json:"format" binding:"required,oneof=json csv"`
+	Format string `json:"format" binding:"required,oneof=json csv ics"`
 	Data   string `json:"data" binding:"required"`
 }
 
@@ -264,11 +315,14 @@ type PerformanceReport struct {
 	ProductivityScore float64 `json:"productivity_score"`
 }
 
+// TimeAllocation is the time spent on the tasks of one category
 type TimeAllocation struct {
-	Category   string  `json:"category"`
-	Priority   int16   `json:"priority"`
-	TaskCount  int64   `json:"task_count"`
-	Percentage float64 `json:"percentage"`
+	Category        string  `json:"category"`
+	TaskCount       int64   `json:"task_count"`
+	TrackedMinutes  int64   `json:"tracked_minutes"`
+	PomodoroMinutes int64   `json:"pomodoro_minutes"`
+	TotalMinutes    int64   `json:"total_minutes"`
+	Percentage      float64 `json:"percentage"`
 }
 
 type HabitStreak struct {
@@ -319,10 +373,36 @@ const (
 	TaskStatusCancelled  TaskStatus = "cancelled"
 )
 
+// IsValid reports whether s is one of the known task statuses
+func (s TaskStatus) IsValid() bool {
+	switch s {
+	case TaskStatusPending, TaskStatusInProgress, TaskStatusCompleted, TaskStatusCancelled:
+		return true
+	}
+	return false
+}
+
 type UpdateTaskStatusRequest struct {
 	Status TaskStatus `json:"status" binding:"required,oneof=pending in_progress completed cancelled"`
 }
 
+type TaskStatusHistory struct {
+	ID         int64      `json:"id" db:"id"`
+	TaskID     int64      `json:"task_id" db:"task_id"`
+	UserID     int64      `json:"user_id" db:"user_id"`
+	FromStatus TaskStatus `json:"from_status" db:"from_status"`
+	ToStatus   TaskStatus `json:"to_status" db:"to_status"`
+	ChangedAt  time.Time  `json:"changed_at" db:"changed_at"`
+}
+
+// TaskStatusResult is returned by status changes; completing a recurring
+// task also reports the occurrence generated to replace it
+type TaskStatusResult struct {
+	Task           *Task    `json:"task"`
+	NextOccurrence *Task    `json:"next_occurrence"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
 type RescheduleTaskRequest struct {
 	NewDueDate CustomTime `json:"new_due_date" binding:"required"`
 	Reason     *string    `json:"reason"`
@@ -333,8 +413,20 @@ type SearchTasksRequest struct {
 	Category    *string    `json:"category"`
 	Priority    *int16     `json:"priority"`
 	Status      *string    `json:"status"`
-	DueDateFrom *time.Time `json:"due_date_from"`
-	DueDateTo   *time.Time `json:"due_date_to"`
+	DueDateFrom *time.Time `json:"due_date_from"` // inclusive
+	DueDateTo   *time.Time `json:"due_date_to"`   // exclusive
+	Page        int        `json:"page"`
+	PageSize    int        `json:"page_size"`
+}
+
+// TaskSearchResult is a task matched by full-text search. Highlight is the
+// task name and Snippet an excerpt of the description, both as HTML: the
+// text is escaped and the matched terms are wrapped in <mark> tags.
+type TaskSearchResult struct {
+	*Task
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+	Snippet   *string `json:"snippet"`
 }
 
 // Dashboard Models
@@ -348,13 +440,15 @@ type DashboardSummary struct {
 }
 
 type UpcomingTask struct {
-	ID       int64       `json:"id"`
-	TaskName string      `json:"task_name"`
-	Category *string     `json:"category"`
-	Priority int16       `json:"priority"`
-	DueDate  *CustomTime `json:"due_date"`
-	DaysLeft int         `json:"days_left"`
-	IsUrgent bool        `json:"is_urgent"`
+	ID        int64       `json:"id"`
+	TaskName  string      `json:"task_name"`
+	Category  *string     `json:"category"`
+	Priority  int16       `json:"priority"`
+	DueDate   *CustomTime `json:"due_date"`
+	DaysLeft  int         `json:"days_left"`
+	IsUrgent  bool        `json:"is_urgent"`
+	IsBlocked bool        `json:"is_blocked"`
+	BlockedBy []int64     `json:"blocked_by,omitempty"` // open tasks this one is waiting on
 }
 
 type RecentActivity struct {
@@ -366,7 +460,835 @@ type RecentActivity struct {
 }
 
 type CalendarView struct {
-	Date  string  `json:"date"`
+	Date   string       `json:"date"`
+	Tasks  []*Task      `json:"tasks"`
+	Count  int          `json:"count"`
+	Blocks []*TimeBlock `json:"blocks,omitempty"` // time blocked out that day, see the planner
+}
+
+// Recurrence Models
+type PreviewRecurrenceRequest struct {
+	Rule  string     `json:"rule" binding:"required"`
+	Start CustomTime `json:"start"`
+	Count int        `json:"count"`
+}
+
+type RecurrencePreview struct {
+	Rule        string      `json:"rule"`
+	From        *CustomTime `json:"from"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// Subtask Models
+const (
+	SubtaskCascade = "cascade" // complete/delete children along with the parent
+	SubtaskBlock   = "block"   // refuse to complete a parent while children are open
+	SubtaskIgnore  = "ignore"  // leave children untouched when the parent is completed
+	SubtaskPromote = "promote" // on delete, move children up to the deleted task's parent
+	SubtaskDeep    = "deep"    // duplicate the whole subtree
+	SubtaskShallow = "shallow" // duplicate only the task itself
+)
+
+// SubtaskPolicy decides what happens to a task's children when the task is
+// completed, deleted or duplicated
+type SubtaskPolicy struct {
+	OnComplete  string `json:"on_complete"`  // cascade, block or ignore
+	OnDelete    string `json:"on_delete"`    // cascade or promote
+	OnDuplicate string `json:"on_duplicate"` // deep or shallow
+}
+
+var DefaultSubtaskPolicy = SubtaskPolicy{
+	OnComplete:  SubtaskCascade,
+	OnDelete:    SubtaskCascade,
+	OnDuplicate: SubtaskDeep,
+}
+
+// TaskTree is a task together with its nested subtasks. Progress is the
+// percentage of work done: leaves count as 0 or 100, parents average their
+// non-cancelled children.
+type TaskTree struct {
+	*Task
+	Progress              float64     `json:"progress"`
+	SubtaskCount          int         `json:"subtask_count"`
+	CompletedSubtaskCount int         `json:"completed_subtask_count"`
+	Subtasks              []*TaskTree `json:"subtasks"`
+}
+
+// Dependency Models
+const (
+	BlockedCompletionWarn   = "warn"   // complete anyway and report the open blockers
+	BlockedCompletionRefuse = "refuse" // reject completing a task with open blockers
+)
+
+// TaskDependency says that TaskID cannot be finished before BlockedByID
+type TaskDependency struct {
+	TaskID      int64     `json:"task_id" db:"task_id"`
+	BlockedByID int64     `json:"blocked_by_id" db:"blocked_by_task_id"`
+	UserID      int64     `json:"user_id" db:"user_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type AddDependencyRequest struct {
+	BlockedByID int64 `json:"blocked_by_id" binding:"required"`
+}
+
+// DependencyGraph is the user's dependency graph. Edges point from the
+// blocking task to the task it blocks; Level is the length of the longest
+// chain of blockers in front of a node, so it can be used as a layout column.
+type DependencyGraph struct {
+	Nodes []*DependencyNode `json:"nodes"`
+	Edges []*DependencyEdge `json:"edges"`
+}
+
+type DependencyNode struct {
+	ID        int64       `json:"id"`
+	TaskName  string      `json:"task_name"`
+	Status    TaskStatus  `json:"status"`
+	DueDate   *CustomTime `json:"due_date"`
+	IsBlocked bool        `json:"is_blocked"`
+	Level     int         `json:"level"`
+}
+
+type DependencyEdge struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// Tag Models
+type Tag struct {
+	ID         int64     `json:"id" db:"id"`
+	UserID     int64     `json:"user_id" db:"user_id"`
+	Name       string    `json:"name" db:"name"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UsageCount int       `json:"usage_count"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagsRequest moves every task tagged with one of SourceIDs onto
+// TargetID and deletes the source tags
+type MergeTagsRequest struct {
+	SourceIDs []int64 `json:"source_ids" binding:"required,min=1"`
+	TargetID  int64   `json:"target_id" binding:"required"`
+}
+
+// Bulk Task Models
+type BulkOperation string
+
+const (
+	BulkComplete    BulkOperation = "complete"
+	BulkUncomplete  BulkOperation = "uncomplete"
+	BulkDelete      BulkOperation = "delete"
+	BulkSetCategory BulkOperation = "set_category"
+	BulkSetPriority BulkOperation = "set_priority"
+	BulkReschedule  BulkOperation = "reschedule"
+)
+
+// BulkTaskRequest applies one operation to the tasks listed in TaskIDs or to
+// every task matching Filter (a filter expression, see GET /tasks?filter=)
+type BulkTaskRequest struct {
+	Operation BulkOperation `json:"operation" binding:"required,oneof=complete uncomplete delete set_category set_priority reschedule"`
+	TaskIDs   []int64       `json:"task_ids"`
+	Filter    string        `json:"filter"`
+	Category  *string       `json:"category"` // set_category; null or "" clears the category
+	Priority  *int16        `json:"priority"` // set_priority
+	Offset    string        `json:"offset"`   // reschedule, e.g. "+2d", "-1w", "90m"
+	Reason    *string       `json:"reason"`   // reschedule, stored in the reschedule history
+}
+
+// BulkItemResult is the outcome for one task: "updated", "unchanged" (the
+// operation had nothing to do) or "failed"
+type BulkItemResult struct {
+	TaskID  int64  `json:"task_id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Task    *Task  `json:"task,omitempty"`
+}
+
+// BulkTaskResult reports a bulk operation. When any item fails nothing is
+// applied and Applied is false.
+type BulkTaskResult struct {
+	Operation       BulkOperation     `json:"operation"`
+	Applied         bool              `json:"applied"`
+	Matched         int               `json:"matched"`
+	Updated         int               `json:"updated"`
+	Failed          int               `json:"failed"`
+	Results         []*BulkItemResult `json:"results"`
+	NextOccurrences []*Task           `json:"next_occurrences,omitempty"`
+	Warnings        []string          `json:"warnings,omitempty"`
+}
+
+// BulkTaskChange is one validated change of a bulk operation. Only the
+// fields used by the operation are set.
+type BulkTaskChange struct {
+	TaskID     int64
+	FromStatus TaskStatus
+	ToStatus   TaskStatus
+	Category   *string
+	Priority   int16
+	DueDate    *CustomTime
+	Reason     *string
+}
+
+// Reschedule Models
+type TaskReschedule struct {
+	ID            int64       `json:"id" db:"id"`
+	TaskID        int64       `json:"task_id" db:"task_id"`
+	UserID        int64       `json:"user_id" db:"user_id"`
+	OldDueDate    *CustomTime `json:"old_due_date" db:"old_due_date"`
+	NewDueDate    CustomTime  `json:"new_due_date" db:"new_due_date"`
+	Reason        *string     `json:"reason" db:"reason"`
+	RescheduledAt time.Time   `json:"rescheduled_at" db:"rescheduled_at"`
+}
+
+// PostponedTask is a task that keeps being pushed back
+type PostponedTask struct {
+	TaskID        int64       `json:"task_id"`
+	TaskName      string      `json:"task_name"`
+	Category      *string     `json:"category"`
+	Status        TaskStatus  `json:"status"`
+	DueDate       *CustomTime `json:"due_date"`
+	PostponeCount int32       `json:"postpone_count"`
+	TotalSlipDays float64     `json:"total_slip_days"` // how far the due date moved in total
+	LastReason    *string     `json:"last_reason"`
+}
+
+// CategorySlip summarises the reschedules of one category. Slip is the
+// distance between the old and new due date; pull-ins count as negative.
+type CategorySlip struct {
+	Category        string  `json:"category"`
+	Reschedules     int64   `json:"reschedules"`
+	Postponements   int64   `json:"postponements"`
+	TasksAffected   int64   `json:"tasks_affected"`
+	AverageSlipDays float64 `json:"average_slip_days"`
+}
+
+type PostponementReport struct {
+	Since                time.Time        `json:"since"`
+	MinPostponements     int              `json:"min_postponements"`
+	ChronicallyPostponed []*PostponedTask `json:"chronically_postponed"`
+	Categories           []*CategorySlip  `json:"categories"`
+}
+
+// Trash Models
+const (
+	TrashTask  = "task"
+	TrashHabit = "habit"
+	TrashGoal  = "goal"
+)
+
+// TrashItem is a soft-deleted task, habit or goal. Subtasks trashed along
+// with a task are not listed separately; they are restored with it.
+type TrashItem struct {
+	Type         string     `json:"type"`
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	DeletedAt    time.Time  `json:"deleted_at"`
+	PurgeAt      *time.Time `json:"purge_at"` // nil when automatic purging is off
+	SubtaskCount int        `json:"subtask_count,omitempty"`
+}
+
+// Revision Models
+const (
+	RevisionTask  = "task"
+	RevisionHabit = "habit"
+	RevisionGoal  = "goal"
+)
+
+// Revision actions. A baseline revision holds the state of an entity that
+// existed before revisions were recorded, taken just before its first change.
+const (
+	RevisionBaseline = "baseline"
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionDelete   = "delete"
+	RevisionRestore  = "restore"
+)
+
+// Revision is the state of a task, habit or goal after one change. Changes
+// lists the fields that differ from the previous revision.
+type Revision struct {
+	ID           int64           `json:"id"`
+	EntityType   string          `json:"entity_type"`
+	EntityID     int64           `json:"entity_id"`
+	UserID       int64           `json:"user_id"`
+	Revision     int             `json:"revision"`
+	Action       string          `json:"action"`
+	Snapshot     json.RawMessage `json:"snapshot"`
+	RestoredFrom *int            `json:"restored_from,omitempty"` // revision a restore went back to; nil for the trash
+	CreatedAt    time.Time       `json:"created_at"`
+	Changes      []FieldChange   `json:"changes"`
+}
+
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
+
+// Time Tracking Models
+const (
+	TimeEntryTimer  = "timer"
+	TimeEntryManual = "manual"
+)
+
+// TimeEntry is a span of time spent on a task. EndedAt is nil while the
+// entry is a running timer; Minutes then counts up to now.
+type TimeEntry struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	TaskID    int64      `json:"task_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Minutes   int64      `json:"minutes"`
+	Note      *string    `json:"note"`
+	Source    string     `json:"source"` // timer or manual
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type StartTimerRequest struct {
+	Note *string `json:"note"`
+}
+
+// CreateTimeEntryRequest records time spent after the fact. The end is given
+// either as ended_at or as a duration in minutes.
+type CreateTimeEntryRequest struct {
+	StartedAt CustomTime  `json:"started_at" binding:"required"`
+	EndedAt   *CustomTime `json:"ended_at"`
+	Minutes   *int32      `json:"minutes" binding:"omitempty,min=1"`
+	Note      *string     `json:"note"`
+}
+
+type UpdateTimeEntryRequest struct {
+	StartedAt *CustomTime `json:"started_at"`
+	EndedAt   *CustomTime `json:"ended_at"` // setting it on a running timer stops it
+	Note      *string     `json:"note"`
+}
+
+// TaskTimeReport compares a task's estimate with the time actually spent on it
+type TaskTimeReport struct {
+	TaskID          int64        `json:"task_id"`
+	TaskName        string       `json:"task_name"`
+	EstimateMinutes *int32       `json:"estimate_minutes"`
+	TrackedMinutes  int64        `json:"tracked_minutes"`  // from time entries
+	PomodoroMinutes int64        `json:"pomodoro_minutes"` // from completed pomodoros
+	ActualMinutes   int64        `json:"actual_minutes"`
+	VarianceMinutes *int64       `json:"variance_minutes"` // actual minus estimate; positive means over
+	Entries         []*TimeEntry `json:"entries"`
+}
+
+// TaskTimeAllocation is the time spent on one task. Pomodoros not linked to
+// a task are reported together with a nil task ID.
+type TaskTimeAllocation struct {
+	TaskID          *int64  `json:"task_id"`
+	TaskName        string  `json:"task_name"`
+	Category        string  `json:"category"`
+	EstimateMinutes *int32  `json:"estimate_minutes"`
+	TrackedMinutes  int64   `json:"tracked_minutes"`
+	PomodoroMinutes int64   `json:"pomodoro_minutes"`
+	TotalMinutes    int64   `json:"total_minutes"`
+	Percentage      float64 `json:"percentage"`
+}
+
+type TimeAllocationReport struct {
+	Since        *time.Time            `json:"since"` // nil covers all time
+	TotalMinutes int64                 `json:"total_minutes"`
+	Categories   []*TimeAllocation     `json:"categories"`
+	Tasks        []*TaskTimeAllocation `json:"tasks"`
+}
+
+// Quick-add models
+
+// QuickAddRequest creates a task from one line of text such as
+// "Pay utility bills tomorrow 5pm #Finance !3 every month"
+type QuickAddRequest struct {
+	Text     string `json:"text" binding:"required"`
+	Timezone string `json:"timezone"` // IANA name such as Europe/Berlin; defaults to UTC
+	Locale   string `json:"locale"`   // keyword language; defaults to en
+}
+
+// Task template models
+
+// TemplateTask is one task of a template. TaskName, Description, Category
+// and Tags may contain {{variable}} placeholders. DueOffset places the due
+// date relative to the anchor date the template is instantiated with, as in
+// "+2d", "-1w" or "+36h"; without it the task has no due date.
+type TemplateTask struct {
+	TaskName        string          `json:"task_name"`
+	Description     *string         `json:"description"`
+	Category        *string         `json:"category"`
+	Priority        int16           `json:"priority"`
+	DueOffset       *string         `json:"due_offset"`
+	EstimateMinutes *int32          `json:"estimate_minutes"`
+	Tags            []string        `json:"tags"`
+	Subtasks        []*TemplateTask `json:"subtasks"`
+}
+
+type TaskTemplate struct {
+	ID          int64           `json:"id" db:"id"`
+	UserID      int64           `json:"user_id" db:"user_id"`
+	Name        string          `json:"name" db:"name"`
+	Description *string         `json:"description" db:"description"`
+	Tasks       []*TemplateTask `json:"tasks" db:"tasks"`
+	Variables   []string        `json:"variables"`  // placeholder names used by the tasks
+	TaskCount   int             `json:"task_count"` // tasks including subtasks
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type CreateTaskTemplateRequest struct {
+	Name        string          `json:"name" binding:"required,max=100"`
+	Description *string         `json:"description"`
+	Tasks       []*TemplateTask `json:"tasks" binding:"required"`
+}
+
+// UpdateTaskTemplateRequest replaces the fields that are given; Tasks
+// replaces the whole task list
+type UpdateTaskTemplateRequest struct {
+	Name        *string         `json:"name" binding:"omitempty,max=100"`
+	Description *string         `json:"description"`
+	Tasks       []*TemplateTask `json:"tasks"`
+}
+
+// TemplateFromTasksRequest saves existing tasks as a template. Due offsets
+// are taken relative to AnchorDate, which defaults to the earliest due date
+// among the tasks.
+type TemplateFromTasksRequest struct {
+	Name            string      `json:"name" binding:"required,max=100"`
+	Description     *string     `json:"description"`
+	TaskIDs         []int64     `json:"task_ids" binding:"required,min=1"`
+	AnchorDate      *CustomTime `json:"anchor_date"`
+	IncludeSubtasks *bool       `json:"include_subtasks"` // defaults to true
+}
+
+// InstantiateTemplateRequest creates a template's tasks. Due offsets count
+// from AnchorDate (now when omitted) and every placeholder used by the
+// template needs a value in Variables. ParentID nests the new top-level
+// tasks under an existing task.
+type InstantiateTemplateRequest struct {
+	AnchorDate *CustomTime       `json:"anchor_date"`
+	Variables  map[string]string `json:"variables"`
+	ParentID   *int64            `json:"parent_id"`
+}
+
+type TemplateInstance struct {
+	TemplateID   int64   `json:"template_id"`
+	TemplateName string  `json:"template_name"`
+	Tasks        []*Task `json:"tasks"` // every created task, parents before their subtasks
+	Created      int     `json:"created"`
+}
+
+// Project models
+type Project struct {
+	ID            int64     `json:"id" db:"id"`
+	UserID        int64     `json:"user_id" db:"user_id"`
+	Name          string    `json:"name" db:"name"`
+	Color         *string   `json:"color" db:"color"` // #RRGGBB
+	Description   *string   `json:"description" db:"description"`
+	IsArchived    bool      `json:"is_archived" db:"is_archived"`
+	TaskCount     int64     `json:"task_count"`
+	OpenTaskCount int64     `json:"open_task_count"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateProjectRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Color       *string `json:"color"`
+	Description *string `json:"description"`
+}
+
+// UpdateProjectRequest changes the fields that are given; an empty color or
+// description clears it
+type UpdateProjectRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=100"`
+	Color       *string `json:"color"`
+	Description *string `json:"description"`
+	IsArchived  *bool   `json:"is_archived"`
+}
+
+// MoveTaskRequest moves a task into a project and places it right after
+// AfterID or right before BeforeID, or at the end when neither is given.
+// Without ProjectID the task is reordered within its current project;
+// project_id 0 takes it out of its project.
+type MoveTaskRequest struct {
+	ProjectID *int64 `json:"project_id"`
+	AfterID   *int64 `json:"after_id"`
+	BeforeID  *int64 `json:"before_id"`
+}
+
+// Kanban board models
+type Board struct {
+	ID        int64          `json:"id" db:"id"`
+	UserID    int64          `json:"user_id" db:"user_id"`
+	ProjectID *int64         `json:"project_id" db:"project_id"` // nil shows all of the user's tasks
+	Name      string         `json:"name" db:"name"`
+	Columns   []*BoardColumn `json:"columns"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// BoardColumn shows the tasks with one status. Cards are only filled in when
+// a single board is requested.
+type BoardColumn struct {
+	ID        int64      `json:"id" db:"id"`
+	BoardID   int64      `json:"board_id" db:"board_id"`
+	Name      string     `json:"name" db:"name"`
+	Status    TaskStatus `json:"status" db:"status"`
+	WIPLimit  *int32     `json:"wip_limit" db:"wip_limit"`
+	SortOrder int32      `json:"sort_order" db:"sort_order"`
+	TaskCount int64      `json:"task_count"`
+	OverLimit bool       `json:"over_limit"` // more tasks than the WIP limit, e.g. after changes outside the board
+	Cards     []*Task    `json:"cards,omitempty"`
+}
+
+type BoardColumnRequest struct {
+	Name     string     `json:"name" binding:"required,max=100"`
+	Status   TaskStatus `json:"status" binding:"required"`
+	WIPLimit *int32     `json:"wip_limit" binding:"omitempty,min=1"`
+}
+
+// CreateBoardRequest creates a board; without columns it gets To do, In
+// progress and Done
+type CreateBoardRequest struct {
+	Name      string                `json:"name" binding:"required,max=100"`
+	ProjectID *int64                `json:"project_id"`
+	Columns   []*BoardColumnRequest `json:"columns" binding:"omitempty,dive"`
+}
+
+// UpdateBoardRequest renames a board and, when Columns is given, replaces its
+// columns in the given order. Columns keep their ID as long as their status
+// stays on the board.
+type UpdateBoardRequest struct {
+	Name    *string               `json:"name" binding:"omitempty,max=100"`
+	Columns []*BoardColumnRequest `json:"columns" binding:"omitempty,dive"`
+}
+
+// MoveCardRequest moves a card into a column, changing the task's status to
+// the column's, and places it right after AfterID or right before BeforeID;
+// with neither it goes to the top. Without ColumnID the card is reordered
+// within its current column.
+type MoveCardRequest struct {
+	ColumnID *int64 `json:"column_id"`
+	AfterID  *int64 `json:"after_id"`
+	BeforeID *int64 `json:"before_id"`
+}
+
+type CardMoveResult struct {
+	Task           *Task        `json:"task"`
+	Column         *BoardColumn `json:"column"`
+	Warnings       []string     `json:"warnings,omitempty"`
+	NextOccurrence *Task        `json:"next_occurrence,omitempty"`
+}
+
+// Attachment models
+type TaskAttachment struct {
+	ID          int64     `json:"id" db:"id"`
+	UserID      int64     `json:"user_id" db:"user_id"`
+	TaskID      int64     `json:"task_id" db:"task_id"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"` // sniffed from the content, not taken from the client
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	Checksum    string    `json:"checksum" db:"checksum"` // hex SHA-256 of the content
+	StorageKey  string    `json:"-" db:"storage_key"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AttachmentLimits bound uploads. AllowedTypes holds media types such as
+// "application/pdf" or "image/*"; empty allows every type.
+type AttachmentLimits struct {
+	MaxBytes     int64    `json:"max_bytes"`
+	QuotaBytes   int64    `json:"quota_bytes"` // per user, over all tasks
+	AllowedTypes []string `json:"allowed_types"`
+}
+
+type AttachmentUsage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+	MaxBytes   int64 `json:"max_bytes"`
+}
+
+// Comment models
+type TaskComment struct {
+	ID        int64      `json:"id" db:"id"`
+	TaskID    int64      `json:"task_id" db:"task_id"`
+	UserID    int64      `json:"user_id" db:"user_id"`
+	Body      string     `json:"body" db:"body"` // Markdown
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	EditedAt  *time.Time `json:"edited_at" db:"edited_at"` // last edit; nil when never edited
+	IsEdited  bool       `json:"is_edited"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// Reminder models
+
+// Reminder statuses
+const (
+	ReminderActive    = "active"
+	ReminderFired     = "fired"     // a one-off reminder that has gone off
+	ReminderDismissed = "dismissed" // turned off by the user
+)
+
+// Reminder channels; which ones can be used depends on the configured notifiers
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Reminder goes off for a task, at RemindAt or OffsetMinutes from its due
+// date, or for a habit, every day at CueTime in Timezone. NextFireAt is when
+// it goes off next, nil when it is not scheduled.
+type Reminder struct {
+	ID            int64      `json:"id" db:"id"`
+	UserID        int64      `json:"user_id" db:"user_id"`
+	TaskID        *int64     `json:"task_id" db:"task_id"`
+	HabitID       *int64     `json:"habit_id" db:"habit_id"`
+	RemindAt      *time.Time `json:"remind_at" db:"remind_at"`
+	OffsetMinutes *int32     `json:"offset_minutes" db:"offset_minutes"` // negative is before the due date
+	CueTime       *string    `json:"cue_time" db:"cue_time"`             // HH:MM
+	Timezone      string     `json:"timezone" db:"timezone"`
+	Channel       string     `json:"channel" db:"channel"`
+	Status        string     `json:"status" db:"status"`
+	NextFireAt    *time.Time `json:"next_fire_at" db:"next_fire_at"`
+	LastFiredAt   *time.Time `json:"last_fired_at" db:"last_fired_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateTaskReminderRequest needs exactly one of RemindAt and OffsetMinutes
+type CreateTaskReminderRequest struct {
+	RemindAt      *CustomTime `json:"remind_at"`
+	OffsetMinutes *int32      `json:"offset_minutes"`
+	Channel       string      `json:"channel"` // in_app when empty
+}
+
+type CreateHabitReminderRequest struct {
+	CueTime  string `json:"cue_time" binding:"required"` // HH:MM
+	Timezone string `json:"timezone"`                    // IANA name, UTC when empty
+	Channel  string `json:"channel"`
+}
+
+type SnoozeReminderRequest struct {
+	Minutes int `json:"minutes" binding:"required,min=1,max=10080"`
+}
+
+// ReminderDelivery is one firing of a reminder, claimed for sending through
+// its channel, together with what the message is about
+type ReminderDelivery struct {
+	ID         int64
+	ReminderID int64
+	UserID     int64
+	Email      *string
+	Channel    string
+	TaskID     *int64
+	HabitID    *int64
+	Subject    string     // task or habit name
+	DueDate    *time.Time // the task's due date
+	Timezone   string
+	FireAt     time.Time
+	Attempts   int32 // including the current one
+}
+
+// Notification is an entry in the user's in-app inbox
+type Notification struct {
+	ID         int64      `json:"id" db:"id"`
+	UserID     int64      `json:"user_id" db:"user_id"`
+	ReminderID *int64     `json:"reminder_id" db:"reminder_id"`
+	TaskID     *int64     `json:"task_id" db:"task_id"`
+	HabitID    *int64     `json:"habit_id" db:"habit_id"`
+	Title      string     `json:"title" db:"title"`
+	Body       string     `json:"body" db:"body"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ReadAt     *time.Time `json:"read_at" db:"read_at"`
+}
+
+// Eisenhower matrix models
+
+// Matrix quadrants
+const (
+	QuadrantDo        = "do"        // urgent and important
+	QuadrantSchedule  = "schedule"  // important, not urgent
+	QuadrantDelegate  = "delegate"  // urgent, not important
+	QuadrantEliminate = "eliminate" // neither
+)
+
+// MatrixSettings decide how open tasks are classified. A task is urgent when
+// it is overdue or due within UrgentDays, and important when it is flagged so
+// or, without a flag, when its priority is at least ImportantPriority.
+type MatrixSettings struct {
+	UrgentDays        int   `json:"urgent_days"`
+	ImportantPriority int16 `json:"important_priority"`
+}
+
+type MatrixQuadrant struct {
 	Tasks []*Task `json:"tasks"`
 	Count int     `json:"count"`
 }
+
+// TaskMatrix buckets a user's open tasks into the four quadrants
+type TaskMatrix struct {
+	Do        *MatrixQuadrant `json:"do"`
+	Schedule  *MatrixQuadrant `json:"schedule"`
+	Delegate  *MatrixQuadrant `json:"delegate"`
+	Eliminate *MatrixQuadrant `json:"eliminate"`
+	Total     int             `json:"total"`
+	Settings  MatrixSettings  `json:"settings"`
+	UrgentBy  time.Time       `json:"urgent_by"` // tasks due before this are urgent
+}
+
+// SetImportanceRequest sets a task's importance flag; null or a missing
+// is_important hands it back to the priority threshold
+type SetImportanceRequest struct {
+	IsImportant *bool `json:"is_important"`
+}
+
+// Planner models
+
+// Time block sources
+const (
+	BlockManual  = "manual"  // busy time added by the user
+	BlockPlanner = "planner" // a task placed by an accepted plan
+)
+
+// WorkingHours is one stretch of working time on a weekday (0 is Sunday),
+// in the wall-clock time of whatever timezone a plan is made in
+type WorkingHours struct {
+	Weekday int    `json:"weekday" binding:"min=0,max=6"`
+	Start   string `json:"start" binding:"required"` // HH:MM
+	End     string `json:"end" binding:"required"`   // HH:MM, after Start
+}
+
+// SetWorkingHoursRequest replaces the user's working hours; an empty list
+// goes back to the default of Monday to Friday, 09:00 to 17:00
+type SetWorkingHoursRequest struct {
+	Hours []WorkingHours `json:"hours" binding:"dive"`
+}
+
+// TimeBlock is a stretch of calendar time, either busy time or a task
+// planned in. Title falls back to the task's name.
+type TimeBlock struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	TaskID    *int64    `json:"task_id" db:"task_id"`
+	Title     string    `json:"title" db:"title"`
+	StartsAt  time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt    time.Time `json:"ends_at" db:"ends_at"`
+	Source    string    `json:"source" db:"source"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateTimeBlockRequest needs a title, a task or both
+type CreateTimeBlockRequest struct {
+	Title    string      `json:"title" binding:"max=200"`
+	TaskID   *int64      `json:"task_id"`
+	StartsAt *CustomTime `json:"starts_at" binding:"required"`
+	EndsAt   *CustomTime `json:"ends_at" binding:"required"`
+}
+
+// PlannedBlock is a proposed block for a task
+type PlannedBlock struct {
+	TaskID   int64     `json:"task_id" binding:"required"`
+	TaskName string    `json:"task_name,omitempty"`
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Late     bool      `json:"late,omitempty"` // ends after the task is due
+}
+
+// UnplannedTask is an open task the plan has no room for, with the reason:
+// no_estimate, blocked, dependency_cycle, too_long, no_time_left or
+// already_planned (for another day)
+type UnplannedTask struct {
+	TaskID   int64  `json:"task_id"`
+	TaskName string `json:"task_name"`
+	Reason   string `json:"reason"`
+}
+
+// DayPlan is a proposed timeline for one day. Nothing is saved until the
+// blocks are accepted.
+type DayPlan struct {
+	Date           string           `json:"date"`
+	Timezone       string           `json:"timezone"`
+	WorkingHours   []WorkingHours   `json:"working_hours"`
+	Busy           []*TimeBlock     `json:"busy"`
+	Blocks         []*PlannedBlock  `json:"blocks"`
+	Unplanned      []*UnplannedTask `json:"unplanned"`
+	PlannedMinutes int              `json:"planned_minutes"`
+	FreeMinutes    int              `json:"free_minutes"` // working time left over
+}
+
+// AcceptPlanRequest saves a plan's blocks for the day, replacing the blocks
+// of a plan accepted for it before
+type AcceptPlanRequest struct {
+	Date     string          `json:"date" binding:"required"` // YYYY-MM-DD
+	Timezone string          `json:"timezone"`
+	Blocks   []*PlannedBlock `json:"blocks" binding:"dive"`
+}
+
+// Calendar feed models
+
+// How a feed shows tasks
+const (
+	FeedTaskEvent = "event" // all-day VEVENT on the due date
+	FeedTaskTodo  = "todo"  // VTODO due at the due date
+)
+
+// CalendarFeed is an iCalendar subscription of the user's tasks and goals.
+// Its token is only returned when the feed is created.
+type CalendarFeed struct {
+	ID               int64      `json:"id" db:"id"`
+	UserID           int64      `json:"user_id" db:"user_id"`
+	Name             string     `json:"name" db:"name"`
+	TaskFormat       string     `json:"task_format" db:"task_format"`
+	Timezone         string     `json:"timezone" db:"timezone"` // picks the day of tasks due at a time of day
+	IncludeCompleted bool       `json:"include_completed" db:"include_completed"`
+	IncludeGoals     bool       `json:"include_goals" db:"include_goals"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	LastAccessedAt   *time.Time `json:"last_accessed_at" db:"last_accessed_at"`
+}
+
+// CreateCalendarFeedRequest makes a feed; it shows tasks as events, in UTC,
+// with completed tasks and goals unless asked otherwise
+type CreateCalendarFeedRequest struct {
+	Name             string `json:"name" binding:"required,max=100"`
+	TaskFormat       string `json:"task_format" binding:"omitempty,oneof=event todo"`
+	Timezone         string `json:"timezone"`
+	IncludeCompleted *bool  `json:"include_completed"`
+	IncludeGoals     *bool  `json:"include_goals"`
+}
+
+// FeedTask is a task in a calendar feed with when it was last completed
+type FeedTask struct {
+	Task
+	CompletedAt *time.Time
+}
+
+// CalDAV models
+
+// CalDAVTask is a task as a CalDAV resource. UID and Href are set once a
+// client or an import gave the task its own; ParentUID is that of the
+// parent task.
+type CalDAVTask struct {
+	FeedTask
+	UID       *string
+	Href      *string
+	ParentUID *string
+}
+
+// AppPassword signs a CalDAV client in. The password itself is only
+// returned when it is created.
+type AppPassword struct {
+	ID         int64      `json:"id" db:"id"`
+	UserID     int64      `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+type CreateAppPasswordRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
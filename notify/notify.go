@@ -0,0 +1,60 @@
+// Package notify delivers reminder messages to users over outside channels.
+// Each channel implements Notifier; which ones are available is decided by
+// configuration.
+package notify
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPermanent marks a failure that will not go away by retrying, such as a
+// user without an email address
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// Message is one reminder to deliver
+type Message struct {
+	// DeliveryID identifies the delivery; retries of the same delivery carry
+	// the same ID so receivers can drop duplicates
+	DeliveryID int64     `json:"delivery_id"`
+	ReminderID int64     `json:"reminder_id"`
+	UserID     int64     `json:"user_id"`
+	Email      string    `json:"-"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	TaskID     *int64    `json:"task_id,omitempty"`
+	HabitID    *int64    `json:"habit_id,omitempty"`
+	FireAt     time.Time `json:"fire_at"`
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	// Channel is the name reminders use to pick the notifier, e.g. "email"
+	Channel() string
+	Notify(msg *Message) error
+}
+
+// Config holds the settings of the outside channels; a channel is only
+// enabled when its address is set
+type Config struct {
+	WebhookURL    string
+	WebhookSecret string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+}
+
+// Open returns the notifiers enabled by cfg
+func Open(cfg Config) []Notifier {
+	var notifiers []Notifier
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret))
+	}
+	if cfg.SMTPHost != "" {
+		notifiers = append(notifiers, NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom))
+	}
+	return notifiers
+}
@@ -0,0 +1,120 @@
+// Package ordering generates position keys for lists ordered by hand.
+//
+// A key is a string of base-62 digits (0-9, A-Z, a-z) that never ends in
+// "0". Keys sort by plain byte comparison, so a new key can always be made
+// between any two keys without touching the rest of the list: moving one
+// item rewrites only that item's key. Store keys in a column compared
+// bytewise, such as a PostgreSQL text column with COLLATE "C".
+package ordering
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// digits are the key digits in ascending byte order
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalidKey is returned for keys with characters outside the digits, a
+// trailing "0", or bounds that are not in ascending order
+var ErrInvalidKey = errors.New("invalid position key")
+
+// Valid reports whether key is a well-formed position key
+func Valid(key string) bool {
+	if key == "" || key[len(key)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Between returns a key that sorts after before and ahead of after. An empty
+// bound is open: Between("", "") is a first key, Between(k, "") sorts after
+// k and Between("", k) sorts ahead of k.
+func Between(before, after string) (string, error) {
+	if before != "" && !Valid(before) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, before)
+	}
+	if after != "" && !Valid(after) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, after)
+	}
+	if before != "" && after != "" && before >= after {
+		return "", fmt.Errorf("%w: %q is not before %q", ErrInvalidKey, before, after)
+	}
+	if after == "" && before != "" {
+		return increment(before), nil
+	}
+	return midpoint(before, after), nil
+}
+
+// After returns a key that sorts after key, or a first key when key is
+// empty. It is meant for appending and keeps keys short: each extra
+// character allows about sixty more appends.
+func After(key string) (string, error) {
+	return Between(key, "")
+}
+
+// increment returns the smallest-looking key after a valid key: the first
+// digit that can grow is bumped and the rest dropped
+func increment(key string) string {
+	for i := 0; i < len(key); i++ {
+		if d := strings.IndexByte(digits, key[i]); d < len(digits)-1 {
+			return key[:i] + string(digits[d+1])
+		}
+	}
+	return key + string(digits[1])
+}
+
+// midpoint returns a key between a and b, where a is "" or a valid key, b is
+// "" (no upper bound) or a valid key, and a < b
+func midpoint(a, b string) string {
+	if b != "" {
+		// Copy the common prefix, reading missing digits of a as zeros
+		n := 0
+		for n < len(b) {
+			digitA := digits[0]
+			if n < len(a) {
+				digitA = a[n]
+			}
+			if digitA != b[n] {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	low := 0
+	if a != "" {
+		low = strings.IndexByte(digits, a[0])
+	}
+	high := len(digits)
+	if b != "" {
+		high = strings.IndexByte(digits, b[0])
+	}
+	if high-low > 1 {
+		return string(digits[(low+high+1)/2])
+	}
+
+	// The first digits are adjacent: b's first digit alone sorts between
+	// them when b is longer, otherwise keep a's digit and go one level deeper
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[low]) + midpoint(rest, "")
+}
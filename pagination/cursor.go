@@ -0,0 +1,161 @@
+// Package pagination implements keyset (cursor) pagination with multi-key
+// sorting. Cursors are opaque to clients: they encode the sort order and the
+// sort values of the row a page ended on, so rows inserted or deleted while a
+// client is paging never shift later pages.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidSort is returned for unknown or repeated sort fields
+	ErrInvalidSort = errors.New("invalid sort")
+	// ErrInvalidCursor is returned for cursors that cannot be decoded or belong to another sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 500
+)
+
+// SortKey is one field of a sort order
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Column describes how a sortable field is ordered in SQL. Expr must never be
+// NULL (use COALESCE) and cursor values are cast to Cast before comparing.
+type Column struct {
+	Expr string
+	Cast string
+}
+
+// Cursor marks the row a page ended on. Backward cursors page towards the
+// start of the list.
+type Cursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+// Query is a validated request for one page
+type Query struct {
+	Sort   []SortKey
+	Cursor *Cursor
+	Limit  int
+}
+
+// ParseSort parses a comma separated list such as "due_date,-priority" where
+// a leading "-" sorts descending. Only fields present in columns are allowed;
+// field names are not case sensitive.
+func ParseSort(spec string, columns map[string]Column) ([]SortKey, error) {
+	var keys []SortKey
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		part = strings.ToLower(part)
+		key := SortKey{Field: part}
+		if strings.HasPrefix(part, "-") {
+			key = SortKey{Field: part[1:], Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			key.Field = part[1:]
+		}
+		if _, ok := columns[key.Field]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, key.Field)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("%w: %q is listed twice", ErrInvalidSort, key.Field)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no sort fields", ErrInvalidSort)
+	}
+	return keys, nil
+}
+
+// FormatSort is the canonical form of a sort order, as accepted by ParseSort
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Desc {
+			parts[i] = "-" + key.Field
+		} else {
+			parts[i] = key.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// NewQuery validates the raw sort, cursor and limit parameters of a list
+// request. A cursor carries its own sort order; an explicit sort must agree.
+func NewQuery(sortSpec, defaultSort, cursor string, limit int, columns map[string]Column) (*Query, error) {
+	q := &Query{Limit: limit}
+	if q.Limit < 1 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+
+	if cursor != "" {
+		c, err := Decode(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if sortSpec != "" {
+			keys, err := ParseSort(sortSpec, columns)
+			if err != nil {
+				return nil, err
+			}
+			if FormatSort(keys) != c.Sort {
+				return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, c.Sort)
+			}
+		}
+		q.Cursor = c
+		sortSpec = c.Sort
+	}
+	if sortSpec == "" {
+		sortSpec = defaultSort
+	}
+
+	keys, err := ParseSort(sortSpec, columns)
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = keys
+
+	// The row id is always the last key so that the order is total
+	if q.Cursor != nil && len(q.Cursor.Values) != len(keys)+1 {
+		return nil, fmt.Errorf("%w: wrong number of values", ErrInvalidCursor)
+	}
+	return q, nil
+}
+
+func Encode(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,113 @@
+package pagination
+
+import "strings"
+
+type orderKey struct {
+	column Column
+	desc   bool
+}
+
+// keys returns the effective order including the id tie-breaker, which
+// follows the direction of the last sort key. Backward pages read the list
+// in reverse.
+func (q *Query) keys(columns map[string]Column, idColumn string) []orderKey {
+	keys := make([]orderKey, 0, len(q.Sort)+1)
+	for _, key := range q.Sort {
+		keys = append(keys, orderKey{column: columns[key.Field], desc: key.Desc})
+	}
+	keys = append(keys, orderKey{column: Column{Expr: idColumn, Cast: "bigint"}, desc: q.Sort[len(q.Sort)-1].Desc})
+
+	if q.Cursor != nil && q.Cursor.Backward {
+		for i := range keys {
+			keys[i].desc = !keys[i].desc
+		}
+	}
+	return keys
+}
+
+// SQL returns the ORDER BY list and, when the query has a cursor, the WHERE
+// condition selecting the rows past it. arg registers a query parameter and
+// returns its placeholder.
+func (q *Query) SQL(columns map[string]Column, idColumn string, arg func(interface{}) string) (where, orderBy string) {
+	keys := q.keys(columns, idColumn)
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		direction := " ASC"
+		if key.desc {
+			direction = " DESC"
+		}
+		order[i] = key.column.Expr + direction
+	}
+	orderBy = strings.Join(order, ", ")
+
+	if q.Cursor == nil {
+		return "", orderBy
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 < v2) OR ... expands the row comparison so
+	// that every key can have its own direction
+	placeholders := make([]string, len(keys))
+	for i, key := range keys {
+		placeholders[i] = arg(q.Cursor.Values[i]) + "::" + key.column.Cast
+	}
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, keys[j].column.Expr+" = "+placeholders[j])
+		}
+		op := " > "
+		if key.desc {
+			op = " < "
+		}
+		terms = append(terms, key.column.Expr+op+placeholders[i])
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", orderBy
+}
+
+// FetchLimit is the number of rows to select: one more than the page size,
+// to find out whether another page follows
+func (q *Query) FetchLimit() int {
+	return q.Limit + 1
+}
+
+// Paginate trims the extra row selected by FetchLimit, restores the natural
+// order of backward pages and returns the cursors of the neighbouring pages.
+// values returns the sort values of a row followed by its id, as strings the
+// columns' Cast types accept.
+func Paginate[T any](q *Query, rows []T, values func(T) []string) (page []T, next, prev string) {
+	hasMore := len(rows) > q.Limit
+	if hasMore {
+		rows = rows[:q.Limit]
+	}
+	backward := q.Cursor != nil && q.Cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+
+	sort := FormatSort(q.Sort)
+	first := Encode(Cursor{Sort: sort, Values: values(rows[0]), Backward: true})
+	last := Encode(Cursor{Sort: sort, Values: values(rows[len(rows)-1])})
+
+	if backward {
+		next = last
+		if hasMore {
+			prev = first
+		}
+	} else {
+		if hasMore {
+			next = last
+		}
+		if q.Cursor != nil {
+			prev = first
+		}
+	}
+	return rows, next, prev
+}
@@ -0,0 +1,260 @@
+// Package planner lays open tasks out as time blocks in the free time of a
+// day.
+//
+// Tasks are taken in a fixed order: a task only comes after the tasks it
+// waits on, and otherwise the earliest due date goes first, then the
+// highest priority, then the lowest ID. Each task is put in the earliest
+// free stretch that holds its whole estimate and starts after its blockers
+// end; tasks are not split. The same input therefore always gives the same
+// plan, whatever order the tasks are passed in.
+package planner
+
+import (
+	"sort"
+	"time"
+)
+
+// Reasons a task is left out of a plan
+const (
+	ReasonNoEstimate = "no_estimate"      // without an estimate there is no block length
+	ReasonBlocked    = "blocked"          // waits on a task that is not planned before it
+	ReasonCycle      = "dependency_cycle" // its chain of blockers loops back on itself
+	ReasonTooLong    = "too_long"         // longer than any free stretch of the day
+	ReasonNoTime     = "no_time_left"     // the stretches long enough went to earlier tasks
+)
+
+// Interval is a stretch of time from Start up to End
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the interval
+func (iv Interval) Duration() time.Duration {
+	return iv.End.Sub(iv.Start)
+}
+
+// Task is a task to plan
+type Task struct {
+	ID       int64
+	Minutes  int // estimate; 0 when there is none
+	Priority int16
+	// Deadline is when the task is due, nil when it has no due date
+	Deadline *time.Time
+	// BlockedBy are the open tasks this one waits on. A blocker missing
+	// from the tasks being planned keeps the task out of the plan.
+	BlockedBy []int64
+}
+
+// Block places a task in the day
+type Block struct {
+	TaskID int64
+	Start  time.Time
+	End    time.Time
+	Late   bool // ends after the task's deadline
+}
+
+// Skipped is a task left out of the plan
+type Skipped struct {
+	TaskID int64
+	Reason string
+}
+
+// Plan is the outcome of Schedule. Blocks are in the order they start,
+// Skipped in the order the tasks were considered.
+type Plan struct {
+	Blocks  []Block
+	Skipped []Skipped
+	Free    []Interval // free time left over
+}
+
+// FreeTime returns the parts of working time that no busy interval covers
+// and that do not start before notBefore, sorted and merged
+func FreeTime(working, busy []Interval, notBefore time.Time) []Interval {
+	free := merge(working)
+	for _, b := range merge(busy) {
+		free = subtract(free, b)
+	}
+	if !notBefore.IsZero() {
+		free = subtract(free, Interval{Start: time.Time{}, End: notBefore})
+	}
+	return free
+}
+
+// merge sorts intervals and joins those that overlap or touch, dropping
+// empty ones
+func merge(intervals []Interval) []Interval {
+	sorted := make([]Interval, 0, len(intervals))
+	for _, iv := range intervals {
+		if iv.End.After(iv.Start) {
+			sorted = append(sorted, iv)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	var merged []Interval
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 && !iv.Start.After(merged[n-1].End) {
+			if iv.End.After(merged[n-1].End) {
+				merged[n-1].End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// subtract cuts cut out of sorted, non-overlapping intervals
+func subtract(intervals []Interval, cut Interval) []Interval {
+	var rest []Interval
+	for _, iv := range intervals {
+		if !cut.Start.Before(iv.End) || !cut.End.After(iv.Start) {
+			rest = append(rest, iv)
+			continue
+		}
+		if cut.Start.After(iv.Start) {
+			rest = append(rest, Interval{Start: iv.Start, End: cut.Start})
+		}
+		if cut.End.Before(iv.End) {
+			rest = append(rest, Interval{Start: cut.End, End: iv.End})
+		}
+	}
+	return rest
+}
+
+// before orders tasks that are free to go: earliest deadline first, tasks
+// without one last, then the highest priority, then the lowest ID
+func before(a, b *Task) bool {
+	switch {
+	case a.Deadline != nil && b.Deadline == nil:
+		return true
+	case a.Deadline == nil && b.Deadline != nil:
+		return false
+	case a.Deadline != nil && !a.Deadline.Equal(*b.Deadline):
+		return a.Deadline.Before(*b.Deadline)
+	case a.Priority != b.Priority:
+		return a.Priority > b.Priority
+	}
+	return a.ID < b.ID
+}
+
+// Schedule places tasks in the free intervals, which must be sorted and not
+// overlap, such as those returned by FreeTime
+func Schedule(tasks []Task, free []Interval) *Plan {
+	pending := make([]*Task, len(tasks))
+	byID := make(map[int64]*Task, len(tasks))
+	for i := range tasks {
+		pending[i] = &tasks[i]
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return before(pending[i], pending[j])
+	})
+
+	longest := time.Duration(0)
+	for _, iv := range free {
+		if iv.Duration() > longest {
+			longest = iv.Duration()
+		}
+	}
+
+	plan := &Plan{Free: append([]Interval(nil), free...)}
+	ends := map[int64]time.Time{} // planned tasks and when they end
+	skipped := map[int64]bool{}
+	resolved := func(id int64) bool {
+		_, planned := ends[id]
+		return planned || skipped[id] || byID[id] == nil
+	}
+	skip := func(task *Task, reason string) {
+		skipped[task.ID] = true
+		plan.Skipped = append(plan.Skipped, Skipped{TaskID: task.ID, Reason: reason})
+	}
+
+	for len(pending) > 0 {
+		// Take the first task in order whose blockers have all been decided
+		next := -1
+		for i, task := range pending {
+			ready := true
+			for _, id := range task.BlockedBy {
+				if id != task.ID && !resolved(id) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			// Everything left waits on a cycle
+			for _, task := range pending {
+				skip(task, ReasonCycle)
+			}
+			break
+		}
+		task := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+
+		earliest := time.Time{}
+		blocked := false
+		for _, id := range task.BlockedBy {
+			end, planned := ends[id]
+			if !planned {
+				blocked = true
+				break
+			}
+			if end.After(earliest) {
+				earliest = end
+			}
+		}
+		if blocked {
+			skip(task, ReasonBlocked)
+			continue
+		}
+		if task.Minutes <= 0 {
+			skip(task, ReasonNoEstimate)
+			continue
+		}
+
+		length := time.Duration(task.Minutes) * time.Minute
+		if length > longest {
+			skip(task, ReasonTooLong)
+			continue
+		}
+		block, ok := place(plan, length, earliest)
+		if !ok {
+			skip(task, ReasonNoTime)
+			continue
+		}
+		block.TaskID = task.ID
+		block.Late = task.Deadline != nil && block.End.After(*task.Deadline)
+		ends[task.ID] = block.End
+		plan.Blocks = append(plan.Blocks, block)
+	}
+
+	sort.SliceStable(plan.Blocks, func(i, j int) bool {
+		return plan.Blocks[i].Start.Before(plan.Blocks[j].Start)
+	})
+	return plan
+}
+
+// place takes length out of the first free interval that holds it from
+// earliest on
+func place(plan *Plan, length time.Duration, earliest time.Time) (Block, bool) {
+	for _, iv := range plan.Free {
+		start := iv.Start
+		if earliest.After(start) {
+			start = earliest
+		}
+		if iv.End.Sub(start) >= length {
+			block := Block{Start: start, End: start.Add(length)}
+			plan.Free = subtract(plan.Free, Interval{Start: block.Start, End: block.End})
+			return block, true
+		}
+	}
+	return Block{}, false
+}
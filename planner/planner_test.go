@@ -0,0 +1,206 @@
+package planner
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// at returns the time of day on the day plans are made for
+func at(hour, minute int) time.Time {
+	return time.Date(2026, 10, 14, hour, minute, 0, 0, time.UTC)
+}
+
+func span(startHour, startMinute, endHour, endMinute int) Interval {
+	return Interval{Start: at(startHour, startMinute), End: at(endHour, endMinute)}
+}
+
+func deadline(hour, minute int) *time.Time {
+	t := at(hour, minute)
+	return &t
+}
+
+func TestFreeTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		working   []Interval
+		busy      []Interval
+		notBefore time.Time
+		want      []Interval
+	}{
+		{
+			name:    "overlapping busy blocks are merged",
+			working: []Interval{span(9, 0, 12, 0), span(13, 0, 17, 0)},
+			busy:    []Interval{span(10, 15, 11, 0), span(10, 0, 10, 30), span(16, 30, 18, 0)},
+			want:    []Interval{span(9, 0, 10, 0), span(11, 0, 12, 0), span(13, 0, 16, 30)},
+		},
+		{
+			name:    "overlapping and touching working hours are merged",
+			working: []Interval{span(13, 0, 15, 0), span(9, 0, 11, 0), span(10, 0, 13, 0)},
+			busy:    []Interval{span(12, 0, 12, 30)},
+			want:    []Interval{span(9, 0, 12, 0), span(12, 30, 15, 0)},
+		},
+		{
+			name:      "time before notBefore is cut",
+			working:   []Interval{span(9, 0, 12, 0), span(13, 0, 17, 0)},
+			busy:      []Interval{span(14, 0, 15, 0)},
+			notBefore: at(13, 20),
+			want:      []Interval{span(13, 20, 14, 0), span(15, 0, 17, 0)},
+		},
+		{
+			name:    "busy block covering the day leaves nothing",
+			working: []Interval{span(9, 0, 17, 0)},
+			busy:    []Interval{span(8, 0, 18, 0)},
+			want:    nil,
+		},
+		{
+			name:    "empty intervals are dropped",
+			working: []Interval{span(9, 0, 9, 0), span(10, 0, 11, 0)},
+			busy:    []Interval{span(10, 30, 10, 30)},
+			want:    []Interval{span(10, 0, 11, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FreeTime(tt.working, tt.busy, tt.notBefore)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FreeTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// dayTasks exercise every reason a task can be left out
+func dayTasks() []Task {
+	return []Task{
+		{ID: 1, Minutes: 60, Priority: 2, Deadline: deadline(12, 0)},
+		{ID: 2, Minutes: 30, BlockedBy: []int64{1}},
+		{ID: 3, Minutes: 0},                          // no estimate
+		{ID: 4, Minutes: 30, BlockedBy: []int64{99}}, // blocker not being planned
+		{ID: 5, Minutes: 30, BlockedBy: []int64{6}},  // cycle
+		{ID: 6, Minutes: 30, BlockedBy: []int64{5}},  // cycle
+		{ID: 7, Minutes: 300},                        // longer than any free stretch
+		{ID: 8, Minutes: 45, Priority: 1, Deadline: deadline(9, 30)},
+		{ID: 9, Minutes: 200}, // fits only before earlier tasks took the time
+		{ID: 10, Minutes: 30},
+		{ID: 11, Minutes: 15, BlockedBy: []int64{5}}, // waits on a task in a cycle
+	}
+}
+
+func dayFree() []Interval {
+	return FreeTime(
+		[]Interval{span(9, 0, 12, 0), span(13, 0, 17, 0)},
+		[]Interval{span(10, 0, 11, 0), span(16, 30, 17, 0)},
+		at(9, 15),
+	)
+}
+
+func TestSchedule(t *testing.T) {
+	plan := Schedule(dayTasks(), dayFree())
+
+	wantBlocks := []Block{
+		{TaskID: 8, Start: at(9, 15), End: at(10, 0), Late: true},
+		{TaskID: 1, Start: at(11, 0), End: at(12, 0)},
+		{TaskID: 2, Start: at(13, 0), End: at(13, 30)},
+		{TaskID: 10, Start: at(13, 30), End: at(14, 0)},
+	}
+	if !reflect.DeepEqual(plan.Blocks, wantBlocks) {
+		t.Errorf("Blocks = %v, want %v", plan.Blocks, wantBlocks)
+	}
+
+	wantSkipped := []Skipped{
+		{TaskID: 3, Reason: ReasonNoEstimate},
+		{TaskID: 4, Reason: ReasonBlocked},
+		{TaskID: 7, Reason: ReasonTooLong},
+		{TaskID: 9, Reason: ReasonNoTime},
+		{TaskID: 5, Reason: ReasonCycle},
+		{TaskID: 6, Reason: ReasonCycle},
+		{TaskID: 11, Reason: ReasonCycle},
+	}
+	if !reflect.DeepEqual(plan.Skipped, wantSkipped) {
+		t.Errorf("Skipped = %v, want %v", plan.Skipped, wantSkipped)
+	}
+
+	wantFree := []Interval{span(14, 0, 16, 30)}
+	if !reflect.DeepEqual(plan.Free, wantFree) {
+		t.Errorf("Free = %v, want %v", plan.Free, wantFree)
+	}
+}
+
+func TestScheduleOrderIndependent(t *testing.T) {
+	want := Schedule(dayTasks(), dayFree())
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		tasks := dayTasks()
+		rng.Shuffle(len(tasks), func(a, b int) { tasks[a], tasks[b] = tasks[b], tasks[a] })
+		if got := Schedule(tasks, dayFree()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("shuffled input %v gave %+v, want %+v", tasks, got, want)
+		}
+	}
+}
+
+func TestScheduleBlockerEndDelaysDependent(t *testing.T) {
+	// Task 1 comes first by deadline and priority but waits on task 2, so it
+	// goes after 2 ends even though there is free time before
+	tasks := []Task{
+		{ID: 1, Minutes: 30, Priority: 3, Deadline: deadline(9, 45), BlockedBy: []int64{2}},
+		{ID: 2, Minutes: 60, Priority: 1},
+		{ID: 3, Minutes: 20},
+	}
+	plan := Schedule(tasks, []Interval{span(9, 0, 12, 0)})
+
+	want := []Block{
+		{TaskID: 2, Start: at(9, 0), End: at(10, 0)},
+		{TaskID: 1, Start: at(10, 0), End: at(10, 30), Late: true},
+		{TaskID: 3, Start: at(10, 30), End: at(10, 50)},
+	}
+	if !reflect.DeepEqual(plan.Blocks, want) {
+		t.Errorf("Blocks = %v, want %v", plan.Blocks, want)
+	}
+	if len(plan.Skipped) != 0 {
+		t.Errorf("Skipped = %v, want none", plan.Skipped)
+	}
+}
+
+func TestScheduleBlockerInLaterInterval(t *testing.T) {
+	// The blocker only fits after lunch, so its dependent cannot use the
+	// morning gap that is still free
+	tasks := []Task{
+		{ID: 1, Minutes: 120},
+		{ID: 2, Minutes: 30, BlockedBy: []int64{1}},
+	}
+	free := []Interval{span(9, 0, 10, 0), span(13, 0, 17, 0)}
+	plan := Schedule(tasks, free)
+
+	want := []Block{
+		{TaskID: 1, Start: at(13, 0), End: at(15, 0)},
+		{TaskID: 2, Start: at(15, 0), End: at(15, 30)},
+	}
+	if !reflect.DeepEqual(plan.Blocks, want) {
+		t.Errorf("Blocks = %v, want %v", plan.Blocks, want)
+	}
+	wantFree := []Interval{span(9, 0, 10, 0), span(15, 30, 17, 0)}
+	if !reflect.DeepEqual(plan.Free, wantFree) {
+		t.Errorf("Free = %v, want %v", plan.Free, wantFree)
+	}
+}
+
+func TestScheduleLate(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Minutes: 60, Deadline: deadline(10, 0)},  // ends exactly at its deadline
+		{ID: 2, Minutes: 30, Deadline: deadline(10, 15)}, // pushed past it by task 1
+		{ID: 3, Minutes: 30},                             // no deadline, never late
+	}
+	plan := Schedule(tasks, []Interval{span(9, 0, 12, 0)})
+
+	late := map[int64]bool{}
+	for _, block := range plan.Blocks {
+		late[block.TaskID] = block.Late
+	}
+	want := map[int64]bool{1: false, 2: true, 3: false}
+	if !reflect.DeepEqual(late, want) {
+		t.Errorf("Late = %v, want %v", late, want)
+	}
+}
@@ -0,0 +1,233 @@
+package quickadd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unit is a calendar unit used by relative dates and recurrence phrases
+type Unit string
+
+const (
+	Day   Unit = "day"
+	Week  Unit = "week"
+	Month Unit = "month"
+	Year  Unit = "year"
+)
+
+func (u Unit) valid() bool {
+	switch u {
+	case Day, Week, Month, Year:
+		return true
+	}
+	return false
+}
+
+// Locale holds the keywords the parser recognises in one language. All
+// keywords are matched case-insensitively against whole words.
+type Locale struct {
+	Code string `json:"code"`
+
+	Today    []string `json:"today"`
+	Tomorrow []string `json:"tomorrow"`
+	Next     []string `json:"next"`  // "next week", "next friday"
+	In       []string `json:"in"`    // "in 3 days"
+	On       []string `json:"on"`    // optional word before a date
+	At       []string `json:"at"`    // optional word before a time
+	Every    []string `json:"every"` // "every 2 weeks", "every monday"
+	Other    []string `json:"other"` // "every other week"
+	AM       []string `json:"am"`
+	PM       []string `json:"pm"`
+
+	// Ordinals are stripped from day numbers, as in "5th"
+	Ordinals []string `json:"ordinals"`
+	// Weekdays is the word for "every weekday": Monday to Friday
+	Weekdays []string `json:"weekdays"`
+
+	Units       map[string]Unit         `json:"units"`       // "day", "weeks", ...
+	Frequencies map[string]Unit         `json:"frequencies"` // "daily", "weekly", ...
+	DayNames    map[string]time.Weekday `json:"day_names"`
+	MonthNames  map[string]time.Month   `json:"month_names"`
+}
+
+// English is the built-in default locale. "sun" and "sat" are left out of
+// the day names because they are ordinary words in task names.
+var English = &Locale{
+	Code:     "en",
+	Today:    []string{"today", "tonight"},
+	Tomorrow: []string{"tomorrow", "tmr"},
+	Next:     []string{"next"},
+	In:       []string{"in"},
+	On:       []string{"on"},
+	At:       []string{"at", "@"},
+	Every:    []string{"every", "each"},
+	Other:    []string{"other"},
+	AM:       []string{"am"},
+	PM:       []string{"pm"},
+	Ordinals: []string{"st", "nd", "rd", "th"},
+	Weekdays: []string{"weekday", "weekdays", "workday", "workdays"},
+	Units: map[string]Unit{
+		"day": Day, "days": Day,
+		"week": Week, "weeks": Week,
+		"month": Month, "months": Month,
+		"year": Year, "years": Year,
+	},
+	Frequencies: map[string]Unit{
+		"daily":    Day,
+		"weekly":   Week,
+		"monthly":  Month,
+		"yearly":   Year,
+		"annually": Year,
+	},
+	DayNames: map[string]time.Weekday{
+		"sunday": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday,
+	},
+	MonthNames: map[string]time.Month{
+		"january": time.January, "jan": time.January,
+		"february": time.February, "feb": time.February,
+		"march": time.March, "mar": time.March,
+		"april": time.April, "apr": time.April,
+		"may":  time.May,
+		"june": time.June, "jun": time.June,
+		"july": time.July, "jul": time.July,
+		"august": time.August, "aug": time.August,
+		"september": time.September, "sep": time.September, "sept": time.September,
+		"october": time.October, "oct": time.October,
+		"november": time.November, "nov": time.November,
+		"december": time.December, "dec": time.December,
+	},
+}
+
+var (
+	localesMu sync.RWMutex
+	locales   = map[string]*Locale{English.Code: English}
+)
+
+// Register makes a locale available to Lookup under its code, replacing any
+// locale registered with the same code
+func Register(locale *Locale) error {
+	if locale == nil || strings.TrimSpace(locale.Code) == "" {
+		return fmt.Errorf("locale needs a code")
+	}
+	for word, unit := range locale.Units {
+		if !unit.valid() {
+			return fmt.Errorf("unit %q of %q is not day, week, month or year", unit, word)
+		}
+	}
+	for word, unit := range locale.Frequencies {
+		if !unit.valid() {
+			return fmt.Errorf("frequency %q of %q is not day, week, month or year", unit, word)
+		}
+	}
+	normalized := locale.normalize()
+
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	locales[normalized.Code] = normalized
+	return nil
+}
+
+// Lookup returns the locale registered under code; the code is matched
+// case-insensitively and "en-US" falls back to "en"
+func Lookup(code string) (*Locale, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return English, true
+	}
+
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	if locale, ok := locales[code]; ok {
+		return locale, true
+	}
+	if base, _, found := strings.Cut(code, "-"); found {
+		if locale, ok := locales[base]; ok {
+			return locale, true
+		}
+	}
+	return nil, false
+}
+
+// LoadLocaleFile registers the locales in a JSON file holding an array of
+// Locale objects. Day and month names are given as numbers: 0 is Sunday and
+// 1 is January.
+func LoadLocaleFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var loaded []*Locale
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return 0, fmt.Errorf("invalid locale file %s: %w", path, err)
+	}
+	for _, locale := range loaded {
+		if err := Register(locale); err != nil {
+			return 0, fmt.Errorf("invalid locale file %s: %w", path, err)
+		}
+	}
+	return len(loaded), nil
+}
+
+// normalize returns a copy of the locale with every keyword lower-cased
+func (l *Locale) normalize() *Locale {
+	lower := func(words []string) []string {
+		out := make([]string, len(words))
+		for i, word := range words {
+			out[i] = strings.ToLower(strings.TrimSpace(word))
+		}
+		return out
+	}
+
+	n := &Locale{
+		Code:        strings.ToLower(strings.TrimSpace(l.Code)),
+		Today:       lower(l.Today),
+		Tomorrow:    lower(l.Tomorrow),
+		Next:        lower(l.Next),
+		In:          lower(l.In),
+		On:          lower(l.On),
+		At:          lower(l.At),
+		Every:       lower(l.Every),
+		Other:       lower(l.Other),
+		AM:          lower(l.AM),
+		PM:          lower(l.PM),
+		Ordinals:    lower(l.Ordinals),
+		Weekdays:    lower(l.Weekdays),
+		Units:       make(map[string]Unit, len(l.Units)),
+		Frequencies: make(map[string]Unit, len(l.Frequencies)),
+		DayNames:    make(map[string]time.Weekday, len(l.DayNames)),
+		MonthNames:  make(map[string]time.Month, len(l.MonthNames)),
+	}
+	for word, unit := range l.Units {
+		n.Units[strings.ToLower(word)] = unit
+	}
+	for word, unit := range l.Frequencies {
+		n.Frequencies[strings.ToLower(word)] = unit
+	}
+	for word, day := range l.DayNames {
+		n.DayNames[strings.ToLower(word)] = day
+	}
+	for word, month := range l.MonthNames {
+		n.MonthNames[strings.ToLower(word)] = month
+	}
+	return n
+}
+
+func contains(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
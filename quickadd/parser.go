@@ -0,0 +1,528 @@
+// Package quickadd turns a one-line task description such as
+//
+//	Pay utility bills tomorrow 5pm #Finance !3 every month
+//
+// into its parts: the task name, a due date, a category (#word), a priority
+// (!n) and a recurrence rule. Words the parser does not recognise make up the
+// name; text in double quotes is always kept in the name as written.
+package quickadd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"todo-backend/recurrence"
+	"unicode"
+)
+
+// ErrEmptyName is returned when nothing is left for the task name once the
+// date, category, priority and recurrence have been taken out
+var ErrEmptyName = errors.New("task name is empty")
+
+// endOfDay is the time of day given to due dates without a time, so that a
+// task due today is not overdue before the day is over
+const (
+	endOfDayHour   = 23
+	endOfDayMinute = 59
+)
+
+// Match is one recognised part of the line
+type Match struct {
+	Kind string `json:"kind"` // due_date, time, category, priority or recurrence
+	Text string `json:"text"` // the words as written
+}
+
+// Result is the parsed line. Fields the line did not mention are nil.
+type Result struct {
+	Name               string     `json:"task_name"`
+	DueDate            *time.Time `json:"due_date"`
+	Category           *string    `json:"category"`
+	Priority           *int16     `json:"priority"`
+	RecurringFrequency *string    `json:"recurring_frequency"`
+	Locale             string     `json:"locale"`
+	Matches            []Match    `json:"matches"`
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+type parser struct {
+	locale *Locale
+	now    time.Time
+	tokens []token
+
+	date  *time.Time // midnight of the due day
+	clock *[2]int    // hour and minute
+	rule  *recurrence.Rule
+	name  []string
+	res   *Result
+}
+
+// Parse parses a line against a locale (English when nil). Relative dates
+// and times are resolved against now, in now's location, which should be
+// the user's timezone.
+func Parse(line string, now time.Time, locale *Locale) (*Result, error) {
+	if locale == nil {
+		locale = English
+	}
+	p := &parser{
+		locale: locale,
+		now:    now,
+		tokens: tokenize(line),
+		res:    &Result{Locale: locale.Code, Matches: []Match{}},
+	}
+
+	for i := 0; i < len(p.tokens); {
+		if p.tokens[i].quoted {
+			p.name = append(p.name, p.tokens[i].text)
+			i++
+			continue
+		}
+		n := p.match(i)
+		if n == 0 {
+			p.name = append(p.name, p.tokens[i].text)
+			n = 1
+		}
+		i += n
+	}
+
+	p.finish()
+	if p.res.Name == "" {
+		return nil, ErrEmptyName
+	}
+	return p.res, nil
+}
+
+// tokenize splits a line on whitespace; double quotes group words into one token
+func tokenize(line string) []token {
+	var tokens []token
+	var current strings.Builder
+	quoted, inQuotes := false, false
+
+	flush := func() {
+		if current.Len() > 0 || quoted {
+			tokens = append(tokens, token{text: current.String(), quoted: quoted})
+		}
+		current.Reset()
+		quoted = false
+	}
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			if inQuotes {
+				inQuotes = false
+				flush()
+			} else {
+				flush()
+				inQuotes, quoted = true, true
+			}
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// word returns the lower-cased token at i without trailing punctuation, or
+// "" past the end or for quoted tokens
+func (p *parser) word(i int) string {
+	if i >= len(p.tokens) || p.tokens[i].quoted {
+		return ""
+	}
+	return strings.ToLower(strings.TrimRight(p.tokens[i].text, ",.;:"))
+}
+
+// text returns the tokens i to i+n-1 as written
+func (p *parser) text(i, n int) string {
+	words := make([]string, 0, n)
+	for _, t := range p.tokens[i : i+n] {
+		words = append(words, t.text)
+	}
+	return strings.Join(words, " ")
+}
+
+func (p *parser) record(kind string, i, n int) int {
+	p.res.Matches = append(p.res.Matches, Match{Kind: kind, Text: p.text(i, n)})
+	return n
+}
+
+// match tries each kind of phrase at token i and returns how many tokens it
+// consumed; 0 leaves the token in the name. A part given twice is only taken
+// the first time.
+func (p *parser) match(i int) int {
+	word := p.word(i)
+	if word == "" {
+		return 0
+	}
+
+	if p.res.Category == nil && strings.HasPrefix(word, "#") && len(word) > 1 {
+		category := strings.TrimRight(p.tokens[i].text, ",.;:")[1:]
+		p.res.Category = &category
+		return p.record("category", i, 1)
+	}
+	if p.res.Priority == nil && strings.HasPrefix(word, "!") {
+		if n, err := strconv.Atoi(word[1:]); err == nil && n >= 0 && n <= 9 {
+			priority := int16(n)
+			p.res.Priority = &priority
+			return p.record("priority", i, 1)
+		}
+	}
+	if p.rule == nil {
+		if n := p.matchRecurrence(i); n > 0 {
+			return p.record("recurrence", i, n)
+		}
+	}
+	if p.date == nil {
+		if n := p.matchDate(i); n > 0 {
+			return p.record("due_date", i, n)
+		}
+		if contains(p.locale.On, word) {
+			if n := p.matchDate(i + 1); n > 0 {
+				return p.record("due_date", i, n+1)
+			}
+		}
+	}
+	if p.clock == nil {
+		if n := p.matchTime(i, false); n > 0 {
+			return p.record("time", i, n)
+		}
+		if contains(p.locale.At, word) {
+			if n := p.matchTime(i+1, true); n > 0 {
+				return p.record("time", i, n+1)
+			}
+		}
+	}
+	return 0
+}
+
+func (p *parser) today() time.Time {
+	return time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.now.Location())
+}
+
+func (p *parser) setDate(t time.Time) {
+	p.date = &t
+}
+
+// addUnit moves a date n units forward
+func addUnit(t time.Time, unit Unit, n int) time.Time {
+	switch unit {
+	case Week:
+		return t.AddDate(0, 0, 7*n)
+	case Month:
+		return t.AddDate(0, n, 0)
+	case Year:
+		return t.AddDate(n, 0, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// nextWeekday returns the first given weekday after today
+func (p *parser) nextWeekday(day time.Weekday) time.Time {
+	today := p.today()
+	ahead := (int(day) - int(today.Weekday()) + 7) % 7
+	if ahead == 0 {
+		ahead = 7
+	}
+	return today.AddDate(0, 0, ahead)
+}
+
+// weekdayNextWeek returns the given weekday in the week after this one, with
+// weeks starting on Monday: on a Wednesday "next friday" is nine days away
+// and "next monday" five
+func (p *parser) weekdayNextWeek(day time.Weekday) time.Time {
+	today := p.today()
+	monday := today.AddDate(0, 0, 7-(int(today.Weekday())+6)%7)
+	return monday.AddDate(0, 0, (int(day)+6)%7)
+}
+
+// matchDate recognises today, tomorrow, weekdays, "next week", "in 3 days",
+// "+3d", "2026-11-05", "nov 5" and "5 nov" (each optionally with a year)
+func (p *parser) matchDate(i int) int {
+	word := p.word(i)
+	if word == "" {
+		return 0
+	}
+	loc := p.locale
+	today := p.today()
+
+	switch {
+	case contains(loc.Today, word):
+		p.setDate(today)
+		return 1
+	case contains(loc.Tomorrow, word):
+		p.setDate(today.AddDate(0, 0, 1))
+		return 1
+	}
+	if day, ok := loc.DayNames[word]; ok {
+		p.setDate(p.nextWeekday(day))
+		return 1
+	}
+	if contains(loc.Next, word) {
+		next := p.word(i + 1)
+		if day, ok := loc.DayNames[next]; ok {
+			p.setDate(p.weekdayNextWeek(day))
+			return 2
+		}
+		if unit, ok := loc.Units[next]; ok {
+			p.setDate(addUnit(today, unit, 1))
+			return 2
+		}
+		return 0
+	}
+	if contains(loc.In, word) {
+		n, err := strconv.Atoi(p.word(i + 1))
+		unit, ok := loc.Units[p.word(i+2)]
+		if err == nil && n >= 0 && ok {
+			p.setDate(addUnit(today, unit, n))
+			return 3
+		}
+		return 0
+	}
+
+	if len(word) >= 3 && word[0] == '+' {
+		if n, err := strconv.Atoi(word[1 : len(word)-1]); err == nil {
+			switch word[len(word)-1] {
+			case 'd':
+				p.setDate(today.AddDate(0, 0, n))
+				return 1
+			case 'w':
+				p.setDate(today.AddDate(0, 0, 7*n))
+				return 1
+			}
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", word, p.now.Location()); err == nil {
+		p.setDate(t)
+		return 1
+	}
+
+	// "nov 5" and "5 nov", optionally followed by a year
+	if month, ok := loc.MonthNames[word]; ok {
+		if day, ok := p.dayNumber(i + 1); ok {
+			return 2 + p.setMonthDay(month, day, i+2)
+		}
+		return 0
+	}
+	if day, ok := p.dayNumber(i); ok {
+		if month, ok := loc.MonthNames[p.word(i+1)]; ok {
+			return 2 + p.setMonthDay(month, day, i+2)
+		}
+	}
+	return 0
+}
+
+// dayNumber reads a day of the month such as 5 or 5th
+func (p *parser) dayNumber(i int) (int, bool) {
+	word := p.word(i)
+	for _, suffix := range p.locale.Ordinals {
+		if suffix != "" && strings.HasSuffix(word, suffix) {
+			word = strings.TrimSuffix(word, suffix)
+			break
+		}
+	}
+	day, err := strconv.Atoi(word)
+	if err != nil || day < 1 || day > 31 {
+		return 0, false
+	}
+	return day, true
+}
+
+// setMonthDay sets the date to a day of a month. A year may follow at token
+// i; without one the date is the next such day from today. It returns 1 if
+// it consumed a year.
+func (p *parser) setMonthDay(month time.Month, day, i int) int {
+	if year, err := strconv.Atoi(p.word(i)); err == nil && year >= 1000 && year <= 9999 {
+		p.setDate(time.Date(year, month, day, 0, 0, 0, 0, p.now.Location()))
+		return 1
+	}
+
+	today := p.today()
+	date := time.Date(today.Year(), month, day, 0, 0, 0, 0, p.now.Location())
+	if date.Before(today) {
+		date = time.Date(today.Year()+1, month, day, 0, 0, 0, 0, p.now.Location())
+	}
+	p.setDate(date)
+	return 0
+}
+
+// matchTime recognises 5pm, 5:30pm, "5 pm" and 17:00. A bare hour such as
+// "5" only counts as a time after "at" (afterAt).
+func (p *parser) matchTime(i int, afterAt bool) int {
+	word := p.word(i)
+	if word == "" {
+		return 0
+	}
+
+	consumed := 1
+	meridiem := ""
+	for _, suffix := range append(append([]string{}, p.locale.AM...), p.locale.PM...) {
+		if suffix != "" && strings.HasSuffix(word, suffix) && len(word) > len(suffix) {
+			meridiem = suffix
+			word = strings.TrimSuffix(word, suffix)
+			break
+		}
+	}
+	if meridiem == "" {
+		next := p.word(i + 1)
+		if contains(p.locale.AM, next) || contains(p.locale.PM, next) {
+			meridiem = next
+			consumed = 2
+		}
+	}
+
+	hourText, minuteText, hasMinutes := strings.Cut(word, ":")
+	hour, err := strconv.Atoi(hourText)
+	if err != nil {
+		return 0
+	}
+	minute := 0
+	if hasMinutes {
+		if len(minuteText) != 2 {
+			return 0
+		}
+		if minute, err = strconv.Atoi(minuteText); err != nil || minute > 59 {
+			return 0
+		}
+	}
+
+	switch {
+	case meridiem != "":
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		if hour == 12 {
+			hour = 0
+		}
+		if contains(p.locale.PM, meridiem) {
+			hour += 12
+		}
+	case hasMinutes || afterAt:
+		if hour > 23 {
+			return 0
+		}
+	default:
+		return 0
+	}
+
+	p.clock = &[2]int{hour, minute}
+	return consumed
+}
+
+// matchRecurrence recognises "daily", "every day", "every 2 weeks", "every
+// other month", "every weekday" and "every mon,wed,fri"
+func (p *parser) matchRecurrence(i int) int {
+	word := p.word(i)
+	loc := p.locale
+
+	if unit, ok := loc.Frequencies[word]; ok {
+		p.rule = &recurrence.Rule{Freq: frequency(unit), Interval: 1}
+		return 1
+	}
+	if !contains(loc.Every, word) {
+		return 0
+	}
+
+	next := p.word(i + 1)
+	if unit, ok := loc.Units[next]; ok {
+		p.rule = &recurrence.Rule{Freq: frequency(unit), Interval: 1}
+		return 2
+	}
+	interval := 0
+	if contains(loc.Other, next) {
+		interval = 2
+	} else if n, err := strconv.Atoi(next); err == nil && n >= 1 {
+		interval = n
+	}
+	if interval > 0 {
+		if unit, ok := loc.Units[p.word(i+2)]; ok {
+			p.rule = &recurrence.Rule{Freq: frequency(unit), Interval: interval}
+			return 3
+		}
+		return 0
+	}
+
+	if contains(loc.Weekdays, next) {
+		p.rule = &recurrence.Rule{Freq: recurrence.Weekly, Interval: 1, ByDay: []recurrence.WeekdayNum{
+			{Weekday: time.Monday}, {Weekday: time.Tuesday}, {Weekday: time.Wednesday},
+			{Weekday: time.Thursday}, {Weekday: time.Friday},
+		}}
+		return 2
+	}
+
+	var days []recurrence.WeekdayNum
+	for _, name := range strings.Split(next, ",") {
+		day, ok := loc.DayNames[name]
+		if !ok {
+			return 0
+		}
+		days = append(days, recurrence.WeekdayNum{Weekday: day})
+	}
+	p.rule = &recurrence.Rule{Freq: recurrence.Weekly, Interval: 1, ByDay: days}
+	return 2
+}
+
+func frequency(unit Unit) recurrence.Frequency {
+	switch unit {
+	case Week:
+		return recurrence.Weekly
+	case Month:
+		return recurrence.Monthly
+	case Year:
+		return recurrence.Yearly
+	}
+	return recurrence.Daily
+}
+
+// finish assembles the due date and name. A time without a date means the
+// next time the clock shows it; a recurrence on weekdays without a date
+// starts on the first matching day that is not over yet.
+func (p *parser) finish() {
+	today := p.today()
+	hour, minute := endOfDayHour, endOfDayMinute
+	if p.clock != nil {
+		hour, minute = p.clock[0], p.clock[1]
+	}
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, p.now.Location())
+	}
+
+	if p.rule != nil {
+		rule := p.rule.String()
+		p.res.RecurringFrequency = &rule
+
+		if p.date == nil && len(p.rule.ByDay) > 0 {
+			for ahead := 0; ahead <= 7 && p.date == nil; ahead++ {
+				day := today.AddDate(0, 0, ahead)
+				if !at(day).After(p.now) {
+					continue
+				}
+				for _, wd := range p.rule.ByDay {
+					if wd.Weekday == day.Weekday() {
+						p.setDate(day)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	if p.date == nil && p.clock != nil {
+		due := at(today)
+		if !due.After(p.now) {
+			due = at(today.AddDate(0, 0, 1))
+		}
+		p.res.DueDate = &due
+	} else if p.date != nil {
+		due := at(*p.date)
+		p.res.DueDate = &due
+	}
+
+	p.res.Name = strings.Join(strings.Fields(strings.Join(p.name, " ")), " ")
+}
@@ -0,0 +1,173 @@
+package quickadd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// now is a Wednesday afternoon
+var now = time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC)
+
+type want struct {
+	name       string
+	due        string // "2006-01-02 15:04", "" for none
+	category   string
+	priority   int16 // -1 for none
+	recurrence string
+	err        error
+}
+
+func check(t *testing.T, line string, res *Result, err error, w want) {
+	t.Helper()
+	if w.err != nil {
+		if !errors.Is(err, w.err) {
+			t.Fatalf("Parse(%q) error = %v, want %v", line, err, w.err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", line, err)
+	}
+	if res.Name != w.name {
+		t.Errorf("Parse(%q) name = %q, want %q", line, res.Name, w.name)
+	}
+	due := ""
+	if res.DueDate != nil {
+		due = res.DueDate.Format("2006-01-02 15:04")
+	}
+	if due != w.due {
+		t.Errorf("Parse(%q) due = %q, want %q", line, due, w.due)
+	}
+	category := ""
+	if res.Category != nil {
+		category = *res.Category
+	}
+	if category != w.category {
+		t.Errorf("Parse(%q) category = %q, want %q", line, category, w.category)
+	}
+	priority := int16(-1)
+	if res.Priority != nil {
+		priority = *res.Priority
+	}
+	if priority != w.priority {
+		t.Errorf("Parse(%q) priority = %d, want %d", line, priority, w.priority)
+	}
+	recurrence := ""
+	if res.RecurringFrequency != nil {
+		recurrence = *res.RecurringFrequency
+	}
+	if recurrence != w.recurrence {
+		t.Errorf("Parse(%q) recurrence = %q, want %q", line, recurrence, w.recurrence)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want want
+	}{
+		// Relative dates are due at the end of the day
+		{"Call mom tomorrow", want{name: "Call mom", due: "2026-10-15 23:59", priority: -1}},
+		{"Submit report next friday", want{name: "Submit report", due: "2026-10-23 23:59", priority: -1}},
+		{"Water plants next monday", want{name: "Water plants", due: "2026-10-19 23:59", priority: -1}},
+		{"Water plants next sunday", want{name: "Water plants", due: "2026-10-25 23:59", priority: -1}},
+		{"Submit report friday", want{name: "Submit report", due: "2026-10-16 23:59", priority: -1}},
+		{"Renew passport in 3 days", want{name: "Renew passport", due: "2026-10-17 23:59", priority: -1}},
+		{"Plan trip next week", want{name: "Plan trip", due: "2026-10-21 23:59", priority: -1}},
+		{"Dentist on nov 5", want{name: "Dentist", due: "2026-11-05 23:59", priority: -1}},
+		{"Dentist 2027-01-02", want{name: "Dentist", due: "2027-01-02 23:59", priority: -1}},
+
+		// A time without a date is the next time the clock shows it
+		{"Pay bills 5pm", want{name: "Pay bills", due: "2026-10-14 17:00", priority: -1}},
+		{"Pay bills 2pm", want{name: "Pay bills", due: "2026-10-15 14:00", priority: -1}},
+		{"Back up laptop at 12am", want{name: "Back up laptop", due: "2026-10-15 00:00", priority: -1}},
+		{"Standup at 9", want{name: "Standup", due: "2026-10-15 09:00", priority: -1}},
+		{"Lunch tomorrow 12:30", want{name: "Lunch", due: "2026-10-15 12:30", priority: -1}},
+		{"Buy 5 apples", want{name: "Buy 5 apples", priority: -1}},
+
+		// Category, priority and recurrence
+		{"Pay utility bills tomorrow 5pm #Finance !3 every month",
+			want{name: "Pay utility bills", due: "2026-10-15 17:00", category: "Finance", priority: 3, recurrence: "FREQ=MONTHLY"}},
+		{"Water plants every other week", want{name: "Water plants", priority: -1, recurrence: "FREQ=WEEKLY;INTERVAL=2"}},
+		{"Gym every monday", want{name: "Gym", due: "2026-10-19 23:59", priority: -1, recurrence: "FREQ=WEEKLY;BYDAY=MO"}},
+		{"Gym every wed 6pm", want{name: "Gym", due: "2026-10-14 18:00", priority: -1, recurrence: "FREQ=WEEKLY;BYDAY=WE"}},
+		{"Review every 2 days", want{name: "Review", priority: -1, recurrence: "FREQ=DAILY;INTERVAL=2"}},
+		{"Timesheet every weekday", want{name: "Timesheet", due: "2026-10-14 23:59", priority: -1, recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"}},
+		{"Stretch daily", want{name: "Stretch", priority: -1, recurrence: "FREQ=DAILY"}},
+
+		// Only the first of each part is taken; quoted text stays in the name
+		{"Sort #home #garage !1 !2", want{name: "Sort #garage !2", category: "home", priority: 1}},
+		{`Watch "next friday" tonight`, want{name: "Watch next friday", due: "2026-10-14 23:59", priority: -1}},
+
+		// Nothing left for the name
+		{"tomorrow", want{err: ErrEmptyName}},
+		{"tomorrow 5pm #Work !2", want{err: ErrEmptyName}},
+		{"", want{err: ErrEmptyName}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			res, err := Parse(tt.line, now, nil)
+			check(t, tt.line, res, err, tt.want)
+		})
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locales.json")
+	data := `[{
+		"code": "de",
+		"today": ["heute"],
+		"tomorrow": ["morgen"],
+		"next": ["nächsten", "nächste"],
+		"in": ["in"],
+		"at": ["um"],
+		"every": ["jeden", "jede"],
+		"other": ["zweite"],
+		"units": {"tag": "day", "tagen": "day", "woche": "week", "wochen": "week", "monat": "month"},
+		"frequencies": {"täglich": "day", "wöchentlich": "week"},
+		"day_names": {"montag": 1, "freitag": 5},
+		"month_names": {"november": 11}
+	}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := LoadLocaleFile(path); err != nil || n != 1 {
+		t.Fatalf("LoadLocaleFile = %d, %v", n, err)
+	}
+	locale, ok := Lookup("de-AT")
+	if !ok || locale.Code != "de" {
+		t.Fatalf("Lookup(de-AT) = %v, %v", locale, ok)
+	}
+
+	tests := []struct {
+		line string
+		want want
+	}{
+		{"Müll rausbringen morgen", want{name: "Müll rausbringen", due: "2026-10-15 23:59", priority: -1}},
+		{"Bericht schreiben in 2 Tagen um 10", want{name: "Bericht schreiben", due: "2026-10-16 10:00", priority: -1}},
+		{"Einkaufen nächsten Freitag #Haushalt", want{name: "Einkaufen", due: "2026-10-23 23:59", category: "Haushalt", priority: -1}},
+		{"Sport jeden Montag", want{name: "Sport", due: "2026-10-19 23:59", priority: -1, recurrence: "FREQ=WEEKLY;BYDAY=MO"}},
+		{"Blumen gießen jede zweite Woche", want{name: "Blumen gießen", priority: -1, recurrence: "FREQ=WEEKLY;INTERVAL=2"}},
+		{"Zeitung lesen täglich", want{name: "Zeitung lesen", priority: -1, recurrence: "FREQ=DAILY"}},
+		// English keywords are not recognised in another locale
+		{"Arzt tomorrow", want{name: "Arzt tomorrow", priority: -1}},
+		{"morgen", want{err: ErrEmptyName}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			res, err := Parse(tt.line, now, locale)
+			check(t, tt.line, res, err, tt.want)
+			if err == nil && res.Locale != "de" {
+				t.Errorf("Parse(%q) locale = %q, want de", tt.line, res.Locale)
+			}
+		})
+	}
+
+	if _, ok := Lookup("fr"); ok {
+		t.Error("Lookup(fr) found a locale that was never registered")
+	}
+}
@@ -0,0 +1,434 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base unit a rule repeats on
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the search for the next occurrence so that rules which
+// can never match (e.g. BYMONTHDAY=31 with INTERVAL=2 starting in February)
+// terminate instead of looping forever
+const maxPeriods = 1000
+
+// WeekdayNum is a BYDAY entry such as MO, 2TU or -1FR. Ordinal is only
+// meaningful for monthly rules; 0 means every such weekday in the period.
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// Rule is a subset of the RFC 5545 RRULE grammar: FREQ, INTERVAL, BYDAY,
+// BYMONTHDAY, UNTIL and COUNT
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	Until      *time.Time
+	Count      int
+
+	// clamp moves a day the month lacks to its last day instead of skipping
+	// the month, as the legacy monthly and yearly frequencies always have
+	clamp bool
+	// anchorDay is the day of the month of the series' first occurrence, set
+	// by Anchor, so that a clamped series returns to it after a short month
+	anchorDay int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// legacyFrequencies maps the plain recurring_frequency values the API has
+// always accepted onto equivalent rules
+var legacyFrequencies = map[string]Frequency{
+	"daily":   Daily,
+	"weekly":  Weekly,
+	"monthly": Monthly,
+	"yearly":  Yearly,
+}
+
+// Parse accepts either an RRULE string (with or without the "RRULE:" prefix)
+// or one of the legacy frequency words daily, weekly, monthly and yearly
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	if freq, ok := legacyFrequencies[strings.ToLower(s)]; ok {
+		return &Rule{Freq: freq, Interval: 1, clamp: freq == Monthly || freq == Yearly}, nil
+	}
+
+	if len(s) > 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed rule part %q", part)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+
+		switch key {
+		case "FREQ":
+			switch Frequency(strings.ToUpper(value)) {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = Frequency(strings.ToUpper(value))
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("INTERVAL must be a positive integer, got %q", value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("COUNT must be a positive integer, got %q", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(item)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(item))
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY value %q", item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "WKST":
+			// Weeks always start on Monday here; accept and ignore the part
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot be combined")
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != Monthly {
+		return nil, errors.New("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	if len(rule.ByDay) > 0 {
+		switch rule.Freq {
+		case Weekly:
+			for _, wd := range rule.ByDay {
+				if wd.Ordinal != 0 {
+					return nil, errors.New("BYDAY ordinals are only supported with FREQ=MONTHLY")
+				}
+			}
+		case Monthly:
+			if len(rule.ByMonthDay) > 0 {
+				return nil, errors.New("BYDAY and BYMONTHDAY cannot be combined")
+			}
+		default:
+			return nil, errors.New("BYDAY is only supported with FREQ=WEEKLY or FREQ=MONTHLY")
+		}
+	}
+
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	layouts := []string{"20060102T150405Z", "20060102T150405", "20060102", time.RFC3339, "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			// A bare date means the whole day is still included
+			if layout == "20060102" || layout == "2006-01-02" {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL value %q", value)
+}
+
+func parseWeekdayNum(item string) (WeekdayNum, error) {
+	item = strings.ToUpper(strings.TrimSpace(item))
+	if len(item) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY value %q", item)
+	}
+
+	code := item[len(item)-2:]
+	weekday, ok := weekdayCodes[code]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY weekday %q", item)
+	}
+
+	wd := WeekdayNum{Weekday: weekday}
+	if prefix := item[:len(item)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY ordinal %q", item)
+		}
+		wd.Ordinal = n
+	}
+	return wd, nil
+}
+
+// String renders the rule back into RRULE form without the "RRULE:" prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (wd WeekdayNum) String() string {
+	if wd.Ordinal == 0 {
+		return weekdayNames[wd.Weekday]
+	}
+	return strconv.Itoa(wd.Ordinal) + weekdayNames[wd.Weekday]
+}
+
+// Anchor records start as the first occurrence of the series. Legacy
+// monthly and yearly rules clamp the 29th to 31st to the end of shorter
+// months; the anchor lets the occurrence after a clamped one go back to the
+// original day (Jan 31, Feb 28, Mar 31) instead of staying on the 28th.
+func (r *Rule) Anchor(start time.Time) {
+	r.anchorDay = start.Day()
+}
+
+// Next returns the first occurrence strictly after prev, keeping prev's time
+// of day and location. ok is false when the rule has run past UNTIL.
+// COUNT is not applied here because it depends on the position of prev in
+// its series; see Upcoming.
+func (r *Rule) Next(prev time.Time) (time.Time, bool) {
+	return r.next(prev, r.dayOf(prev))
+}
+
+// dayOf is the day of the month the series is on at prev: prev's own day,
+// unless prev was clamped to the end of a month shorter than the anchor day
+func (r *Rule) dayOf(prev time.Time) int {
+	day := prev.Day()
+	if r.clamp && r.anchorDay > day && day == daysIn(prev.AddDate(0, 0, 1-day)) {
+		return r.anchorDay
+	}
+	return day
+}
+
+func (r *Rule) next(prev time.Time, day int) (time.Time, bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	var next time.Time
+	found := false
+
+	switch r.Freq {
+	case Daily:
+		next, found = prev.AddDate(0, 0, interval), true
+	case Weekly:
+		next, found = r.nextWeekly(prev, interval)
+	case Monthly:
+		next, found = r.nextMonthly(prev, interval, day)
+	case Yearly:
+		next, found = r.nextYearly(prev, interval, day)
+	}
+
+	if !found {
+		return time.Time{}, false
+	}
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Upcoming returns up to n occurrences that follow prev, where prev is the
+// index-th occurrence (1-based) of its series. It honours both COUNT and UNTIL.
+func (r *Rule) Upcoming(prev time.Time, index, n int) []time.Time {
+	occurrences := make([]time.Time, 0, n)
+	current := prev
+	day := r.dayOf(prev)
+	for len(occurrences) < n {
+		if r.Count > 0 && index >= r.Count {
+			break
+		}
+		next, ok := r.next(current, day)
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, next)
+		current = next
+		index++
+	}
+	return occurrences
+}
+
+func (r *Rule) nextWeekly(prev time.Time, interval int) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return prev.AddDate(0, 0, 7*interval), true
+	}
+
+	// Weeks start on Monday (WKST=MO)
+	offsets := make([]int, 0, len(r.ByDay))
+	for _, wd := range r.ByDay {
+		offsets = append(offsets, mondayOffset(wd.Weekday))
+	}
+	sort.Ints(offsets)
+
+	current := mondayOffset(prev.Weekday())
+	for _, offset := range offsets {
+		if offset > current {
+			return prev.AddDate(0, 0, offset-current), true
+		}
+	}
+
+	weekStart := prev.AddDate(0, 0, -current)
+	return weekStart.AddDate(0, 0, 7*interval+offsets[0]), true
+}
+
+func (r *Rule) nextMonthly(prev time.Time, interval, day int) (time.Time, bool) {
+	year, month, _ := prev.Date()
+	monthStart := time.Date(year, month, 1, prev.Hour(), prev.Minute(), prev.Second(), prev.Nanosecond(), prev.Location())
+
+	for i := 0; i < maxPeriods; i++ {
+		start := monthStart.AddDate(0, i*interval, 0)
+		for _, d := range r.monthDays(start, day) {
+			candidate := start.AddDate(0, 0, d-1)
+			if candidate.After(prev) {
+				return candidate, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// monthDays lists the matching days of the month that starts at monthStart,
+// in ascending order. defaultDay is used when the rule has no BY* parts.
+func (r *Rule) monthDays(monthStart time.Time, defaultDay int) []int {
+	last := daysIn(monthStart)
+	var days []int
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = last + d + 1
+			}
+			if d >= 1 && d <= last {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		firstWeekday := monthStart.Weekday()
+		for _, wd := range r.ByDay {
+			first := 1 + (int(wd.Weekday)-int(firstWeekday)+7)%7
+			var matches []int
+			for d := first; d <= last; d += 7 {
+				matches = append(matches, d)
+			}
+			switch {
+			case wd.Ordinal == 0:
+				days = append(days, matches...)
+			case wd.Ordinal > 0 && wd.Ordinal <= len(matches):
+				days = append(days, matches[wd.Ordinal-1])
+			case wd.Ordinal < 0 && -wd.Ordinal <= len(matches):
+				days = append(days, matches[len(matches)+wd.Ordinal])
+			}
+		}
+	default:
+		// Months without this day are skipped, as RFC 5545 requires, unless
+		// the rule is a legacy one that clamps to the last day
+		if defaultDay <= last {
+			days = append(days, defaultDay)
+		} else if r.clamp {
+			days = append(days, last)
+		}
+	}
+
+	sort.Ints(days)
+	return days
+}
+
+func (r *Rule) nextYearly(prev time.Time, interval, day int) (time.Time, bool) {
+	year, month, _ := prev.Date()
+	for i := 1; i <= maxPeriods; i++ {
+		candidate := time.Date(year+i*interval, month, day, prev.Hour(), prev.Minute(), prev.Second(), prev.Nanosecond(), prev.Location())
+		// Feb 29 only exists in leap years; time.Date would normalise it to Mar 1
+		if candidate.Month() == month {
+			return candidate, true
+		}
+		if r.clamp {
+			return candidate.AddDate(0, 0, -candidate.Day()), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func daysIn(monthStart time.Time) int {
+	return monthStart.AddDate(0, 1, -1).Day()
+}
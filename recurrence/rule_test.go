@@ -0,0 +1,185 @@
+package recurrence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestUpcoming(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		prev  string
+		index int
+		n     int
+		want  []string
+	}{
+		// Legacy frequencies
+		{
+			name: "legacy monthly clamps to the last day",
+			rule: "monthly", prev: "2026-01-31 09:00", index: 1, n: 5,
+			want: []string{"2026-02-28 09:00", "2026-03-31 09:00", "2026-04-30 09:00", "2026-05-31 09:00", "2026-06-30 09:00"},
+		},
+		{
+			name: "legacy monthly in a leap year",
+			rule: "monthly", prev: "2028-01-30 09:00", index: 1, n: 2,
+			want: []string{"2028-02-29 09:00", "2028-03-30 09:00"},
+		},
+		{
+			name: "legacy yearly on feb 29",
+			rule: "yearly", prev: "2024-02-29 09:00", index: 1, n: 4,
+			want: []string{"2025-02-28 09:00", "2026-02-28 09:00", "2027-02-28 09:00", "2028-02-29 09:00"},
+		},
+
+		// Without a clamp, months and years that lack the day are skipped
+		{
+			name: "monthly rrule skips short months",
+			rule: "FREQ=MONTHLY", prev: "2026-01-31 09:00", index: 1, n: 3,
+			want: []string{"2026-03-31 09:00", "2026-05-31 09:00", "2026-07-31 09:00"},
+		},
+		{
+			name: "yearly rrule on feb 29",
+			rule: "FREQ=YEARLY", prev: "2024-02-29 09:00", index: 1, n: 2,
+			want: []string{"2028-02-29 09:00", "2032-02-29 09:00"},
+		},
+
+		// BYDAY with ordinals
+		{
+			name: "second tuesday",
+			rule: "FREQ=MONTHLY;BYDAY=2TU", prev: "2026-10-13 18:30", index: 1, n: 3,
+			want: []string{"2026-11-10 18:30", "2026-12-08 18:30", "2027-01-12 18:30"},
+		},
+		{
+			name: "last friday",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR", prev: "2026-10-30 16:00", index: 1, n: 3,
+			want: []string{"2026-11-27 16:00", "2026-12-25 16:00", "2027-01-29 16:00"},
+		},
+		{
+			name: "fifth monday skips months without one",
+			rule: "FREQ=MONTHLY;BYDAY=5MO", prev: "2026-08-31 08:00", index: 1, n: 2,
+			want: []string{"2026-11-30 08:00", "2027-03-29 08:00"},
+		},
+		{
+			name: "first and third wednesday",
+			rule: "FREQ=MONTHLY;BYDAY=1WE,3WE", prev: "2026-10-07 12:00", index: 1, n: 3,
+			want: []string{"2026-10-21 12:00", "2026-11-04 12:00", "2026-11-18 12:00"},
+		},
+
+		// INTERVAL
+		{
+			name: "every third day",
+			rule: "FREQ=DAILY;INTERVAL=3", prev: "2026-10-30 07:00", index: 1, n: 2,
+			want: []string{"2026-11-02 07:00", "2026-11-05 07:00"},
+		},
+		{
+			name: "every other week on monday and thursday",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", prev: "2026-10-12 10:00", index: 1, n: 4,
+			want: []string{"2026-10-15 10:00", "2026-10-26 10:00", "2026-10-29 10:00", "2026-11-09 10:00"},
+		},
+		{
+			name: "every third month on the last day",
+			rule: "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=-1", prev: "2026-01-31 09:00", index: 1, n: 3,
+			want: []string{"2026-04-30 09:00", "2026-07-31 09:00", "2026-10-31 09:00"},
+		},
+
+		// COUNT and UNTIL
+		{
+			name: "count includes the first occurrence",
+			rule: "FREQ=DAILY;COUNT=3", prev: "2026-10-16 09:00", index: 1, n: 5,
+			want: []string{"2026-10-17 09:00", "2026-10-18 09:00"},
+		},
+		{
+			name: "count already reached",
+			rule: "FREQ=WEEKLY;COUNT=4", prev: "2026-10-16 09:00", index: 4, n: 5,
+			want: []string{},
+		},
+		{
+			name: "until date is inclusive",
+			rule: "FREQ=WEEKLY;UNTIL=20261102", prev: "2026-10-12 10:00", index: 1, n: 5,
+			want: []string{"2026-10-19 10:00", "2026-10-26 10:00", "2026-11-02 10:00"},
+		},
+		{
+			name: "until with a time of day",
+			rule: "FREQ=DAILY;UNTIL=20261018T080000Z", prev: "2026-10-16 09:00", index: 1, n: 5,
+			want: []string{"2026-10-17 09:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := []string{}
+			for _, occurrence := range rule.Upcoming(date(tt.prev), tt.index, tt.n) {
+				got = append(got, occurrence.Format("2006-01-02 15:04"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Upcoming() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextAnchored(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		anchor string
+		prev   string
+		want   string
+	}{
+		{
+			name: "clamped occurrence returns to the anchor day",
+			rule: "monthly", anchor: "2026-01-31 09:00", prev: "2026-02-28 09:00",
+			want: "2026-03-31 09:00",
+		},
+		{
+			name: "clamped to the 30th returns to the 31st",
+			rule: "monthly", anchor: "2026-01-31 09:00", prev: "2026-04-30 09:00",
+			want: "2026-05-31 09:00",
+		},
+		{
+			name: "rescheduled occurrence keeps its own day",
+			rule: "monthly", anchor: "2026-01-31 09:00", prev: "2026-02-15 09:00",
+			want: "2026-03-15 09:00",
+		},
+		{
+			name: "clamped yearly returns to feb 29",
+			rule: "yearly", anchor: "2024-02-29 09:00", prev: "2027-02-28 09:00",
+			want: "2028-02-29 09:00",
+		},
+		{
+			name: "rrule ignores the anchor",
+			rule: "FREQ=MONTHLY", anchor: "2026-01-31 09:00", prev: "2026-02-28 09:00",
+			want: "2026-03-28 09:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			rule.Anchor(date(tt.anchor))
+			next, ok := rule.Next(date(tt.prev))
+			if !ok {
+				t.Fatalf("Next() found no occurrence")
+			}
+			if got := next.Format("2006-01-02 15:04"); got != tt.want {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+)
+
+// App Password Repository
+type AppPasswordRepository interface {
+	CreateAppPassword(password *models.AppPassword, tokenHash string) (*models.AppPassword, error)
+	GetAppPasswords(userID int64) ([]*models.AppPassword, error)
+	DeleteAppPassword(passwordID, userID int64) error
+	GetUserByAppPassword(tokenHash string) (*models.User, error)
+}
+
+type appPasswordRepository struct {
+	db *sql.DB
+}
+
+func NewAppPasswordRepository(db *sql.DB) AppPasswordRepository {
+	return &appPasswordRepository{db: db}
+}
+
+const appPasswordColumns = `id, user_id, name, created_at, last_used_at`
+
+func scanAppPassword(row rowScanner) (*models.AppPassword, error) {
+	password := &models.AppPassword{}
+	err := row.Scan(&password.ID, &password.UserID, &password.Name, &password.CreatedAt, &password.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return password, nil
+}
+
+func (r *appPasswordRepository) CreateAppPassword(password *models.AppPassword, tokenHash string) (*models.AppPassword, error) {
+	return scanAppPassword(r.db.QueryRow(`
+		INSERT INTO app_passwords (user_id, name, token_hash)
+		VALUES ($1, $2, $3)
+		RETURNING `+appPasswordColumns,
+		password.UserID, password.Name, tokenHash))
+}
+
+func (r *appPasswordRepository) GetAppPasswords(userID int64) ([]*models.AppPassword, error) {
+	rows, err := r.db.Query(`
+		SELECT `+appPasswordColumns+`
+		FROM app_passwords
+		WHERE user_id = $1
+		ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passwords := []*models.AppPassword{}
+	for rows.Next() {
+		password, err := scanAppPassword(rows)
+		if err != nil {
+			return nil, err
+		}
+		passwords = append(passwords, password)
+	}
+	return passwords, rows.Err()
+}
+
+// DeleteAppPassword revokes a password; clients using it are signed out
+func (r *appPasswordRepository) DeleteAppPassword(passwordID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM app_passwords WHERE id = $1 AND user_id = $2`, passwordID, userID)
+	return requireRow(result, err)
+}
+
+// GetUserByAppPassword returns the owner of a password and notes its use
+func (r *appPasswordRepository) GetUserByAppPassword(tokenHash string) (*models.User, error) {
+	user := &models.User{}
+	err := r.db.QueryRow(`
+		WITH used AS (
+			UPDATE app_passwords SET last_used_at = now()
+			WHERE token_hash = $1
+			RETURNING user_id
+		)
+		SELECT u.id, u.username FROM users u JOIN used ON used.user_id = u.id`, tokenHash).Scan(&user.ID, &user.Username)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+)
+
+// Attachment Repository
+type AttachmentRepository interface {
+	CreateAttachment(attachment *models.TaskAttachment, quotaBytes int64) (*models.TaskAttachment, error)
+	GetTaskAttachments(taskID, userID int64) ([]*models.TaskAttachment, error)
+	GetAttachment(attachmentID, taskID, userID int64) (*models.TaskAttachment, error)
+	GetUsage(userID int64) (int64, error)
+	DetachAttachment(attachmentID, taskID, userID int64) error
+	GetOrphanAttachments(limit int) ([]*models.TaskAttachment, error)
+	DeleteAttachment(attachmentID int64) error
+}
+
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+const attachmentColumns = `id, user_id, task_id, file_name, content_type, size_bytes, checksum, storage_key, created_at`
+
+func scanAttachment(row rowScanner) (*models.TaskAttachment, error) {
+	attachment := &models.TaskAttachment{}
+	err := row.Scan(&attachment.ID, &attachment.UserID, &attachment.TaskID, &attachment.FileName, &attachment.ContentType,
+		&attachment.SizeBytes, &attachment.Checksum, &attachment.StorageKey, &attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func scanAttachments(rows *sql.Rows) ([]*models.TaskAttachment, error) {
+	attachments := []*models.TaskAttachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// CreateAttachment records an uploaded attachment unless it would take the
+// user's attachments past quotaBytes, in which case sql.ErrNoRows is
+// returned. The user's attachments are locked while the quota is checked so
+// that concurrent uploads cannot both squeeze in.
+func (r *attachmentRepository) CreateAttachment(attachment *models.TaskAttachment, quotaBytes int64) (*models.TaskAttachment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, attachment.UserID); err != nil {
+		return nil, err
+	}
+	createdAttachment, err := scanAttachment(tx.QueryRow(`
+		INSERT INTO task_attachments (user_id, task_id, file_name, content_type, size_bytes, checksum, storage_key)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE (SELECT COALESCE(SUM(size_bytes), 0) FROM task_attachments WHERE user_id = $1) + $5 <= $8
+		RETURNING `+attachmentColumns,
+		attachment.UserID, attachment.TaskID, attachment.FileName, attachment.ContentType,
+		attachment.SizeBytes, attachment.Checksum, attachment.StorageKey, quotaBytes))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return createdAttachment, nil
+}
+
+// GetTaskAttachments returns the attachments of a task that is not in the trash
+func (r *attachmentRepository) GetTaskAttachments(taskID, userID int64) ([]*models.TaskAttachment, error) {
+	rows, err := r.db.Query(`
+		SELECT `+attachmentColumns+`
+		FROM task_attachments
+		WHERE task_id = $1 AND user_id = $2
+			AND EXISTS (SELECT 1 FROM tasks t WHERE t.id = $1 AND t.deleted_at IS NULL)
+		ORDER BY created_at, id`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAttachments(rows)
+}
+
+func (r *attachmentRepository) GetAttachment(attachmentID, taskID, userID int64) (*models.TaskAttachment, error) {
+	return scanAttachment(r.db.QueryRow(`
+		SELECT `+attachmentColumns+`
+		FROM task_attachments
+		WHERE id = $1 AND task_id = $2 AND user_id = $3
+			AND EXISTS (SELECT 1 FROM tasks t WHERE t.id = $2 AND t.deleted_at IS NULL)`, attachmentID, taskID, userID))
+}
+
+// GetUsage returns the bytes a user's attachments take up, counting those of
+// trashed tasks and those waiting to be swept
+func (r *attachmentRepository) GetUsage(userID int64) (int64, error) {
+	var used int64
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(size_bytes), 0) FROM task_attachments WHERE user_id = $1`, userID).Scan(&used)
+	return used, err
+}
+
+// DetachAttachment takes an attachment off its task; the row and its blob
+// are then removed by the orphan sweep
+func (r *attachmentRepository) DetachAttachment(attachmentID, taskID, userID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE task_attachments SET task_id = NULL
+		WHERE id = $1 AND task_id = $2 AND user_id = $3`, attachmentID, taskID, userID)
+	return requireRow(result, err)
+}
+
+// GetOrphanAttachments returns up to limit attachments that no longer belong
+// to a task, oldest first. Only the ID and StorageKey are set: the user of an
+// orphan may be gone as well.
+func (r *attachmentRepository) GetOrphanAttachments(limit int) ([]*models.TaskAttachment, error) {
+	rows, err := r.db.Query(`
+		SELECT id, storage_key FROM task_attachments
+		WHERE task_id IS NULL
+		ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*models.TaskAttachment{}
+	for rows.Next() {
+		attachment := &models.TaskAttachment{}
+		if err := rows.Scan(&attachment.ID, &attachment.StorageKey); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment deletes an orphaned attachment row once its blob is gone
+func (r *attachmentRepository) DeleteAttachment(attachmentID int64) error {
+	result, err := r.db.Exec(`DELETE FROM task_attachments WHERE id = $1 AND task_id IS NULL`, attachmentID)
+	return requireRow(result, err)
+}
+
+// GetUserAttachments returns the attachments of all of a user's tasks, for
+// the export
+func (r *taskRepository) GetUserAttachments(userID int64) ([]*models.TaskAttachment, error) {
+	rows, err := r.db.Query(`
+		SELECT `+attachmentColumns+`
+		FROM task_attachments
+		WHERE user_id = $1 AND task_id IS NOT NULL
+		ORDER BY task_id, created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAttachments(rows)
+}
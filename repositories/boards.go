@@ -0,0 +1,433 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"todo-backend/models"
+	"todo-backend/ordering"
+
+	"github.com/lib/pq"
+)
+
+// Board Repository
+type BoardRepository interface {
+	CreateBoard(board *models.Board) (*models.Board, error)
+	GetBoards(userID int64) ([]*models.Board, error)
+	GetBoardByID(boardID, userID int64) (*models.Board, error)
+	UpdateBoard(board *models.Board, replaceColumns bool) (*models.Board, error)
+	DeleteBoard(boardID, userID int64) error
+	GetColumnCards(board *models.Board, column *models.BoardColumn, limit int) ([]*models.Task, error)
+	PlaceCard(board *models.Board, column *models.BoardColumn, taskID int64, afterID, beforeID *int64) error
+	MoveCard(board *models.Board, column *models.BoardColumn, taskID int64, from models.TaskStatus, afterID, beforeID *int64) (*models.Task, error)
+}
+
+// ErrColumnFull is returned by MoveCard when the column already holds as
+// many tasks as its work-in-progress limit allows
+var ErrColumnFull = errors.New("column is at its work-in-progress limit")
+
+// ErrCardAnchorNotFound is returned when the card to place next to is not in
+// the column
+var ErrCardAnchorNotFound = errors.New("anchor card is not in the column")
+
+type boardRepository struct {
+	db *sql.DB
+}
+
+func NewBoardRepository(db *sql.DB) BoardRepository {
+	return &boardRepository{db: db}
+}
+
+const boardColumns = `id, user_id, project_id, name, created_at, updated_at`
+
+// boardTaskScope limits tasks to a board's user ($2) and, for a project
+// board, its project ($3)
+const boardTaskScope = `user_id = $2 AND deleted_at IS NULL AND ($3::bigint IS NULL OR project_id = $3)`
+
+// boardCardOrder orders a column's cards: cards placed by hand first, in
+// their board position ($1 is the board), then the rest by priority and due date
+const boardCardOrder = `(SELECT bc.position FROM board_cards bc WHERE bc.board_id = $1 AND bc.task_id = tasks.id) NULLS LAST,
+		priority DESC, due_date ASC NULLS LAST, id ASC`
+
+func scanBoard(row rowScanner) (*models.Board, error) {
+	board := &models.Board{}
+	err := row.Scan(&board.ID, &board.UserID, &board.ProjectID, &board.Name, &board.CreatedAt, &board.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// loadColumns fills in a board's columns with their task counts
+func loadColumns(q *sql.DB, board *models.Board) error {
+	rows, err := q.Query(`
+		SELECT id, board_id, name, status, wip_limit, sort_order
+		FROM board_columns WHERE board_id = $1
+		ORDER BY sort_order, id`, board.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	board.Columns = []*models.BoardColumn{}
+	for rows.Next() {
+		column := &models.BoardColumn{}
+		if err := rows.Scan(&column.ID, &column.BoardID, &column.Name, &column.Status, &column.WIPLimit, &column.SortOrder); err != nil {
+			return err
+		}
+		board.Columns = append(board.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	counts, err := q.Query(`
+		SELECT status, COUNT(*) FROM tasks
+		WHERE user_id = $1 AND deleted_at IS NULL AND ($2::bigint IS NULL OR project_id = $2)
+		GROUP BY status`, board.UserID, board.ProjectID)
+	if err != nil {
+		return err
+	}
+	defer counts.Close()
+
+	byStatus := map[models.TaskStatus]int64{}
+	for counts.Next() {
+		var status models.TaskStatus
+		var count int64
+		if err := counts.Scan(&status, &count); err != nil {
+			return err
+		}
+		byStatus[status] = count
+	}
+	for _, column := range board.Columns {
+		column.TaskCount = byStatus[column.Status]
+		column.OverLimit = column.WIPLimit != nil && column.TaskCount > int64(*column.WIPLimit)
+	}
+	return counts.Err()
+}
+
+// insertColumnsTx adds columns to a board, or updates the board's column
+// with the same status, keeping the given order
+func insertColumnsTx(tx *sql.Tx, boardID int64, columns []*models.BoardColumn) error {
+	for i, column := range columns {
+		_, err := tx.Exec(`
+			INSERT INTO board_columns (board_id, name, status, wip_limit, sort_order)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (board_id, status) DO UPDATE
+			SET name = EXCLUDED.name, wip_limit = EXCLUDED.wip_limit, sort_order = EXCLUDED.sort_order`,
+			boardID, column.Name, column.Status, column.WIPLimit, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *boardRepository) CreateBoard(board *models.Board) (*models.Board, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createdBoard, err := scanBoard(tx.QueryRow(`
+		INSERT INTO boards (user_id, project_id, name)
+		VALUES ($1, $2, $3)
+		RETURNING `+boardColumns, board.UserID, board.ProjectID, board.Name))
+	if err != nil {
+		return nil, err
+	}
+	if err := insertColumnsTx(tx, createdBoard.ID, board.Columns); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := loadColumns(r.db, createdBoard); err != nil {
+		return nil, err
+	}
+	return createdBoard, nil
+}
+
+func (r *boardRepository) GetBoards(userID int64) ([]*models.Board, error) {
+	rows, err := r.db.Query(`
+		SELECT `+boardColumns+`
+		FROM boards WHERE user_id = $1
+		ORDER BY lower(name), id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	boards := []*models.Board{}
+	for rows.Next() {
+		board, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, board)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, board := range boards {
+		if err := loadColumns(r.db, board); err != nil {
+			return nil, err
+		}
+	}
+	return boards, nil
+}
+
+func (r *boardRepository) GetBoardByID(boardID, userID int64) (*models.Board, error) {
+	board, err := scanBoard(r.db.QueryRow(`
+		SELECT `+boardColumns+`
+		FROM boards WHERE id = $1 AND user_id = $2`, boardID, userID))
+	if err != nil {
+		return nil, err
+	}
+	if err := loadColumns(r.db, board); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// UpdateBoard renames a board. With replaceColumns its columns become
+// board.Columns: columns are matched by status, so a kept status keeps its
+// column ID, and columns for other statuses are deleted.
+func (r *boardRepository) UpdateBoard(board *models.Board, replaceColumns bool) (*models.Board, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	updatedBoard, err := scanBoard(tx.QueryRow(`
+		UPDATE boards SET name = $1, updated_at = now()
+		WHERE id = $2 AND user_id = $3
+		RETURNING `+boardColumns, board.Name, board.ID, board.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	if replaceColumns {
+		statuses := make([]string, len(board.Columns))
+		for i, column := range board.Columns {
+			statuses[i] = string(column.Status)
+		}
+		_, err = tx.Exec(`
+			DELETE FROM board_columns WHERE board_id = $1 AND NOT (status = ANY($2))`,
+			board.ID, pq.Array(statuses))
+		if err != nil {
+			return nil, err
+		}
+		if err := insertColumnsTx(tx, board.ID, board.Columns); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := loadColumns(r.db, updatedBoard); err != nil {
+		return nil, err
+	}
+	return updatedBoard, nil
+}
+
+func (r *boardRepository) DeleteBoard(boardID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM boards WHERE id = $1 AND user_id = $2`, boardID, userID)
+	return requireRow(result, err)
+}
+
+// GetColumnCards returns up to limit of a column's cards in board order
+func (r *boardRepository) GetColumnCards(board *models.Board, column *models.BoardColumn, limit int) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks WHERE `+boardTaskScope+` AND status = $4
+		ORDER BY `+boardCardOrder+`
+		LIMIT $5`, board.ID, board.UserID, board.ProjectID, column.Status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if tasks == nil {
+		tasks = []*models.Task{}
+	}
+	return tasks, nil
+}
+
+// PlaceCard positions a task, which must already have the column's status,
+// in the column right after afterID, right before beforeID or, with neither,
+// at the top. Cards ahead of the new position that were never placed by hand
+// get positions in their current order first, so nothing else moves on the
+// board. ErrCardAnchorNotFound is returned when the anchor is not in the
+// column.
+func (r *boardRepository) PlaceCard(board *models.Board, column *models.BoardColumn, taskID int64, afterID, beforeID *int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockBoardTx(tx, board.ID); err != nil {
+		return err
+	}
+	if err := placeCardTx(tx, board, column, taskID, afterID, beforeID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MoveCard moves a task with the from status into a column of another
+// status and places it there like PlaceCard. The column's task count is
+// taken under the board's lock, so concurrent moves cannot take it past its
+// work-in-progress limit; ErrColumnFull is returned when it is reached.
+// sql.ErrNoRows is returned when the task no longer has the from status.
+func (r *boardRepository) MoveCard(board *models.Board, column *models.BoardColumn, taskID int64, from models.TaskStatus, afterID, beforeID *int64) (*models.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockBoardTx(tx, board.ID); err != nil {
+		return nil, err
+	}
+	if column.WIPLimit != nil {
+		var count int64
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM tasks
+			WHERE user_id = $1 AND deleted_at IS NULL AND ($2::bigint IS NULL OR project_id = $2) AND status = $3`,
+			board.UserID, board.ProjectID, column.Status).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(*column.WIPLimit) {
+			return nil, ErrColumnFull
+		}
+	}
+
+	task, err := updateTaskStatusTx(tx, taskID, board.UserID, from, column.Status)
+	if err != nil {
+		return nil, err
+	}
+	if err := placeCardTx(tx, board, column, taskID, afterID, beforeID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// lockBoardTx locks the board so concurrent moves on it run one after the
+// other
+func lockBoardTx(tx *sql.Tx, boardID int64) error {
+	var id int64
+	return tx.QueryRow(`SELECT id FROM boards WHERE id = $1 FOR UPDATE`, boardID).Scan(&id)
+}
+
+func placeCardTx(tx *sql.Tx, board *models.Board, column *models.BoardColumn, taskID int64, afterID, beforeID *int64) error {
+	rows, err := tx.Query(`
+		SELECT id, (SELECT bc.position FROM board_cards bc WHERE bc.board_id = $1 AND bc.task_id = tasks.id)
+		FROM tasks WHERE `+boardTaskScope+` AND status = $4 AND id <> $5
+		ORDER BY `+boardCardOrder, board.ID, board.UserID, board.ProjectID, column.Status, taskID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	var positions []string
+	for rows.Next() {
+		var cardID int64
+		var position sql.NullString
+		if err := rows.Scan(&cardID, &position); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, cardID)
+		positions = append(positions, position.String)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// index is the card the new position goes after (or before); -1 is the top
+	index := -1
+	if anchorID := afterID; anchorID != nil || beforeID != nil {
+		if anchorID == nil {
+			anchorID = beforeID
+		}
+		for i, cardID := range ids {
+			if cardID == *anchorID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return ErrCardAnchorNotFound
+		}
+	}
+
+	// Placed cards come first, so backfilling appends after the last of them
+	for i := 0; i <= index; i++ {
+		if positions[i] != "" {
+			continue
+		}
+		previous := ""
+		if i > 0 {
+			previous = positions[i-1]
+		}
+		if positions[i], err = ordering.After(previous); err != nil {
+			return fmt.Errorf("failed to compute position: %w", err)
+		}
+		if err := upsertCardTx(tx, board.ID, ids[i], positions[i]); err != nil {
+			return err
+		}
+	}
+
+	var before, after string
+	switch {
+	case afterID != nil:
+		before = positions[index]
+		if index+1 < len(positions) {
+			after = positions[index+1]
+		}
+	case beforeID != nil:
+		after = positions[index]
+		if index > 0 {
+			before = positions[index-1]
+		}
+	default:
+		if len(positions) > 0 {
+			after = positions[0]
+		}
+	}
+
+	position, err := ordering.Between(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to compute position: %w", err)
+	}
+	return upsertCardTx(tx, board.ID, taskID, position)
+}
+
+func upsertCardTx(tx *sql.Tx, boardID, taskID int64, position string) error {
+	_, err := tx.Exec(`
+		INSERT INTO board_cards (board_id, task_id, position) VALUES ($1, $2, $3)
+		ON CONFLICT (board_id, task_id) DO UPDATE SET position = EXCLUDED.position`, boardID, taskID, position)
+	return err
+}
+
+// clearBoardCardsTx drops a task's hand-placed board positions; called when
+// its status changes, since the positions belonged to its old columns
+func clearBoardCardsTx(tx *sql.Tx, taskID int64) error {
+	_, err := tx.Exec(`DELETE FROM board_cards WHERE task_id = $1`, taskID)
+	return err
+}
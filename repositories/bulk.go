@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"todo-backend/models"
+
+	"github.com/lib/pq"
+)
+
+// GetTasksByIDs returns the user's tasks among ids, in id order. IDs that do
+// not exist or belong to another user are left out.
+func (r *taskRepository) GetTasksByIDs(userID int64, ids []int64) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL
+		ORDER BY id`, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// ApplyBulkTaskChanges applies validated changes in one transaction: either
+// every change is written or none is. Every change is recorded in the task's
+// revision history. Status changes are recorded in the status history and
+// must still find the task in FromStatus; reschedules are recorded in the
+// reschedule history. Deleting with promoteSubtasks
+// moves the subtasks of each deleted task up to its parent before trashing
+// it. The updated tasks are returned; deleted tasks are not.
+func (r *taskRepository) ApplyBulkTaskChanges(userID int64, op models.BulkOperation, changes []*models.BulkTaskChange, promoteSubtasks bool) ([]*models.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var updated []*models.Task
+	for _, change := range changes {
+		if op == models.BulkReschedule {
+			task, _, err := rescheduleTx(tx, change.TaskID, userID, change.DueDate.Time, change.Reason)
+			if err != nil {
+				return nil, fmt.Errorf("task %d: %w", change.TaskID, err)
+			}
+			updated = append(updated, task)
+			continue
+		}
+
+		before, err := lockTaskTx(tx, change.TaskID, userID)
+		if errors.Is(err, sql.ErrNoRows) && op == models.BulkDelete {
+			// Trashed along with an ancestor earlier in this change
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", change.TaskID, err)
+		}
+
+		var row *sql.Row
+		switch op {
+		case models.BulkComplete, models.BulkUncomplete:
+			row = tx.QueryRow(`
+				UPDATE tasks SET status = $1, is_completed = ($1 = 'completed')
+				WHERE id = $2 AND user_id = $3 AND status = $4 AND deleted_at IS NULL
+				RETURNING `+taskColumns, change.ToStatus, change.TaskID, userID, change.FromStatus)
+		case models.BulkSetCategory:
+			row = tx.QueryRow(`
+				UPDATE tasks SET category = $1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+				RETURNING `+taskColumns, change.Category, change.TaskID, userID)
+		case models.BulkSetPriority:
+			row = tx.QueryRow(`
+				UPDATE tasks SET priority = $1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+				RETURNING `+taskColumns, change.Priority, change.TaskID, userID)
+		case models.BulkDelete:
+			if err := deleteTaskTx(tx, change.TaskID, userID, promoteSubtasks); err != nil {
+				return nil, err
+			}
+			if err := addTaskRevisionTx(tx, models.RevisionDelete, nil, before); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("unknown bulk operation %q", op)
+		}
+
+		task, err := scanTask(row)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", change.TaskID, err)
+		}
+		if change.ToStatus != "" {
+			_, err = tx.Exec(`
+				INSERT INTO task_status_history (task_id, user_id, from_status, to_status)
+				VALUES ($1, $2, $3, $4)`, change.TaskID, userID, change.FromStatus, change.ToStatus)
+			if err != nil {
+				return nil, err
+			}
+			if err := clearBoardCardsTx(tx, change.TaskID); err != nil {
+				return nil, err
+			}
+		}
+		if err := addTaskRevisionTx(tx, models.RevisionUpdate, before, task); err != nil {
+			return nil, err
+		}
+		updated = append(updated, task)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// lockTaskTx returns one of the user's tasks outside the trash, locked for
+// the rest of the transaction
+func lockTaskTx(tx *sql.Tx, taskID, userID int64) (*models.Task, error) {
+	return scanTask(tx.QueryRow(`
+		SELECT `+taskColumns+`
+		FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		FOR UPDATE`, taskID, userID))
+}
+
+// deleteTaskTx moves a task to the trash inside a transaction. A task already
+// trashed with a deleted ancestor is not an error.
+func deleteTaskTx(tx *sql.Tx, taskID, userID int64, promoteSubtasks bool) error {
+	if promoteSubtasks {
+		_, err := tx.Exec(`
+			UPDATE tasks SET parent_id = (SELECT parent_id FROM tasks WHERE id = $1 AND user_id = $2)
+			WHERE parent_id = $1 AND user_id = $2 AND deleted_at IS NULL`, taskID, userID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(trashTaskSQL, taskID, userID)
+	return err
+}
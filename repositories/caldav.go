@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+)
+
+// calDAVTaskQuery selects tasks with their CalDAV references. A task made
+// by a client keeps the UID and name it was given; one that was imported
+// several times under different UIDs shows the first.
+const calDAVTaskQuery = `
+	SELECT ` + taskColumns + `,
+		(SELECT MAX(h.changed_at) FROM task_status_history h
+		 WHERE h.task_id = tasks.id AND h.to_status = 'completed'),
+		ref.uid, ref.href,
+		(SELECT pu.uid FROM task_ical_uids pu WHERE pu.task_id = tasks.parent_id
+		 ORDER BY pu.href IS NULL, pu.uid LIMIT 1)
+	FROM tasks
+	LEFT JOIN LATERAL (
+		SELECT u.uid, u.href FROM task_ical_uids u
+		WHERE u.task_id = tasks.id
+		ORDER BY u.href IS NULL, u.uid LIMIT 1
+	) ref ON TRUE`
+
+func scanCalDAVTask(row rowScanner) (*models.CalDAVTask, error) {
+	task := &models.CalDAVTask{}
+	fields := append(taskFields(&task.Task), &task.CompletedAt, &task.UID, &task.Href, &task.ParentUID)
+	if err := row.Scan(fields...); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetCalDAVTasks returns every task of the user that is not in the trash
+func (r *taskRepository) GetCalDAVTasks(userID int64) ([]*models.CalDAVTask, error) {
+	rows, err := r.db.Query(calDAVTaskQuery+`
+		WHERE tasks.user_id = $1 AND tasks.deleted_at IS NULL
+		ORDER BY tasks.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*models.CalDAVTask{}
+	for rows.Next() {
+		task, err := scanCalDAVTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *taskRepository) GetCalDAVTask(taskID, userID int64) (*models.CalDAVTask, error) {
+	return scanCalDAVTask(r.db.QueryRow(calDAVTaskQuery+`
+		WHERE tasks.id = $1 AND tasks.user_id = $2 AND tasks.deleted_at IS NULL`, taskID, userID))
+}
+
+// GetTaskIDByCalDAVHref returns the task a client created under href
+func (r *taskRepository) GetTaskIDByCalDAVHref(userID int64, href string) (int64, error) {
+	var taskID int64
+	err := r.db.QueryRow(`
+		SELECT u.task_id FROM task_ical_uids u
+		JOIN tasks ON tasks.id = u.task_id
+		WHERE u.user_id = $1 AND u.href = $2 AND tasks.deleted_at IS NULL`, userID, href).Scan(&taskID)
+	return taskID, err
+}
+
+// SetTaskCalDAVRef records the UID and resource name a client gave taskID
+func (r *taskRepository) SetTaskCalDAVRef(taskID, userID int64, uid, href string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setCalDAVRefTx(tx, taskID, userID, uid, href); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setCalDAVRefTx(tx *sql.Tx, taskID, userID int64, uid, href string) error {
+	// A name is freed when its task was deleted and the client reuses it
+	if _, err := tx.Exec(`
+		UPDATE task_ical_uids SET href = NULL
+		WHERE user_id = $1 AND href = $2 AND uid <> $3`, userID, href, uid); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO task_ical_uids (user_id, uid, task_id, href)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, uid) DO UPDATE SET task_id = EXCLUDED.task_id, href = EXCLUDED.href`,
+		userID, uid, taskID, href)
+	return err
+}
+
+// CreateCalDAVTask creates a task a client sent under href, with its status,
+// its tags and its UID, in one transaction. A status other than pending is
+// recorded in the status history as a change from pending.
+func (r *taskRepository) CreateCalDAVTask(task *models.Task, uid, href string) (*models.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taskID int64
+	err = tx.QueryRow(`
+		INSERT INTO tasks (user_id, task_name, description, category, priority, due_date, is_recurring, recurring_frequency,
+			parent_id, estimate_minutes, is_important, status, is_completed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12 = 'completed')
+		RETURNING id`,
+		task.UserID, task.TaskName, task.Description, task.Category, task.Priority, task.DueDate, task.IsRecurring,
+		task.RecurringFrequency, task.ParentID, task.EstimateMinutes, task.IsImportant, task.Status,
+	).Scan(&taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != models.TaskStatusPending {
+		_, err = tx.Exec(`
+			INSERT INTO task_status_history (task_id, user_id, from_status, to_status)
+			VALUES ($1, $2, $3, $4)`, taskID, task.UserID, models.TaskStatusPending, task.Status)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := addTaskTagsTx(tx, taskID, task.UserID, task.Tags); err != nil {
+		return nil, err
+	}
+	if err := setCalDAVRefTx(tx, taskID, task.UserID, uid, href); err != nil {
+		return nil, err
+	}
+
+	created, err := scanTask(tx.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1`, taskID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// WithCalDAVLock runs fn holding a lock on the user's CalDAV collection. The
+// lock belongs to a transaction kept open while fn writes through other
+// connections, so it is released however fn ends.
+func (r *taskRepository) WithCalDAVLock(userID int64, fn func() error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('caldav:' || $1::text))`, userID); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+)
+
+// Calendar Feed Repository
+type CalendarFeedRepository interface {
+	CreateFeed(feed *models.CalendarFeed, tokenHash string) (*models.CalendarFeed, error)
+	GetFeeds(userID int64) ([]*models.CalendarFeed, error)
+	DeleteFeed(feedID, userID int64) error
+	GetFeedByTokenHash(tokenHash string) (*models.CalendarFeed, error)
+}
+
+type calendarFeedRepository struct {
+	db *sql.DB
+}
+
+func NewCalendarFeedRepository(db *sql.DB) CalendarFeedRepository {
+	return &calendarFeedRepository{db: db}
+}
+
+const calendarFeedColumns = `id, user_id, name, task_format, timezone, include_completed, include_goals, created_at, last_accessed_at`
+
+func scanCalendarFeed(row rowScanner) (*models.CalendarFeed, error) {
+	feed := &models.CalendarFeed{}
+	err := row.Scan(&feed.ID, &feed.UserID, &feed.Name, &feed.TaskFormat, &feed.Timezone,
+		&feed.IncludeCompleted, &feed.IncludeGoals, &feed.CreatedAt, &feed.LastAccessedAt)
+	if err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (r *calendarFeedRepository) CreateFeed(feed *models.CalendarFeed, tokenHash string) (*models.CalendarFeed, error) {
+	return scanCalendarFeed(r.db.QueryRow(`
+		INSERT INTO calendar_feeds (user_id, name, token_hash, task_format, timezone, include_completed, include_goals)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+calendarFeedColumns,
+		feed.UserID, feed.Name, tokenHash, feed.TaskFormat, feed.Timezone, feed.IncludeCompleted, feed.IncludeGoals))
+}
+
+func (r *calendarFeedRepository) GetFeeds(userID int64) ([]*models.CalendarFeed, error) {
+	rows, err := r.db.Query(`
+		SELECT `+calendarFeedColumns+`
+		FROM calendar_feeds
+		WHERE user_id = $1
+		ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []*models.CalendarFeed{}
+	for rows.Next() {
+		feed, err := scanCalendarFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// DeleteFeed revokes a feed; its URL stops working at once
+func (r *calendarFeedRepository) DeleteFeed(feedID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM calendar_feeds WHERE id = $1 AND user_id = $2`, feedID, userID)
+	return requireRow(result, err)
+}
+
+// GetFeedByTokenHash looks a feed up by its token and notes the access
+func (r *calendarFeedRepository) GetFeedByTokenHash(tokenHash string) (*models.CalendarFeed, error) {
+	return scanCalendarFeed(r.db.QueryRow(`
+		UPDATE calendar_feeds SET last_accessed_at = now()
+		WHERE token_hash = $1
+		RETURNING `+calendarFeedColumns, tokenHash))
+}
+
+// GetFeedTasks returns the user's tasks that have a due date, soonest first,
+// with when each was last completed; completed tasks only when asked
+func (r *taskRepository) GetFeedTasks(userID int64, includeCompleted bool) ([]*models.FeedTask, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`,
+			(SELECT MAX(h.changed_at) FROM task_status_history h
+			 WHERE h.task_id = tasks.id AND h.to_status = 'completed')
+		FROM tasks
+		WHERE user_id = $1 AND due_date IS NOT NULL AND deleted_at IS NULL
+			AND ($2 OR status <> 'completed')
+		ORDER BY due_date, id`, userID, includeCompleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*models.FeedTask
+	for rows.Next() {
+		task := &models.FeedTask{}
+		if err := rows.Scan(append(taskFields(&task.Task), &task.CompletedAt)...); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
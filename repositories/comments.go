@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+)
+
+// Comment Repository
+type CommentRepository interface {
+	CreateComment(comment *models.TaskComment) (*models.TaskComment, error)
+	GetTaskComments(taskID, userID int64) ([]*models.TaskComment, error)
+	GetComment(commentID, taskID, userID int64) (*models.TaskComment, error)
+	UpdateComment(commentID, taskID, userID int64, body string) (*models.TaskComment, error)
+	DeleteComment(commentID, taskID, userID int64) error
+}
+
+type commentRepository struct {
+	db *sql.DB
+}
+
+func NewCommentRepository(db *sql.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+const commentColumns = `id, task_id, user_id, body, created_at, edited_at`
+
+func scanComment(row rowScanner) (*models.TaskComment, error) {
+	comment := &models.TaskComment{}
+	err := row.Scan(&comment.ID, &comment.TaskID, &comment.UserID, &comment.Body, &comment.CreatedAt, &comment.EditedAt)
+	if err != nil {
+		return nil, err
+	}
+	comment.IsEdited = comment.EditedAt != nil
+	return comment, nil
+}
+
+func (r *commentRepository) CreateComment(comment *models.TaskComment) (*models.TaskComment, error) {
+	return scanComment(r.db.QueryRow(`
+		INSERT INTO task_comments (task_id, user_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING `+commentColumns, comment.TaskID, comment.UserID, comment.Body))
+}
+
+// GetTaskComments returns a task's comments, oldest first
+func (r *commentRepository) GetTaskComments(taskID, userID int64) ([]*models.TaskComment, error) {
+	rows, err := r.db.Query(`
+		SELECT `+commentColumns+`
+		FROM task_comments WHERE task_id = $1 AND user_id = $2
+		ORDER BY created_at, id`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.TaskComment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+func (r *commentRepository) GetComment(commentID, taskID, userID int64) (*models.TaskComment, error) {
+	return scanComment(r.db.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM task_comments WHERE id = $1 AND task_id = $2 AND user_id = $3`, commentID, taskID, userID))
+}
+
+// UpdateComment replaces a comment's body and marks it as edited
+func (r *commentRepository) UpdateComment(commentID, taskID, userID int64, body string) (*models.TaskComment, error) {
+	return scanComment(r.db.QueryRow(`
+		UPDATE task_comments SET body = $1, edited_at = now()
+		WHERE id = $2 AND task_id = $3 AND user_id = $4
+		RETURNING `+commentColumns, body, commentID, taskID, userID))
+}
+
+func (r *commentRepository) DeleteComment(commentID, taskID, userID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM task_comments WHERE id = $1 AND task_id = $2 AND user_id = $3`, commentID, taskID, userID)
+	return requireRow(result, err)
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"strconv"
+	"time"
+	"todo-backend/filter"
+	"todo-backend/models"
+	"todo-backend/pagination"
+)
+
+// TaskSortColumns are the fields GET /tasks can be sorted by. Tasks without a
+// due date sort after all others.
+var TaskSortColumns = map[string]pagination.Column{
+	"due_date":   {Expr: "COALESCE(due_date, 'infinity'::timestamptz)", Cast: "timestamptz"},
+	"priority":   {Expr: "priority", Cast: "smallint"},
+	"created_at": {Expr: "created_at", Cast: "timestamptz"},
+	"task_name":  {Expr: "task_name", Cast: "text"},
+	"status":     {Expr: "status", Cast: "text"},
+}
+
+// HabitSortColumns are the fields GET /habits can be sorted by
+var HabitSortColumns = map[string]pagination.Column{
+	"name":              {Expr: "name", Cast: "text"},
+	"type":              {Expr: "type", Cast: "text"},
+	"created_at":        {Expr: "created_at", Cast: "timestamptz"},
+	"last_tracked_date": {Expr: "COALESCE(last_tracked_date, 'infinity'::timestamptz)", Cast: "timestamptz"},
+	"is_achieved":       {Expr: "is_achieved", Cast: "boolean"},
+}
+
+func cursorTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func cursorOptionalTime(t *models.CustomTime) string {
+	if t == nil {
+		return "infinity"
+	}
+	return cursorTime(t.Time)
+}
+
+// taskCursorValues returns the values of a task for each sort key, followed by its id
+func taskCursorValues(keys []pagination.SortKey) func(*models.Task) []string {
+	return func(task *models.Task) []string {
+		values := make([]string, 0, len(keys)+1)
+		for _, key := range keys {
+			switch key.Field {
+			case "due_date":
+				values = append(values, cursorOptionalTime(task.DueDate))
+			case "priority":
+				values = append(values, strconv.Itoa(int(task.Priority)))
+			case "created_at":
+				values = append(values, cursorTime(task.CreatedAt))
+			case "task_name":
+				values = append(values, task.TaskName)
+			case "status":
+				values = append(values, string(task.Status))
+			}
+		}
+		return append(values, strconv.FormatInt(task.ID, 10))
+	}
+}
+
+func habitCursorValues(keys []pagination.SortKey) func(*models.Habit) []string {
+	return func(habit *models.Habit) []string {
+		values := make([]string, 0, len(keys)+1)
+		for _, key := range keys {
+			switch key.Field {
+			case "name":
+				values = append(values, habit.Name)
+			case "type":
+				values = append(values, habit.Type)
+			case "created_at":
+				values = append(values, cursorTime(habit.CreatedAt))
+			case "last_tracked_date":
+				values = append(values, cursorOptionalTime(habit.LastTrackedDate))
+			case "is_achieved":
+				values = append(values, strconv.FormatBool(habit.IsAchieved))
+			}
+		}
+		return append(values, strconv.FormatInt(habit.ID, 10))
+	}
+}
+
+// GetTasksByCursor returns one page of a user's tasks in the query's sort
+// order, optionally narrowed by a parsed filter expression (node may be nil)
+func (r *taskRepository) GetTasksByCursor(userID int64, node filter.Node, q *pagination.Query) ([]*models.Task, *models.CursorPage, error) {
+	c := &taskFilterCompiler{args: []interface{}{userID}}
+	where := "user_id = $1 AND deleted_at IS NULL"
+	if node != nil {
+		where += " AND " + c.compile(node)
+	}
+
+	page := &models.CursorPage{}
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE `+where, c.args...).Scan(&page.Total)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, orderBy := q.SQL(TaskSortColumns, "id", c.arg)
+	if after != "" {
+		where += " AND " + after
+	}
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks WHERE `+where+`
+		ORDER BY `+orderBy+` LIMIT `+c.arg(q.FetchLimit()), c.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tasks, page.NextCursor, page.PrevCursor = pagination.Paginate(q, tasks, taskCursorValues(q.Sort))
+	return tasks, page, nil
+}
+
+// GetHabitsByCursor returns one page of a user's habits in the query's sort order
+func (r *habitRepository) GetHabitsByCursor(userID int64, q *pagination.Query) ([]*models.Habit, *models.CursorPage, error) {
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	page := &models.CursorPage{}
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM habits WHERE user_id = $1 AND deleted_at IS NULL`, userID).Scan(&page.Total)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where := "user_id = $1 AND deleted_at IS NULL"
+	after, orderBy := q.SQL(HabitSortColumns, "id", arg)
+	if after != "" {
+		where += " AND " + after
+	}
+	rows, err := r.db.Query(`
+		SELECT id, user_id, name, type, target_value, is_achieved, last_tracked_date, created_at
+		FROM habits WHERE `+where+`
+		ORDER BY `+orderBy+` LIMIT `+arg(q.FetchLimit()), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var habits []*models.Habit
+	for rows.Next() {
+		habit := &models.Habit{}
+		err := rows.Scan(&habit.ID, &habit.UserID, &habit.Name, &habit.Type,
+			&habit.TargetValue, &habit.IsAchieved, &habit.LastTrackedDate, &habit.CreatedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		habits = append(habits, habit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	habits, page.NextCursor, page.PrevCursor = pagination.Paginate(q, habits, habitCursorValues(q.Sort))
+	return habits, page, nil
+}
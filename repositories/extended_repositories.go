@@ -3,6 +3,8 @@ package repositories
 import (
 	"database/sql"
 	"todo-backend/models"
+
+	"github.com/lib/pq"
 )
 
 // Pomodoro Session Repository
@@ -169,7 +171,7 @@ func (r *goalRepository) GetGoalsByUserID(userID int64) ([]*models.Goal, error)
 	rows, err := r.db.Query(`
 		SELECT id, user_id, title, description, category, target_value, current_value, unit, due_date, is_completed, created_at 
 		FROM goals 
-		WHERE user_id = $1 
+		WHERE user_id = $1 AND deleted_at IS NULL 
 		ORDER BY created_at DESC`, userID)
 	if err != nil {
 		return nil, err
@@ -195,7 +197,7 @@ func (r *goalRepository) GetGoalByID(goalID int64) (*models.Goal, error) {
 	err := r.db.QueryRow(`
 		SELECT id, user_id, title, description, category, target_value, current_value, unit, due_date, is_completed, created_at 
 		FROM goals 
-		WHERE id = $1`, goalID).Scan(&goal.ID, &goal.UserID, &goal.Title, &goal.Description,
+		WHERE id = $1 AND deleted_at IS NULL`, goalID).Scan(&goal.ID, &goal.UserID, &goal.Title, &goal.Description,
 		&goal.Category, &goal.TargetValue, &goal.CurrentValue, &goal.Unit, &goal.DueDate, &goal.IsCompleted, &goal.CreatedAt)
 
 	if err != nil {
@@ -211,7 +213,7 @@ func (r *goalRepository) UpdateGoal(goal *models.Goal) (*models.Goal, error) {
 		UPDATE goals 
 		SET title = $2, description = $3, category = $4, target_value = $5, current_value = $6, 
 			unit = $7, due_date = $8, is_completed = $9
-		WHERE id = $1 
+		WHERE id = $1 AND deleted_at IS NULL 
 		RETURNING id, user_id, title, description, category, target_value, current_value, unit, due_date, is_completed, created_at`,
 		goal.ID, goal.Title, goal.Description, goal.Category, goal.TargetValue, goal.CurrentValue,
 		goal.Unit, goal.DueDate, goal.IsCompleted,
@@ -227,7 +229,7 @@ func (r *goalRepository) UpdateGoal(goal *models.Goal) (*models.Goal, error) {
 }
 
 func (r *goalRepository) DeleteGoal(goalID int64) error {
-	_, err := r.db.Exec("DELETE FROM goals WHERE id = $1", goalID)
+	_, err := r.db.Exec("UPDATE goals SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", goalID)
 	return err
 }
 
@@ -235,6 +237,161 @@ func (r *goalRepository) UpdateGoalProgress(goalID int64, progress int32) error
 	_, err := r.db.Exec(`
 		UPDATE goals 
 		SET current_value = $2, is_completed = (current_value >= target_value) 
-		WHERE id = $1`, goalID, progress)
+		WHERE id = $1 AND deleted_at IS NULL`, goalID, progress)
 	return err
 }
+
+// Tag Repository
+type TagRepository interface {
+	GetTagsByUserID(userID int64) ([]*models.Tag, error)
+	GetTagByID(tagID, userID int64) (*models.Tag, error)
+	GetTagByName(userID int64, name string) (*models.Tag, error)
+	SetTaskTags(taskID, userID int64, names []string) error
+	RenameTag(tagID, userID int64, name string) (*models.Tag, error)
+	MergeTags(sourceIDs []int64, targetID, userID int64) (int64, error)
+	DeleteTag(tagID, userID int64) error
+}
+
+type tagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+// tagColumns selects a tag with the number of tasks carrying it
+const tagColumns = `tags.id, tags.user_id, tags.name, tags.created_at,
+		(SELECT COUNT(*) FROM task_tags JOIN tasks ON tasks.id = task_tags.task_id
+			WHERE task_tags.tag_id = tags.id AND tasks.deleted_at IS NULL) AS usage_count`
+
+func scanTag(row rowScanner) (*models.Tag, error) {
+	tag := &models.Tag{}
+	if err := row.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt, &tag.UsageCount); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (r *tagRepository) GetTagsByUserID(userID int64) ([]*models.Tag, error) {
+	rows, err := r.db.Query(`
+		SELECT `+tagColumns+` 
+		FROM tags WHERE user_id = $1 ORDER BY lower(name)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *tagRepository) GetTagByID(tagID, userID int64) (*models.Tag, error) {
+	return scanTag(r.db.QueryRow(`
+		SELECT `+tagColumns+` 
+		FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID))
+}
+
+func (r *tagRepository) GetTagByName(userID int64, name string) (*models.Tag, error) {
+	return scanTag(r.db.QueryRow(`
+		SELECT `+tagColumns+` 
+		FROM tags WHERE user_id = $1 AND lower(name) = lower($2)`, userID, name))
+}
+
+// SetTaskTags replaces the tags of a task, creating any tag the user does not
+// have yet. Existing tags keep their original spelling.
+func (r *tagRepository) SetTaskTags(taskID, userID int64, names []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM task_tags WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, err := tx.Exec(`
+			INSERT INTO tags (user_id, name) VALUES ($1, $2) 
+			ON CONFLICT (user_id, (lower(name))) DO NOTHING`, userID, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO task_tags (task_id, tag_id) 
+			SELECT $1, id FROM tags WHERE user_id = $2 AND lower(name) = lower($3) 
+			ON CONFLICT DO NOTHING`, taskID, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *tagRepository) RenameTag(tagID, userID int64, name string) (*models.Tag, error) {
+	return scanTag(r.db.QueryRow(`
+		UPDATE tags SET name = $1 WHERE id = $2 AND user_id = $3 
+		RETURNING `+tagColumns, name, tagID, userID))
+}
+
+// MergeTags retags every task carrying one of the source tags with the target
+// tag, deletes the source tags and reports how many task links were added
+func (r *tagRepository) MergeTags(sourceIDs []int64, targetID, userID int64) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO task_tags (task_id, tag_id) 
+		SELECT DISTINCT tt.task_id, $1::BIGINT FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id 
+		WHERE tg.user_id = $3 AND tt.tag_id = ANY($2) 
+		ON CONFLICT DO NOTHING`, targetID, pq.Array(sourceIDs), userID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id = ANY($1) AND user_id = $2`, pq.Array(sourceIDs), userID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+func (r *tagRepository) DeleteTag(tagID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"github.com/lib/pq"
+)
+
+// GetTaskIDsByICalUID returns the tasks earlier imports created for uids,
+// trashed ones included, keyed by UID
+func (r *taskRepository) GetTaskIDsByICalUID(userID int64, uids []string) (map[string]int64, error) {
+	ids := map[string]int64{}
+	if len(uids) == 0 {
+		return ids, nil
+	}
+	rows, err := r.db.Query(`
+		SELECT uid, task_id FROM task_ical_uids
+		WHERE user_id = $1 AND uid = ANY($2)`, userID, pq.Array(uids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid string
+		var taskID int64
+		if err := rows.Scan(&uid, &taskID); err != nil {
+			return nil, err
+		}
+		ids[uid] = taskID
+	}
+	return ids, rows.Err()
+}
+
+// SetTaskICalUID records that uid was imported as taskID
+func (r *taskRepository) SetTaskICalUID(taskID, userID int64, uid string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO task_ical_uids (user_id, uid, task_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, uid) DO UPDATE SET task_id = EXCLUDED.task_id`, userID, uid, taskID)
+	return err
+}
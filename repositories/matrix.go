@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"todo-backend/models"
+)
+
+// GetOpenTasks returns the user's pending and in-progress tasks, soonest due
+// first, for the Eisenhower matrix
+func (r *taskRepository) GetOpenTasks(userID int64) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks
+		WHERE user_id = $1 AND status IN ('pending', 'in_progress') AND deleted_at IS NULL
+		ORDER BY due_date ASC NULLS LAST, priority DESC, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// SetTaskImportance sets or, with nil, clears a task's importance flag
+// without touching its priority
+func (r *taskRepository) SetTaskImportance(taskID, userID int64, isImportant *bool) (*models.Task, error) {
+	return scanTask(r.db.QueryRow(`
+		UPDATE tasks SET is_important = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+		RETURNING `+taskColumns, isImportant, taskID, userID))
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+)
+
+// Notification Repository
+type NotificationRepository interface {
+	CreateNotification(notification *models.Notification, deliveryID int64) error
+	GetNotifications(userID int64, unreadOnly bool, limit int) ([]*models.Notification, error)
+	GetUnreadCount(userID int64) (int64, error)
+	MarkNotificationRead(notificationID, userID int64) error
+	MarkAllNotificationsRead(userID int64) (int64, error)
+}
+
+type notificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// CreateNotification puts a notification in the user's inbox. A delivery
+// that is retried after its notification was written does not add a second one.
+func (r *notificationRepository) CreateNotification(notification *models.Notification, deliveryID int64) error {
+	_, err := r.db.Exec(`
+		INSERT INTO notifications (user_id, delivery_id, reminder_id, task_id, habit_id, title, body)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (delivery_id) DO NOTHING`,
+		notification.UserID, deliveryID, notification.ReminderID, notification.TaskID, notification.HabitID,
+		notification.Title, notification.Body)
+	return err
+}
+
+// GetNotifications returns the user's newest notifications first
+func (r *notificationRepository) GetNotifications(userID int64, unreadOnly bool, limit int) ([]*models.Notification, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, reminder_id, task_id, habit_id, title, body, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n := &models.Notification{}
+		err := rows.Scan(&n.ID, &n.UserID, &n.ReminderID, &n.TaskID, &n.HabitID, &n.Title, &n.Body, &n.CreatedAt, &n.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (r *notificationRepository) GetUnreadCount(userID int64) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks a notification as read; marking it again keeps
+// the time it was first read
+func (r *notificationRepository) MarkNotificationRead(notificationID, userID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, now())
+		WHERE id = $1 AND user_id = $2`, notificationID, userID)
+	return requireRow(result, err)
+}
+
+// MarkAllNotificationsRead marks every unread notification as read and
+// returns how many there were
+func (r *notificationRepository) MarkAllNotificationsRead(userID int64) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET read_at = now()
+		WHERE user_id = $1 AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
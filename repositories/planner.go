@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+	"todo-backend/models"
+)
+
+// Planner Repository
+type PlannerRepository interface {
+	GetWorkingHours(userID int64) ([]models.WorkingHours, error)
+	SetWorkingHours(userID int64, hours []models.WorkingHours) error
+	GetTimeBlocks(userID int64, from, to time.Time) ([]*models.TimeBlock, error)
+	CreateTimeBlock(block *models.TimeBlock) (*models.TimeBlock, error)
+	DeleteTimeBlock(blockID, userID int64) error
+	GetPlannedElsewhere(userID int64, from, to, after time.Time) ([]int64, error)
+	ReplacePlannedBlocks(userID int64, from, to time.Time, blocks []*models.TimeBlock) ([]*models.TimeBlock, error)
+}
+
+type plannerRepository struct {
+	db *sql.DB
+}
+
+func NewPlannerRepository(db *sql.DB) PlannerRepository {
+	return &plannerRepository{db: db}
+}
+
+// GetWorkingHours returns the user's working hours by weekday and start
+func (r *plannerRepository) GetWorkingHours(userID int64) ([]models.WorkingHours, error) {
+	rows, err := r.db.Query(`
+		SELECT weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI')
+		FROM working_hours
+		WHERE user_id = $1
+		ORDER BY weekday, start_time`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []models.WorkingHours{}
+	for rows.Next() {
+		var h models.WorkingHours
+		if err := rows.Scan(&h.Weekday, &h.Start, &h.End); err != nil {
+			return nil, err
+		}
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
+
+// SetWorkingHours replaces all of the user's working hours
+func (r *plannerRepository) SetWorkingHours(userID int64, hours []models.WorkingHours) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM working_hours WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range hours {
+		_, err := tx.Exec(`
+			INSERT INTO working_hours (user_id, weekday, start_time, end_time)
+			VALUES ($1, $2, $3::time, $4::time)`, userID, h.Weekday, h.Start, h.End)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const timeBlockSelect = `
+	SELECT b.id, b.user_id, b.task_id, COALESCE(b.title, t.task_name, ''), b.starts_at, b.ends_at, b.source, b.created_at
+	FROM time_blocks b LEFT JOIN tasks t ON t.id = b.task_id`
+
+func scanTimeBlock(row rowScanner) (*models.TimeBlock, error) {
+	block := &models.TimeBlock{}
+	err := row.Scan(&block.ID, &block.UserID, &block.TaskID, &block.Title, &block.StartsAt, &block.EndsAt,
+		&block.Source, &block.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetTimeBlocks returns the user's blocks that overlap from..to, in the order
+// they start. Blocks of trashed tasks are left out.
+func (r *plannerRepository) GetTimeBlocks(userID int64, from, to time.Time) ([]*models.TimeBlock, error) {
+	rows, err := r.db.Query(timeBlockSelect+`
+		WHERE b.user_id = $1 AND b.starts_at < $3 AND b.ends_at > $2
+			AND (b.task_id IS NULL OR t.deleted_at IS NULL)
+		ORDER BY b.starts_at, b.id`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []*models.TimeBlock{}
+	for rows.Next() {
+		block, err := scanTimeBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, rows.Err()
+}
+
+func (r *plannerRepository) CreateTimeBlock(block *models.TimeBlock) (*models.TimeBlock, error) {
+	var id int64
+	err := r.db.QueryRow(`
+		INSERT INTO time_blocks (user_id, task_id, title, starts_at, ends_at, source)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)
+		RETURNING id`,
+		block.UserID, block.TaskID, block.Title, block.StartsAt, block.EndsAt, block.Source).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return scanTimeBlock(r.db.QueryRow(timeBlockSelect+` WHERE b.id = $1`, id))
+}
+
+func (r *plannerRepository) DeleteTimeBlock(blockID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM time_blocks WHERE id = $1 AND user_id = $2`, blockID, userID)
+	return requireRow(result, err)
+}
+
+// GetPlannedElsewhere returns the tasks an accepted plan put outside from..to
+// in blocks that end after after, i.e. tasks already planned for another day
+func (r *plannerRepository) GetPlannedElsewhere(userID int64, from, to, after time.Time) ([]int64, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT task_id FROM time_blocks
+		WHERE user_id = $1 AND source = 'planner' AND task_id IS NOT NULL
+			AND (starts_at < $2 OR starts_at >= $3) AND ends_at > $4
+		ORDER BY task_id`, userID, from, to, after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReplacePlannedBlocks swaps the planner blocks starting in from..to for
+// blocks in one transaction; blocks added by hand stay
+func (r *plannerRepository) ReplacePlannedBlocks(userID int64, from, to time.Time, blocks []*models.TimeBlock) ([]*models.TimeBlock, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM time_blocks
+		WHERE user_id = $1 AND source = 'planner' AND starts_at >= $2 AND starts_at < $3`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(blocks))
+	for _, block := range blocks {
+		var id int64
+		err := tx.QueryRow(`
+			INSERT INTO time_blocks (user_id, task_id, starts_at, ends_at, source)
+			VALUES ($1, $2, $3, $4, 'planner')
+			RETURNING id`, userID, block.TaskID, block.StartsAt, block.EndsAt).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	saved := make([]*models.TimeBlock, 0, len(ids))
+	for _, id := range ids {
+		block, err := scanTimeBlock(tx.QueryRow(timeBlockSelect+` WHERE b.id = $1`, id))
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, block)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"todo-backend/models"
+	"todo-backend/ordering"
+)
+
+// Project Repository
+type ProjectRepository interface {
+	CreateProject(project *models.Project) (*models.Project, error)
+	GetProjects(userID int64, includeArchived bool) ([]*models.Project, error)
+	GetProjectByID(projectID, userID int64) (*models.Project, error)
+	GetProjectByName(userID int64, name string) (*models.Project, error)
+	UpdateProject(project *models.Project) (*models.Project, error)
+	DeleteProject(projectID, userID int64) error
+}
+
+type projectRepository struct {
+	db *sql.DB
+}
+
+func NewProjectRepository(db *sql.DB) ProjectRepository {
+	return &projectRepository{db: db}
+}
+
+const projectColumns = `id, user_id, name, color, description, is_archived,
+		(SELECT COUNT(*) FROM tasks t WHERE t.project_id = projects.id AND t.deleted_at IS NULL) AS task_count,
+		(SELECT COUNT(*) FROM tasks t WHERE t.project_id = projects.id AND t.deleted_at IS NULL
+			AND t.status IN ('pending', 'in_progress')) AS open_task_count,
+		created_at, updated_at`
+
+func scanProject(row rowScanner) (*models.Project, error) {
+	project := &models.Project{}
+	err := row.Scan(&project.ID, &project.UserID, &project.Name, &project.Color, &project.Description, &project.IsArchived,
+		&project.TaskCount, &project.OpenTaskCount, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (r *projectRepository) CreateProject(project *models.Project) (*models.Project, error) {
+	return scanProject(r.db.QueryRow(`
+		INSERT INTO projects (user_id, name, color, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+projectColumns, project.UserID, project.Name, project.Color, project.Description))
+}
+
+// GetProjects returns the user's projects by name; archived projects only
+// when includeArchived is set
+func (r *projectRepository) GetProjects(userID int64, includeArchived bool) ([]*models.Project, error) {
+	rows, err := r.db.Query(`
+		SELECT `+projectColumns+`
+		FROM projects WHERE user_id = $1 AND ($2 OR NOT is_archived)
+		ORDER BY lower(name), id`, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*models.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+
+	return projects, rows.Err()
+}
+
+func (r *projectRepository) GetProjectByID(projectID, userID int64) (*models.Project, error) {
+	return scanProject(r.db.QueryRow(`
+		SELECT `+projectColumns+`
+		FROM projects WHERE id = $1 AND user_id = $2`, projectID, userID))
+}
+
+func (r *projectRepository) GetProjectByName(userID int64, name string) (*models.Project, error) {
+	return scanProject(r.db.QueryRow(`
+		SELECT `+projectColumns+`
+		FROM projects WHERE user_id = $1 AND lower(name) = lower($2)`, userID, name))
+}
+
+func (r *projectRepository) UpdateProject(project *models.Project) (*models.Project, error) {
+	return scanProject(r.db.QueryRow(`
+		UPDATE projects SET name = $1, color = $2, description = $3, is_archived = $4, updated_at = now()
+		WHERE id = $5 AND user_id = $6
+		RETURNING `+projectColumns,
+		project.Name, project.Color, project.Description, project.IsArchived, project.ID, project.UserID))
+}
+
+// DeleteProject deletes a project. Its tasks, trashed ones included, are
+// kept and taken out of the project.
+func (r *projectRepository) DeleteProject(projectID, userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE tasks SET project_id = NULL, position = NULL
+		WHERE project_id = $1 AND user_id = $2`, projectID, userID)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM projects WHERE id = $1 AND user_id = $2`, projectID, userID)
+	if err := requireRow(result, err); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetProjectTasks returns a project's tasks in their manual order
+func (r *taskRepository) GetProjectTasks(projectID, userID int64) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks WHERE project_id = $1 AND user_id = $2 AND deleted_at IS NULL
+		ORDER BY position, id`, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if tasks == nil {
+		tasks = []*models.Task{}
+	}
+	return tasks, nil
+}
+
+// lockProjectTx locks a user's project row so that concurrent moves into the
+// project compute their positions one after the other
+func lockProjectTx(tx *sql.Tx, projectID, userID int64) error {
+	var id int64
+	return tx.QueryRow(`SELECT id FROM projects WHERE id = $1 AND user_id = $2 FOR UPDATE`, projectID, userID).Scan(&id)
+}
+
+// positionQueryTx runs a query returning one position, which may be NULL
+// when the project has no matching task
+func positionQueryTx(tx *sql.Tx, query string, args ...interface{}) (string, error) {
+	var position sql.NullString
+	if err := tx.QueryRow(query, args...).Scan(&position); err != nil {
+		return "", err
+	}
+	return position.String, nil
+}
+
+// endPositionTx locks a project and returns a position after its last task
+func endPositionTx(tx *sql.Tx, projectID, userID int64) (string, error) {
+	if err := lockProjectTx(tx, projectID, userID); err != nil {
+		return "", err
+	}
+	last, err := positionQueryTx(tx, `
+		SELECT MAX(position) FROM tasks WHERE project_id = $1 AND deleted_at IS NULL`, projectID)
+	if err != nil {
+		return "", err
+	}
+	return ordering.After(last)
+}
+
+// MoveTask puts a task into a project right after afterID, right before
+// beforeID or, when neither is given, at the end. Only the moved task's row
+// changes. A nil projectID takes the task out of its project. The anchor
+// task must already be in the project; sql.ErrNoRows is returned when it or
+// the task is missing.
+func (r *taskRepository) MoveTask(taskID, userID int64, projectID, afterID, beforeID *int64) (*models.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if projectID == nil {
+		task, err := scanTask(tx.QueryRow(`
+			UPDATE tasks SET project_id = NULL, position = NULL
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+			RETURNING `+taskColumns, taskID, userID))
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return task, nil
+	}
+
+	if err := lockProjectTx(tx, *projectID, userID); err != nil {
+		return nil, err
+	}
+
+	anchorPosition := func(anchorID int64) (string, error) {
+		return positionQueryTx(tx, `
+			SELECT position FROM tasks
+			WHERE id = $1 AND user_id = $2 AND project_id = $3 AND deleted_at IS NULL`, anchorID, userID, *projectID)
+	}
+
+	var before, after string
+	switch {
+	case afterID != nil:
+		if before, err = anchorPosition(*afterID); err != nil {
+			return nil, err
+		}
+		after, err = positionQueryTx(tx, `
+			SELECT MIN(position) FROM tasks
+			WHERE project_id = $1 AND id <> $2 AND position > $3 AND deleted_at IS NULL`, *projectID, taskID, before)
+	case beforeID != nil:
+		if after, err = anchorPosition(*beforeID); err != nil {
+			return nil, err
+		}
+		before, err = positionQueryTx(tx, `
+			SELECT MAX(position) FROM tasks
+			WHERE project_id = $1 AND id <> $2 AND position < $3 AND deleted_at IS NULL`, *projectID, taskID, after)
+	default:
+		before, err = positionQueryTx(tx, `
+			SELECT MAX(position) FROM tasks
+			WHERE project_id = $1 AND id <> $2 AND deleted_at IS NULL`, *projectID, taskID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := ordering.Between(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute position: %w", err)
+	}
+
+	task, err := scanTask(tx.QueryRow(`
+		UPDATE tasks SET project_id = $1, position = $2
+		WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
+		RETURNING `+taskColumns, *projectID, position, taskID, userID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+	"todo-backend/models"
+)
+
+// Reminder Repository
+type ReminderRepository interface {
+	CreateReminder(reminder *models.Reminder) (*models.Reminder, error)
+	GetReminder(reminderID, userID int64) (*models.Reminder, error)
+	GetReminders(userID int64, taskID, habitID *int64) ([]*models.Reminder, error)
+	DeleteReminder(reminderID, userID int64) error
+	SnoozeReminder(reminderID, userID int64, until time.Time) (*models.Reminder, error)
+	DismissReminder(reminderID, userID int64) (*models.Reminder, error)
+	ScheduleDueReminders(limit int) (int, error)
+	ClaimDeliveries(limit int, lease time.Duration) ([]*models.ReminderDelivery, error)
+	CompleteDelivery(deliveryID int64) error
+	FailDelivery(deliveryID int64, reason string, retryAt *time.Time) error
+}
+
+type reminderRepository struct {
+	db *sql.DB
+}
+
+func NewReminderRepository(db *sql.DB) ReminderRepository {
+	return &reminderRepository{db: db}
+}
+
+// reminderFireAt is when an active reminder r goes off next, joined with its
+// task t: the stored time, or for a due-date reminder the due date plus the
+// offset as long as it has not fired for that due date yet
+const reminderFireAt = `COALESCE(r.next_fire_at, CASE WHEN r.offset_minutes IS NOT NULL AND t.due_date IS DISTINCT FROM r.fired_due_date
+		THEN t.due_date + r.offset_minutes * interval '1 minute' END)`
+
+// nextCue returns SQL for the first time strictly after the timestamp after
+// at which the clock in timezone tz shows cue
+func nextCue(after, cue, tz string) string {
+	return `((((` + after + `) AT TIME ZONE ` + tz + `)::date
+		+ CASE WHEN ((` + after + `) AT TIME ZONE ` + tz + `)::time >= ` + cue + ` THEN 1 ELSE 0 END) + ` + cue + `) AT TIME ZONE ` + tz
+}
+
+const reminderSelect = `
+	SELECT r.id, r.user_id, r.task_id, r.habit_id, r.remind_at, r.offset_minutes, to_char(r.cue_time, 'HH24:MI'),
+		r.timezone, r.channel, r.status, CASE WHEN r.status = 'active' THEN ` + reminderFireAt + ` END,
+		r.last_fired_at, r.created_at
+	FROM reminders r LEFT JOIN tasks t ON t.id = r.task_id`
+
+func scanReminder(row rowScanner) (*models.Reminder, error) {
+	reminder := &models.Reminder{}
+	err := row.Scan(&reminder.ID, &reminder.UserID, &reminder.TaskID, &reminder.HabitID, &reminder.RemindAt,
+		&reminder.OffsetMinutes, &reminder.CueTime, &reminder.Timezone, &reminder.Channel, &reminder.Status,
+		&reminder.NextFireAt, &reminder.LastFiredAt, &reminder.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// CreateReminder adds a reminder and schedules it. A due-date reminder whose
+// time has already passed counts as fired for the current due date.
+func (r *reminderRepository) CreateReminder(reminder *models.Reminder) (*models.Reminder, error) {
+	var id int64
+	err := r.db.QueryRow(`
+		INSERT INTO reminders (user_id, task_id, habit_id, remind_at, offset_minutes, cue_time, timezone, channel,
+			next_fire_at, fired_due_date)
+		VALUES ($1, $2, $3, $4, $5, $6::time, $7, $8,
+			CASE WHEN $3::bigint IS NOT NULL THEN `+nextCue("now()", "$6::time", "$7")+` ELSE $4 END,
+			(SELECT t.due_date FROM tasks t WHERE t.id = $2 AND $5::integer IS NOT NULL
+				AND t.due_date + $5::integer * interval '1 minute' <= now()))
+		RETURNING id`,
+		reminder.UserID, reminder.TaskID, reminder.HabitID, reminder.RemindAt, reminder.OffsetMinutes,
+		reminder.CueTime, reminder.Timezone, reminder.Channel).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetReminder(id, reminder.UserID)
+}
+
+func (r *reminderRepository) GetReminder(reminderID, userID int64) (*models.Reminder, error) {
+	return scanReminder(r.db.QueryRow(reminderSelect+`
+		WHERE r.id = $1 AND r.user_id = $2`, reminderID, userID))
+}
+
+// GetReminders returns the user's reminders, narrowed to one task or habit
+// when given, soonest first
+func (r *reminderRepository) GetReminders(userID int64, taskID, habitID *int64) ([]*models.Reminder, error) {
+	rows, err := r.db.Query(reminderSelect+`
+		WHERE r.user_id = $1 AND ($2::bigint IS NULL OR r.task_id = $2) AND ($3::bigint IS NULL OR r.habit_id = $3)
+		ORDER BY 11 ASC NULLS LAST, r.id`, userID, taskID, habitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []*models.Reminder{}
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+func (r *reminderRepository) DeleteReminder(reminderID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM reminders WHERE id = $1 AND user_id = $2`, reminderID, userID)
+	return requireRow(result, err)
+}
+
+// SnoozeReminder makes a reminder go off at until, also when it had already
+// fired or was dismissed
+func (r *reminderRepository) SnoozeReminder(reminderID, userID int64, until time.Time) (*models.Reminder, error) {
+	result, err := r.db.Exec(`
+		UPDATE reminders SET status = 'active', next_fire_at = $1
+		WHERE id = $2 AND user_id = $3`, until, reminderID, userID)
+	if err := requireRow(result, err); err != nil {
+		return nil, err
+	}
+	return r.GetReminder(reminderID, userID)
+}
+
+// DismissReminder turns a task reminder off. A habit reminder skips its next
+// cue instead and stays on for the days after.
+func (r *reminderRepository) DismissReminder(reminderID, userID int64) (*models.Reminder, error) {
+	result, err := r.db.Exec(`
+		UPDATE reminders r
+		SET status = CASE WHEN r.habit_id IS NULL THEN 'dismissed' ELSE 'active' END,
+			next_fire_at = CASE WHEN r.habit_id IS NULL THEN NULL
+				ELSE `+nextCue("GREATEST(r.next_fire_at, now())", "r.cue_time", "r.timezone")+` END
+		WHERE r.id = $1 AND r.user_id = $2`, reminderID, userID)
+	if err := requireRow(result, err); err != nil {
+		return nil, err
+	}
+	return r.GetReminder(reminderID, userID)
+}
+
+// ScheduleDueReminders turns up to limit reminders that are due into pending
+// deliveries and moves each reminder on: one-off reminders are marked fired,
+// due-date reminders remember the due date they fired for and habit
+// reminders advance to their next cue. Reminders are locked with SKIP LOCKED
+// and deliveries are unique per reminder and fire time, so concurrent
+// instances never schedule the same firing twice. Reminders of trashed,
+// completed or cancelled tasks and of trashed habits stay quiet.
+func (r *reminderRepository) ScheduleDueReminders(limit int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT r.id, r.user_id, r.channel, r.offset_minutes IS NOT NULL, t.due_date, `+reminderFireAt+` AS fire_at
+		FROM reminders r
+		LEFT JOIN tasks t ON t.id = r.task_id
+		LEFT JOIN habits h ON h.id = r.habit_id
+		WHERE r.status = 'active' AND `+reminderFireAt+` <= now()
+			AND (r.task_id IS NULL OR (t.deleted_at IS NULL AND t.status IN ('pending', 'in_progress')))
+			AND (r.habit_id IS NULL OR h.deleted_at IS NULL)
+		ORDER BY fire_at, r.id
+		LIMIT $1
+		FOR UPDATE OF r SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	type dueReminder struct {
+		id, userID int64
+		channel    string
+		relative   bool
+		dueDate    *time.Time
+		fireAt     time.Time
+	}
+	var due []dueReminder
+	for rows.Next() {
+		var d dueReminder
+		if err := rows.Scan(&d.id, &d.userID, &d.channel, &d.relative, &d.dueDate, &d.fireAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range due {
+		_, err := tx.Exec(`
+			INSERT INTO reminder_deliveries (reminder_id, user_id, channel, fire_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (reminder_id, fire_at) DO NOTHING`, d.id, d.userID, d.channel, d.fireAt)
+		if err != nil {
+			return 0, err
+		}
+
+		var firedDueDate *time.Time
+		if d.relative {
+			firedDueDate = d.dueDate
+		}
+		_, err = tx.Exec(`
+			UPDATE reminders r
+			SET last_fired_at = now(),
+				status = CASE WHEN r.habit_id IS NULL AND r.offset_minutes IS NULL THEN 'fired' ELSE r.status END,
+				next_fire_at = CASE WHEN r.habit_id IS NOT NULL THEN `+nextCue("now()", "r.cue_time", "r.timezone")+` END,
+				fired_due_date = COALESCE($2, r.fired_due_date)
+			WHERE r.id = $1`, d.id, firedDueDate)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(due), nil
+}
+
+// ClaimDeliveries leases up to limit pending deliveries for sending: their
+// next attempt moves lease ahead, so no other instance picks them up unless
+// this one dies before finishing them
+func (r *reminderRepository) ClaimDeliveries(limit int, lease time.Duration) ([]*models.ReminderDelivery, error) {
+	rows, err := r.db.Query(`
+		UPDATE reminder_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = now() + $2::double precision * interval '1 second'
+		FROM reminders r
+		JOIN users u ON u.id = r.user_id
+		LEFT JOIN tasks t ON t.id = r.task_id
+		LEFT JOIN habits h ON h.id = r.habit_id
+		WHERE r.id = d.reminder_id AND d.id IN (
+			SELECT id FROM reminder_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.reminder_id, d.user_id, u.email, d.channel, r.task_id, r.habit_id,
+			COALESCE(t.task_name, h.name, ''), t.due_date, r.timezone, d.fire_at, d.attempts`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.ReminderDelivery
+	for rows.Next() {
+		d := &models.ReminderDelivery{}
+		err := rows.Scan(&d.ID, &d.ReminderID, &d.UserID, &d.Email, &d.Channel, &d.TaskID, &d.HabitID,
+			&d.Subject, &d.DueDate, &d.Timezone, &d.FireAt, &d.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *reminderRepository) CompleteDelivery(deliveryID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE reminder_deliveries SET status = 'sent', delivered_at = now(), last_error = NULL
+		WHERE id = $1`, deliveryID)
+	return err
+}
+
+// FailDelivery records a failed attempt; it is retried at retryAt, or given
+// up on when retryAt is nil
+func (r *reminderRepository) FailDelivery(deliveryID int64, reason string, retryAt *time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE reminder_deliveries
+		SET last_error = $1,
+			status = CASE WHEN $2::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($2, next_attempt_at)
+		WHERE id = $3`, reason, retryAt, deliveryID)
+	return err
+}
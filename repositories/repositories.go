@@ -3,7 +3,14 @@ package repositories
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"todo-backend/filter"
 	"todo-backend/models"
+	"todo-backend/pagination"
+
+	"github.com/lib/pq"
 )
 
 type UserRepository interface {
@@ -97,7 +104,7 @@ func (r *userRepository) GetUserStats(userID int64) (*models.UserStats, error) {
 
 	// Get task statistics
 	err := r.db.QueryRow(
-		"SELECT COUNT(*) FROM tasks WHERE user_id = $1",
+		"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.TotalTasks)
 	if err != nil {
@@ -105,7 +112,7 @@ func (r *userRepository) GetUserStats(userID int64) (*models.UserStats, error) {
 	}
 
 	err = r.db.QueryRow(
-		"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND is_completed = true",
+		"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND is_completed = true AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.CompletedTasks)
 	if err != nil {
@@ -116,7 +123,7 @@ func (r *userRepository) GetUserStats(userID int64) (*models.UserStats, error) {
 
 	// Get habit statistics
 	err = r.db.QueryRow(
-		"SELECT COUNT(*) FROM habits WHERE user_id = $1",
+		"SELECT COUNT(*) FROM habits WHERE user_id = $1 AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.TotalHabits)
 	if err != nil {
@@ -124,7 +131,7 @@ func (r *userRepository) GetUserStats(userID int64) (*models.UserStats, error) {
 	}
 
 	err = r.db.QueryRow(
-		"SELECT COUNT(*) FROM habits WHERE user_id = $1 AND is_achieved = true",
+		"SELECT COUNT(*) FROM habits WHERE user_id = $1 AND is_achieved = true AND deleted_at IS NULL",
 		userID,
 	).Scan(&stats.AchievedHabits)
 	if err != nil {
@@ -155,9 +162,46 @@ type TaskRepository interface {
 	GetTaskByID(taskID, userID int64) (*models.Task, error)
 	UpdateTask(task *models.Task) (*models.Task, error)
 	DeleteTask(taskID, userID int64) error
-	MarkTaskCompleted(taskID, userID int64, isCompleted bool) error
+	UpdateTaskStatus(taskID, userID int64, from, to models.TaskStatus) (*models.Task, error)
+	GetTaskStatusHistory(taskID, userID int64) ([]*models.TaskStatusHistory, error)
+	GetTasksByStatusPaginated(userID int64, status models.TaskStatus, page, pageSize int) ([]*models.Task, int64, error)
 	GetTasksByCategory(userID int64, category string) ([]*models.Task, error)
 	GetTasksByPriority(userID int64, priority int16) ([]*models.Task, error)
+	GetRecurrenceInstance(seriesID, userID int64, index int32) (*models.Task, error)
+	GetSubtasks(parentID, userID int64) ([]*models.Task, error)
+	GetTaskDescendants(taskID, userID int64) ([]*models.Task, error)
+	ReparentSubtasks(taskID, userID int64, newParentID *int64) (int64, error)
+	AddTaskDependency(taskID, blockedByID, userID int64) error
+	RemoveTaskDependency(taskID, blockedByID, userID int64) error
+	GetTaskBlockers(taskID, userID int64) ([]*models.Task, error)
+	GetTaskDependents(taskID, userID int64) ([]*models.Task, error)
+	GetUserDependencies(userID int64) ([]*models.TaskDependency, error)
+	GetOpenBlockers(userID int64) (map[int64][]int64, error)
+	GetTasksByTagsPaginated(userID int64, tags []string, matchAll bool, page, pageSize int) ([]*models.Task, int64, error)
+	SearchTasks(userID int64, tsQuery string, req *models.SearchTasksRequest) ([]*models.TaskSearchResult, int64, error)
+	GetTasksByFilterPaginated(userID int64, node filter.Node, page, pageSize int) ([]*models.Task, int64, error)
+	GetTasksByCursor(userID int64, node filter.Node, q *pagination.Query) ([]*models.Task, *models.CursorPage, error)
+	GetTasksByIDs(userID int64, ids []int64) ([]*models.Task, error)
+	ApplyBulkTaskChanges(userID int64, op models.BulkOperation, changes []*models.BulkTaskChange, promoteSubtasks bool) ([]*models.Task, error)
+	RescheduleTask(taskID, userID int64, newDueDate time.Time, reason *string) (*models.Task, *models.TaskReschedule, error)
+	GetTaskReschedules(taskID, userID int64) ([]*models.TaskReschedule, error)
+	GetPostponedTasks(userID int64, minCount int) ([]*models.PostponedTask, error)
+	GetRescheduleSlipByCategory(userID int64, since time.Time) ([]*models.CategorySlip, error)
+	CreateTaskTrees(userID int64, parentID *int64, trees []*models.TaskTree) ([]*models.Task, error)
+	GetProjectTasks(projectID, userID int64) ([]*models.Task, error)
+	MoveTask(taskID, userID int64, projectID, afterID, beforeID *int64) (*models.Task, error)
+	GetUserAttachments(userID int64) ([]*models.TaskAttachment, error)
+	GetOpenTasks(userID int64) ([]*models.Task, error)
+	SetTaskImportance(taskID, userID int64, isImportant *bool) (*models.Task, error)
+	GetFeedTasks(userID int64, includeCompleted bool) ([]*models.FeedTask, error)
+	GetTaskIDsByICalUID(userID int64, uids []string) (map[string]int64, error)
+	SetTaskICalUID(taskID, userID int64, uid string) error
+	GetCalDAVTasks(userID int64) ([]*models.CalDAVTask, error)
+	GetCalDAVTask(taskID, userID int64) (*models.CalDAVTask, error)
+	GetTaskIDByCalDAVHref(userID int64, href string) (int64, error)
+	SetTaskCalDAVRef(taskID, userID int64, uid, href string) error
+	CreateCalDAVTask(task *models.Task, uid, href string) (*models.Task, error)
+	WithCalDAVLock(userID int64, fn func() error) error
 }
 
 type taskRepository struct {
@@ -168,9 +212,53 @@ func NewTaskRepository(db *sql.DB) TaskRepository {
 	return &taskRepository{db: db}
 }
 
-func (r *taskRepository) CreateTask(task *models.Task) (*models.Task, error) {
-	var createdTask models.Task
+// taskColumns is the column list every task query selects, in the order taskFields lists them
+const taskColumns = `id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at,
+		recurrence_parent_id, recurrence_index, status, parent_id, postpone_count, estimate_minutes, ` + taskActualMinutes + ` AS actual_minutes,
+		project_id, (SELECT p.name FROM projects p WHERE p.id = tasks.project_id) AS project_name, position,
+		(SELECT COUNT(*) FROM task_comments tc WHERE tc.task_id = tasks.id) AS comment_count, is_important,
+		ARRAY(SELECT tg.name FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = tasks.id ORDER BY lower(tg.name)) AS tags`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// taskFields returns the scan destinations matching taskColumns
+func taskFields(task *models.Task) []interface{} {
+	return []interface{}{&task.ID, &task.UserID, &task.TaskName, &task.Description,
+		&task.Category, &task.Priority, &task.DueDate, &task.IsCompleted,
+		&task.IsRecurring, &task.RecurringFrequency, &task.CreatedAt,
+		&task.RecurrenceParentID, &task.RecurrenceIndex, &task.Status, &task.ParentID, &task.PostponeCount,
+		&task.EstimateMinutes, &task.ActualMinutes, &task.ProjectID, &task.ProjectName, &task.Position, &task.CommentCount, &task.IsImportant, pq.Array(&task.Tags)}
+}
+
+func scanTask(row rowScanner) (*models.Task, error) {
+	var task models.Task
+	if err := row.Scan(taskFields(&task)...); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
 
+func scanTasks(rows *sql.Rows) ([]*models.Task, error) {
+	var tasks []*models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *taskRepository) CreateTask(task *models.Task) (*models.Task, error) {
 	// Handle nil DueDate pointer
 	var dueDate interface{}
 	if task.DueDate != nil {
@@ -179,20 +267,47 @@ func (r *taskRepository) CreateTask(task *models.Task) (*models.Task, error) {
 		dueDate = nil
 	}
 
-	err := r.db.QueryRow(`
-		INSERT INTO tasks (user_id, task_name, description, category, priority, due_date, is_recurring, recurring_frequency) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
-		RETURNING id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at`,
-		task.UserID, task.TaskName, task.Description, task.Category, task.Priority, dueDate, task.IsRecurring, task.RecurringFrequency,
-	).Scan(&createdTask.ID, &createdTask.UserID, &createdTask.TaskName, &createdTask.Description,
-		&createdTask.Category, &createdTask.Priority, &createdTask.DueDate, &createdTask.IsCompleted,
-		&createdTask.IsRecurring, &createdTask.RecurringFrequency, &createdTask.CreatedAt)
+	recurrenceIndex := task.RecurrenceIndex
+	if recurrenceIndex < 1 {
+		recurrenceIndex = 1
+	}
 
+	insert := func(q queryRower, position *string) (*models.Task, error) {
+		return scanTask(q.QueryRow(`
+			INSERT INTO tasks (user_id, task_name, description, category, priority, due_date, is_recurring, recurring_frequency,
+				recurrence_parent_id, recurrence_index, parent_id, estimate_minutes, project_id, position, is_important) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) 
+			RETURNING `+taskColumns,
+			task.UserID, task.TaskName, task.Description, task.Category, task.Priority, dueDate, task.IsRecurring, task.RecurringFrequency,
+			task.RecurrenceParentID, recurrenceIndex, task.ParentID, task.EstimateMinutes, task.ProjectID, position, task.IsImportant,
+		))
+	}
+	if task.ProjectID == nil {
+		return insert(r.db, nil)
+	}
+	if task.Position != nil {
+		return insert(r.db, task.Position)
+	}
+
+	// A task added to a project without a position goes at the end
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &createdTask, nil
+	position, err := endPositionTx(tx, *task.ProjectID, task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	createdTask, err := insert(tx, &position)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return createdTask, nil
 }
 
 func (r *taskRepository) GetTasksByUserID(userID int64) ([]*models.Task, error) {
@@ -203,28 +318,21 @@ func (r *taskRepository) GetTasksByUserID(userID int64) ([]*models.Task, error)
 func (r *taskRepository) GetTasksByUserIDPaginated(userID int64, page, pageSize int) ([]*models.Task, int64, error) {
 	offset := (page - 1) * pageSize
 	rows, err := r.db.Query(`
-		SELECT id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at 
-		FROM tasks WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`, userID, pageSize, offset)
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC LIMIT $2 OFFSET $3`, userID, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var tasks []*models.Task
-	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(&task.ID, &task.UserID, &task.TaskName, &task.Description,
-			&task.Category, &task.Priority, &task.DueDate, &task.IsCompleted,
-			&task.IsRecurring, &task.RecurringFrequency, &task.CreatedAt)
-		if err != nil {
-			return nil, 0, err
-		}
-		tasks = append(tasks, &task)
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// Get total count
 	var total int64
-	err = r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE user_id = $1`, userID).Scan(&total)
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND deleted_at IS NULL`, userID).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -233,24 +341,12 @@ func (r *taskRepository) GetTasksByUserIDPaginated(userID int64, page, pageSize
 }
 
 func (r *taskRepository) GetTaskByID(taskID, userID int64) (*models.Task, error) {
-	var task models.Task
-	err := r.db.QueryRow(`
-		SELECT id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at 
-		FROM tasks WHERE id = $1 AND user_id = $2`, taskID, userID).Scan(
-		&task.ID, &task.UserID, &task.TaskName, &task.Description,
-		&task.Category, &task.Priority, &task.DueDate, &task.IsCompleted,
-		&task.IsRecurring, &task.RecurringFrequency, &task.CreatedAt)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &task, nil
+	return scanTask(r.db.QueryRow(`
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, taskID, userID))
 }
 
 func (r *taskRepository) UpdateTask(task *models.Task) (*models.Task, error) {
-	var updatedTask models.Task
-
 	// Handle nil DueDate pointer
 	var dueDate interface{}
 	if task.DueDate != nil {
@@ -259,26 +355,29 @@ func (r *taskRepository) UpdateTask(task *models.Task) (*models.Task, error) {
 		dueDate = nil
 	}
 
-	err := r.db.QueryRow(`
+	return scanTask(r.db.QueryRow(`
 		UPDATE tasks SET task_name = $1, description = $2, category = $3, priority = $4, due_date = $5, 
-		is_completed = $6, is_recurring = $7, recurring_frequency = $8 
-		WHERE id = $9 AND user_id = $10 
-		RETURNING id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at`,
+		is_recurring = $6, recurring_frequency = $7, parent_id = $8, estimate_minutes = $9, is_important = $10 
+		WHERE id = $11 AND user_id = $12 AND deleted_at IS NULL 
+		RETURNING `+taskColumns,
 		task.TaskName, task.Description, task.Category, task.Priority, dueDate,
-		task.IsCompleted, task.IsRecurring, task.RecurringFrequency, task.ID, task.UserID,
-	).Scan(&updatedTask.ID, &updatedTask.UserID, &updatedTask.TaskName, &updatedTask.Description,
-		&updatedTask.Category, &updatedTask.Priority, &updatedTask.DueDate, &updatedTask.IsCompleted,
-		&updatedTask.IsRecurring, &updatedTask.RecurringFrequency, &updatedTask.CreatedAt)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &updatedTask, nil
+		task.IsRecurring, task.RecurringFrequency, task.ParentID, task.EstimateMinutes, task.IsImportant, task.ID, task.UserID,
+	))
 }
 
+// trashTaskSQL moves a task and every subtask still below it to the trash.
+// They share one deleted_at so that restoring the task brings them back too.
+const trashTaskSQL = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		UNION ALL
+		SELECT t.id FROM tasks t JOIN subtree ON t.parent_id = subtree.id WHERE t.deleted_at IS NULL
+	)
+	UPDATE tasks SET deleted_at = now() WHERE id IN (SELECT id FROM subtree)`
+
+// DeleteTask moves a task and its subtasks to the trash
 func (r *taskRepository) DeleteTask(taskID, userID int64) error {
-	result, err := r.db.Exec("DELETE FROM tasks WHERE id = $1 AND user_id = $2", taskID, userID)
+	result, err := r.db.Exec(trashTaskSQL, taskID, userID)
 	if err != nil {
 		return err
 	}
@@ -295,8 +394,193 @@ func (r *taskRepository) DeleteTask(taskID, userID int64) error {
 	return nil
 }
 
-func (r *taskRepository) MarkTaskCompleted(taskID, userID int64, isCompleted bool) error {
-	result, err := r.db.Exec("UPDATE tasks SET is_completed = $1 WHERE id = $2 AND user_id = $3", isCompleted, taskID, userID)
+// UpdateTaskStatus moves a task from one status to another and records the
+// change in task_status_history within a single transaction. The update only
+// applies while the task is still in the from status; otherwise sql.ErrNoRows
+// is returned so concurrent transitions cannot both succeed.
+func (r *taskRepository) UpdateTaskStatus(taskID, userID int64, from, to models.TaskStatus) (*models.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	task, err := updateTaskStatusTx(tx, taskID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// updateTaskStatusTx moves a task from one status to another and records the
+// change in its status history. sql.ErrNoRows is returned when the task no
+// longer has the from status.
+func updateTaskStatusTx(tx *sql.Tx, taskID, userID int64, from, to models.TaskStatus) (*models.Task, error) {
+	task, err := scanTask(tx.QueryRow(`
+		UPDATE tasks SET status = $1, is_completed = ($1 = 'completed') 
+		WHERE id = $2 AND user_id = $3 AND status = $4 AND deleted_at IS NULL 
+		RETURNING `+taskColumns, to, taskID, userID, from))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO task_status_history (task_id, user_id, from_status, to_status) 
+		VALUES ($1, $2, $3, $4)`, taskID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := clearBoardCardsTx(tx, taskID); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (r *taskRepository) GetTaskStatusHistory(taskID, userID int64) ([]*models.TaskStatusHistory, error) {
+	rows, err := r.db.Query(`
+		SELECT id, task_id, user_id, from_status, to_status, changed_at 
+		FROM task_status_history 
+		WHERE task_id = $1 AND user_id = $2 
+		ORDER BY changed_at ASC, id ASC`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*models.TaskStatusHistory{}
+	for rows.Next() {
+		entry := &models.TaskStatusHistory{}
+		err := rows.Scan(&entry.ID, &entry.TaskID, &entry.UserID, &entry.FromStatus, &entry.ToStatus, &entry.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+func (r *taskRepository) GetTasksByStatusPaginated(userID int64, status models.TaskStatus, page, pageSize int) ([]*models.Task, int64, error) {
+	offset := (page - 1) * pageSize
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE user_id = $1 AND status = $2 AND deleted_at IS NULL ORDER BY created_at DESC LIMIT $3 OFFSET $4`, userID, status, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND status = $2 AND deleted_at IS NULL`, userID, status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tasks, total, nil
+}
+
+func (r *taskRepository) GetTasksByCategory(userID int64, category string) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE user_id = $1 AND category = $2 AND deleted_at IS NULL ORDER BY created_at DESC`, userID, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+func (r *taskRepository) GetTasksByPriority(userID int64, priority int16) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE user_id = $1 AND priority = $2 AND deleted_at IS NULL ORDER BY created_at DESC`, userID, priority)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// GetRecurrenceInstance finds the index-th occurrence of a recurring series.
+// The series root is the first occurrence and has no recurrence_parent_id.
+func (r *taskRepository) GetRecurrenceInstance(seriesID, userID int64, index int32) (*models.Task, error) {
+	return scanTask(r.db.QueryRow(`
+		SELECT `+taskColumns+` 
+		FROM tasks 
+		WHERE user_id = $2 AND recurrence_index = $3 AND (id = $1 OR recurrence_parent_id = $1) AND deleted_at IS NULL
+		ORDER BY id LIMIT 1`, seriesID, userID, index))
+}
+
+// GetSubtasks returns the direct children of a task
+func (r *taskRepository) GetSubtasks(parentID, userID int64) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE parent_id = $1 AND user_id = $2 AND deleted_at IS NULL ORDER BY created_at ASC, id ASC`, parentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// GetTaskDescendants returns every task below taskID in the subtask
+// hierarchy. Parents always come before their children.
+func (r *taskRepository) GetTaskDescendants(taskID, userID int64) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		WITH RECURSIVE subtree AS (
+			SELECT id, 1 AS depth FROM tasks WHERE parent_id = $1 AND user_id = $2 AND deleted_at IS NULL
+			UNION ALL
+			SELECT t.id, subtree.depth + 1 FROM tasks t JOIN subtree ON t.parent_id = subtree.id 
+			WHERE t.user_id = $2 AND t.deleted_at IS NULL
+		)
+		SELECT `+taskColumns+` 
+		FROM tasks JOIN subtree USING (id) 
+		ORDER BY subtree.depth ASC, created_at ASC, id ASC`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// ReparentSubtasks moves the direct children of a task under newParentID
+// (nil makes them top-level tasks) and reports how many were moved
+func (r *taskRepository) ReparentSubtasks(taskID, userID int64, newParentID *int64) (int64, error) {
+	result, err := r.db.Exec(`UPDATE tasks SET parent_id = $1 WHERE parent_id = $2 AND user_id = $3 AND deleted_at IS NULL`, newParentID, taskID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AddTaskDependency records that taskID is blocked by blockedByID. Adding an
+// existing dependency again is a no-op.
+func (r *taskRepository) AddTaskDependency(taskID, blockedByID, userID int64) error {
+	_, err := r.db.Exec(`
+		INSERT INTO task_dependencies (task_id, blocked_by_task_id, user_id) 
+		VALUES ($1, $2, $3) 
+		ON CONFLICT (task_id, blocked_by_task_id) DO NOTHING`, taskID, blockedByID, userID)
+	return err
+}
+
+func (r *taskRepository) RemoveTaskDependency(taskID, blockedByID, userID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM task_dependencies WHERE task_id = $1 AND blocked_by_task_id = $2 AND user_id = $3`,
+		taskID, blockedByID, userID)
 	if err != nil {
 		return err
 	}
@@ -313,52 +597,218 @@ func (r *taskRepository) MarkTaskCompleted(taskID, userID int64, isCompleted boo
 	return nil
 }
 
-func (r *taskRepository) GetTasksByCategory(userID int64, category string) ([]*models.Task, error) {
+// GetTaskBlockers returns the tasks that taskID is waiting on
+func (r *taskRepository) GetTaskBlockers(taskID, userID int64) ([]*models.Task, error) {
 	rows, err := r.db.Query(`
-		SELECT id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at 
-		FROM tasks WHERE user_id = $1 AND category = $2 ORDER BY created_at DESC`, userID, category)
+		SELECT `+taskColumns+` 
+		FROM tasks 
+		WHERE user_id = $2 AND deleted_at IS NULL AND id IN (SELECT blocked_by_task_id FROM task_dependencies WHERE task_id = $1 AND user_id = $2) 
+		ORDER BY due_date ASC NULLS LAST, id ASC`, taskID, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tasks []*models.Task
+	return scanTasks(rows)
+}
+
+// GetTaskDependents returns the tasks that are waiting on taskID
+func (r *taskRepository) GetTaskDependents(taskID, userID int64) ([]*models.Task, error) {
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+` 
+		FROM tasks 
+		WHERE user_id = $2 AND deleted_at IS NULL AND id IN (SELECT task_id FROM task_dependencies WHERE blocked_by_task_id = $1 AND user_id = $2) 
+		ORDER BY due_date ASC NULLS LAST, id ASC`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+func (r *taskRepository) GetUserDependencies(userID int64) ([]*models.TaskDependency, error) {
+	rows, err := r.db.Query(`
+		SELECT task_id, blocked_by_task_id, user_id, created_at 
+		FROM task_dependencies d WHERE user_id = $1 
+			AND NOT EXISTS (SELECT 1 FROM tasks t WHERE t.id IN (d.task_id, d.blocked_by_task_id) AND t.deleted_at IS NOT NULL) 
+		ORDER BY task_id, blocked_by_task_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependencies := []*models.TaskDependency{}
 	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(&task.ID, &task.UserID, &task.TaskName, &task.Description,
-			&task.Category, &task.Priority, &task.DueDate, &task.IsCompleted,
-			&task.IsRecurring, &task.RecurringFrequency, &task.CreatedAt)
-		if err != nil {
+		dependency := &models.TaskDependency{}
+		if err := rows.Scan(&dependency.TaskID, &dependency.BlockedByID, &dependency.UserID, &dependency.CreatedAt); err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, &task)
+		dependencies = append(dependencies, dependency)
 	}
 
-	return tasks, nil
+	return dependencies, rows.Err()
 }
 
-func (r *taskRepository) GetTasksByPriority(userID int64, priority int16) ([]*models.Task, error) {
+// GetOpenBlockers maps each of the user's blocked tasks to the IDs of the
+// blockers that are still pending or in progress
+func (r *taskRepository) GetOpenBlockers(userID int64) (map[int64][]int64, error) {
 	rows, err := r.db.Query(`
-		SELECT id, user_id, task_name, description, category, priority, due_date, is_completed, is_recurring, recurring_frequency, created_at 
-		FROM tasks WHERE user_id = $1 AND priority = $2 ORDER BY created_at DESC`, userID, priority)
+		SELECT d.task_id, d.blocked_by_task_id 
+		FROM task_dependencies d 
+		JOIN tasks b ON b.id = d.blocked_by_task_id 
+		WHERE d.user_id = $1 AND b.status IN ('pending', 'in_progress') AND b.deleted_at IS NULL 
+		ORDER BY d.task_id, d.blocked_by_task_id`, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tasks []*models.Task
+	blockers := make(map[int64][]int64)
+	for rows.Next() {
+		var taskID, blockedByID int64
+		if err := rows.Scan(&taskID, &blockedByID); err != nil {
+			return nil, err
+		}
+		blockers[taskID] = append(blockers[taskID], blockedByID)
+	}
+
+	return blockers, rows.Err()
+}
+
+// GetTasksByTagsPaginated returns tasks carrying any (or, with matchAll,
+// every) of the given tag names. Names are compared case-insensitively.
+func (r *taskRepository) GetTasksByTagsPaginated(userID int64, tags []string, matchAll bool, page, pageSize int) ([]*models.Task, int64, error) {
+	lowered := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		name := strings.ToLower(tag)
+		if !seen[name] {
+			seen[name] = true
+			lowered = append(lowered, name)
+		}
+	}
+
+	required := 1
+	if matchAll {
+		required = len(lowered)
+	}
+
+	tagFilter := `id IN (
+			SELECT tt.task_id FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id 
+			WHERE tg.user_id = $1 AND lower(tg.name) = ANY($2) 
+			GROUP BY tt.task_id HAVING COUNT(DISTINCT tg.id) >= $3)`
+
+	offset := (page - 1) * pageSize
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+` 
+		FROM tasks WHERE user_id = $1 AND deleted_at IS NULL AND `+tagFilter+` 
+		ORDER BY created_at DESC LIMIT $4 OFFSET $5`, userID, pq.Array(lowered), required, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND deleted_at IS NULL AND `+tagFilter, userID, pq.Array(lowered), required).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tasks, total, nil
+}
+
+// escapeHTMLSQL escapes a text column for use as HTML. The entities it
+// leaves are not words to the text search parser, so they are never marked.
+func escapeHTMLSQL(column string) string {
+	return "replace(replace(replace(" + column + ", '&', '&amp;'), '<', '&lt;'), '>', '&gt;')"
+}
+
+// SearchTasks runs a full-text search. tsQuery is a to_tsquery expression;
+// when it is empty only the filters apply and results are ordered newest
+// first instead of by rank.
+func (r *taskRepository) SearchTasks(userID int64, tsQuery string, req *models.SearchTasksRequest) ([]*models.TaskSearchResult, int64, error) {
+	conditions := []string{"user_id = $1", "deleted_at IS NULL"}
+	args := []interface{}{userID}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Highlights are HTML, so the text is escaped before marks are added
+	name, description := escapeHTMLSQL("task_name"), escapeHTMLSQL("description")
+	rank, highlight, snippet := "0", name, "NULL::TEXT"
+	orderBy := "created_at DESC, id DESC"
+	if tsQuery != "" {
+		query := "to_tsquery('simple', " + addArg(tsQuery) + ")"
+		conditions = append(conditions, "search_vector @@ "+query)
+		rank = "ts_rank_cd(search_vector, " + query + ")"
+		highlight = "ts_headline('simple', " + name + ", " + query + ", 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')"
+		snippet = "CASE WHEN description IS NULL OR description = '' THEN NULL ELSE ts_headline('simple', " + description + ", " + query +
+			", 'StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=8, MaxFragments=2, FragmentDelimiter=\" ... \"') END"
+		orderBy = "rank DESC, created_at DESC, id DESC"
+	}
+	if req.Category != nil {
+		conditions = append(conditions, "lower(category) = lower("+addArg(*req.Category)+")")
+	}
+	if req.Priority != nil {
+		conditions = append(conditions, "priority = "+addArg(*req.Priority))
+	}
+	if req.Status != nil {
+		conditions = append(conditions, "status = "+addArg(*req.Status))
+	}
+	if req.DueDateFrom != nil {
+		conditions = append(conditions, "due_date >= "+addArg(*req.DueDateFrom))
+	}
+	if req.DueDateTo != nil {
+		conditions = append(conditions, "due_date < "+addArg(*req.DueDateTo))
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	limitArg, offsetArg := addArg(req.PageSize), addArg(offset)
+
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`, `+rank+` AS rank, `+highlight+` AS highlight, `+snippet+` AS snippet, 
+			COUNT(*) OVER () AS total 
+		FROM tasks 
+		WHERE `+strings.Join(conditions, " AND ")+` 
+		ORDER BY `+orderBy+` 
+		LIMIT `+limitArg+` OFFSET `+offsetArg, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []*models.TaskSearchResult{}
+	var total int64
 	for rows.Next() {
 		var task models.Task
-		err := rows.Scan(&task.ID, &task.UserID, &task.TaskName, &task.Description,
-			&task.Category, &task.Priority, &task.DueDate, &task.IsCompleted,
-			&task.IsRecurring, &task.RecurringFrequency, &task.CreatedAt)
+		result := &models.TaskSearchResult{Task: &task}
+		dest := append(taskFields(&task), &result.Rank, &result.Highlight, &result.Snippet, &total)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// COUNT(*) OVER () is only available when the page has rows
+	if len(results) == 0 && offset > 0 {
+		countArgs := args[:len(args)-2]
+		err = r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE `+strings.Join(conditions, " AND "), countArgs...).Scan(&total)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		tasks = append(tasks, &task)
 	}
 
-	return tasks, nil
+	return results, total, nil
 }
 
 // Log Repository
@@ -469,6 +919,7 @@ type HabitRepository interface {
 	MarkHabitAchieved(habitID, userID int64, isAchieved bool) error
 	TrackHabit(habitID, userID int64) error
 	GetHabitsByType(userID int64, habitType string) ([]*models.Habit, error)
+	GetHabitsByCursor(userID int64, q *pagination.Query) ([]*models.Habit, *models.CursorPage, error)
 }
 
 type habitRepository struct {
@@ -504,7 +955,7 @@ func (r *habitRepository) GetHabitsByUserIDPaginated(userID int64, page, pageSiz
 	query := `
 		SELECT id, user_id, name, type, target_value, is_achieved, last_tracked_date, created_at
 		FROM habits
-		WHERE user_id = $1
+		WHERE user_id = $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3`
 	rows, err := r.db.Query(query, userID, pageSize, offset)
@@ -526,7 +977,7 @@ func (r *habitRepository) GetHabitsByUserIDPaginated(userID int64, page, pageSiz
 
 	// Get total count
 	var total int64
-	err = r.db.QueryRow(`SELECT COUNT(*) FROM habits WHERE user_id = $1`, userID).Scan(&total)
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM habits WHERE user_id = $1 AND deleted_at IS NULL`, userID).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -538,7 +989,7 @@ func (r *habitRepository) GetHabitByID(habitID, userID int64) (*models.Habit, er
 	query := `
 		SELECT id, user_id, name, type, target_value, is_achieved, last_tracked_date, created_at
 		FROM habits
-		WHERE id = $1 AND user_id = $2`
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 
 	habit := &models.Habit{}
 	err := r.db.QueryRow(query, habitID, userID).Scan(
@@ -555,7 +1006,7 @@ func (r *habitRepository) UpdateHabit(habit *models.Habit) (*models.Habit, error
 	query := `
 		UPDATE habits
 		SET name = $1, type = $2, target_value = $3, is_achieved = $4
-		WHERE id = $5 AND user_id = $6
+		WHERE id = $5 AND user_id = $6 AND deleted_at IS NULL
 		RETURNING id, user_id, name, type, target_value, is_achieved, last_tracked_date, created_at`
 
 	err := r.db.QueryRow(query, habit.Name, habit.Type, habit.TargetValue,
@@ -570,7 +1021,7 @@ func (r *habitRepository) UpdateHabit(habit *models.Habit) (*models.Habit, error
 }
 
 func (r *habitRepository) DeleteHabit(habitID, userID int64) error {
-	query := `DELETE FROM habits WHERE id = $1 AND user_id = $2`
+	query := `UPDATE habits SET deleted_at = now() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 	result, err := r.db.Exec(query, habitID, userID)
 	if err != nil {
 		return err
@@ -591,7 +1042,7 @@ func (r *habitRepository) MarkHabitAchieved(habitID, userID int64, isAchieved bo
 	query := `
 		UPDATE habits
 		SET is_achieved = $1
-		WHERE id = $2 AND user_id = $3`
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL`
 
 	result, err := r.db.Exec(query, isAchieved, habitID, userID)
 	if err != nil {
@@ -613,7 +1064,7 @@ func (r *habitRepository) TrackHabit(habitID, userID int64) error {
 	query := `
 		UPDATE habits
 		SET last_tracked_date = NOW()
-		WHERE id = $1 AND user_id = $2`
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 
 	result, err := r.db.Exec(query, habitID, userID)
 	if err != nil {
@@ -635,7 +1086,7 @@ func (r *habitRepository) GetHabitsByType(userID int64, habitType string) ([]*mo
 	query := `
 		SELECT id, user_id, name, type, target_value, is_achieved, last_tracked_date, created_at
 		FROM habits
-		WHERE user_id = $1 AND type = $2
+		WHERE user_id = $1 AND type = $2 AND deleted_at IS NULL
 		ORDER BY created_at DESC`
 
 	rows, err := r.db.Query(query, userID, habitType)
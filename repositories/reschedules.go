@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+	"todo-backend/models"
+)
+
+// RescheduleTask moves a task's due date and records the change in the
+// reschedule history in one transaction
+func (r *taskRepository) RescheduleTask(taskID, userID int64, newDueDate time.Time, reason *string) (*models.Task, *models.TaskReschedule, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	task, reschedule, err := rescheduleTx(tx, taskID, userID, newDueDate, reason)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return task, reschedule, nil
+}
+
+// rescheduleTx updates the due date, counts the change as a postponement
+// when it moves the date later and inserts the history and revision rows
+func rescheduleTx(tx *sql.Tx, taskID, userID int64, newDueDate time.Time, reason *string) (*models.Task, *models.TaskReschedule, error) {
+	before, err := lockTaskTx(tx, taskID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	reschedule := &models.TaskReschedule{
+		TaskID:     taskID,
+		UserID:     userID,
+		OldDueDate: before.DueDate,
+		NewDueDate: models.CustomTime{Time: newDueDate},
+		Reason:     reason,
+	}
+
+	task, err := scanTask(tx.QueryRow(`
+		UPDATE tasks SET due_date = $1,
+			postpone_count = postpone_count + CASE WHEN due_date IS NOT NULL AND $1 > due_date THEN 1 ELSE 0 END
+		WHERE id = $2 AND user_id = $3
+		RETURNING `+taskColumns, newDueDate, taskID, userID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO task_reschedules (task_id, user_id, old_due_date, new_due_date, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, rescheduled_at`,
+		taskID, userID, reschedule.OldDueDate, newDueDate, reason).Scan(&reschedule.ID, &reschedule.RescheduledAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := addTaskRevisionTx(tx, models.RevisionUpdate, before, task); err != nil {
+		return nil, nil, err
+	}
+
+	return task, reschedule, nil
+}
+
+func (r *taskRepository) GetTaskReschedules(taskID, userID int64) ([]*models.TaskReschedule, error) {
+	rows, err := r.db.Query(`
+		SELECT id, task_id, user_id, old_due_date, new_due_date, reason, rescheduled_at
+		FROM task_reschedules
+		WHERE task_id = $1 AND user_id = $2
+		ORDER BY rescheduled_at ASC, id ASC`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reschedules := []*models.TaskReschedule{}
+	for rows.Next() {
+		entry := &models.TaskReschedule{}
+		err := rows.Scan(&entry.ID, &entry.TaskID, &entry.UserID, &entry.OldDueDate,
+			&entry.NewDueDate, &entry.Reason, &entry.RescheduledAt)
+		if err != nil {
+			return nil, err
+		}
+		reschedules = append(reschedules, entry)
+	}
+
+	return reschedules, rows.Err()
+}
+
+// GetPostponedTasks returns the user's open tasks postponed at least minCount
+// times, most postponed first
+func (r *taskRepository) GetPostponedTasks(userID int64, minCount int) ([]*models.PostponedTask, error) {
+	rows, err := r.db.Query(`
+		SELECT t.id, t.task_name, t.category, t.status, t.due_date, t.postpone_count,
+			COALESCE((SELECT EXTRACT(EPOCH FROM SUM(rs.new_due_date - rs.old_due_date)) / 86400
+				FROM task_reschedules rs WHERE rs.task_id = t.id), 0),
+			(SELECT rs.reason FROM task_reschedules rs
+				WHERE rs.task_id = t.id ORDER BY rs.rescheduled_at DESC, rs.id DESC LIMIT 1)
+		FROM tasks t
+		WHERE t.user_id = $1 AND t.postpone_count >= $2 AND t.status IN ('pending', 'in_progress') AND t.deleted_at IS NULL
+		ORDER BY t.postpone_count DESC, t.due_date ASC NULLS LAST, t.id ASC`, userID, minCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*models.PostponedTask{}
+	for rows.Next() {
+		task := &models.PostponedTask{}
+		err := rows.Scan(&task.TaskID, &task.TaskName, &task.Category, &task.Status, &task.DueDate,
+			&task.PostponeCount, &task.TotalSlipDays, &task.LastReason)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// GetRescheduleSlipByCategory aggregates the reschedules made since a given
+// time by task category. Reschedules of tasks without a previous due date
+// have no slip and are left out of the average.
+func (r *taskRepository) GetRescheduleSlipByCategory(userID int64, since time.Time) ([]*models.CategorySlip, error) {
+	rows, err := r.db.Query(`
+		SELECT COALESCE(t.category, 'Uncategorized'),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE rs.new_due_date > rs.old_due_date),
+			COUNT(DISTINCT rs.task_id),
+			COALESCE(EXTRACT(EPOCH FROM AVG(rs.new_due_date - rs.old_due_date)) / 86400, 0)
+		FROM task_reschedules rs
+		JOIN tasks t ON t.id = rs.task_id
+		WHERE rs.user_id = $1 AND rs.rescheduled_at >= $2 AND t.deleted_at IS NULL
+		GROUP BY 1
+		ORDER BY 5 DESC, 1 ASC`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*models.CategorySlip{}
+	for rows.Next() {
+		slip := &models.CategorySlip{}
+		err := rows.Scan(&slip.Category, &slip.Reschedules, &slip.Postponements, &slip.TasksAffected, &slip.AverageSlipDays)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, slip)
+	}
+
+	return categories, rows.Err()
+}
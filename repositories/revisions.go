@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"todo-backend/models"
+)
+
+// Revision Repository
+type RevisionRepository interface {
+	AddRevision(revision *models.Revision, baseline json.RawMessage) (*models.Revision, error)
+	GetRevisions(entityType string, entityID, userID int64) ([]*models.Revision, error)
+	GetRevision(entityType string, entityID, userID int64, number int) (*models.Revision, error)
+}
+
+type revisionRepository struct {
+	db *sql.DB
+}
+
+func NewRevisionRepository(db *sql.DB) RevisionRepository {
+	return &revisionRepository{db: db}
+}
+
+const revisionColumns = `id, entity_type, entity_id, user_id, revision, action, snapshot, restored_from, created_at`
+
+func scanRevision(row rowScanner) (*models.Revision, error) {
+	revision := &models.Revision{}
+	var snapshot []byte
+	err := row.Scan(&revision.ID, &revision.EntityType, &revision.EntityID, &revision.UserID,
+		&revision.Revision, &revision.Action, &snapshot, &revision.RestoredFrom, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	revision.Snapshot = json.RawMessage(snapshot)
+	return revision, nil
+}
+
+// AddRevision appends a revision to the entity's history and numbers it. When
+// the entity has no history yet and baseline is given, baseline is stored
+// first as revision 1 so the change can be diffed and undone.
+func (r *revisionRepository) AddRevision(revision *models.Revision, baseline json.RawMessage) (*models.Revision, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := addRevisionTx(tx, revision, baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// addTaskRevisionTx records a change to a task in its history as part of the
+// transaction that makes it. state is the task after the change, or before
+// it for a delete; before is stored as the baseline of a task with no
+// history yet.
+func addTaskRevisionTx(tx *sql.Tx, action string, before, state *models.Task) error {
+	snapshot, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	var baseline json.RawMessage
+	if before != nil {
+		if baseline, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	_, err = addRevisionTx(tx, &models.Revision{
+		EntityType: models.RevisionTask,
+		EntityID:   state.ID,
+		UserID:     state.UserID,
+		Action:     action,
+		Snapshot:   snapshot,
+	}, baseline)
+	return err
+}
+
+func addRevisionTx(tx *sql.Tx, revision *models.Revision, baseline json.RawMessage) (*models.Revision, error) {
+	// Serialise writers of the same entity so revision numbers stay gapless
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2::text))`, revision.EntityType, revision.EntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var last int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(revision), 0) FROM revisions WHERE entity_type = $1 AND entity_id = $2`,
+		revision.EntityType, revision.EntityID).Scan(&last)
+	if err != nil {
+		return nil, err
+	}
+
+	insert := `
+		INSERT INTO revisions (entity_type, entity_id, user_id, revision, action, snapshot, restored_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + revisionColumns
+
+	if last == 0 && baseline != nil {
+		last++
+		_, err = tx.Exec(insert, revision.EntityType, revision.EntityID, revision.UserID, last,
+			models.RevisionBaseline, []byte(baseline), nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return scanRevision(tx.QueryRow(insert, revision.EntityType, revision.EntityID, revision.UserID, last+1,
+		revision.Action, []byte(revision.Snapshot), revision.RestoredFrom))
+}
+
+// GetRevisions returns the history of an entity, oldest revision first
+func (r *revisionRepository) GetRevisions(entityType string, entityID, userID int64) ([]*models.Revision, error) {
+	rows, err := r.db.Query(`
+		SELECT `+revisionColumns+`
+		FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2 AND user_id = $3
+		ORDER BY revision ASC`, entityType, entityID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (r *revisionRepository) GetRevision(entityType string, entityID, userID int64, number int) (*models.Revision, error) {
+	return scanRevision(r.db.QueryRow(`
+		SELECT `+revisionColumns+`
+		FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2 AND user_id = $3 AND revision = $4`,
+		entityType, entityID, userID, number))
+}
+
+// deleteOrphanRevisions removes the history of the given type's entities that
+// no longer exist, after they have been purged from the trash
+func deleteOrphanRevisions(tx *sql.Tx, entityType, table string) error {
+	_, err := tx.Exec(`
+		DELETE FROM revisions r
+		WHERE r.entity_type = $1 AND NOT EXISTS (SELECT 1 FROM `+table+` e WHERE e.id = r.entity_id)`, entityType)
+	return err
+}
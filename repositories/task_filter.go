@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"todo-backend/filter"
+	"todo-backend/models"
+)
+
+// filterColumns maps filter fields onto task columns
+var filterColumns = map[filter.Field]string{
+	filter.FieldPriority:  "priority",
+	filter.FieldCategory:  "category",
+	filter.FieldStatus:    "status",
+	filter.FieldDue:       "due_date",
+	filter.FieldCreated:   "created_at",
+	filter.FieldName:      "task_name",
+	filter.FieldCompleted: "is_completed",
+	filter.FieldRecurring: "is_recurring",
+}
+
+// taskFilterCompiler turns a filter AST into a parameterized WHERE fragment.
+// Every comparison compiles to an expression that is never NULL, so NOT
+// behaves as users expect for tasks without a category or due date.
+type taskFilterCompiler struct {
+	args []interface{}
+}
+
+func (c *taskFilterCompiler) arg(value interface{}) string {
+	c.args = append(c.args, value)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+func (c *taskFilterCompiler) compile(node filter.Node) string {
+	switch n := node.(type) {
+	case *filter.Binary:
+		return "(" + c.compile(n.Left) + " " + n.Op + " " + c.compile(n.Right) + ")"
+	case *filter.Not:
+		return "(NOT " + c.compile(n.Expr) + ")"
+	case *filter.Comparison:
+		return "COALESCE(" + c.comparison(n) + ", false)"
+	}
+	return "true"
+}
+
+func (c *taskFilterCompiler) comparison(cmp *filter.Comparison) string {
+	column := filterColumns[cmp.Field]
+	value := cmp.Value
+
+	if value.Null {
+		if cmp.Op == filter.OpNe {
+			return column + " IS NOT NULL"
+		}
+		return column + " IS NULL"
+	}
+
+	switch cmp.Field {
+	case filter.FieldTag:
+		match := `id IN (SELECT tt.task_id FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE lower(tg.name) = lower(` + c.arg(value.Str) + `))`
+		if cmp.Op == filter.OpNe {
+			return "NOT " + match
+		}
+		return match
+	case filter.FieldCategory:
+		return "lower(" + column + ") " + sqlOperator(cmp.Op) + " lower(" + c.arg(value.Str) + ")"
+	}
+
+	switch filter.KindOf(cmp.Field) {
+	case filter.KindText:
+		pattern := "%" + escapeLike(value.Str) + "%"
+		if cmp.Op == filter.OpNe {
+			return column + " NOT ILIKE " + c.arg(pattern)
+		}
+		return column + " ILIKE " + c.arg(pattern)
+	case filter.KindInt:
+		return column + " " + sqlOperator(cmp.Op) + " " + c.arg(value.Int)
+	case filter.KindBool:
+		return column + " " + sqlOperator(cmp.Op) + " " + c.arg(value.Bool)
+	case filter.KindDate:
+		return c.dateComparison(column, cmp.Op, value)
+	default:
+		return column + " " + sqlOperator(cmp.Op) + " " + c.arg(value.Str)
+	}
+}
+
+// dateComparison treats a calendar date as the whole day, so due:2026-11-01
+// matches any time that day and due>2026-11-01 starts the day after
+func (c *taskFilterCompiler) dateComparison(column string, op filter.Operator, value filter.Value) string {
+	if !value.DateOnly {
+		return column + " " + sqlOperator(op) + " " + c.arg(value.Time)
+	}
+
+	start, end := value.Time, value.Time.AddDate(0, 0, 1)
+	switch op {
+	case filter.OpEq:
+		return "(" + column + " >= " + c.arg(start) + " AND " + column + " < " + c.arg(end) + ")"
+	case filter.OpNe:
+		return "(" + column + " < " + c.arg(start) + " OR " + column + " >= " + c.arg(end) + ")"
+	case filter.OpLt:
+		return column + " < " + c.arg(start)
+	case filter.OpLte:
+		return column + " < " + c.arg(end)
+	case filter.OpGt:
+		return column + " >= " + c.arg(end)
+	default: // OpGte
+		return column + " >= " + c.arg(start)
+	}
+}
+
+func sqlOperator(op filter.Operator) string {
+	switch op {
+	case filter.OpEq:
+		return "="
+	case filter.OpNe:
+		return "<>"
+	default:
+		return string(op)
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetTasksByFilterPaginated returns the tasks matching a parsed filter expression
+func (r *taskRepository) GetTasksByFilterPaginated(userID int64, node filter.Node, page, pageSize int) ([]*models.Task, int64, error) {
+	c := &taskFilterCompiler{args: []interface{}{userID}}
+	where := "user_id = $1 AND deleted_at IS NULL AND " + c.compile(node)
+
+	var total int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE `+where, c.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	limitArg, offsetArg := c.arg(pageSize), c.arg(offset)
+	rows, err := r.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks WHERE `+where+`
+		ORDER BY created_at DESC LIMIT `+limitArg+` OFFSET `+offsetArg, c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tasks, total, nil
+}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"todo-backend/models"
+)
+
+// Task Template Repository
+type TemplateRepository interface {
+	CreateTemplate(template *models.TaskTemplate) (*models.TaskTemplate, error)
+	GetTemplates(userID int64) ([]*models.TaskTemplate, error)
+	GetTemplateByID(templateID, userID int64) (*models.TaskTemplate, error)
+	GetTemplateByName(userID int64, name string) (*models.TaskTemplate, error)
+	UpdateTemplate(template *models.TaskTemplate) (*models.TaskTemplate, error)
+	DeleteTemplate(templateID, userID int64) error
+}
+
+type templateRepository struct {
+	db *sql.DB
+}
+
+func NewTemplateRepository(db *sql.DB) TemplateRepository {
+	return &templateRepository{db: db}
+}
+
+const templateColumns = `id, user_id, name, description, tasks, created_at, updated_at`
+
+func scanTemplate(row rowScanner) (*models.TaskTemplate, error) {
+	template := &models.TaskTemplate{}
+	var tasks []byte
+	err := row.Scan(&template.ID, &template.UserID, &template.Name, &template.Description, &tasks,
+		&template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tasks, &template.Tasks); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (r *templateRepository) CreateTemplate(template *models.TaskTemplate) (*models.TaskTemplate, error) {
+	tasks, err := json.Marshal(template.Tasks)
+	if err != nil {
+		return nil, err
+	}
+	return scanTemplate(r.db.QueryRow(`
+		INSERT INTO task_templates (user_id, name, description, tasks)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+templateColumns, template.UserID, template.Name, template.Description, tasks))
+}
+
+func (r *templateRepository) GetTemplates(userID int64) ([]*models.TaskTemplate, error) {
+	rows, err := r.db.Query(`
+		SELECT `+templateColumns+`
+		FROM task_templates WHERE user_id = $1
+		ORDER BY lower(name)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*models.TaskTemplate{}
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+func (r *templateRepository) GetTemplateByID(templateID, userID int64) (*models.TaskTemplate, error) {
+	return scanTemplate(r.db.QueryRow(`
+		SELECT `+templateColumns+`
+		FROM task_templates WHERE id = $1 AND user_id = $2`, templateID, userID))
+}
+
+func (r *templateRepository) GetTemplateByName(userID int64, name string) (*models.TaskTemplate, error) {
+	return scanTemplate(r.db.QueryRow(`
+		SELECT `+templateColumns+`
+		FROM task_templates WHERE user_id = $1 AND lower(name) = lower($2)`, userID, name))
+}
+
+func (r *templateRepository) UpdateTemplate(template *models.TaskTemplate) (*models.TaskTemplate, error) {
+	tasks, err := json.Marshal(template.Tasks)
+	if err != nil {
+		return nil, err
+	}
+	return scanTemplate(r.db.QueryRow(`
+		UPDATE task_templates SET name = $1, description = $2, tasks = $3, updated_at = now()
+		WHERE id = $4 AND user_id = $5
+		RETURNING `+templateColumns, template.Name, template.Description, tasks, template.ID, template.UserID))
+}
+
+func (r *templateRepository) DeleteTemplate(templateID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM task_templates WHERE id = $1 AND user_id = $2`, templateID, userID)
+	return requireRow(result, err)
+}
+
+// CreateTaskTrees creates tasks with their tags and nested subtasks in one
+// transaction: either every task is created or none is. The top-level tasks
+// get parentID as their parent. The created tasks are returned in creation
+// order, so parents come before their subtasks.
+func (r *taskRepository) CreateTaskTrees(userID int64, parentID *int64, trees []*models.TaskTree) ([]*models.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ids []int64
+	var create func(trees []*models.TaskTree, parentID *int64) error
+	create = func(trees []*models.TaskTree, parentID *int64) error {
+		for _, tree := range trees {
+			task := tree.Task
+			var id int64
+			err := tx.QueryRow(`
+				INSERT INTO tasks (user_id, task_name, description, category, priority, due_date, parent_id, estimate_minutes)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				RETURNING id`,
+				userID, task.TaskName, task.Description, task.Category, task.Priority, task.DueDate, parentID, task.EstimateMinutes,
+			).Scan(&id)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+
+			if err := addTaskTagsTx(tx, id, userID, task.Tags); err != nil {
+				return err
+			}
+
+			if err := create(tree.Subtasks, &id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := create(trees, parentID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetTasksByIDs(userID, ids)
+}
+
+// addTaskTagsTx tags a task, creating the tags the user does not have yet
+func addTaskTagsTx(tx *sql.Tx, taskID, userID int64, names []string) error {
+	for _, name := range names {
+		_, err := tx.Exec(`
+			INSERT INTO tags (user_id, name) VALUES ($1, $2)
+			ON CONFLICT (user_id, (lower(name))) DO NOTHING`, userID, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO task_tags (task_id, tag_id)
+			SELECT $1, id FROM tags WHERE user_id = $2 AND lower(name) = lower($3)
+			ON CONFLICT DO NOTHING`, taskID, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
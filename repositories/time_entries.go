@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+	"todo-backend/models"
+)
+
+// taskActualMinutes is the time spent on a task: its time entries, a running
+// timer up to now, and its completed pomodoros
+const taskActualMinutes = `((SELECT COALESCE(ROUND(SUM(EXTRACT(EPOCH FROM COALESCE(te.ended_at, now()) - te.started_at)) / 60), 0)
+			FROM time_entries te WHERE te.task_id = tasks.id AND te.user_id = tasks.user_id)
+		+ (SELECT COALESCE(SUM(ps.duration), 0) FROM pomodoro_sessions ps WHERE ps.task_id = tasks.id AND ps.user_id = tasks.user_id AND ps.is_completed))::bigint`
+
+// Time Entry Repository
+type TimeEntryRepository interface {
+	StartTimer(userID, taskID int64, note *string) (*models.TimeEntry, error)
+	StopTimer(userID, taskID int64) (*models.TimeEntry, error)
+	GetRunningTimer(userID int64) (*models.TimeEntry, error)
+	CreateTimeEntry(entry *models.TimeEntry) (*models.TimeEntry, error)
+	GetTimeEntryByID(entryID, userID int64) (*models.TimeEntry, error)
+	UpdateTimeEntry(entry *models.TimeEntry) (*models.TimeEntry, error)
+	DeleteTimeEntry(entryID, userID int64) error
+	GetTaskTimeEntries(taskID, userID int64) ([]*models.TimeEntry, error)
+	GetTaskPomodoroMinutes(taskID, userID int64) (int64, error)
+	GetTimeAllocation(userID int64, since *time.Time) ([]*models.TaskTimeAllocation, error)
+}
+
+type timeEntryRepository struct {
+	db *sql.DB
+}
+
+func NewTimeEntryRepository(db *sql.DB) TimeEntryRepository {
+	return &timeEntryRepository{db: db}
+}
+
+const timeEntryColumns = `id, user_id, task_id, started_at, ended_at,
+		ROUND(EXTRACT(EPOCH FROM COALESCE(ended_at, now()) - started_at) / 60)::bigint AS minutes,
+		note, source, created_at`
+
+func scanTimeEntry(row rowScanner) (*models.TimeEntry, error) {
+	entry := &models.TimeEntry{}
+	err := row.Scan(&entry.ID, &entry.UserID, &entry.TaskID, &entry.StartedAt, &entry.EndedAt,
+		&entry.Minutes, &entry.Note, &entry.Source, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// StartTimer starts a running time entry on a task. It returns sql.ErrNoRows
+// when the user already has a running timer.
+func (r *timeEntryRepository) StartTimer(userID, taskID int64, note *string) (*models.TimeEntry, error) {
+	return scanTimeEntry(r.db.QueryRow(`
+		INSERT INTO time_entries (user_id, task_id, started_at, note, source)
+		VALUES ($1, $2, now(), $3, 'timer')
+		ON CONFLICT (user_id) WHERE ended_at IS NULL DO NOTHING
+		RETURNING `+timeEntryColumns, userID, taskID, note))
+}
+
+// StopTimer ends the user's running timer on a task
+func (r *timeEntryRepository) StopTimer(userID, taskID int64) (*models.TimeEntry, error) {
+	return scanTimeEntry(r.db.QueryRow(`
+		UPDATE time_entries SET ended_at = GREATEST(now(), started_at)
+		WHERE user_id = $1 AND task_id = $2 AND ended_at IS NULL
+		RETURNING `+timeEntryColumns, userID, taskID))
+}
+
+func (r *timeEntryRepository) GetRunningTimer(userID int64) (*models.TimeEntry, error) {
+	return scanTimeEntry(r.db.QueryRow(`
+		SELECT `+timeEntryColumns+`
+		FROM time_entries WHERE user_id = $1 AND ended_at IS NULL`, userID))
+}
+
+func (r *timeEntryRepository) CreateTimeEntry(entry *models.TimeEntry) (*models.TimeEntry, error) {
+	return scanTimeEntry(r.db.QueryRow(`
+		INSERT INTO time_entries (user_id, task_id, started_at, ended_at, note, source)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+timeEntryColumns,
+		entry.UserID, entry.TaskID, entry.StartedAt, entry.EndedAt, entry.Note, entry.Source))
+}
+
+func (r *timeEntryRepository) GetTimeEntryByID(entryID, userID int64) (*models.TimeEntry, error) {
+	return scanTimeEntry(r.db.QueryRow(`
+		SELECT `+timeEntryColumns+`
+		FROM time_entries WHERE id = $1 AND user_id = $2`, entryID, userID))
+}
+
+func (r *timeEntryRepository) UpdateTimeEntry(entry *models.TimeEntry) (*models.TimeEntry, error) {
+	return scanTimeEntry(r.db.QueryRow(`
+		UPDATE time_entries SET started_at = $1, ended_at = $2, note = $3
+		WHERE id = $4 AND user_id = $5
+		RETURNING `+timeEntryColumns,
+		entry.StartedAt, entry.EndedAt, entry.Note, entry.ID, entry.UserID))
+}
+
+func (r *timeEntryRepository) DeleteTimeEntry(entryID, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM time_entries WHERE id = $1 AND user_id = $2`, entryID, userID)
+	return requireRow(result, err)
+}
+
+// GetTaskTimeEntries returns a task's time entries, most recent first
+func (r *timeEntryRepository) GetTaskTimeEntries(taskID, userID int64) ([]*models.TimeEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT `+timeEntryColumns+`
+		FROM time_entries
+		WHERE task_id = $1 AND user_id = $2
+		ORDER BY started_at DESC, id DESC`, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.TimeEntry{}
+	for rows.Next() {
+		entry, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *timeEntryRepository) GetTaskPomodoroMinutes(taskID, userID int64) (int64, error) {
+	var minutes int64
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(duration), 0)
+		FROM pomodoro_sessions
+		WHERE task_id = $1 AND user_id = $2 AND is_completed`, taskID, userID).Scan(&minutes)
+	return minutes, err
+}
+
+// GetTimeAllocation sums the time entries and completed pomodoros started
+// since a given time (nil for all time) per task, largest first. Trashed
+// tasks, and tasks that are not the user's, are left out; pomodoros without a
+// task form one row with no task ID.
+func (r *timeEntryRepository) GetTimeAllocation(userID int64, since *time.Time) ([]*models.TaskTimeAllocation, error) {
+	rows, err := r.db.Query(`
+		WITH spent AS (
+			SELECT te.task_id, EXTRACT(EPOCH FROM COALESCE(te.ended_at, now()) - te.started_at) / 60 AS tracked, 0 AS pomodoro
+			FROM time_entries te
+			WHERE te.user_id = $1 AND ($2::timestamptz IS NULL OR te.started_at >= $2)
+			UNION ALL
+			SELECT ps.task_id, 0, ps.duration
+			FROM pomodoro_sessions ps
+			WHERE ps.user_id = $1 AND ps.is_completed AND ($2::timestamptz IS NULL OR ps.started_at >= $2)
+		)
+		SELECT t.id, COALESCE(t.task_name, ''),
+			CASE WHEN t.id IS NULL THEN 'Unassigned' ELSE COALESCE(t.category, 'Uncategorized') END,
+			t.estimate_minutes, ROUND(SUM(s.tracked))::bigint, SUM(s.pomodoro)::bigint
+		FROM spent s
+		LEFT JOIN tasks t ON t.id = s.task_id AND t.user_id = $1
+		WHERE s.task_id IS NULL OR (t.id IS NOT NULL AND t.deleted_at IS NULL)
+		GROUP BY t.id, t.task_name, t.category, t.estimate_minutes
+		ORDER BY ROUND(SUM(s.tracked)) + SUM(s.pomodoro) DESC, t.id ASC NULLS LAST`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*models.TaskTimeAllocation{}
+	for rows.Next() {
+		task := &models.TaskTimeAllocation{}
+		err := rows.Scan(&task.TaskID, &task.TaskName, &task.Category, &task.EstimateMinutes,
+			&task.TrackedMinutes, &task.PomodoroMinutes)
+		if err != nil {
+			return nil, err
+		}
+		task.TotalMinutes = task.TrackedMinutes + task.PomodoroMinutes
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
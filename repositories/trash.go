@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"todo-backend/models"
+)
+
+// Trash Repository
+type TrashRepository interface {
+	GetTrash(userID int64, itemType string) ([]*models.TrashItem, error)
+	RestoreItem(itemType string, itemID, userID int64) error
+	PurgeItem(itemType string, itemID, userID int64) error
+	EmptyTrash(userID int64) (int64, error)
+	PurgeDeletedBefore(cutoff time.Time) (int64, error)
+}
+
+type trashRepository struct {
+	db *sql.DB
+}
+
+func NewTrashRepository(db *sql.DB) TrashRepository {
+	return &trashRepository{db: db}
+}
+
+// trashTables maps item types onto their table
+var trashTables = map[string]string{
+	models.TrashTask:  "tasks",
+	models.TrashHabit: "habits",
+	models.TrashGoal:  "goals",
+}
+
+// GetTrash lists the user's trashed items, most recently deleted first.
+// itemType narrows the list to one type; empty lists all of them.
+func (r *trashRepository) GetTrash(userID int64, itemType string) ([]*models.TrashItem, error) {
+	rows, err := r.db.Query(`
+		SELECT type, id, name, deleted_at, subtask_count FROM (
+			SELECT 'task' AS type, t.id, t.task_name AS name, t.deleted_at,
+				(SELECT COUNT(*) FROM tasks c WHERE c.parent_id = t.id AND c.deleted_at = t.deleted_at) AS subtask_count
+			FROM tasks t
+			WHERE t.user_id = $1 AND t.deleted_at IS NOT NULL
+				AND NOT EXISTS (SELECT 1 FROM tasks p WHERE p.id = t.parent_id AND p.deleted_at = t.deleted_at)
+			UNION ALL
+			SELECT 'habit', id, name, deleted_at, 0 FROM habits WHERE user_id = $1 AND deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'goal', id, title, deleted_at, 0 FROM goals WHERE user_id = $1 AND deleted_at IS NOT NULL
+		) trash
+		WHERE $2 = '' OR type = $2
+		ORDER BY deleted_at DESC, type, id`, userID, itemType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.TrashItem{}
+	for rows.Next() {
+		item := &models.TrashItem{}
+		if err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.DeletedAt, &item.SubtaskCount); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// RestoreItem takes an item out of the trash. A task comes back with the
+// subtasks that were trashed with it; if its parent is still in the trash it
+// becomes a top-level task. The restore is recorded in the task's revision
+// history.
+func (r *trashRepository) RestoreItem(itemType string, itemID, userID int64) error {
+	table, ok := trashTables[itemType]
+	if !ok {
+		return fmt.Errorf("unknown trash item type %q", itemType)
+	}
+
+	if itemType != models.TrashTask {
+		result, err := r.db.Exec(`UPDATE `+table+` SET deleted_at = NULL
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`, itemID, userID)
+		return requireRow(result, err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE tasks SET parent_id = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+			AND parent_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL)`, itemID, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		WITH RECURSIVE subtree AS (
+			SELECT id, deleted_at FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+			UNION ALL
+			SELECT t.id, t.deleted_at FROM tasks t JOIN subtree ON t.parent_id = subtree.id
+			WHERE t.deleted_at = subtree.deleted_at
+		)
+		UPDATE tasks SET deleted_at = NULL WHERE id IN (SELECT id FROM subtree)`, itemID, userID)
+	if err := requireRow(result, err); err != nil {
+		return err
+	}
+
+	task, err := scanTask(tx.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1`, itemID))
+	if err != nil {
+		return err
+	}
+	if err := addTaskRevisionTx(tx, models.RevisionRestore, nil, task); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeItem permanently deletes a trashed item and its revision history.
+// Subtasks trashed with a task go with it through the ON DELETE CASCADE.
+func (r *trashRepository) PurgeItem(itemType string, itemID, userID int64) error {
+	table, ok := trashTables[itemType]
+	if !ok {
+		return fmt.Errorf("unknown trash item type %q", itemType)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM `+table+` WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`, itemID, userID)
+	if err := requireRow(result, err); err != nil {
+		return err
+	}
+	if err := deleteOrphanRevisions(tx, itemType, table); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// EmptyTrash permanently deletes every trashed item of a user
+func (r *trashRepository) EmptyTrash(userID int64) (int64, error) {
+	return r.purge(`user_id = $1`, userID)
+}
+
+// PurgeDeletedBefore permanently deletes, for every user, the items trashed before cutoff
+func (r *trashRepository) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	return r.purge(`deleted_at < $1`, cutoff)
+}
+
+func (r *trashRepository) purge(condition string, arg interface{}) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, itemType := range []string{models.TrashTask, models.TrashHabit, models.TrashGoal} {
+		table := trashTables[itemType]
+		result, err := tx.Exec(`DELETE FROM `+table+` WHERE deleted_at IS NOT NULL AND `+condition, arg)
+		if err != nil {
+			return 0, err
+		}
+		purged, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += purged
+
+		if purged > 0 {
+			if err := deleteOrphanRevisions(tx, itemType, table); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// requireRow turns an UPDATE or DELETE that matched nothing into sql.ErrNoRows
+func requireRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -89,15 +89,440 @@ CREATE TABLE IF NOT EXISTS goals (
     CONSTRAINT goals_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+-- Recurring task series: generated occurrences point back at the first task of the series
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence_parent_id BIGINT REFERENCES tasks(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence_index INTEGER NOT NULL DEFAULT 1;
+
+-- Persisted task status; is_completed is kept in sync as (status = 'completed')
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'in_progress', 'completed', 'cancelled'));
+UPDATE tasks SET status = 'completed' WHERE is_completed = true AND status = 'pending';
+
+-- Task status history: one row per status transition
+CREATE TABLE IF NOT EXISTS task_status_history (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    task_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT task_status_history_pkey PRIMARY KEY (id),
+    CONSTRAINT task_status_history_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_status_history_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Subtasks: a task may belong to a parent task, to any depth
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES tasks(id) ON DELETE CASCADE;
+
+-- Task dependencies: task_id cannot be finished before blocked_by_task_id
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id BIGINT NOT NULL,
+    blocked_by_task_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT task_dependencies_pkey PRIMARY KEY (task_id, blocked_by_task_id),
+    CONSTRAINT task_dependencies_no_self CHECK (task_id <> blocked_by_task_id),
+    CONSTRAINT task_dependencies_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_dependencies_blocked_by_task_id_fkey FOREIGN KEY (blocked_by_task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_dependencies_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Tags: many-to-many labels on tasks, unique per user regardless of case
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT tags_pkey PRIMARY KEY (id),
+    CONSTRAINT tags_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS task_tags (
+    task_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    CONSTRAINT task_tags_pkey PRIMARY KEY (task_id, tag_id),
+    CONSTRAINT task_tags_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_tags_tag_id_fkey FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Full-text search over task name (weight A) and description (weight B).
+-- The 'simple' configuration does no stemming, so it works for any language.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(task_name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+
+-- Reschedule history: one row per due date change made by rescheduling.
+-- postpone_count counts the reschedules that moved the due date later.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS postpone_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS task_reschedules (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    task_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    old_due_date TIMESTAMP WITH TIME ZONE,
+    new_due_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    reason TEXT,
+    rescheduled_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT task_reschedules_pkey PRIMARY KEY (id),
+    CONSTRAINT task_reschedules_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_reschedules_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Trash: deleting a task, habit or goal sets deleted_at. Trashed rows are
+-- hidden everywhere, can be restored, and are purged after the retention period.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE habits ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Revision history: a JSON snapshot of a task, habit or goal after each
+-- create, update, delete and restore, numbered per entity from 1.
+CREATE TABLE IF NOT EXISTS revisions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    entity_type VARCHAR(10) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    revision INTEGER NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    snapshot JSONB NOT NULL,
+    restored_from INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT revisions_pkey PRIMARY KEY (id),
+    CONSTRAINT revisions_entity_revision_key UNIQUE (entity_type, entity_id, revision),
+    CONSTRAINT revisions_entity_type_check CHECK (entity_type IN ('task', 'habit', 'goal')),
+    CONSTRAINT revisions_action_check CHECK (action IN ('baseline', 'create', 'update', 'delete', 'restore')),
+    CONSTRAINT revisions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Time tracking: an optional estimate per task and the time actually spent
+-- on it. A time entry without ended_at is a running timer; a user has at most one.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS estimate_minutes INTEGER
+    CONSTRAINT tasks_estimate_minutes_check CHECK (estimate_minutes > 0);
+
+CREATE TABLE IF NOT EXISTS time_entries (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    task_id BIGINT NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    note TEXT,
+    source VARCHAR(10) NOT NULL DEFAULT 'timer',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT time_entries_pkey PRIMARY KEY (id),
+    CONSTRAINT time_entries_range_check CHECK (ended_at IS NULL OR ended_at >= started_at),
+    CONSTRAINT time_entries_source_check CHECK (source IN ('timer', 'manual')),
+    CONSTRAINT time_entries_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT time_entries_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Task templates: a named set of tasks, stored as a JSON tree, that can be
+-- instantiated again and again with {{variable}} values and an anchor date
+CREATE TABLE IF NOT EXISTS task_templates (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    tasks JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT task_templates_pkey PRIMARY KEY (id),
+    CONSTRAINT task_templates_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Projects: named lists that own tasks. Inside a project tasks are ordered
+-- by hand with position keys that compare bytewise (see package ordering).
+CREATE TABLE IF NOT EXISTS projects (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    color VARCHAR(7),
+    description TEXT,
+    is_archived BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT projects_pkey PRIMARY KEY (id),
+    CONSTRAINT projects_color_check CHECK (color ~ '^#[0-9a-fA-F]{6}$'),
+    CONSTRAINT projects_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS project_id BIGINT REFERENCES projects(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS position TEXT COLLATE "C";
+
+-- Kanban boards: columns map task statuses, optionally with a work-in-progress
+-- limit. A board without a project shows all of the user's tasks. Cards
+-- placed by hand keep a position key until their status changes.
+CREATE TABLE IF NOT EXISTS boards (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    project_id BIGINT,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT boards_pkey PRIMARY KEY (id),
+    CONSTRAINT boards_project_id_fkey FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    CONSTRAINT boards_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS board_columns (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    board_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    status TEXT NOT NULL,
+    wip_limit INTEGER,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT board_columns_pkey PRIMARY KEY (id),
+    CONSTRAINT board_columns_board_status_key UNIQUE (board_id, status),
+    CONSTRAINT board_columns_status_check CHECK (status IN ('pending', 'in_progress', 'completed', 'cancelled')),
+    CONSTRAINT board_columns_wip_limit_check CHECK (wip_limit > 0),
+    CONSTRAINT board_columns_board_id_fkey FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS board_cards (
+    board_id BIGINT NOT NULL,
+    task_id BIGINT NOT NULL,
+    position TEXT COLLATE "C" NOT NULL,
+    CONSTRAINT board_cards_pkey PRIMARY KEY (board_id, task_id),
+    CONSTRAINT board_cards_board_id_fkey FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE,
+    CONSTRAINT board_cards_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+-- Files attached to tasks. The content lives in blob storage under
+-- storage_key. task_id becomes NULL when the task is purged, and user_id
+-- when the user is deleted (their tasks go with them); such rows are swept
+-- by deleting their blob and then the row.
+CREATE TABLE IF NOT EXISTS task_attachments (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT,
+    task_id BIGINT,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT task_attachments_pkey PRIMARY KEY (id),
+    CONSTRAINT task_attachments_storage_key_key UNIQUE (storage_key),
+    CONSTRAINT task_attachments_size_check CHECK (size_bytes >= 0),
+    CONSTRAINT task_attachments_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE SET NULL,
+    CONSTRAINT task_attachments_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Comment threads on tasks; bodies are Markdown and rendered by clients
+CREATE TABLE IF NOT EXISTS task_comments (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    task_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    edited_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT task_comments_pkey PRIMARY KEY (id),
+    CONSTRAINT task_comments_body_check CHECK (length(body) BETWEEN 1 AND 10000),
+    CONSTRAINT task_comments_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_comments_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Reminders on tasks (at remind_at, or offset_minutes from the due date) and
+-- on habits (every day at cue_time in timezone). next_fire_at is stored for
+-- one-off, habit and snoozed reminders; a due-date reminder otherwise goes
+-- off at due_date + offset_minutes unless it already fired for that due
+-- date, so rescheduling the task re-arms it.
+CREATE TABLE IF NOT EXISTS reminders (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    task_id BIGINT,
+    habit_id BIGINT,
+    remind_at TIMESTAMP WITH TIME ZONE,
+    offset_minutes INTEGER,
+    cue_time TIME,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    channel TEXT NOT NULL DEFAULT 'in_app',
+    status TEXT NOT NULL DEFAULT 'active',
+    next_fire_at TIMESTAMP WITH TIME ZONE,
+    fired_due_date TIMESTAMP WITH TIME ZONE,
+    last_fired_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT reminders_pkey PRIMARY KEY (id),
+    CONSTRAINT reminders_target_check CHECK (
+        (task_id IS NOT NULL AND habit_id IS NULL AND cue_time IS NULL AND (remind_at IS NULL) <> (offset_minutes IS NULL))
+        OR (habit_id IS NOT NULL AND task_id IS NULL AND cue_time IS NOT NULL AND remind_at IS NULL AND offset_minutes IS NULL)),
+    CONSTRAINT reminders_channel_check CHECK (channel IN ('in_app', 'email', 'webhook')),
+    CONSTRAINT reminders_status_check CHECK (status IN ('active', 'fired', 'dismissed')),
+    CONSTRAINT reminders_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT reminders_habit_id_fkey FOREIGN KEY (habit_id) REFERENCES habits(id) ON DELETE CASCADE,
+    CONSTRAINT reminders_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One row per firing of a reminder; the unique key keeps a reminder from
+-- firing twice for the same time, whichever server instance picks it up.
+-- Pending rows are leased by pushing next_attempt_at ahead while they are
+-- being sent, so a crashed instance's deliveries are retried after the lease.
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    reminder_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    channel TEXT NOT NULL,
+    fire_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_error TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT reminder_deliveries_pkey PRIMARY KEY (id),
+    CONSTRAINT reminder_deliveries_reminder_fire_key UNIQUE (reminder_id, fire_at),
+    CONSTRAINT reminder_deliveries_status_check CHECK (status IN ('pending', 'sent', 'failed')),
+    CONSTRAINT reminder_deliveries_reminder_id_fkey FOREIGN KEY (reminder_id) REFERENCES reminders(id) ON DELETE CASCADE,
+    CONSTRAINT reminder_deliveries_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- In-app notification inbox
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    delivery_id BIGINT,
+    reminder_id BIGINT,
+    task_id BIGINT,
+    habit_id BIGINT,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    read_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT notifications_pkey PRIMARY KEY (id),
+    CONSTRAINT notifications_delivery_id_key UNIQUE (delivery_id),
+    CONSTRAINT notifications_delivery_id_fkey FOREIGN KEY (delivery_id) REFERENCES reminder_deliveries(id) ON DELETE SET NULL,
+    CONSTRAINT notifications_reminder_id_fkey FOREIGN KEY (reminder_id) REFERENCES reminders(id) ON DELETE SET NULL,
+    CONSTRAINT notifications_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Eisenhower matrix: is_important marks a task important or not regardless
+-- of its priority; NULL leaves it to the priority threshold
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS is_important BOOLEAN;
+
+-- Planner: working hours per weekday (0 is Sunday) in wall-clock time, with
+-- several stretches a day allowed. Users without rows work Monday to Friday,
+-- 09:00 to 17:00.
+CREATE TABLE IF NOT EXISTS working_hours (
+    user_id BIGINT NOT NULL,
+    weekday SMALLINT NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    CONSTRAINT working_hours_pkey PRIMARY KEY (user_id, weekday, start_time),
+    CONSTRAINT working_hours_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT working_hours_range_check CHECK (end_time > start_time),
+    CONSTRAINT working_hours_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Calendar time blocks: busy time added by hand ('manual') or tasks placed by
+-- an accepted plan ('planner'). A block without a title shows its task's name.
+CREATE TABLE IF NOT EXISTS time_blocks (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    task_id BIGINT,
+    title TEXT,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT time_blocks_pkey PRIMARY KEY (id),
+    CONSTRAINT time_blocks_range_check CHECK (ends_at > starts_at),
+    CONSTRAINT time_blocks_source_check CHECK (source IN ('manual', 'planner')),
+    CONSTRAINT time_blocks_title_check CHECK (task_id IS NOT NULL OR title IS NOT NULL),
+    CONSTRAINT time_blocks_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT time_blocks_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Calendar feeds: read-only iCalendar subscriptions. Only the SHA-256 of a
+-- feed's token is kept; the token itself is shown once, when the feed is made.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    task_format TEXT NOT NULL DEFAULT 'event',
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    include_completed BOOLEAN NOT NULL DEFAULT TRUE,
+    include_goals BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_accessed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT calendar_feeds_pkey PRIMARY KEY (id),
+    CONSTRAINT calendar_feeds_token_hash_key UNIQUE (token_hash),
+    CONSTRAINT calendar_feeds_task_format_check CHECK (task_format IN ('event', 'todo')),
+    CONSTRAINT calendar_feeds_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- iCalendar UIDs of imported tasks, so that importing the same file again
+-- updates those tasks instead of adding them twice
+CREATE TABLE IF NOT EXISTS task_ical_uids (
+    user_id BIGINT NOT NULL,
+    uid TEXT NOT NULL,
+    task_id BIGINT NOT NULL,
+    CONSTRAINT task_ical_uids_pkey PRIMARY KEY (user_id, uid),
+    CONSTRAINT task_ical_uids_task_id_fkey FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT task_ical_uids_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- CalDAV resource names of tasks a client created under a name of its own
+ALTER TABLE task_ical_uids ADD COLUMN IF NOT EXISTS href TEXT;
+
+-- App passwords sign CalDAV clients in instead of a JWT. As with calendar
+-- feeds only the SHA-256 of the password is kept.
+CREATE TABLE IF NOT EXISTS app_passwords (
+    id BIGINT GENERATED ALWAYS AS IDENTITY NOT NULL,
+    user_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT app_passwords_pkey PRIMARY KEY (id),
+    CONSTRAINT app_passwords_token_hash_key UNIQUE (token_hash),
+    CONSTRAINT app_passwords_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id);
 CREATE INDEX IF NOT EXISTS idx_tasks_category ON tasks(category);
 CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date);
 CREATE INDEX IF NOT EXISTS idx_tasks_is_completed ON tasks(is_completed);
 CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at);
+CREATE INDEX IF NOT EXISTS idx_tasks_recurrence_parent_id ON tasks(recurrence_parent_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_user_status ON tasks(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_task_status_history_task_id ON task_status_history(task_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks(parent_id);
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_blocked_by ON task_dependencies(blocked_by_task_id);
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_user_id ON task_dependencies(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_lower_name ON tags(user_id, lower(name));
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id ON task_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector);
+-- Keyset pagination over the default sort orders
+CREATE INDEX IF NOT EXISTS idx_tasks_user_created_id ON tasks(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_task_reschedules_task_id ON task_reschedules(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_reschedules_user_id ON task_reschedules(user_id, rescheduled_at);
+CREATE INDEX IF NOT EXISTS idx_tasks_deleted_at ON tasks(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tasks_project_position ON tasks(project_id, position, id) WHERE project_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_user_name ON projects(user_id, lower(name));
+CREATE INDEX IF NOT EXISTS idx_boards_user_id ON boards(user_id);
+CREATE INDEX IF NOT EXISTS idx_board_cards_task_id ON board_cards(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_attachments_task_id ON task_attachments(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_attachments_user_id ON task_attachments(user_id);
+CREATE INDEX IF NOT EXISTS idx_task_attachments_orphans ON task_attachments(id) WHERE task_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_task_comments_task_id ON task_comments(task_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_reminders_user_id ON reminders(user_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_task_id ON reminders(task_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_habit_id ON reminders(habit_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_active ON reminders(next_fire_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_reminder_deliveries_pending ON reminder_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_time_blocks_user_id ON time_blocks(user_id, starts_at);
+CREATE INDEX IF NOT EXISTS idx_time_blocks_task_id ON time_blocks(task_id);
+CREATE INDEX IF NOT EXISTS idx_calendar_feeds_user_id ON calendar_feeds(user_id);
+CREATE INDEX IF NOT EXISTS idx_task_ical_uids_task_id ON task_ical_uids(task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_ical_uids_href ON task_ical_uids(user_id, href) WHERE href IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_app_passwords_user_id ON app_passwords(user_id);
 
 CREATE INDEX IF NOT EXISTS idx_habits_user_id ON habits(user_id);
 CREATE INDEX IF NOT EXISTS idx_habits_type ON habits(type);
+CREATE INDEX IF NOT EXISTS idx_habits_user_created_id ON habits(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_habits_deleted_at ON habits(deleted_at) WHERE deleted_at IS NOT NULL;
 
 CREATE INDEX IF NOT EXISTS idx_logs_user_id ON logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_logs_event_type ON logs(event_type);
@@ -105,10 +530,20 @@ CREATE INDEX IF NOT EXISTS idx_logs_created_at ON logs(created_at);
 
 CREATE INDEX IF NOT EXISTS idx_goals_user_id ON goals(user_id);
 CREATE INDEX IF NOT EXISTS idx_goals_category ON goals(category);
+CREATE INDEX IF NOT EXISTS idx_goals_deleted_at ON goals(deleted_at) WHERE deleted_at IS NOT NULL;
 
 CREATE INDEX IF NOT EXISTS idx_pomodoro_user_id ON pomodoro_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_pomodoro_task_id ON pomodoro_sessions(task_id);
 
+CREATE INDEX IF NOT EXISTS idx_revisions_user_id ON revisions(user_id);
+
+CREATE INDEX IF NOT EXISTS idx_time_entries_task_id ON time_entries(task_id);
+CREATE INDEX IF NOT EXISTS idx_time_entries_user_started ON time_entries(user_id, started_at);
+-- At most one running timer per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries(user_id) WHERE ended_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_templates_user_name ON task_templates(user_id, lower(name));
+
 -- Insert sample data (optional)
 -- Insert a default admin user (password: admin)
 INSERT INTO users (username, password_hash, display_name, email) 
@@ -157,5 +592,5 @@ SELECT
     COUNT(CASE WHEN t.is_completed = false THEN 1 END) as pending_tasks,
     COUNT(CASE WHEN t.due_date < CURRENT_DATE AND t.is_completed = false THEN 1 END) as overdue_tasks
 FROM users u
-LEFT JOIN tasks t ON u.id = t.user_id
+LEFT JOIN tasks t ON u.id = t.user_id AND t.deleted_at IS NULL
 GROUP BY u.id, u.username;
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrAppPasswordNotFound is returned when an app password does not exist or
+// belongs to another user
+var ErrAppPasswordNotFound = errors.New("app password not found")
+
+// ErrInvalidAppPassword is returned when an app password does not sign the
+// user in
+var ErrInvalidAppPassword = errors.New("invalid app password")
+
+// App Password Service
+type AppPasswordService interface {
+	CreateAppPassword(userID int64, req *models.CreateAppPasswordRequest) (*models.AppPassword, string, error)
+	GetAppPasswords(userID int64) ([]*models.AppPassword, error)
+	RevokeAppPassword(passwordID, userID int64) error
+	Authenticate(username, password string) (int64, string, error)
+}
+
+type appPasswordService struct {
+	passwordRepo repositories.AppPasswordRepository
+	logRepo      repositories.LogRepository
+}
+
+func NewAppPasswordService(passwordRepo repositories.AppPasswordRepository, logRepo repositories.LogRepository) AppPasswordService {
+	return &appPasswordService{
+		passwordRepo: passwordRepo,
+		logRepo:      logRepo,
+	}
+}
+
+// CreateAppPassword makes a password for a client such as a CalDAV app and
+// returns it with the password. As with feed tokens only its hash is kept.
+func (s *appPasswordService) CreateAppPassword(userID int64, req *models.CreateAppPasswordRequest) (*models.AppPassword, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAppPassword)
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("failed to generate app password: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	password, err := s.passwordRepo.CreateAppPassword(&models.AppPassword{UserID: userID, Name: name}, feedTokenHash(token))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create app password: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"app_password_id": password.ID,
+		"name":            password.Name,
+	}
+	s.logRepo.CreateLog(&userID, "app_password_created", fmt.Sprintf("Created app password '%s'", password.Name), metadata)
+
+	return password, token, nil
+}
+
+func (s *appPasswordService) GetAppPasswords(userID int64) ([]*models.AppPassword, error) {
+	passwords, err := s.passwordRepo.GetAppPasswords(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app passwords: %w", err)
+	}
+	return passwords, nil
+}
+
+func (s *appPasswordService) RevokeAppPassword(passwordID, userID int64) error {
+	if err := s.passwordRepo.DeleteAppPassword(passwordID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAppPasswordNotFound
+		}
+		return fmt.Errorf("failed to revoke app password: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"app_password_id": passwordID,
+	}
+	s.logRepo.CreateLog(&userID, "app_password_revoked", "Revoked an app password", metadata)
+
+	return nil
+}
+
+// Authenticate returns the ID and name of the user an app password belongs
+// to. username is that of HTTP Basic authentication and must match; it is
+// empty for a bearer token.
+func (s *appPasswordService) Authenticate(username, password string) (int64, string, error) {
+	if password == "" {
+		return 0, "", ErrInvalidAppPassword
+	}
+	user, err := s.passwordRepo.GetUserByAppPassword(feedTokenHash(password))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", ErrInvalidAppPassword
+		}
+		return 0, "", fmt.Errorf("failed to check app password: %w", err)
+	}
+	if username != "" && username != user.Username {
+		return 0, "", ErrInvalidAppPassword
+	}
+	return user.ID, user.Username, nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/repositories"
+	"todo-backend/storage"
+	"unicode"
+)
+
+// ErrAttachmentNotFound is returned when an attachment does not exist, belongs
+// to another task or user, or its task is in the trash
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// ErrAttachmentTooLarge is returned for uploads over the size limit
+var ErrAttachmentTooLarge = errors.New("attachment too large")
+
+// ErrAttachmentQuotaExceeded is returned when an upload would take the user
+// over their storage quota
+var ErrAttachmentQuotaExceeded = errors.New("attachment quota exceeded")
+
+// ErrAttachmentTypeNotAllowed is returned when the sniffed content type is
+// not among the allowed types
+var ErrAttachmentTypeNotAllowed = errors.New("attachment type not allowed")
+
+// orphanSweepBatch is how many orphaned attachments a sweep deletes per query
+const orphanSweepBatch = 100
+
+// Attachment Service
+type AttachmentService interface {
+	UploadAttachment(taskID, userID int64, fileName string, size int64, content io.Reader) (*models.TaskAttachment, error)
+	GetTaskAttachments(taskID, userID int64) ([]*models.TaskAttachment, *models.AttachmentUsage, error)
+	OpenAttachment(attachmentID, taskID, userID int64) (*models.TaskAttachment, io.ReadCloser, error)
+	DeleteAttachment(attachmentID, taskID, userID int64) error
+	SweepOrphans() (int, error)
+}
+
+type attachmentService struct {
+	attachmentRepo repositories.AttachmentRepository
+	taskRepo       repositories.TaskRepository
+	logRepo        repositories.LogRepository
+	store          storage.Store
+	limits         models.AttachmentLimits
+}
+
+func NewAttachmentService(attachmentRepo repositories.AttachmentRepository, taskRepo repositories.TaskRepository, logRepo repositories.LogRepository, store storage.Store, limits models.AttachmentLimits) AttachmentService {
+	return &attachmentService{
+		attachmentRepo: attachmentRepo,
+		taskRepo:       taskRepo,
+		logRepo:        logRepo,
+		store:          store,
+		limits:         limits,
+	}
+}
+
+// cleanFileName keeps the base name of an uploaded file without control
+// characters, shortened to fit the column
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	return name
+}
+
+// typeAllowed matches a media type against the allowed types, where
+// "image/*" stands for every image type
+func (s *attachmentService) typeAllowed(mediaType string) bool {
+	if len(s.limits.AllowedTypes) == 0 {
+		return true
+	}
+	for _, allowed := range s.limits.AllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func newStorageKey(userID, taskID int64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("users/%d/tasks/%d/%s", userID, taskID, hex.EncodeToString(random)), nil
+}
+
+// countingReader counts the bytes read through it and fails once more than
+// limit bytes come through
+type countingReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n > c.limit {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, err
+}
+
+// UploadAttachment stores a file for a task. The content type is sniffed
+// from the first bytes of the content; the name and type the client sent are
+// not trusted. size is the length the client declared and must match.
+func (s *attachmentService) UploadAttachment(taskID, userID int64, fileName string, size int64, content io.Reader) (*models.TaskAttachment, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+	if size > s.limits.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrAttachmentTooLarge, size, s.limits.MaxBytes)
+	}
+	used, err := s.attachmentRepo.GetUsage(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment usage: %w", err)
+	}
+	if used+size > s.limits.QuotaBytes {
+		return nil, fmt.Errorf("%w: %d of %d bytes used", ErrAttachmentQuotaExceeded, used, s.limits.QuotaBytes)
+	}
+
+	buffered := bufio.NewReaderSize(content, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	contentType := http.DetectContentType(head)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "application/octet-stream"
+	}
+	if !s.typeAllowed(mediaType) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, mediaType)
+	}
+
+	key, err := newStorageKey(userID, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage key: %w", err)
+	}
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(buffered, hash), limit: s.limits.MaxBytes}
+	if err := s.store.Put(key, counter, size, contentType); err != nil {
+		s.deleteBlob(key)
+		if errors.Is(err, ErrAttachmentTooLarge) {
+			return nil, fmt.Errorf("%w: the limit is %d bytes", ErrAttachmentTooLarge, s.limits.MaxBytes)
+		}
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if counter.n != size {
+		s.deleteBlob(key)
+		return nil, fmt.Errorf("failed to store attachment: got %d bytes, expected %d", counter.n, size)
+	}
+
+	attachment, err := s.attachmentRepo.CreateAttachment(&models.TaskAttachment{
+		UserID:      userID,
+		TaskID:      taskID,
+		FileName:    cleanFileName(fileName),
+		ContentType: contentType,
+		SizeBytes:   size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+	}, s.limits.QuotaBytes)
+	if err != nil {
+		s.deleteBlob(key)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: the quota is %d bytes", ErrAttachmentQuotaExceeded, s.limits.QuotaBytes)
+		}
+		return nil, fmt.Errorf("failed to save attachment: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"attachment_id": attachment.ID,
+		"file_name":     attachment.FileName,
+		"content_type":  attachment.ContentType,
+		"size_bytes":    attachment.SizeBytes,
+	}
+	s.logRepo.CreateLog(&userID, "attachment_uploaded", fmt.Sprintf("Attached '%s' to task %d", attachment.FileName, taskID), metadata)
+
+	return attachment, nil
+}
+
+func (s *attachmentService) GetTaskAttachments(taskID, userID int64) ([]*models.TaskAttachment, *models.AttachmentUsage, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	attachments, err := s.attachmentRepo.GetTaskAttachments(taskID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	used, err := s.attachmentRepo.GetUsage(userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get attachment usage: %w", err)
+	}
+
+	usage := &models.AttachmentUsage{
+		UsedBytes:  used,
+		QuotaBytes: s.limits.QuotaBytes,
+		MaxBytes:   s.limits.MaxBytes,
+	}
+	return attachments, usage, nil
+}
+
+// OpenAttachment returns an attachment with its content; the caller closes it
+func (s *attachmentService) OpenAttachment(attachmentID, taskID, userID int64) (*models.TaskAttachment, io.ReadCloser, error) {
+	attachment, err := s.attachmentRepo.GetAttachment(attachmentID, taskID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("%w: %d", ErrAttachmentNotFound, attachmentID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	content, err := s.store.Get(attachment.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	return attachment, content, nil
+}
+
+// DeleteAttachment takes an attachment off its task and deletes its blob
+func (s *attachmentService) DeleteAttachment(attachmentID, taskID, userID int64) error {
+	attachment, err := s.attachmentRepo.GetAttachment(attachmentID, taskID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrAttachmentNotFound, attachmentID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	if err := s.attachmentRepo.DetachAttachment(attachmentID, taskID, userID); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	if _, err := s.SweepOrphans(); err != nil {
+		log.Printf("Attachment sweep failed: %v", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"attachment_id": attachmentID,
+		"file_name":     attachment.FileName,
+	}
+	s.logRepo.CreateLog(&userID, "attachment_deleted", fmt.Sprintf("Removed '%s' from task %d", attachment.FileName, taskID), metadata)
+
+	return nil
+}
+
+// SweepOrphans deletes the blobs and rows of attachments whose task is gone,
+// such as those of tasks purged from the trash or of deleted users, and
+// returns how many it deleted. A blob that cannot be deleted keeps its row for the next sweep.
+func (s *attachmentService) SweepOrphans() (int, error) {
+	deleted := 0
+	for {
+		orphans, err := s.attachmentRepo.GetOrphanAttachments(orphanSweepBatch)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get orphaned attachments: %w", err)
+		}
+
+		var failed error
+		for _, orphan := range orphans {
+			if err := s.store.Delete(orphan.StorageKey); err != nil {
+				failed = fmt.Errorf("failed to delete blob %s: %w", orphan.StorageKey, err)
+				continue
+			}
+			if err := s.attachmentRepo.DeleteAttachment(orphan.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return deleted, fmt.Errorf("failed to delete attachment %d: %w", orphan.ID, err)
+			}
+			deleted++
+		}
+		// Stop on a short batch, or when blobs fail so the same rows would come back
+		if len(orphans) < orphanSweepBatch || failed != nil {
+			return deleted, failed
+		}
+	}
+}
+
+// deleteBlob removes the blob of an upload that was not recorded
+func (s *attachmentService) deleteBlob(key string) {
+	if err := s.store.Delete(key); err != nil {
+		log.Printf("Failed to delete blob %s: %v", key, err)
+	}
+}
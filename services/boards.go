@@ -0,0 +1,336 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrBoardNotFound is returned when a board does not exist or belongs to another user
+var ErrBoardNotFound = errors.New("board not found")
+
+// ErrInvalidBoard is returned for invalid board names and columns
+var ErrInvalidBoard = errors.New("invalid board")
+
+// ErrWIPLimitExceeded is returned when moving a card would put more tasks in
+// a column than its work-in-progress limit allows
+var ErrWIPLimitExceeded = errors.New("work-in-progress limit reached")
+
+// Card limits for a single board, per column
+const (
+	DefaultBoardCardLimit = 100
+	MaxBoardCardLimit     = 500
+)
+
+// defaultBoardColumns are the columns of a board created without any
+var defaultBoardColumns = []*models.BoardColumnRequest{
+	{Name: "To do", Status: models.TaskStatusPending},
+	{Name: "In progress", Status: models.TaskStatusInProgress},
+	{Name: "Done", Status: models.TaskStatusCompleted},
+}
+
+// Board Service
+type BoardService interface {
+	CreateBoard(userID int64, req *models.CreateBoardRequest) (*models.Board, error)
+	GetBoards(userID int64) ([]*models.Board, error)
+	GetBoard(boardID, userID int64, cardLimit int) (*models.Board, error)
+	UpdateBoard(boardID, userID int64, req *models.UpdateBoardRequest) (*models.Board, error)
+	DeleteBoard(boardID, userID int64) error
+	MoveCard(boardID, taskID, userID int64, req *models.MoveCardRequest) (*models.CardMoveResult, error)
+}
+
+type boardService struct {
+	boardRepo   repositories.BoardRepository
+	taskRepo    repositories.TaskRepository
+	projectRepo repositories.ProjectRepository
+	taskService TaskService
+	logRepo     repositories.LogRepository
+}
+
+func NewBoardService(boardRepo repositories.BoardRepository, taskRepo repositories.TaskRepository, projectRepo repositories.ProjectRepository, taskService TaskService, logRepo repositories.LogRepository) BoardService {
+	return &boardService{
+		boardRepo:   boardRepo,
+		taskRepo:    taskRepo,
+		projectRepo: projectRepo,
+		taskService: taskService,
+		logRepo:     logRepo,
+	}
+}
+
+// boardColumns validates column requests: every column needs a name and a
+// status, and a status may have only one column
+func boardColumns(requests []*models.BoardColumnRequest) ([]*models.BoardColumn, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("%w: a board needs at least one column", ErrInvalidBoard)
+	}
+
+	seen := make(map[models.TaskStatus]bool, len(requests))
+	columns := make([]*models.BoardColumn, 0, len(requests))
+	for _, req := range requests {
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: column name is required", ErrInvalidBoard)
+		}
+		if !req.Status.IsValid() {
+			return nil, fmt.Errorf("%w: unknown status %q for column '%s'", ErrInvalidBoard, req.Status, name)
+		}
+		if seen[req.Status] {
+			return nil, fmt.Errorf("%w: more than one column for status %s", ErrInvalidBoard, req.Status)
+		}
+		if req.WIPLimit != nil && *req.WIPLimit < 1 {
+			return nil, fmt.Errorf("%w: WIP limit of column '%s' must be at least 1", ErrInvalidBoard, name)
+		}
+		seen[req.Status] = true
+		columns = append(columns, &models.BoardColumn{Name: name, Status: req.Status, WIPLimit: req.WIPLimit})
+	}
+	return columns, nil
+}
+
+func findColumn(board *models.Board, match func(*models.BoardColumn) bool) *models.BoardColumn {
+	for _, column := range board.Columns {
+		if match(column) {
+			return column
+		}
+	}
+	return nil
+}
+
+// onBoard reports whether a task falls within a board's project
+func onBoard(board *models.Board, task *models.Task) bool {
+	return board.ProjectID == nil || (task.ProjectID != nil && *task.ProjectID == *board.ProjectID)
+}
+
+func (s *boardService) CreateBoard(userID int64, req *models.CreateBoardRequest) (*models.Board, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidBoard)
+	}
+	requests := req.Columns
+	if requests == nil {
+		requests = defaultBoardColumns
+	}
+	columns, err := boardColumns(requests)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProjectID != nil {
+		if err := checkProjectForTasks(s.projectRepo, *req.ProjectID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	board, err := s.boardRepo.CreateBoard(&models.Board{
+		UserID:    userID,
+		ProjectID: req.ProjectID,
+		Name:      name,
+		Columns:   columns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create board: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"board_id":   board.ID,
+		"name":       board.Name,
+		"project_id": board.ProjectID,
+		"columns":    len(board.Columns),
+	}
+	s.logRepo.CreateLog(&userID, "board_created", fmt.Sprintf("Board '%s' created", board.Name), metadata)
+
+	return board, nil
+}
+
+func (s *boardService) GetBoards(userID int64) ([]*models.Board, error) {
+	boards, err := s.boardRepo.GetBoards(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get boards: %w", err)
+	}
+	return boards, nil
+}
+
+func (s *boardService) getBoard(boardID, userID int64) (*models.Board, error) {
+	board, err := s.boardRepo.GetBoardByID(boardID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrBoardNotFound, boardID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, nil
+}
+
+// GetBoard returns a board with up to cardLimit ordered cards per column
+func (s *boardService) GetBoard(boardID, userID int64, cardLimit int) (*models.Board, error) {
+	if cardLimit < 1 {
+		cardLimit = DefaultBoardCardLimit
+	}
+	if cardLimit > MaxBoardCardLimit {
+		cardLimit = MaxBoardCardLimit
+	}
+
+	board, err := s.getBoard(boardID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range board.Columns {
+		column.Cards, err = s.boardRepo.GetColumnCards(board, column, cardLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get board cards: %w", err)
+		}
+	}
+	return board, nil
+}
+
+func (s *boardService) UpdateBoard(boardID, userID int64, req *models.UpdateBoardRequest) (*models.Board, error) {
+	board, err := s.getBoard(boardID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidBoard)
+		}
+		board.Name = name
+	}
+	replaceColumns := req.Columns != nil
+	if replaceColumns {
+		if board.Columns, err = boardColumns(req.Columns); err != nil {
+			return nil, err
+		}
+	}
+
+	updatedBoard, err := s.boardRepo.UpdateBoard(board, replaceColumns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update board: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"board_id": boardID,
+		"name":     updatedBoard.Name,
+		"columns":  len(updatedBoard.Columns),
+	}
+	s.logRepo.CreateLog(&userID, "board_updated", fmt.Sprintf("Board '%s' updated", updatedBoard.Name), metadata)
+
+	return updatedBoard, nil
+}
+
+// DeleteBoard deletes a board; its tasks are not touched
+func (s *boardService) DeleteBoard(boardID, userID int64) error {
+	board, err := s.getBoard(boardID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.boardRepo.DeleteBoard(boardID, userID); err != nil {
+		return fmt.Errorf("failed to delete board: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"board_id": boardID,
+		"name":     board.Name,
+	}
+	s.logRepo.CreateLog(&userID, "board_deleted", fmt.Sprintf("Board '%s' deleted", board.Name), metadata)
+
+	return nil
+}
+
+// MoveCard moves a task's card into a column and places it among the
+// column's cards. Moving to another column changes the task's status through
+// the status state machine, after checking the column's WIP limit.
+func (s *boardService) MoveCard(boardID, taskID, userID int64, req *models.MoveCardRequest) (*models.CardMoveResult, error) {
+	board, err := s.getBoard(boardID, userID)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+	if !onBoard(board, task) {
+		return nil, fmt.Errorf("%w: task %d is not on board '%s'", ErrInvalidMove, taskID, board.Name)
+	}
+
+	var column *models.BoardColumn
+	if req.ColumnID != nil {
+		column = findColumn(board, func(c *models.BoardColumn) bool { return c.ID == *req.ColumnID })
+		if column == nil {
+			return nil, fmt.Errorf("%w: column %d is not on board '%s'", ErrInvalidMove, *req.ColumnID, board.Name)
+		}
+	} else {
+		column = findColumn(board, func(c *models.BoardColumn) bool { return c.Status == task.Status })
+		if column == nil {
+			return nil, fmt.Errorf("%w: board '%s' has no column for status %s; give column_id", ErrInvalidMove, board.Name, task.Status)
+		}
+	}
+
+	if req.AfterID != nil && req.BeforeID != nil {
+		return nil, fmt.Errorf("%w: give after_id or before_id, not both", ErrInvalidMove)
+	}
+	anchorID := req.AfterID
+	if anchorID == nil {
+		anchorID = req.BeforeID
+	}
+	if anchorID != nil {
+		if *anchorID == taskID {
+			return nil, fmt.Errorf("%w: a card cannot be placed next to itself", ErrInvalidMove)
+		}
+		anchor, err := s.taskRepo.GetTaskByID(*anchorID, userID)
+		if err != nil || !onBoard(board, anchor) || anchor.Status != column.Status {
+			return nil, fmt.Errorf("%w: task %d is not in column '%s'", ErrInvalidMove, *anchorID, column.Name)
+		}
+	}
+
+	result := &models.CardMoveResult{Task: task}
+	fromStatus := task.Status
+	if column.Status == fromStatus {
+		err = s.boardRepo.PlaceCard(board, column, taskID, req.AfterID, req.BeforeID)
+	} else {
+		// The WIP limit is checked, the status changed and the card placed in
+		// one transaction, so concurrent moves cannot overfill the column
+		var statusResult *models.TaskStatusResult
+		statusResult, err = s.taskService.UpdateTaskStatusWith(taskID, userID, column.Status,
+			func(task *models.Task, from, to models.TaskStatus) (*models.Task, error) {
+				return s.boardRepo.MoveCard(board, column, task.ID, from, req.AfterID, req.BeforeID)
+			})
+		if err == nil {
+			result.Task = statusResult.Task
+			result.Warnings = statusResult.Warnings
+			result.NextOccurrence = statusResult.NextOccurrence
+		}
+	}
+	switch {
+	case errors.Is(err, repositories.ErrColumnFull):
+		return nil, fmt.Errorf("%w: column '%s' already holds its limit of %d tasks", ErrWIPLimitExceeded, column.Name, *column.WIPLimit)
+	case errors.Is(err, repositories.ErrCardAnchorNotFound):
+		return nil, fmt.Errorf("%w: task %d is not in column '%s'", ErrInvalidMove, *anchorID, column.Name)
+	case err != nil && column.Status == fromStatus:
+		return nil, fmt.Errorf("failed to place card: %w", err)
+	case err != nil:
+		return nil, err
+	}
+
+	// Reload the board for the column's new task count
+	if updatedBoard, err := s.boardRepo.GetBoardByID(boardID, userID); err == nil {
+		if updated := findColumn(updatedBoard, func(c *models.BoardColumn) bool { return c.ID == column.ID }); updated != nil {
+			column = updated
+		}
+	}
+	result.Column = column
+
+	metadata := map[string]interface{}{
+		"board_id":    boardID,
+		"task_id":     taskID,
+		"task_name":   task.TaskName,
+		"column_id":   column.ID,
+		"from_status": fromStatus,
+		"to_status":   column.Status,
+	}
+	s.logRepo.CreateLog(&userID, "card_moved", fmt.Sprintf("Task '%s' moved to '%s' on board '%s'", task.TaskName, column.Name, board.Name), metadata)
+
+	return result, nil
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"todo-backend/filter"
+	"todo-backend/models"
+)
+
+// ErrInvalidBulkRequest is returned for bulk requests without targets, with
+// too many targets or without the value their operation needs
+var ErrInvalidBulkRequest = errors.New("invalid bulk request")
+
+// maxBulkTasks bounds the number of tasks one bulk request may touch
+const maxBulkTasks = 1000
+
+const (
+	bulkUpdated   = "updated"
+	bulkUnchanged = "unchanged"
+	bulkFailed    = "failed"
+)
+
+// BulkUpdateTasks applies one operation to a set of tasks, all or nothing.
+// Every task is validated first; if any fails nothing is written and the
+// result lists why. Otherwise all changes are applied in one transaction and
+// a single aggregated entry is logged.
+func (s *taskService) BulkUpdateTasks(userID int64, req *models.BulkTaskRequest) (*models.BulkTaskResult, error) {
+	days, duration, err := validateBulkRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	ids, tasksByID, err := s.bulkTargets(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	inBatch := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		inBatch[id] = true
+	}
+
+	result := &models.BulkTaskResult{Operation: req.Operation, Matched: len(ids), Results: []*models.BulkItemResult{}}
+	var changes []*models.BulkTaskChange
+	items := make(map[int64]*models.BulkItemResult)
+	add := func(item *models.BulkItemResult, change *models.BulkTaskChange) {
+		result.Results = append(result.Results, item)
+		items[item.TaskID] = item
+		switch {
+		case item.Status == bulkFailed:
+			result.Failed++
+		case change != nil:
+			changes = append(changes, change)
+		}
+	}
+
+	for _, id := range ids {
+		task, ok := tasksByID[id]
+		if !ok {
+			add(&models.BulkItemResult{TaskID: id, Status: bulkFailed, Message: "task not found"}, nil)
+			continue
+		}
+
+		item := &models.BulkItemResult{TaskID: id, Status: bulkUpdated}
+		change := &models.BulkTaskChange{TaskID: id}
+		switch req.Operation {
+		case models.BulkComplete:
+			if task.Status == models.TaskStatusCompleted {
+				item.Status, item.Message, change = bulkUnchanged, "task is already completed", nil
+				break
+			}
+			if !canTransition(task.Status, models.TaskStatusCompleted) {
+				item.Status, item.Message = bulkFailed, fmt.Sprintf("cannot complete a %s task", task.Status)
+				break
+			}
+			cascaded, warnings, err := s.bulkCompletionChecks(task, inBatch)
+			if err != nil {
+				if !errors.Is(err, ErrOpenSubtasks) && !errors.Is(err, ErrTaskBlocked) {
+					return nil, err
+				}
+				item.Status, item.Message = bulkFailed, err.Error()
+				break
+			}
+			result.Warnings = append(result.Warnings, warnings...)
+			change.FromStatus, change.ToStatus = task.Status, models.TaskStatusCompleted
+			for _, subtask := range cascaded {
+				// A subtask below two tasks of the batch is completed once
+				if inBatch[subtask.ID] {
+					continue
+				}
+				inBatch[subtask.ID] = true
+				add(&models.BulkItemResult{TaskID: subtask.ID, Status: bulkUpdated, Message: "completed with its parent task"},
+					&models.BulkTaskChange{TaskID: subtask.ID, FromStatus: subtask.Status, ToStatus: models.TaskStatusCompleted})
+			}
+
+		case models.BulkUncomplete:
+			if task.Status != models.TaskStatusCompleted {
+				item.Status, item.Message, change = bulkUnchanged, "task is not completed", nil
+				break
+			}
+			change.FromStatus, change.ToStatus = task.Status, models.TaskStatusPending
+
+		case models.BulkDelete:
+			// nothing to validate
+
+		case models.BulkSetCategory:
+			if sameCategory(task.Category, req.Category) {
+				item.Status, item.Message, change = bulkUnchanged, "category is already set", nil
+				break
+			}
+			change.Category = req.Category
+
+		case models.BulkSetPriority:
+			if task.Priority == *req.Priority {
+				item.Status, item.Message, change = bulkUnchanged, "priority is already set", nil
+				break
+			}
+			change.Priority = *req.Priority
+
+		case models.BulkReschedule:
+			if task.DueDate == nil {
+				item.Status, item.Message, change = bulkUnchanged, "task has no due date", nil
+				break
+			}
+			change.DueDate = &models.CustomTime{Time: task.DueDate.Time.AddDate(0, 0, days).Add(duration)}
+			change.Reason = req.Reason
+		}
+		add(item, change)
+	}
+
+	if result.Failed > 0 {
+		return result, nil
+	}
+
+	var updated []*models.Task
+	if len(changes) > 0 {
+		updated, err = s.taskRepo.ApplyBulkTaskChanges(userID, req.Operation, changes, s.subtaskPolicy.OnDelete == models.SubtaskPromote)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: a task was changed by another request", ErrInvalidStatusTransition)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply bulk %s: %w", req.Operation, err)
+		}
+	}
+	result.Applied = true
+	result.Updated = len(changes)
+
+	for _, task := range updated {
+		items[task.ID].Task = task
+	}
+
+	// Completing a recurring task generates its next occurrence, as a single
+	// completion does. This runs after the commit, so a failure here cannot
+	// undo the bulk change and is reported as a warning instead.
+	if req.Operation == models.BulkComplete {
+		for _, task := range updated {
+			next, err := s.createNextOccurrence(task)
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Task '%s': %v", task.TaskName, err))
+				continue
+			}
+			if next != nil {
+				result.NextOccurrences = append(result.NextOccurrences, next)
+			}
+		}
+	}
+
+	changedIDs := make([]int64, 0, len(changes))
+	for _, change := range changes {
+		changedIDs = append(changedIDs, change.TaskID)
+	}
+	metadata := map[string]interface{}{
+		"operation": req.Operation,
+		"task_ids":  changedIDs,
+		"matched":   result.Matched,
+		"updated":   result.Updated,
+		"unchanged": len(result.Results) - result.Updated,
+	}
+	if req.Filter != "" {
+		metadata["filter"] = req.Filter
+	}
+	if len(result.Warnings) > 0 {
+		metadata["warnings"] = result.Warnings
+	}
+	s.logRepo.CreateLog(&userID, "tasks_bulk_updated", fmt.Sprintf("Bulk %s applied to %d task(s)", req.Operation, result.Updated), metadata)
+
+	return result, nil
+}
+
+// bulkTargets resolves the task IDs or the filter of a bulk request. IDs are
+// kept in request order without duplicates; IDs missing from the returned map
+// do not exist.
+func (s *taskService) bulkTargets(userID int64, req *models.BulkTaskRequest) ([]int64, map[int64]*models.Task, error) {
+	if (len(req.TaskIDs) == 0) == (req.Filter == "") {
+		return nil, nil, fmt.Errorf("%w: give either task_ids or filter", ErrInvalidBulkRequest)
+	}
+
+	var ids []int64
+	var tasks []*models.Task
+	if req.Filter != "" {
+		node, err := filter.Parse(req.Filter, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		var total int64
+		tasks, total, err = s.taskRepo.GetTasksByFilterPaginated(userID, node, 1, maxBulkTasks)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to filter tasks: %w", err)
+		}
+		if total > maxBulkTasks {
+			return nil, nil, fmt.Errorf("%w: filter matches %d tasks, at most %d can be changed at once", ErrInvalidBulkRequest, total, maxBulkTasks)
+		}
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+	} else {
+		seen := make(map[int64]bool, len(req.TaskIDs))
+		for _, id := range req.TaskIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > maxBulkTasks {
+			return nil, nil, fmt.Errorf("%w: at most %d tasks can be changed at once", ErrInvalidBulkRequest, maxBulkTasks)
+		}
+		var err error
+		tasks, err = s.taskRepo.GetTasksByIDs(userID, ids)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get tasks: %w", err)
+		}
+	}
+
+	tasksByID := make(map[int64]*models.Task, len(tasks))
+	for _, task := range tasks {
+		tasksByID[task.ID] = task
+	}
+	return ids, tasksByID, nil
+}
+
+// validateBulkRequest checks the value the operation needs and returns the
+// parsed offset of a reschedule
+func validateBulkRequest(req *models.BulkTaskRequest) (int, time.Duration, error) {
+	switch req.Operation {
+	case models.BulkSetPriority:
+		if req.Priority == nil {
+			return 0, 0, fmt.Errorf("%w: set_priority needs a priority", ErrInvalidBulkRequest)
+		}
+	case models.BulkReschedule:
+		days, duration, err := parseOffset(req.Offset)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", ErrInvalidBulkRequest, err)
+		}
+		return days, duration, nil
+	}
+	return 0, 0, nil
+}
+
+// parseOffset parses a signed offset such as "+2d", "-1w", "3h" or "90m".
+// Days and weeks are calendar days, so local times stay put across DST.
+func parseOffset(offset string) (int, time.Duration, error) {
+	value := strings.TrimSpace(offset)
+	if len(value) < 2 {
+		return 0, 0, fmt.Errorf("offset %q must look like +2d, -1w, 3h or 90m", offset)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(value[:len(value)-1], "+"))
+	if err != nil || n == 0 {
+		return 0, 0, fmt.Errorf("offset %q must look like +2d, -1w, 3h or 90m", offset)
+	}
+	switch value[len(value)-1] {
+	case 'm':
+		return 0, time.Duration(n) * time.Minute, nil
+	case 'h':
+		return 0, time.Duration(n) * time.Hour, nil
+	case 'd':
+		return n, 0, nil
+	case 'w':
+		return 7 * n, 0, nil
+	}
+	return 0, 0, fmt.Errorf("offset %q must end in m, h, d or w", offset)
+}
+
+func sameCategory(current, next *string) bool {
+	if current == nil || next == nil {
+		return current == next
+	}
+	return *current == *next
+}
+
+// bulkCompletionChecks applies the subtask and blocked-task policies to a
+// task completed in bulk. Subtasks and blockers that are part of the same
+// batch count as done. With the cascade policy it returns the open subtasks
+// that must be completed along with the task.
+func (s *taskService) bulkCompletionChecks(task *models.Task, inBatch map[int64]bool) ([]*models.Task, []string, error) {
+	var cascaded []*models.Task
+	if s.subtaskPolicy.OnComplete != models.SubtaskIgnore {
+		descendants, err := s.taskRepo.GetTaskDescendants(task.ID, task.UserID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get subtasks: %w", err)
+		}
+		for _, descendant := range descendants {
+			if isOpenStatus(descendant.Status) && !inBatch[descendant.ID] {
+				cascaded = append(cascaded, descendant)
+			}
+		}
+		if s.subtaskPolicy.OnComplete == models.SubtaskBlock && len(cascaded) > 0 {
+			return nil, nil, fmt.Errorf("%w: %d subtask(s) of '%s' are not done", ErrOpenSubtasks, len(cascaded), task.TaskName)
+		}
+	}
+
+	blockers, err := s.taskRepo.GetTaskBlockers(task.ID, task.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get blockers: %w", err)
+	}
+	var warnings []string
+	for _, blocker := range blockers {
+		if !isOpenStatus(blocker.Status) || inBatch[blocker.ID] {
+			continue
+		}
+		if s.blockedCompletion == models.BlockedCompletionRefuse {
+			return nil, nil, fmt.Errorf("%w: '%s' is waiting on '%s'", ErrTaskBlocked, task.TaskName, blocker.TaskName)
+		}
+		warnings = append(warnings, fmt.Sprintf("Task '%s' is still blocked by '%s' (%s)", task.TaskName, blocker.TaskName, blocker.Status))
+	}
+	return cascaded, warnings, nil
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"todo-backend/caldav"
+	"todo-backend/ical"
+	"todo-backend/models"
+	"todo-backend/recurrence"
+	"todo-backend/repositories"
+	"todo-backend/utils"
+)
+
+// CalDAV Service keeps each user's tasks as the VTODO resources of one
+// CalDAV collection. Changes from clients go through TaskService, so they
+// are validated, logged and revisioned like any other edit.
+type CalDAVService interface {
+	caldav.Backend
+}
+
+type calDAVService struct {
+	taskService TaskService
+	taskRepo    repositories.TaskRepository
+}
+
+func NewCalDAVService(taskService TaskService, taskRepo repositories.TaskRepository) CalDAVService {
+	return &calDAVService{
+		taskService: taskService,
+		taskRepo:    taskRepo,
+	}
+}
+
+// calDAVName is the resource name of a task no client has named
+func calDAVName(taskID int64) string {
+	return fmt.Sprintf("task-%d.ics", taskID)
+}
+
+// defaultTaskID reads the task ID out of a name or UID this service made up
+func defaultTaskID(value, prefix, suffix string) (int64, bool) {
+	if !strings.HasPrefix(value, prefix) || !strings.HasSuffix(value, suffix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(value, prefix), suffix), 10, 64)
+	return id, err == nil && id > 0
+}
+
+func (s *calDAVService) object(task *models.CalDAVTask) (*caldav.Object, error) {
+	uid := taskUID(task.ID)
+	if task.UID != nil {
+		uid = *task.UID
+	}
+	name := calDAVName(task.ID)
+	if task.Href != nil {
+		name = *task.Href
+	}
+
+	cal := ical.NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0")
+	cal.AddText("PRODID", "-//"+icalDomain+"//CalDAV//EN")
+	// Stamped with the creation time so that the ETag only changes with the task
+	todo := taskComponent(&task.FeedTask, uid, models.FeedTaskTodo, time.UTC, task.CreatedAt)
+	if task.ParentID != nil {
+		parentUID := taskUID(*task.ParentID)
+		if task.ParentUID != nil {
+			parentUID = *task.ParentUID
+		}
+		todo.AddText("RELATED-TO", parentUID, "RELTYPE=PARENT")
+	}
+	cal.AddComponent(todo)
+
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render task: %w", err)
+	}
+	return &caldav.Object{Name: name, ETag: caldav.ETag(buf.Bytes()), Data: buf.Bytes()}, nil
+}
+
+func (s *calDAVService) Objects(userID int64) ([]*caldav.Object, error) {
+	tasks, err := s.taskRepo.GetCalDAVTasks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	objects := make([]*caldav.Object, 0, len(tasks))
+	for _, task := range tasks {
+		object, err := s.object(task)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// findTask returns the task stored under name, or nil. Names a client
+// chose are looked up first; a task a client named is not also found under
+// its default name.
+func (s *calDAVService) findTask(userID int64, name string) (*models.CalDAVTask, error) {
+	taskID, err := s.taskRepo.GetTaskIDByCalDAVHref(userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		var ok bool
+		if taskID, ok = defaultTaskID(name, "task-", ".ics"); !ok {
+			return nil, nil
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	task, err := s.taskRepo.GetCalDAVTask(taskID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	if task.Href != nil && *task.Href != name {
+		return nil, nil
+	}
+	return task, nil
+}
+
+func (s *calDAVService) Object(userID int64, name string) (*caldav.Object, error) {
+	task, err := s.findTask(userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, caldav.ErrNotFound
+	}
+	return s.object(task)
+}
+
+// taskIDByUID returns the task, not in the trash, that has uid, or 0
+func (s *calDAVService) taskIDByUID(userID int64, uid string) (int64, error) {
+	ids, err := s.taskRepo.GetTaskIDsByICalUID(userID, []string{uid})
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up UID: %w", err)
+	}
+	taskID, ok := ids[uid]
+	if !ok {
+		if taskID, ok = defaultTaskID(uid, "task-", "@"+icalDomain); !ok {
+			return 0, nil
+		}
+	}
+	if _, err := s.taskRepo.GetCalDAVTask(taskID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get task: %w", err)
+	}
+	return taskID, nil
+}
+
+// parentID resolves the RELATED-TO parent of a VTODO. It reports false
+// when the VTODO names no parent, and 0 for a parent that is unknown.
+func (s *calDAVService) parentID(userID int64, todo *ical.Component) (int64, bool, error) {
+	for _, related := range todo.All("RELATED-TO") {
+		if reltype := strings.ToUpper(related.Param("RELTYPE")); reltype != "" && reltype != "PARENT" {
+			continue
+		}
+		taskID, err := s.taskIDByUID(userID, ical.Unescape(related.Value))
+		return taskID, true, err
+	}
+	return 0, false, nil
+}
+
+// calDAVError turns the validation errors of TaskService into invalid
+// calendar data
+func calDAVError(err error) error {
+	for _, invalid := range []error{ErrInvalidRecurrence, ErrInvalidTag, ErrInvalidParent, ErrInvalidStatusTransition, ErrOpenSubtasks, ErrTaskBlocked} {
+		if errors.Is(err, invalid) {
+			return fmt.Errorf("%w: %v", caldav.ErrInvalidData, err)
+		}
+	}
+	return err
+}
+
+// PutObject creates or updates a task from a client's VTODO. Overridden
+// occurrences of a recurring VTODO are ignored: a series has one open
+// occurrence at a time. A due date a client removes is kept, since tasks
+// cannot lose their due date through an update.
+func (s *calDAVService) PutObject(userID int64, name string, cal *ical.Component) (bool, error) {
+	var todo *ical.Component
+	for _, c := range cal.Children("VTODO") {
+		if c.Get("RECURRENCE-ID") == nil {
+			todo = c
+			break
+		}
+	}
+	if todo == nil {
+		return false, fmt.Errorf("%w: no VTODO without RECURRENCE-ID", caldav.ErrInvalidData)
+	}
+	imported := utils.TaskFromICS(todo, ical.NewTimezones(cal, nil))
+	if imported == nil {
+		return false, fmt.Errorf("%w: SUMMARY is required", caldav.ErrInvalidData)
+	}
+	uid := imported.ICalUID
+
+	existing, err := s.findTask(userID, name)
+	if err != nil {
+		return false, err
+	}
+	owner, err := s.taskIDByUID(userID, uid)
+	if err != nil {
+		return false, err
+	}
+	if owner != 0 && (existing == nil || owner != existing.ID) {
+		return false, caldav.ErrUIDConflict
+	}
+	parentID, hasParent, err := s.parentID(userID, todo)
+	if err != nil {
+		return false, err
+	}
+
+	if existing == nil {
+		return true, s.createTask(userID, name, uid, imported, parentID)
+	}
+	if (existing.UID == nil && uid != taskUID(existing.ID)) || (existing.UID != nil && uid != *existing.UID) {
+		return false, caldav.ErrUIDConflict
+	}
+	return false, s.updateTask(userID, existing, imported, parentID, hasParent)
+}
+
+// createTask creates the task, its status and its CalDAV name in one
+// transaction, so that a failure cannot leave a task the client's retry
+// does not find. Like an import, a VTODO created completed describes its
+// series itself and generates no next occurrence.
+func (s *calDAVService) createTask(userID int64, name, uid string, imported *models.Task, parentID int64) error {
+	req := &models.CreateTaskRequest{
+		TaskName:           imported.TaskName,
+		Description:        imported.Description,
+		Category:           imported.Category,
+		Priority:           imported.Priority,
+		DueDate:            imported.DueDate,
+		IsRecurring:        imported.IsRecurring,
+		RecurringFrequency: imported.RecurringFrequency,
+		Tags:               imported.Tags,
+	}
+	if parentID != 0 {
+		req.ParentID = &parentID
+	}
+	status := imported.Status
+	if !status.IsValid() {
+		status = models.TaskStatusPending
+	}
+	_, err := s.taskService.CreateTaskWith(userID, req, func(task *models.Task) (*models.Task, error) {
+		task.Status = status
+		return s.taskRepo.CreateCalDAVTask(task, uid, name)
+	})
+	return calDAVError(err)
+}
+
+func (s *calDAVService) updateTask(userID int64, existing *models.CalDAVTask, imported *models.Task, parentID int64, hasParent bool) error {
+	description := ""
+	if imported.Description != nil {
+		description = *imported.Description
+	}
+	// The task's category is shown among its CATEGORIES; the rest are tags,
+	// including the first when the task has no category
+	categories := imported.Tags
+	if imported.Category != nil {
+		categories = append([]string{*imported.Category}, imported.Tags...)
+	}
+	tags := []string{}
+	for _, tag := range categories {
+		if existing.Category == nil || !strings.EqualFold(tag, *existing.Category) {
+			tags = append(tags, tag)
+		}
+	}
+	req := &models.UpdateTaskRequest{
+		TaskName:    &imported.TaskName,
+		Description: &description,
+		Priority:    &imported.Priority,
+		DueDate:     imported.DueDate,
+		Tags:        &tags,
+	}
+
+	// The rule sent is the rest of the series as rendered, so it only
+	// replaces the task's own when the client changed it
+	dateOnly := false
+	if existing.DueDate != nil {
+		_, dateOnly = feedDay(existing.DueDate.Time, time.UTC)
+	}
+	if !sameRule(taskRRule(&existing.Task, dateOnly), imported.RecurringFrequency) {
+		req.IsRecurring = &imported.IsRecurring
+		if imported.IsRecurring {
+			req.RecurringFrequency = imported.RecurringFrequency
+		}
+	}
+
+	// Statuses the task cannot move to directly are left as they are
+	if canTransition(existing.Status, imported.Status) {
+		req.Status = &imported.Status
+	}
+	switch {
+	case hasParent && parentID != 0:
+		req.ParentID = &parentID
+	case !hasParent && existing.ParentID != nil:
+		topLevel := int64(0)
+		req.ParentID = &topLevel
+	}
+
+	if _, err := s.taskService.UpdateTask(existing.ID, userID, req); err != nil {
+		return calDAVError(err)
+	}
+	return nil
+}
+
+// sameRule compares a rendered RRULE with one read back from a client
+func sameRule(rendered string, imported *string) bool {
+	if imported == nil || *imported == "" {
+		return rendered == ""
+	}
+	rule, err := recurrence.Parse(rendered)
+	if err != nil {
+		return false
+	}
+	return rule.String() == *imported
+}
+
+func (s *calDAVService) WithLock(userID int64, fn func() error) error {
+	return s.taskRepo.WithCalDAVLock(userID, fn)
+}
+
+// DeleteObject moves the task to the trash
+func (s *calDAVService) DeleteObject(userID int64, name string) error {
+	task, err := s.findTask(userID, name)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return caldav.ErrNotFound
+	}
+	if err := s.taskService.DeleteTask(task.ID, userID); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	return nil
+}
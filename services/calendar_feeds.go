@@ -0,0 +1,408 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"todo-backend/ical"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrCalendarFeedNotFound is returned when a feed does not exist, belongs to
+// another user or its token is unknown
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+// ErrInvalidCalendarFeed is returned for a blank feed name or unknown timezone
+var ErrInvalidCalendarFeed = errors.New("invalid calendar feed")
+
+// icalDomain makes UIDs unique to this service
+const icalDomain = "todo-backend"
+
+// Calendar Feed Service
+type CalendarFeedService interface {
+	CreateFeed(userID int64, req *models.CreateCalendarFeedRequest) (*models.CalendarFeed, string, error)
+	GetFeeds(userID int64) ([]*models.CalendarFeed, error)
+	RevokeFeed(feedID, userID int64) error
+	RenderFeed(token string) ([]byte, error)
+}
+
+type calendarFeedService struct {
+	feedRepo repositories.CalendarFeedRepository
+	taskRepo repositories.TaskRepository
+	goalRepo repositories.GoalRepository
+	logRepo  repositories.LogRepository
+}
+
+func NewCalendarFeedService(feedRepo repositories.CalendarFeedRepository, taskRepo repositories.TaskRepository, goalRepo repositories.GoalRepository, logRepo repositories.LogRepository) CalendarFeedService {
+	return &calendarFeedService{
+		feedRepo: feedRepo,
+		taskRepo: taskRepo,
+		goalRepo: goalRepo,
+		logRepo:  logRepo,
+	}
+}
+
+// feedTokenHash is what is stored in place of a feed token
+func feedTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateFeed makes a feed and returns it with its token. The token is not
+// stored and cannot be shown again; a lost token means a new feed.
+func (s *calendarFeedService) CreateFeed(userID int64, req *models.CreateCalendarFeedRequest) (*models.CalendarFeed, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidCalendarFeed)
+	}
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+		return nil, "", fmt.Errorf("%w: unknown timezone %q", ErrInvalidCalendarFeed, timezone)
+	}
+
+	feed := &models.CalendarFeed{
+		UserID:           userID,
+		Name:             name,
+		TaskFormat:       models.FeedTaskEvent,
+		Timezone:         timezone,
+		IncludeCompleted: true,
+		IncludeGoals:     true,
+	}
+	if req.TaskFormat != "" {
+		feed.TaskFormat = req.TaskFormat
+	}
+	if req.IncludeCompleted != nil {
+		feed.IncludeCompleted = *req.IncludeCompleted
+	}
+	if req.IncludeGoals != nil {
+		feed.IncludeGoals = *req.IncludeGoals
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("failed to generate feed token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	feed, err := s.feedRepo.CreateFeed(feed, feedTokenHash(token))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create calendar feed: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"feed_id": feed.ID,
+		"name":    feed.Name,
+	}
+	s.logRepo.CreateLog(&userID, "calendar_feed_created", fmt.Sprintf("Created calendar feed '%s'", feed.Name), metadata)
+
+	return feed, token, nil
+}
+
+func (s *calendarFeedService) GetFeeds(userID int64) ([]*models.CalendarFeed, error) {
+	feeds, err := s.feedRepo.GetFeeds(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar feeds: %w", err)
+	}
+	return feeds, nil
+}
+
+// RevokeFeed deletes a feed so its URL stops working
+func (s *calendarFeedService) RevokeFeed(feedID, userID int64) error {
+	if err := s.feedRepo.DeleteFeed(feedID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCalendarFeedNotFound
+		}
+		return fmt.Errorf("failed to revoke calendar feed: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"feed_id": feedID,
+	}
+	s.logRepo.CreateLog(&userID, "calendar_feed_revoked", "Revoked a calendar feed", metadata)
+
+	return nil
+}
+
+// RenderFeed returns the iCalendar data of the feed with token
+func (s *calendarFeedService) RenderFeed(token string) ([]byte, error) {
+	if token == "" {
+		return nil, ErrCalendarFeedNotFound
+	}
+	feed, err := s.feedRepo.GetFeedByTokenHash(feedTokenHash(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCalendarFeedNotFound
+		}
+		return nil, fmt.Errorf("failed to get calendar feed: %w", err)
+	}
+	location, err := time.LoadLocation(feed.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	tasks, err := s.taskRepo.GetFeedTasks(feed.UserID, feed.IncludeCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	var goals []*models.Goal
+	if feed.IncludeGoals {
+		if goals, err = s.goalRepo.GetGoalsByUserID(feed.UserID); err != nil {
+			return nil, fmt.Errorf("failed to get goals: %w", err)
+		}
+	}
+
+	now := time.Now()
+	cal := ical.NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0")
+	cal.AddText("PRODID", "-//"+icalDomain+"//Calendar Feed//EN")
+	cal.Add("CALSCALE", "GREGORIAN")
+	cal.Add("METHOD", "PUBLISH")
+	cal.AddText("X-WR-CALNAME", feed.Name)
+	cal.AddText("X-WR-TIMEZONE", feed.Timezone)
+	cal.Add("REFRESH-INTERVAL", "PT1H", "VALUE=DURATION")
+	cal.Add("X-PUBLISHED-TTL", "PT1H")
+
+	for _, task := range tasks {
+		cal.AddComponent(taskComponent(task, taskUID(task.ID), feed.TaskFormat, location, now))
+	}
+	for _, goal := range goals {
+		if goal.DueDate == nil || (goal.IsCompleted && !feed.IncludeCompleted) {
+			continue
+		}
+		cal.AddComponent(goalComponent(goal, feed.TaskFormat, location, now))
+	}
+
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render calendar feed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// feedDay tells whether a due date is date-only, stored as midnight UTC, and
+// returns the day it falls on: in UTC when date-only, else in location
+func feedDay(due time.Time, location *time.Location) (time.Time, bool) {
+	due = due.UTC()
+	if due.Equal(due.Truncate(24 * time.Hour)) {
+		return due, true
+	}
+	day := due.In(location)
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location), false
+}
+
+// addDue sets when an item is due: an all-day event on its day, or for a
+// to-do a DUE date, or date-time when it is due at a time of day. It
+// reports whether the values written are dates.
+func addDue(c *ical.Component, due time.Time, format string, location *time.Location) bool {
+	day, allDay := feedDay(due, location)
+	if format == models.FeedTaskTodo {
+		if allDay {
+			c.Add("DUE", ical.Date(day), "VALUE=DATE")
+		} else {
+			c.Add("DUE", ical.DateTime(due))
+		}
+		return allDay
+	}
+	c.Add("DTSTART", ical.Date(day), "VALUE=DATE")
+	c.Add("DTEND", ical.Date(day.AddDate(0, 0, 1)), "VALUE=DATE")
+	return true
+}
+
+// taskPriority maps priority 3 (high), 2 and 1 (low) onto iCalendar's 1 to 9
+// scale; 0 means no priority and is left out
+func taskPriority(priority int16) string {
+	switch priority {
+	case 3:
+		return "1"
+	case 2:
+		return "5"
+	case 1:
+		return "9"
+	}
+	return ""
+}
+
+// taskRRule returns the RRULE value of the rest of an open recurring task's
+// series, counting from this occurrence, or "" when there is none. Series
+// are created one occurrence at a time, so finished occurrences are in the
+// feed on their own and only the open one carries the rule.
+func taskRRule(task *models.Task, dateOnly bool) string {
+	if task.Status == models.TaskStatusCompleted || task.Status == models.TaskStatusCancelled {
+		return ""
+	}
+	parsed, err := taskRule(task)
+	if err != nil || parsed == nil {
+		return ""
+	}
+	rule := *parsed
+	if rule.Count > 0 {
+		index := int(task.RecurrenceIndex)
+		if index < 1 {
+			index = 1
+		}
+		rule.Count -= index - 1
+		if rule.Count <= 0 {
+			return ""
+		}
+	}
+	// UNTIL must be a DATE when the start is one; a date-only UNTIL is
+	// stored as the last second of its day in UTC
+	if rule.Until != nil && dateOnly {
+		until := rule.Until.UTC()
+		rule.Until = nil
+		return rule.String() + ";UNTIL=" + ical.Date(until)
+	}
+	return rule.String()
+}
+
+// taskUID is the UID of a task this service made up
+func taskUID(taskID int64) string {
+	return fmt.Sprintf("task-%d@%s", taskID, icalDomain)
+}
+
+// taskComponent renders a task as an all-day VEVENT or a VTODO. An event
+// needs a due date; a to-do without one is left undated.
+func taskComponent(task *models.FeedTask, uid, format string, location *time.Location, stamp time.Time) *ical.Component {
+	name := "VEVENT"
+	if format == models.FeedTaskTodo {
+		name = "VTODO"
+	}
+	c := ical.NewComponent(name)
+	c.AddText("UID", uid)
+	c.Add("DTSTAMP", ical.DateTime(stamp))
+	c.Add("CREATED", ical.DateTime(task.CreatedAt))
+
+	var due time.Time
+	dateOnly := false
+	if task.DueDate != nil {
+		due = task.DueDate.Time
+		dateOnly = addDue(c, due, format, location)
+	}
+	if rrule := taskRRule(&task.Task, dateOnly); rrule != "" && task.DueDate != nil {
+		if format == models.FeedTaskTodo {
+			// A repeating to-do needs DTSTART to anchor its rule
+			if dateOnly {
+				day, _ := feedDay(due, location)
+				c.Add("DTSTART", ical.Date(day), "VALUE=DATE")
+			} else {
+				c.Add("DTSTART", ical.DateTime(due))
+			}
+		}
+		c.Add("RRULE", rrule)
+	}
+
+	summary := task.TaskName
+	switch {
+	case format == models.FeedTaskTodo:
+		c.Add("STATUS", todoStatus(task.Status))
+		if task.Status == models.TaskStatusCompleted {
+			c.Add("PERCENT-COMPLETE", "100")
+			if task.CompletedAt != nil {
+				c.Add("COMPLETED", ical.DateTime(*task.CompletedAt))
+			}
+		}
+	case task.Status == models.TaskStatusCancelled:
+		c.Add("STATUS", "CANCELLED")
+	case task.Status == models.TaskStatusCompleted:
+		// Events have no completed status
+		c.Add("STATUS", "CONFIRMED")
+		summary = "Done: " + summary
+	default:
+		c.Add("STATUS", "CONFIRMED")
+	}
+	c.AddText("SUMMARY", summary)
+	if task.Description != nil && *task.Description != "" {
+		c.AddText("DESCRIPTION", *task.Description)
+	}
+	if priority := taskPriority(task.Priority); priority != "" {
+		c.Add("PRIORITY", priority)
+	}
+
+	var categories []string
+	if task.Category != nil && *task.Category != "" {
+		categories = append(categories, ical.Text(*task.Category))
+	}
+	for _, tag := range task.Tags {
+		categories = append(categories, ical.Text(tag))
+	}
+	if len(categories) > 0 {
+		c.Add("CATEGORIES", strings.Join(categories, ","))
+	}
+	if format != models.FeedTaskTodo {
+		c.Add("TRANSP", "TRANSPARENT")
+	}
+	return c
+}
+
+// todoStatus maps a task status onto VTODO's STATUS
+func todoStatus(status models.TaskStatus) string {
+	switch status {
+	case models.TaskStatusInProgress:
+		return "IN-PROCESS"
+	case models.TaskStatusCompleted:
+		return "COMPLETED"
+	case models.TaskStatusCancelled:
+		return "CANCELLED"
+	}
+	return "NEEDS-ACTION"
+}
+
+// goalComponent renders a goal's deadline like a task's
+func goalComponent(goal *models.Goal, format string, location *time.Location, now time.Time) *ical.Component {
+	name := "VEVENT"
+	if format == models.FeedTaskTodo {
+		name = "VTODO"
+	}
+	c := ical.NewComponent(name)
+	c.AddText("UID", fmt.Sprintf("goal-%d@%s", goal.ID, icalDomain))
+	c.Add("DTSTAMP", ical.DateTime(now))
+	c.Add("CREATED", ical.DateTime(goal.CreatedAt))
+	addDue(c, *goal.DueDate, format, location)
+
+	summary := "Goal: " + goal.Title
+	percent := 0
+	if goal.TargetValue > 0 {
+		percent = int(goal.CurrentValue) * 100 / int(goal.TargetValue)
+	}
+	if percent > 100 || goal.IsCompleted {
+		percent = 100
+	}
+	switch {
+	case format == models.FeedTaskTodo && goal.IsCompleted:
+		c.Add("STATUS", "COMPLETED")
+	case format == models.FeedTaskTodo:
+		c.Add("STATUS", "NEEDS-ACTION")
+	case goal.IsCompleted:
+		c.Add("STATUS", "CONFIRMED")
+		summary = "Done: " + summary
+	default:
+		c.Add("STATUS", "CONFIRMED")
+	}
+	if format == models.FeedTaskTodo {
+		c.Add("PERCENT-COMPLETE", strconv.Itoa(percent))
+	}
+	c.AddText("SUMMARY", summary)
+
+	description := fmt.Sprintf("Progress: %d/%d %s (%d%%)", goal.CurrentValue, goal.TargetValue, goal.Unit, percent)
+	if goal.Description != nil && *goal.Description != "" {
+		description = *goal.Description + "\n\n" + description
+	}
+	c.AddText("DESCRIPTION", description)
+	c.AddText("CATEGORIES", "Goals")
+	if format != models.FeedTaskTodo {
+		c.Add("TRANSP", "TRANSPARENT")
+	}
+	return c
+}
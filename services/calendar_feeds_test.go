@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+	"todo-backend/ical"
+	"todo-backend/models"
+	"todo-backend/utils"
+)
+
+func TestFeedTaskRoundTrip(t *testing.T) {
+	category := "Work"
+	description := "Quarterly numbers, see the shared folder"
+	frequency := "FREQ=WEEKLY;BYDAY=MO"
+	due := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task models.Task
+	}{
+		{
+			name: "category and tags",
+			task: models.Task{
+				ID:          7,
+				TaskName:    "Report, draft; final",
+				Description: &description,
+				Category:    &category,
+				Priority:    3,
+				DueDate:     &models.CustomTime{Time: due},
+				Status:      models.TaskStatusInProgress,
+				Tags:        []string{"finance", "q4"},
+			},
+		},
+		{
+			name: "category only, recurring",
+			task: models.Task{
+				ID:                 8,
+				TaskName:           "Standup",
+				Category:           &category,
+				Priority:           2,
+				DueDate:            &models.CustomTime{Time: due},
+				IsRecurring:        true,
+				RecurringFrequency: &frequency,
+				Status:             models.TaskStatusPending,
+			},
+		},
+		{
+			name: "completed without category",
+			task: models.Task{
+				ID:          9,
+				TaskName:    "Renew passport",
+				Priority:    1,
+				Status:      models.TaskStatusCompleted,
+				IsCompleted: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := ical.NewComponent("VCALENDAR")
+			cal.Add("VERSION", "2.0")
+			cal.AddComponent(taskComponent(&models.FeedTask{Task: tt.task}, taskUID(tt.task.ID), models.FeedTaskTodo, time.UTC, due))
+			var buf bytes.Buffer
+			if err := cal.Encode(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			tasks, err := utils.ImportTasksFromICS(buf.Bytes())
+			if err != nil {
+				t.Fatalf("ImportTasksFromICS: %v\n%s", err, buf.String())
+			}
+			if len(tasks) != 1 {
+				t.Fatalf("got %d tasks, want 1", len(tasks))
+			}
+			got := tasks[0]
+
+			if got.ICalUID != taskUID(tt.task.ID) {
+				t.Errorf("UID = %q, want %q", got.ICalUID, taskUID(tt.task.ID))
+			}
+			if got.TaskName != tt.task.TaskName {
+				t.Errorf("name = %q, want %q", got.TaskName, tt.task.TaskName)
+			}
+			if !reflect.DeepEqual(got.Description, tt.task.Description) {
+				t.Errorf("description = %v, want %v", got.Description, tt.task.Description)
+			}
+			if !reflect.DeepEqual(got.Category, tt.task.Category) {
+				t.Errorf("category = %v, want %v", got.Category, tt.task.Category)
+			}
+			if !reflect.DeepEqual(got.Tags, tt.task.Tags) {
+				t.Errorf("tags = %v, want %v", got.Tags, tt.task.Tags)
+			}
+			if got.Priority != tt.task.Priority {
+				t.Errorf("priority = %d, want %d", got.Priority, tt.task.Priority)
+			}
+			if got.Status != tt.task.Status {
+				t.Errorf("status = %s, want %s", got.Status, tt.task.Status)
+			}
+			if !reflect.DeepEqual(got.DueDate, tt.task.DueDate) {
+				t.Errorf("due = %v, want %v", got.DueDate, tt.task.DueDate)
+			}
+			if got.IsRecurring != tt.task.IsRecurring || !reflect.DeepEqual(got.RecurringFrequency, tt.task.RecurringFrequency) {
+				t.Errorf("recurrence = %v %v, want %v %v", got.IsRecurring, got.RecurringFrequency, tt.task.IsRecurring, tt.task.RecurringFrequency)
+			}
+		})
+	}
+}
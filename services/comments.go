@@ -0,0 +1,149 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrCommentNotFound is returned when a comment does not exist or belongs to
+// another task or user
+var ErrCommentNotFound = errors.New("comment not found")
+
+// ErrInvalidComment is returned for blank comment bodies
+var ErrInvalidComment = errors.New("invalid comment")
+
+// Comment Service
+type CommentService interface {
+	AddComment(taskID, userID int64, req *models.CommentRequest) (*models.TaskComment, error)
+	GetTaskComments(taskID, userID int64) ([]*models.TaskComment, error)
+	UpdateComment(commentID, taskID, userID int64, req *models.CommentRequest) (*models.TaskComment, error)
+	DeleteComment(commentID, taskID, userID int64) error
+}
+
+type commentService struct {
+	commentRepo repositories.CommentRepository
+	taskRepo    repositories.TaskRepository
+	logRepo     repositories.LogRepository
+}
+
+func NewCommentService(commentRepo repositories.CommentRepository, taskRepo repositories.TaskRepository, logRepo repositories.LogRepository) CommentService {
+	return &commentService{
+		commentRepo: commentRepo,
+		taskRepo:    taskRepo,
+		logRepo:     logRepo,
+	}
+}
+
+// commentBody trims a comment body; Markdown is kept as written
+func commentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("%w: body is required", ErrInvalidComment)
+	}
+	return body, nil
+}
+
+// AddComment comments on a task and records it in the activity log
+func (s *commentService) AddComment(taskID, userID int64, req *models.CommentRequest) (*models.TaskComment, error) {
+	body, err := commentBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	comment, err := s.commentRepo.CreateComment(&models.TaskComment{
+		TaskID: taskID,
+		UserID: userID,
+		Body:   body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add comment: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":    taskID,
+		"task_name":  task.TaskName,
+		"comment_id": comment.ID,
+	}
+	s.logRepo.CreateLog(&userID, "comment_added", fmt.Sprintf("Commented on task '%s'", task.TaskName), metadata)
+
+	return comment, nil
+}
+
+func (s *commentService) GetTaskComments(taskID, userID int64) ([]*models.TaskComment, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	comments, err := s.commentRepo.GetTaskComments(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	return comments, nil
+}
+
+func (s *commentService) UpdateComment(commentID, taskID, userID int64, req *models.CommentRequest) (*models.TaskComment, error) {
+	body, err := commentBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+	comment, err := s.commentRepo.GetComment(commentID, taskID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrCommentNotFound, commentID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	if comment.Body == body {
+		return comment, nil
+	}
+
+	updatedComment, err := s.commentRepo.UpdateComment(commentID, taskID, userID, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":    taskID,
+		"task_name":  task.TaskName,
+		"comment_id": commentID,
+	}
+	s.logRepo.CreateLog(&userID, "comment_edited", fmt.Sprintf("Edited a comment on task '%s'", task.TaskName), metadata)
+
+	return updatedComment, nil
+}
+
+func (s *commentService) DeleteComment(commentID, taskID, userID int64) error {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	err = s.commentRepo.DeleteComment(commentID, taskID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrCommentNotFound, commentID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":    taskID,
+		"task_name":  task.TaskName,
+		"comment_id": commentID,
+	}
+	s.logRepo.CreateLog(&userID, "comment_deleted", fmt.Sprintf("Deleted a comment on task '%s'", task.TaskName), metadata)
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"todo-backend/models"
+)
+
+// ErrDependencyCycle is returned when a new dependency would make a task (indirectly) wait on itself
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// ErrTaskBlocked is returned when completing a task whose blockers are still open and the policy refuses it
+var ErrTaskBlocked = errors.New("task is blocked by open tasks")
+
+func normalizeBlockedCompletion(policy string) string {
+	if policy == models.BlockedCompletionRefuse {
+		return policy
+	}
+	return models.BlockedCompletionWarn
+}
+
+func (s *taskService) AddTaskDependency(taskID, blockedByID, userID int64) error {
+	if taskID == blockedByID {
+		return fmt.Errorf("%w: a task cannot block itself", ErrDependencyCycle)
+	}
+
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	blocker, err := s.taskRepo.GetTaskByID(blockedByID, userID)
+	if err != nil {
+		return fmt.Errorf("blocking task not found: %w", err)
+	}
+
+	dependencies, err := s.taskRepo.GetUserDependencies(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get dependencies: %w", err)
+	}
+	if dependsOn(dependencies, blockedByID, taskID) {
+		return fmt.Errorf("%w: '%s' already waits on '%s'", ErrDependencyCycle, blocker.TaskName, task.TaskName)
+	}
+
+	if err := s.taskRepo.AddTaskDependency(taskID, blockedByID, userID); err != nil {
+		return fmt.Errorf("failed to add dependency: %w", err)
+	}
+
+	// Log dependency creation
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"task_name":     task.TaskName,
+		"blocked_by_id": blockedByID,
+		"blocked_by":    blocker.TaskName,
+	}
+	s.logRepo.CreateLog(&userID, "task_dependency_added", fmt.Sprintf("Task '%s' is now blocked by '%s'", task.TaskName, blocker.TaskName), metadata)
+
+	return nil
+}
+
+// dependsOn reports whether from waits on target, directly or through other tasks
+func dependsOn(dependencies []*models.TaskDependency, from, target int64) bool {
+	blockedBy := make(map[int64][]int64)
+	for _, dependency := range dependencies {
+		blockedBy[dependency.TaskID] = append(blockedBy[dependency.TaskID], dependency.BlockedByID)
+	}
+
+	visited := map[int64]bool{from: true}
+	queue := []int64{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range blockedBy[current] {
+			if next == target {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
+
+func (s *taskService) RemoveTaskDependency(taskID, blockedByID, userID int64) error {
+	if err := s.taskRepo.RemoveTaskDependency(taskID, blockedByID, userID); err != nil {
+		return fmt.Errorf("failed to remove dependency: %w", err)
+	}
+
+	// Log dependency removal
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"blocked_by_id": blockedByID,
+	}
+	s.logRepo.CreateLog(&userID, "task_dependency_removed", fmt.Sprintf("Task %d is no longer blocked by task %d", taskID, blockedByID), metadata)
+
+	return nil
+}
+
+func (s *taskService) GetTaskBlockers(taskID, userID int64) ([]*models.Task, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	blockers, err := s.taskRepo.GetTaskBlockers(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockers: %w", err)
+	}
+	return blockers, nil
+}
+
+func (s *taskService) GetTaskDependents(taskID, userID int64) ([]*models.Task, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	dependents, err := s.taskRepo.GetTaskDependents(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependents: %w", err)
+	}
+	return dependents, nil
+}
+
+// GetDependencyGraph returns every task that takes part in a dependency, with
+// the edges between them
+func (s *taskService) GetDependencyGraph(userID int64) (*models.DependencyGraph, error) {
+	dependencies, err := s.taskRepo.GetUserDependencies(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependencies: %w", err)
+	}
+
+	graph := &models.DependencyGraph{
+		Nodes: []*models.DependencyNode{},
+		Edges: []*models.DependencyEdge{},
+	}
+	if len(dependencies) == 0 {
+		return graph, nil
+	}
+
+	blockedBy := make(map[int64][]int64)
+	inGraph := make(map[int64]bool)
+	var ids []int64
+	for _, dependency := range dependencies {
+		blockedBy[dependency.TaskID] = append(blockedBy[dependency.TaskID], dependency.BlockedByID)
+		for _, id := range []int64{dependency.TaskID, dependency.BlockedByID} {
+			if !inGraph[id] {
+				inGraph[id] = true
+				ids = append(ids, id)
+			}
+		}
+		graph.Edges = append(graph.Edges, &models.DependencyEdge{From: dependency.BlockedByID, To: dependency.TaskID})
+	}
+
+	tasks, err := s.taskRepo.GetTasksByIDs(userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	tasksByID := make(map[int64]*models.Task, len(tasks))
+	for _, task := range tasks {
+		tasksByID[task.ID] = task
+	}
+
+	levels := make(map[int64]int)
+	var level func(id int64, visiting map[int64]bool) int
+	level = func(id int64, visiting map[int64]bool) int {
+		if l, ok := levels[id]; ok {
+			return l
+		}
+		if visiting[id] {
+			return 0
+		}
+		visiting[id] = true
+		l := 0
+		for _, blocker := range blockedBy[id] {
+			if candidate := level(blocker, visiting) + 1; candidate > l {
+				l = candidate
+			}
+		}
+		delete(visiting, id)
+		levels[id] = l
+		return l
+	}
+
+	// Newest first, like the task list, so the output is stable
+	for i := len(tasks) - 1; i >= 0; i-- {
+		task := tasks[i]
+		node := &models.DependencyNode{
+			ID:       task.ID,
+			TaskName: task.TaskName,
+			Status:   task.Status,
+			DueDate:  task.DueDate,
+			Level:    level(task.ID, map[int64]bool{}),
+		}
+		for _, blocker := range blockedBy[task.ID] {
+			if b, ok := tasksByID[blocker]; ok && isOpenStatus(b.Status) {
+				node.IsBlocked = true
+				break
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	return graph, nil
+}
+
+// checkBlockersBeforeCompletion applies the blocked-completion policy. With
+// the warn policy it returns one warning per open blocker instead of an error.
+func (s *taskService) checkBlockersBeforeCompletion(task *models.Task) ([]string, error) {
+	blockers, err := s.taskRepo.GetTaskBlockers(task.ID, task.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockers: %w", err)
+	}
+
+	var open []*models.Task
+	for _, blocker := range blockers {
+		if isOpenStatus(blocker.Status) {
+			open = append(open, blocker)
+		}
+	}
+	if len(open) == 0 {
+		return nil, nil
+	}
+
+	if s.blockedCompletion == models.BlockedCompletionRefuse {
+		return nil, fmt.Errorf("%w: '%s' is waiting on %d open task(s)", ErrTaskBlocked, task.TaskName, len(open))
+	}
+
+	warnings := make([]string, 0, len(open))
+	for _, blocker := range open {
+		warnings = append(warnings, fmt.Sprintf("Task '%s' is still blocked by '%s' (%s)", task.TaskName, blocker.TaskName, blocker.Status))
+	}
+	return warnings, nil
+}
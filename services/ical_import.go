@@ -0,0 +1,88 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"todo-backend/models"
+)
+
+// errImportTrashed is returned by reimportTask when the task an earlier
+// import created for a UID is in the trash
+var errImportTrashed = errors.New("imported task is in the trash")
+
+// importedICalIDs maps the UIDs in an import to the tasks earlier imports
+// created for them. A failed lookup imports everything as new.
+func (s *taskService) importedICalIDs(userID int64, tasks []*models.Task) map[string]int64 {
+	var uids []string
+	for _, task := range tasks {
+		if task.ICalUID != "" {
+			uids = append(uids, task.ICalUID)
+		}
+	}
+	ids, err := s.taskRepo.GetTaskIDsByICalUID(userID, uids)
+	if err != nil {
+		return map[string]int64{}
+	}
+	return ids
+}
+
+// reimportTask brings the task an earlier import created for the same UID
+// in line with the file through UpdateTask. Its project, subtasks and
+// estimate are kept, and so are its category and tags when the file has no
+// CATEGORIES and its due date when the file has none. Trashed tasks stay in the trash and are
+// not imported again.
+func (s *taskService) reimportTask(userID, taskID int64, imported *models.Task) error {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errImportTrashed
+		}
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	description := ""
+	if imported.Description != nil {
+		description = *imported.Description
+	}
+	req := &models.UpdateTaskRequest{
+		TaskName:    &imported.TaskName,
+		Description: &description,
+		Priority:    &imported.Priority,
+		DueDate:     imported.DueDate,
+		IsRecurring: &imported.IsRecurring,
+	}
+	if imported.IsRecurring {
+		req.RecurringFrequency = imported.RecurringFrequency
+	}
+	if imported.Category != nil {
+		tags := imported.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		req.Category = imported.Category
+		req.Tags = &tags
+	}
+
+	updatedTask, err := s.UpdateTask(taskID, userID, req)
+	if err != nil {
+		return err
+	}
+	s.importStatus(updatedTask, imported.Status)
+	return nil
+}
+
+// importStatus moves an imported task to the status its file gave it, with
+// the same checks and subtask and blocked-task policies as any other status
+// change. Unlike completing a task by hand it does not create the next
+// occurrence of a recurring task: the file already describes the series. A
+// status the task cannot move to, or that a policy refuses, is left out.
+func (s *taskService) importStatus(task *models.Task, status models.TaskStatus) *models.Task {
+	if status == "" || status == task.Status || !canTransition(task.Status, status) {
+		return task
+	}
+	result, err := s.changeStatus(task, status, false, nil)
+	if err != nil {
+		return task
+	}
+	return result.Task
+}
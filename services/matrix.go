@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"todo-backend/models"
+)
+
+// ErrInvalidMatrixSettings is returned for a negative urgency horizon
+var ErrInvalidMatrixSettings = errors.New("invalid matrix settings")
+
+// isImportant applies the task's own flag, falling back to the priority threshold
+func isImportant(task *models.Task, settings models.MatrixSettings) bool {
+	if task.IsImportant != nil {
+		return *task.IsImportant
+	}
+	return task.Priority >= settings.ImportantPriority
+}
+
+// GetTaskMatrix buckets the user's open tasks into the Eisenhower quadrants.
+// urgentDays and importantPriority override the configured settings when set.
+// Tasks without a due date are never urgent.
+func (s *taskService) GetTaskMatrix(userID int64, urgentDays *int, importantPriority *int16) (*models.TaskMatrix, error) {
+	settings := s.matrixSettings
+	if urgentDays != nil {
+		settings.UrgentDays = *urgentDays
+	}
+	if importantPriority != nil {
+		settings.ImportantPriority = *importantPriority
+	}
+	if settings.UrgentDays < 0 {
+		return nil, fmt.Errorf("%w: urgent_days must not be negative", ErrInvalidMatrixSettings)
+	}
+
+	tasks, err := s.taskRepo.GetOpenTasks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open tasks: %w", err)
+	}
+
+	matrix := &models.TaskMatrix{
+		Do:        &models.MatrixQuadrant{Tasks: []*models.Task{}},
+		Schedule:  &models.MatrixQuadrant{Tasks: []*models.Task{}},
+		Delegate:  &models.MatrixQuadrant{Tasks: []*models.Task{}},
+		Eliminate: &models.MatrixQuadrant{Tasks: []*models.Task{}},
+		Total:     len(tasks),
+		Settings:  settings,
+		UrgentBy:  time.Now().AddDate(0, 0, settings.UrgentDays),
+	}
+	for _, task := range tasks {
+		urgent := task.DueDate != nil && task.DueDate.Before(matrix.UrgentBy)
+		var quadrant *models.MatrixQuadrant
+		switch important := isImportant(task, settings); {
+		case urgent && important:
+			quadrant = matrix.Do
+		case important:
+			quadrant = matrix.Schedule
+		case urgent:
+			quadrant = matrix.Delegate
+		default:
+			quadrant = matrix.Eliminate
+		}
+		quadrant.Tasks = append(quadrant.Tasks, task)
+		quadrant.Count++
+	}
+
+	return matrix, nil
+}
+
+// SetTaskImportance flags a task important or not, leaving its priority as
+// it is; nil hands the decision back to the priority threshold
+func (s *taskService) SetTaskImportance(taskID, userID int64, isImportant *bool) (*models.Task, error) {
+	existingTask, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+	before := snapshot(existingTask)
+
+	task, err := s.taskRepo.SetTaskImportance(taskID, userID, isImportant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set importance: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":      task.ID,
+		"task_name":    task.TaskName,
+		"is_important": isImportant,
+	}
+	s.logRepo.CreateLog(&userID, "task_updated", fmt.Sprintf("Task '%s' importance changed", task.TaskName), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionTask, task.ID, models.RevisionUpdate, before, snapshot(task), nil)
+
+	return task, nil
+}
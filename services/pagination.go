@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"todo-backend/filter"
+	"todo-backend/models"
+	"todo-backend/pagination"
+	"todo-backend/repositories"
+)
+
+const (
+	defaultTaskSort  = "-created_at"
+	defaultHabitSort = "-created_at"
+)
+
+// GetTasksByCursor lists a user's tasks with keyset pagination. The list can
+// be narrowed by a filter expression and by tags, which are combined with AND.
+// Invalid sorts and cursors wrap pagination.ErrInvalidSort and ErrInvalidCursor.
+func (s *taskService) GetTasksByCursor(userID int64, expr string, tags []string, matchAll bool, req *models.CursorRequest) ([]*models.Task, *models.CursorPage, error) {
+	q, err := pagination.NewQuery(req.Sort, defaultTaskSort, req.Cursor, req.Limit, repositories.TaskSortColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var node filter.Node
+	if expr != "" {
+		node, err = filter.Parse(expr, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(tags) > 0 {
+		tagNode, err := tagFilter(tags, matchAll)
+		if err != nil {
+			return nil, nil, err
+		}
+		node = andNodes(node, tagNode)
+	}
+
+	tasks, page, err := s.taskRepo.GetTasksByCursor(userID, node, q)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	return tasks, page, nil
+}
+
+// tagFilter expresses a tag list as tag:a OR tag:b, or AND when every tag must match
+func tagFilter(tags []string, matchAll bool) (filter.Node, error) {
+	names, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no tags given", ErrInvalidTag)
+	}
+
+	op := "OR"
+	if matchAll {
+		op = "AND"
+	}
+	var node filter.Node
+	for _, name := range names {
+		cmp := &filter.Comparison{Field: filter.FieldTag, Op: filter.OpEq, Value: filter.Value{Str: name}}
+		if node == nil {
+			node = cmp
+		} else {
+			node = &filter.Binary{Op: op, Left: node, Right: cmp}
+		}
+	}
+	return node, nil
+}
+
+func andNodes(left, right filter.Node) filter.Node {
+	if left == nil {
+		return right
+	}
+	return &filter.Binary{Op: "AND", Left: left, Right: right}
+}
+
+// GetHabitsByCursor lists a user's habits with keyset pagination
+func (s *habitService) GetHabitsByCursor(userID int64, req *models.CursorRequest) ([]*models.Habit, *models.CursorPage, error) {
+	q, err := pagination.NewQuery(req.Sort, defaultHabitSort, req.Cursor, req.Limit, repositories.HabitSortColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	habits, page, err := s.habitRepo.GetHabitsByCursor(userID, q)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get habits: %w", err)
+	}
+	return habits, page, nil
+}
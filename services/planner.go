@@ -0,0 +1,417 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"todo-backend/models"
+	"todo-backend/planner"
+	"todo-backend/repositories"
+)
+
+// ErrInvalidPlan is returned for a bad date, timezone, working hours or block
+var ErrInvalidPlan = errors.New("invalid plan")
+
+// ErrPlanConflict is returned when accepted blocks overlap each other or
+// time blocked out by hand
+var ErrPlanConflict = errors.New("plan conflicts with the calendar")
+
+// ErrTimeBlockNotFound is returned when a time block does not exist or
+// belongs to another user
+var ErrTimeBlockNotFound = errors.New("time block not found")
+
+// reasonAlreadyPlanned marks tasks an accepted plan put on another day
+const reasonAlreadyPlanned = "already_planned"
+
+// defaultWorkingHours apply to users who have not set their own
+var defaultWorkingHours = []models.WorkingHours{
+	{Weekday: 1, Start: "09:00", End: "17:00"},
+	{Weekday: 2, Start: "09:00", End: "17:00"},
+	{Weekday: 3, Start: "09:00", End: "17:00"},
+	{Weekday: 4, Start: "09:00", End: "17:00"},
+	{Weekday: 5, Start: "09:00", End: "17:00"},
+}
+
+// Planner Service
+type PlannerService interface {
+	GetWorkingHours(userID int64) ([]models.WorkingHours, error)
+	SetWorkingHours(userID int64, req *models.SetWorkingHoursRequest) ([]models.WorkingHours, error)
+	GetTimeBlocks(userID int64, date, timezone string) ([]*models.TimeBlock, error)
+	CreateTimeBlock(userID int64, req *models.CreateTimeBlockRequest) (*models.TimeBlock, error)
+	DeleteTimeBlock(blockID, userID int64) error
+	PlanDay(userID int64, date, timezone string) (*models.DayPlan, error)
+	AcceptPlan(userID int64, req *models.AcceptPlanRequest) ([]*models.TimeBlock, error)
+}
+
+type plannerService struct {
+	plannerRepo repositories.PlannerRepository
+	taskRepo    repositories.TaskRepository
+	logRepo     repositories.LogRepository
+}
+
+func NewPlannerService(plannerRepo repositories.PlannerRepository, taskRepo repositories.TaskRepository, logRepo repositories.LogRepository) PlannerService {
+	return &plannerService{
+		plannerRepo: plannerRepo,
+		taskRepo:    taskRepo,
+		logRepo:     logRepo,
+	}
+}
+
+// plannerDay resolves a YYYY-MM-DD date in a timezone, UTC when empty, to
+// the start and end of that day
+func plannerDay(date, timezone string) (time.Time, time.Time, string, error) {
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("%w: unknown timezone %q", ErrInvalidPlan, timezone)
+	}
+	start, err := time.ParseInLocation("2006-01-02", date, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidPlan)
+	}
+	return start, start.AddDate(0, 0, 1), timezone, nil
+}
+
+// clockMinutes parses HH:MM into minutes after midnight
+func clockMinutes(clock string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// workingIntervals turns the working hours of the day's weekday into times
+// on that day
+func workingIntervals(hours []models.WorkingHours, dayStart time.Time) []planner.Interval {
+	var intervals []planner.Interval
+	for _, h := range hours {
+		if h.Weekday != int(dayStart.Weekday()) {
+			continue
+		}
+		start, _ := clockMinutes(h.Start)
+		end, _ := clockMinutes(h.End)
+		y, m, d := dayStart.Date()
+		intervals = append(intervals, planner.Interval{
+			Start: time.Date(y, m, d, start/60, start%60, 0, 0, dayStart.Location()),
+			End:   time.Date(y, m, d, end/60, end%60, 0, 0, dayStart.Location()),
+		})
+	}
+	return intervals
+}
+
+// taskDeadline is when a task must be done by. A due date without a time of
+// day is stored as midnight UTC and leaves the whole of that day in the
+// plan's location.
+func taskDeadline(task *models.Task, location *time.Location) *time.Time {
+	if task.DueDate == nil {
+		return nil
+	}
+	deadline := task.DueDate.UTC()
+	if deadline.Equal(deadline.Truncate(24 * time.Hour)) {
+		year, month, day := deadline.Date()
+		deadline = time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	}
+	return &deadline
+}
+
+// GetWorkingHours returns the user's working hours, or the defaults when
+// they have set none
+func (s *plannerService) GetWorkingHours(userID int64) ([]models.WorkingHours, error) {
+	hours, err := s.plannerRepo.GetWorkingHours(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working hours: %w", err)
+	}
+	if len(hours) == 0 {
+		return defaultWorkingHours, nil
+	}
+	return hours, nil
+}
+
+// SetWorkingHours replaces the user's working hours. Stretches on the same
+// weekday must not overlap.
+func (s *plannerService) SetWorkingHours(userID int64, req *models.SetWorkingHoursRequest) ([]models.WorkingHours, error) {
+	hours := make([]models.WorkingHours, len(req.Hours))
+	for i, h := range req.Hours {
+		start, okStart := clockMinutes(h.Start)
+		end, okEnd := clockMinutes(h.End)
+		if !okStart || !okEnd {
+			return nil, fmt.Errorf("%w: working hours must be HH:MM", ErrInvalidPlan)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("%w: working hours on weekday %d end before they start", ErrInvalidPlan, h.Weekday)
+		}
+		hours[i] = models.WorkingHours{
+			Weekday: h.Weekday,
+			Start:   fmt.Sprintf("%02d:%02d", start/60, start%60),
+			End:     fmt.Sprintf("%02d:%02d", end/60, end%60),
+		}
+	}
+	// HH:MM strings sort like the times they stand for
+	sort.Slice(hours, func(i, j int) bool {
+		if hours[i].Weekday != hours[j].Weekday {
+			return hours[i].Weekday < hours[j].Weekday
+		}
+		return hours[i].Start < hours[j].Start
+	})
+	for i := 1; i < len(hours); i++ {
+		if hours[i].Weekday == hours[i-1].Weekday && hours[i].Start < hours[i-1].End {
+			return nil, fmt.Errorf("%w: working hours on weekday %d overlap", ErrInvalidPlan, hours[i].Weekday)
+		}
+	}
+
+	if err := s.plannerRepo.SetWorkingHours(userID, hours); err != nil {
+		return nil, fmt.Errorf("failed to set working hours: %w", err)
+	}
+	return s.GetWorkingHours(userID)
+}
+
+// GetTimeBlocks returns the blocks on a day in the given timezone
+func (s *plannerService) GetTimeBlocks(userID int64, date, timezone string) ([]*models.TimeBlock, error) {
+	dayStart, dayEnd, _, err := plannerDay(date, timezone)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.plannerRepo.GetTimeBlocks(userID, dayStart, dayEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// CreateTimeBlock blocks out time by hand, e.g. for a meeting, so plans
+// leave it free
+func (s *plannerService) CreateTimeBlock(userID int64, req *models.CreateTimeBlockRequest) (*models.TimeBlock, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" && req.TaskID == nil {
+		return nil, fmt.Errorf("%w: a time block needs a title or a task", ErrInvalidPlan)
+	}
+	if !req.EndsAt.After(req.StartsAt.Time) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPlan)
+	}
+	if req.TaskID != nil {
+		if _, err := s.taskRepo.GetTaskByID(*req.TaskID, userID); err != nil {
+			return nil, fmt.Errorf("task not found: %w", err)
+		}
+	}
+
+	block, err := s.plannerRepo.CreateTimeBlock(&models.TimeBlock{
+		UserID:   userID,
+		TaskID:   req.TaskID,
+		Title:    title,
+		StartsAt: req.StartsAt.Time,
+		EndsAt:   req.EndsAt.Time,
+		Source:   models.BlockManual,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create time block: %w", err)
+	}
+	return block, nil
+}
+
+func (s *plannerService) DeleteTimeBlock(blockID, userID int64) error {
+	err := s.plannerRepo.DeleteTimeBlock(blockID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrTimeBlockNotFound, blockID)
+	}
+	return err
+}
+
+// manualBlocks keeps the blocks added by hand; the day's planner blocks are
+// replaced when a new plan is accepted, so they do not count as busy
+func manualBlocks(blocks []*models.TimeBlock) []*models.TimeBlock {
+	manual := []*models.TimeBlock{}
+	for _, block := range blocks {
+		if block.Source == models.BlockManual {
+			manual = append(manual, block)
+		}
+	}
+	return manual
+}
+
+// PlanDay proposes a timeline for the day: open tasks with estimates are
+// placed in the working hours around the blocks added by hand, in the
+// order the planner package describes. On the current day only the time
+// still ahead is used. Nothing is saved.
+func (s *plannerService) PlanDay(userID int64, date, timezone string) (*models.DayPlan, error) {
+	dayStart, dayEnd, timezone, err := plannerDay(date, timezone)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !dayEnd.After(now) {
+		return nil, fmt.Errorf("%w: %s is in the past", ErrInvalidPlan, date)
+	}
+
+	hours, err := s.GetWorkingHours(userID)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.plannerRepo.GetTimeBlocks(userID, dayStart, dayEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time blocks: %w", err)
+	}
+	busy := manualBlocks(blocks)
+	tasks, err := s.taskRepo.GetOpenTasks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open tasks: %w", err)
+	}
+	openBlockers, err := s.taskRepo.GetOpenBlockers(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocked tasks: %w", err)
+	}
+	elsewhere, err := s.plannerRepo.GetPlannedElsewhere(userID, dayStart, dayEnd, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get planned tasks: %w", err)
+	}
+
+	plannedElsewhere := make(map[int64]bool, len(elsewhere))
+	for _, id := range elsewhere {
+		plannedElsewhere[id] = true
+	}
+	names := make(map[int64]string, len(tasks))
+	dayPlan := &models.DayPlan{
+		Date:         date,
+		Timezone:     timezone,
+		WorkingHours: []models.WorkingHours{},
+		Busy:         busy,
+		Blocks:       []*models.PlannedBlock{},
+		Unplanned:    []*models.UnplannedTask{},
+	}
+	var candidates []planner.Task
+	for _, task := range tasks {
+		names[task.ID] = task.TaskName
+		if plannedElsewhere[task.ID] {
+			dayPlan.Unplanned = append(dayPlan.Unplanned, &models.UnplannedTask{
+				TaskID:   task.ID,
+				TaskName: task.TaskName,
+				Reason:   reasonAlreadyPlanned,
+			})
+			continue
+		}
+		candidate := planner.Task{
+			ID:        task.ID,
+			Priority:  task.Priority,
+			Deadline:  taskDeadline(task, dayStart.Location()),
+			BlockedBy: openBlockers[task.ID],
+		}
+		if task.EstimateMinutes != nil {
+			candidate.Minutes = int(*task.EstimateMinutes)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	for _, h := range hours {
+		if h.Weekday == int(dayStart.Weekday()) {
+			dayPlan.WorkingHours = append(dayPlan.WorkingHours, h)
+		}
+	}
+	busyIntervals := make([]planner.Interval, len(busy))
+	for i, block := range busy {
+		busyIntervals[i] = planner.Interval{Start: block.StartsAt, End: block.EndsAt}
+	}
+	free := planner.FreeTime(workingIntervals(hours, dayStart), busyIntervals, now)
+
+	plan := planner.Schedule(candidates, free)
+	for _, block := range plan.Blocks {
+		dayPlan.Blocks = append(dayPlan.Blocks, &models.PlannedBlock{
+			TaskID:   block.TaskID,
+			TaskName: names[block.TaskID],
+			StartsAt: block.Start,
+			EndsAt:   block.End,
+			Late:     block.Late,
+		})
+		dayPlan.PlannedMinutes += int(block.End.Sub(block.Start) / time.Minute)
+	}
+	for _, skipped := range plan.Skipped {
+		dayPlan.Unplanned = append(dayPlan.Unplanned, &models.UnplannedTask{
+			TaskID:   skipped.TaskID,
+			TaskName: names[skipped.TaskID],
+			Reason:   skipped.Reason,
+		})
+	}
+	for _, iv := range plan.Free {
+		dayPlan.FreeMinutes += int(iv.Duration() / time.Minute)
+	}
+
+	return dayPlan, nil
+}
+
+// AcceptPlan saves the blocks of a plan, as proposed or adjusted by the
+// user, replacing the blocks of a plan accepted for the day before. Each
+// block must be for an open task, lie within the day and not overlap
+// another block or time blocked out by hand.
+func (s *plannerService) AcceptPlan(userID int64, req *models.AcceptPlanRequest) ([]*models.TimeBlock, error) {
+	dayStart, dayEnd, _, err := plannerDay(req.Date, req.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.GetOpenTasks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open tasks: %w", err)
+	}
+	open := make(map[int64]bool, len(tasks))
+	for _, task := range tasks {
+		open[task.ID] = true
+	}
+	existing, err := s.plannerRepo.GetTimeBlocks(userID, dayStart, dayEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time blocks: %w", err)
+	}
+
+	blocks := make([]*models.TimeBlock, 0, len(req.Blocks))
+	seen := make(map[int64]bool, len(req.Blocks))
+	for _, b := range req.Blocks {
+		if !open[b.TaskID] {
+			return nil, fmt.Errorf("%w: task %d is not an open task", ErrInvalidPlan, b.TaskID)
+		}
+		if seen[b.TaskID] {
+			return nil, fmt.Errorf("%w: task %d is planned twice", ErrInvalidPlan, b.TaskID)
+		}
+		seen[b.TaskID] = true
+		if !b.EndsAt.After(b.StartsAt) {
+			return nil, fmt.Errorf("%w: the block for task %d ends before it starts", ErrInvalidPlan, b.TaskID)
+		}
+		if b.StartsAt.Before(dayStart) || b.EndsAt.After(dayEnd) {
+			return nil, fmt.Errorf("%w: the block for task %d is not on %s", ErrInvalidPlan, b.TaskID, req.Date)
+		}
+		taskID := b.TaskID
+		blocks = append(blocks, &models.TimeBlock{UserID: userID, TaskID: &taskID, StartsAt: b.StartsAt, EndsAt: b.EndsAt})
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].StartsAt.Before(blocks[j].StartsAt)
+	})
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].StartsAt.Before(blocks[i-1].EndsAt) {
+			return nil, fmt.Errorf("%w: the blocks for tasks %d and %d overlap", ErrPlanConflict, *blocks[i-1].TaskID, *blocks[i].TaskID)
+		}
+	}
+	for _, block := range blocks {
+		for _, busy := range manualBlocks(existing) {
+			if block.StartsAt.Before(busy.EndsAt) && block.EndsAt.After(busy.StartsAt) {
+				return nil, fmt.Errorf("%w: the block for task %d overlaps '%s'", ErrPlanConflict, *block.TaskID, busy.Title)
+			}
+		}
+	}
+
+	saved, err := s.plannerRepo.ReplacePlannedBlocks(userID, dayStart, dayEnd, blocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save plan: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"date":   req.Date,
+		"blocks": len(saved),
+	}
+	s.logRepo.CreateLog(&userID, "plan_accepted", fmt.Sprintf("Planned %d task(s) for %s", len(saved), req.Date), metadata)
+
+	return saved, nil
+}
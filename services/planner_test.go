@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"todo-backend/models"
+)
+
+func TestTaskDeadline(t *testing.T) {
+	newYork := time.FixedZone("EST", -5*60*60)
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name     string
+		due      *time.Time
+		location *time.Location
+		want     *time.Time
+	}{
+		{
+			name:     "no due date",
+			location: time.UTC,
+		},
+		{
+			name:     "date only in UTC",
+			due:      ptrTime(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)),
+			location: time.UTC,
+			want:     ptrTime(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "date only west of UTC ends at local midnight",
+			due:      ptrTime(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)),
+			location: newYork,
+			want:     ptrTime(time.Date(2026, 10, 17, 0, 0, 0, 0, newYork)),
+		},
+		{
+			name:     "date only east of UTC ends at local midnight",
+			due:      ptrTime(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)),
+			location: tokyo,
+			want:     ptrTime(time.Date(2026, 10, 17, 0, 0, 0, 0, tokyo)),
+		},
+		{
+			name:     "date only at the end of a month",
+			due:      ptrTime(time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)),
+			location: newYork,
+			want:     ptrTime(time.Date(2026, 11, 1, 0, 0, 0, 0, newYork)),
+		},
+		{
+			name:     "a time of day is kept",
+			due:      ptrTime(time.Date(2026, 10, 16, 14, 30, 0, 0, time.UTC)),
+			location: newYork,
+			want:     ptrTime(time.Date(2026, 10, 16, 14, 30, 0, 0, time.UTC)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &models.Task{}
+			if tt.due != nil {
+				task.DueDate = &models.CustomTime{Time: *tt.due}
+			}
+			got := taskDeadline(task, tt.location)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.Equal(*tt.want):
+				t.Errorf("taskDeadline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrProjectNotFound is returned when a project does not exist or belongs to another user
+var ErrProjectNotFound = errors.New("project not found")
+
+// ErrInvalidProject is returned for invalid project fields and for tasks
+// added to a project that does not exist or is archived
+var ErrInvalidProject = errors.New("invalid project")
+
+// ErrProjectNameTaken is returned when the user already has a project with the name
+var ErrProjectNameTaken = errors.New("project name already in use")
+
+// ErrInvalidMove is returned when a task cannot be placed next to the given task
+var ErrInvalidMove = errors.New("invalid task move")
+
+var projectColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Project Service
+type ProjectService interface {
+	CreateProject(userID int64, req *models.CreateProjectRequest) (*models.Project, error)
+	GetProjects(userID int64, includeArchived bool) ([]*models.Project, error)
+	GetProject(projectID, userID int64) (*models.Project, error)
+	UpdateProject(projectID, userID int64, req *models.UpdateProjectRequest) (*models.Project, error)
+	DeleteProject(projectID, userID int64) error
+	GetProjectTasks(projectID, userID int64) ([]*models.Task, error)
+	MoveTask(taskID, userID int64, req *models.MoveTaskRequest) (*models.Task, error)
+}
+
+type projectService struct {
+	projectRepo  repositories.ProjectRepository
+	taskRepo     repositories.TaskRepository
+	logRepo      repositories.LogRepository
+	revisionRepo repositories.RevisionRepository
+}
+
+func NewProjectService(projectRepo repositories.ProjectRepository, taskRepo repositories.TaskRepository, logRepo repositories.LogRepository, revisionRepo repositories.RevisionRepository) ProjectService {
+	return &projectService{
+		projectRepo:  projectRepo,
+		taskRepo:     taskRepo,
+		logRepo:      logRepo,
+		revisionRepo: revisionRepo,
+	}
+}
+
+// optionalText trims a text field; blank text reads as no value
+func optionalText(text *string) *string {
+	if text == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*text)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func validateProjectColor(color *string) error {
+	if color != nil && !projectColorPattern.MatchString(*color) {
+		return fmt.Errorf("%w: color %q is not a #RRGGBB hex color", ErrInvalidProject, *color)
+	}
+	return nil
+}
+
+// checkProjectForTasks makes sure tasks can be added to a project: it exists,
+// belongs to the user and is not archived
+func checkProjectForTasks(projectRepo repositories.ProjectRepository, projectID, userID int64) error {
+	project, err := projectRepo.GetProjectByID(projectID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: project %d not found", ErrInvalidProject, projectID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+	if project.IsArchived {
+		return fmt.Errorf("%w: project '%s' is archived", ErrInvalidProject, project.Name)
+	}
+	return nil
+}
+
+// checkProjectName makes sure the user has no other project with the name
+func (s *projectService) checkProjectName(userID int64, name string, projectID int64) error {
+	existing, err := s.projectRepo.GetProjectByName(userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check project name: %w", err)
+	}
+	if existing.ID != projectID {
+		return fmt.Errorf("%w: '%s'", ErrProjectNameTaken, existing.Name)
+	}
+	return nil
+}
+
+func (s *projectService) CreateProject(userID int64, req *models.CreateProjectRequest) (*models.Project, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidProject)
+	}
+	color := optionalText(req.Color)
+	if err := validateProjectColor(color); err != nil {
+		return nil, err
+	}
+	if err := s.checkProjectName(userID, name, 0); err != nil {
+		return nil, err
+	}
+
+	project, err := s.projectRepo.CreateProject(&models.Project{
+		UserID:      userID,
+		Name:        name,
+		Color:       color,
+		Description: optionalText(req.Description),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"project_id": project.ID,
+		"name":       project.Name,
+	}
+	s.logRepo.CreateLog(&userID, "project_created", fmt.Sprintf("Project '%s' created", project.Name), metadata)
+
+	return project, nil
+}
+
+func (s *projectService) GetProjects(userID int64, includeArchived bool) ([]*models.Project, error) {
+	projects, err := s.projectRepo.GetProjects(userID, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects: %w", err)
+	}
+	return projects, nil
+}
+
+func (s *projectService) GetProject(projectID, userID int64) (*models.Project, error) {
+	project, err := s.projectRepo.GetProjectByID(projectID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrProjectNotFound, projectID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	return project, nil
+}
+
+func (s *projectService) UpdateProject(projectID, userID int64, req *models.UpdateProjectRequest) (*models.Project, error) {
+	project, err := s.GetProject(projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidProject)
+		}
+		if err := s.checkProjectName(userID, name, projectID); err != nil {
+			return nil, err
+		}
+		project.Name = name
+	}
+	if req.Color != nil {
+		color := optionalText(req.Color)
+		if err := validateProjectColor(color); err != nil {
+			return nil, err
+		}
+		project.Color = color
+	}
+	if req.Description != nil {
+		project.Description = optionalText(req.Description)
+	}
+	if req.IsArchived != nil {
+		project.IsArchived = *req.IsArchived
+	}
+
+	updatedProject, err := s.projectRepo.UpdateProject(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"project_id":  projectID,
+		"name":        updatedProject.Name,
+		"is_archived": updatedProject.IsArchived,
+	}
+	s.logRepo.CreateLog(&userID, "project_updated", fmt.Sprintf("Project '%s' updated", updatedProject.Name), metadata)
+
+	return updatedProject, nil
+}
+
+// DeleteProject deletes a project; its tasks are kept without a project
+func (s *projectService) DeleteProject(projectID, userID int64) error {
+	project, err := s.GetProject(projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.projectRepo.DeleteProject(projectID, userID); err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"project_id": projectID,
+		"name":       project.Name,
+		"task_count": project.TaskCount,
+	}
+	s.logRepo.CreateLog(&userID, "project_deleted", fmt.Sprintf("Project '%s' deleted", project.Name), metadata)
+
+	return nil
+}
+
+func (s *projectService) GetProjectTasks(projectID, userID int64) ([]*models.Task, error) {
+	if _, err := s.GetProject(projectID, userID); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.GetProjectTasks(projectID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// MoveTask changes a task's project and position in one step
+func (s *projectService) MoveTask(taskID, userID int64, req *models.MoveTaskRequest) (*models.Task, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	projectID := task.ProjectID
+	if req.ProjectID != nil {
+		projectID = req.ProjectID
+		if *req.ProjectID == 0 {
+			projectID = nil
+		}
+	}
+	if req.AfterID != nil && req.BeforeID != nil {
+		return nil, fmt.Errorf("%w: give after_id or before_id, not both", ErrInvalidMove)
+	}
+	anchorID := req.AfterID
+	if anchorID == nil {
+		anchorID = req.BeforeID
+	}
+
+	if projectID == nil {
+		if anchorID != nil {
+			return nil, fmt.Errorf("%w: a task outside a project has no position", ErrInvalidMove)
+		}
+	} else {
+		if err := checkProjectForTasks(s.projectRepo, *projectID, userID); err != nil {
+			return nil, err
+		}
+		if anchorID != nil {
+			if *anchorID == taskID {
+				return nil, fmt.Errorf("%w: a task cannot be placed next to itself", ErrInvalidMove)
+			}
+			anchor, err := s.taskRepo.GetTaskByID(*anchorID, userID)
+			if err != nil || anchor.ProjectID == nil || *anchor.ProjectID != *projectID {
+				return nil, fmt.Errorf("%w: task %d is not in project %d", ErrInvalidMove, *anchorID, *projectID)
+			}
+		}
+	}
+
+	before := snapshot(task)
+	movedTask, err := s.taskRepo.MoveTask(taskID, userID, projectID, req.AfterID, req.BeforeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move task: %w", err)
+	}
+	recordRevision(s.revisionRepo, userID, models.RevisionTask, taskID, models.RevisionUpdate, before, snapshot(movedTask), nil)
+
+	metadata := map[string]interface{}{
+		"task_id":         taskID,
+		"task_name":       movedTask.TaskName,
+		"from_project_id": task.ProjectID,
+		"to_project_id":   movedTask.ProjectID,
+		"position":        movedTask.Position,
+	}
+	s.logRepo.CreateLog(&userID, "task_moved", fmt.Sprintf("Task '%s' moved", movedTask.TaskName), metadata)
+
+	return movedTask, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todo-backend/models"
+	"todo-backend/quickadd"
+)
+
+// ErrInvalidQuickAdd is returned when a quick-add line cannot be turned into a task
+var ErrInvalidQuickAdd = errors.New("invalid quick-add text")
+
+// QuickAddTask parses one line of text into a task and creates it. Relative
+// dates are resolved in the request's timezone. The parse result is returned
+// alongside the task so the client can show what was recognised.
+func (s *taskService) QuickAddTask(userID int64, req *models.QuickAddRequest) (*models.Task, *quickadd.Result, error) {
+	location := time.UTC
+	if tz := strings.TrimSpace(req.Timezone); tz != "" {
+		loaded, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidQuickAdd, tz)
+		}
+		location = loaded
+	}
+
+	locale, ok := quickadd.Lookup(req.Locale)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unknown locale %q", ErrInvalidQuickAdd, req.Locale)
+	}
+
+	parsed, err := quickadd.Parse(req.Text, time.Now().In(location), locale)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidQuickAdd, err)
+	}
+
+	createReq := &models.CreateTaskRequest{
+		TaskName:           parsed.Name,
+		Category:           parsed.Category,
+		RecurringFrequency: parsed.RecurringFrequency,
+		IsRecurring:        parsed.RecurringFrequency != nil,
+	}
+	if parsed.Priority != nil {
+		createReq.Priority = *parsed.Priority
+	}
+	if parsed.DueDate != nil {
+		createReq.DueDate = &models.CustomTime{Time: *parsed.DueDate}
+	}
+
+	task, err := s.CreateTask(userID, createReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, parsed, nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"todo-backend/models"
+	"todo-backend/recurrence"
+)
+
+// ErrInvalidRecurrence is returned when a task's recurring_frequency cannot be parsed
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+const maxPreviewOccurrences = 100
+
+func validateRecurrence(isRecurring bool, frequency *string) error {
+	if !isRecurring {
+		return nil
+	}
+	if frequency == nil || *frequency == "" {
+		return fmt.Errorf("%w: recurring tasks need a recurring_frequency", ErrInvalidRecurrence)
+	}
+	if _, err := recurrence.Parse(*frequency); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return nil
+}
+
+// taskRule returns the parsed rule of a recurring task, or nil if the task does not recur
+func taskRule(task *models.Task) (*recurrence.Rule, error) {
+	if !task.IsRecurring || task.RecurringFrequency == nil || *task.RecurringFrequency == "" {
+		return nil, nil
+	}
+	rule, err := recurrence.Parse(*task.RecurringFrequency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return rule, nil
+}
+
+// recurrenceAnchor is the occurrence the next one is computed from. Tasks
+// without a due date recur relative to the moment they were completed.
+func recurrenceAnchor(task *models.Task) time.Time {
+	if task.DueDate != nil && !task.DueDate.Time.IsZero() {
+		return task.DueDate.Time
+	}
+	return time.Now()
+}
+
+// anchorRule anchors rule at the due date of the first occurrence of task's
+// series, so that legacy monthly rules return to the 31st after clamping to
+// a shorter month. A series whose first occurrence is gone stays unanchored.
+func (s *taskService) anchorRule(rule *recurrence.Rule, task *models.Task) {
+	first := task
+	if task.RecurrenceParentID != nil {
+		root, err := s.taskRepo.GetRecurrenceInstance(*task.RecurrenceParentID, task.UserID, 1)
+		if err != nil {
+			return
+		}
+		first = root
+	}
+	if first.DueDate != nil && !first.DueDate.Time.IsZero() {
+		rule.Anchor(first.DueDate.Time)
+	}
+}
+
+// createNextOccurrence creates the task that follows a just-completed
+// recurring task. It is idempotent: completing, reopening and completing the
+// same occurrence again returns the instance created the first time.
+func (s *taskService) createNextOccurrence(task *models.Task) (*models.Task, error) {
+	rule, err := taskRule(task)
+	if err != nil || rule == nil {
+		// Legacy rows may hold frequencies we can no longer parse; leave them alone
+		return nil, nil
+	}
+	s.anchorRule(rule, task)
+
+	index := task.RecurrenceIndex
+	if index < 1 {
+		index = 1
+	}
+	upcoming := rule.Upcoming(recurrenceAnchor(task), int(index), 1)
+	if len(upcoming) == 0 {
+		return nil, nil
+	}
+
+	seriesID := task.ID
+	if task.RecurrenceParentID != nil {
+		seriesID = *task.RecurrenceParentID
+	}
+
+	existing, err := s.taskRepo.GetRecurrenceInstance(seriesID, task.UserID, index+1)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to look up next occurrence: %w", err)
+	}
+
+	nextTask := &models.Task{
+		UserID:             task.UserID,
+		TaskName:           task.TaskName,
+		Description:        task.Description,
+		Category:           task.Category,
+		Priority:           task.Priority,
+		DueDate:            &models.CustomTime{Time: upcoming[0]},
+		IsRecurring:        true,
+		RecurringFrequency: task.RecurringFrequency,
+		RecurrenceParentID: &seriesID,
+		RecurrenceIndex:    index + 1,
+		ParentID:           task.ParentID,
+		EstimateMinutes:    task.EstimateMinutes,
+		ProjectID:          task.ProjectID,
+		IsImportant:        task.IsImportant,
+	}
+
+	createdTask, err := s.taskRepo.CreateTask(nextTask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create next occurrence: %w", err)
+	}
+	if len(task.Tags) > 0 {
+		createdTask, err = s.setTaskTags(createdTask, task.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Log occurrence generation
+	metadata := map[string]interface{}{
+		"task_id":          createdTask.ID,
+		"task_name":        createdTask.TaskName,
+		"previous_task_id": task.ID,
+		"series_id":        seriesID,
+		"occurrence":       createdTask.RecurrenceIndex,
+		"due_date":         upcoming[0],
+	}
+	s.logRepo.CreateLog(&task.UserID, "task_recurrence_generated", fmt.Sprintf("Next occurrence of '%s' scheduled for %s", createdTask.TaskName, upcoming[0].Format("2006-01-02")), metadata)
+
+	return createdTask, nil
+}
+
+func clampPreviewCount(count int) int {
+	if count < 1 {
+		return 5
+	}
+	if count > maxPreviewOccurrences {
+		return maxPreviewOccurrences
+	}
+	return count
+}
+
+// PreviewTaskOccurrences lists the next occurrences of a recurring task after its current due date
+func (s *taskService) PreviewTaskOccurrences(taskID, userID int64, count int) (*models.RecurrencePreview, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	rule, err := taskRule(task)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("%w: task is not recurring", ErrInvalidRecurrence)
+	}
+	s.anchorRule(rule, task)
+
+	index := task.RecurrenceIndex
+	if index < 1 {
+		index = 1
+	}
+	anchor := recurrenceAnchor(task)
+
+	return &models.RecurrencePreview{
+		Rule:        rule.String(),
+		From:        &models.CustomTime{Time: anchor},
+		Occurrences: rule.Upcoming(anchor, int(index), clampPreviewCount(count)),
+	}, nil
+}
+
+// PreviewRecurrence evaluates a rule that has not been saved yet, treating
+// start as its first occurrence
+func (s *taskService) PreviewRecurrence(req *models.PreviewRecurrenceRequest) (*models.RecurrencePreview, error) {
+	rule, err := recurrence.Parse(req.Rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	start := req.Start.Time
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	occurrences := []time.Time{}
+	if rule.Until == nil || !start.After(*rule.Until) {
+		count := clampPreviewCount(req.Count)
+		occurrences = append(occurrences, start)
+		occurrences = append(occurrences, rule.Upcoming(start, 1, count-1)...)
+	}
+
+	return &models.RecurrencePreview{
+		Rule:        rule.String(),
+		From:        &models.CustomTime{Time: start},
+		Occurrences: occurrences,
+	}, nil
+}
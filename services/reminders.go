@@ -0,0 +1,393 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"todo-backend/models"
+	"todo-backend/notify"
+	"todo-backend/repositories"
+)
+
+// ErrReminderNotFound is returned when a reminder does not exist or belongs
+// to another user
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// ErrInvalidReminder is returned for reminders that cannot be scheduled, such
+// as one in the past or on a channel that is not configured
+var ErrInvalidReminder = errors.New("invalid reminder")
+
+// ErrNotificationNotFound is returned when a notification does not exist or
+// belongs to another user
+var ErrNotificationNotFound = errors.New("notification not found")
+
+const (
+	// reminderBatch is how many reminders are scheduled per query
+	reminderBatch = 100
+	// deliveryBatch is how many deliveries are claimed at a time. A send can
+	// take up to a minute (the SMTP dial and session timeouts), so a batch is
+	// sent well inside deliveryLease and no delivery is reclaimed mid-batch.
+	deliveryBatch = 2
+	// deliveryLease is how long a claimed delivery is left to its instance
+	// before another one may retry it
+	deliveryLease = 5 * time.Minute
+	// deliveryMaxAttempts is how often a delivery is tried before it fails
+	deliveryMaxAttempts = 5
+)
+
+// Reminder Service
+type ReminderService interface {
+	CreateTaskReminder(taskID, userID int64, req *models.CreateTaskReminderRequest) (*models.Reminder, error)
+	CreateHabitReminder(habitID, userID int64, req *models.CreateHabitReminderRequest) (*models.Reminder, error)
+	GetReminders(userID int64) ([]*models.Reminder, error)
+	GetTaskReminders(taskID, userID int64) ([]*models.Reminder, error)
+	GetHabitReminders(habitID, userID int64) ([]*models.Reminder, error)
+	DeleteReminder(reminderID, userID int64) error
+	SnoozeReminder(reminderID, userID int64, minutes int) (*models.Reminder, error)
+	DismissReminder(reminderID, userID int64) (*models.Reminder, error)
+	GetNotifications(userID int64, unreadOnly bool, limit int) ([]*models.Notification, int64, error)
+	MarkNotificationRead(notificationID, userID int64) error
+	MarkAllNotificationsRead(userID int64) (int64, error)
+	ProcessDue() (int, error)
+	RunScheduler(interval time.Duration)
+}
+
+type reminderService struct {
+	reminderRepo     repositories.ReminderRepository
+	notificationRepo repositories.NotificationRepository
+	taskRepo         repositories.TaskRepository
+	habitRepo        repositories.HabitRepository
+	logRepo          repositories.LogRepository
+	notifiers        map[string]notify.Notifier
+}
+
+// NewReminderService creates the reminder service. The in-app inbox is always
+// available; notifiers add the outside channels that are configured.
+func NewReminderService(reminderRepo repositories.ReminderRepository, notificationRepo repositories.NotificationRepository, taskRepo repositories.TaskRepository, habitRepo repositories.HabitRepository, logRepo repositories.LogRepository, notifiers ...notify.Notifier) ReminderService {
+	s := &reminderService{
+		reminderRepo:     reminderRepo,
+		notificationRepo: notificationRepo,
+		taskRepo:         taskRepo,
+		habitRepo:        habitRepo,
+		logRepo:          logRepo,
+		notifiers:        map[string]notify.Notifier{},
+	}
+	for _, notifier := range append([]notify.Notifier{&inboxNotifier{notificationRepo}}, notifiers...) {
+		s.notifiers[notifier.Channel()] = notifier
+	}
+	return s
+}
+
+// inboxNotifier delivers reminders to the in-app notification inbox
+type inboxNotifier struct {
+	notificationRepo repositories.NotificationRepository
+}
+
+func (n *inboxNotifier) Channel() string {
+	return models.ChannelInApp
+}
+
+func (n *inboxNotifier) Notify(msg *notify.Message) error {
+	return n.notificationRepo.CreateNotification(&models.Notification{
+		UserID:     msg.UserID,
+		ReminderID: &msg.ReminderID,
+		TaskID:     msg.TaskID,
+		HabitID:    msg.HabitID,
+		Title:      msg.Title,
+		Body:       msg.Body,
+	}, msg.DeliveryID)
+}
+
+// reminderChannel defaults an empty channel to the inbox and checks that a
+// notifier is configured for it
+func (s *reminderService) reminderChannel(channel string) (string, error) {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if channel == "" {
+		return models.ChannelInApp, nil
+	}
+	if _, ok := s.notifiers[channel]; !ok {
+		return "", fmt.Errorf("%w: channel %q is not available", ErrInvalidReminder, channel)
+	}
+	return channel, nil
+}
+
+func reminderError(err error, reminderID int64) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrReminderNotFound, reminderID)
+	}
+	return err
+}
+
+// CreateTaskReminder adds a reminder at a fixed time or relative to the
+// task's due date. A relative reminder follows the due date when it changes,
+// and stays quiet while the task has none.
+func (s *reminderService) CreateTaskReminder(taskID, userID int64, req *models.CreateTaskReminderRequest) (*models.Reminder, error) {
+	if (req.RemindAt == nil) == (req.OffsetMinutes == nil) {
+		return nil, fmt.Errorf("%w: give exactly one of remind_at and offset_minutes", ErrInvalidReminder)
+	}
+	channel, err := s.reminderChannel(req.Channel)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	reminder := &models.Reminder{
+		UserID:        userID,
+		TaskID:        &taskID,
+		OffsetMinutes: req.OffsetMinutes,
+		Timezone:      "UTC",
+		Channel:       channel,
+	}
+	if req.RemindAt != nil {
+		if !req.RemindAt.After(time.Now()) {
+			return nil, fmt.Errorf("%w: remind_at is in the past", ErrInvalidReminder)
+		}
+		remindAt := req.RemindAt.Time
+		reminder.RemindAt = &remindAt
+	}
+
+	reminder, err = s.reminderRepo.CreateReminder(reminder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reminder: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":     taskID,
+		"reminder_id": reminder.ID,
+		"channel":     reminder.Channel,
+	}
+	s.logRepo.CreateLog(&userID, "reminder_created", fmt.Sprintf("Set a reminder on task '%s'", task.TaskName), metadata)
+
+	return reminder, nil
+}
+
+// CreateHabitReminder adds a daily cue at a wall-clock time in the given
+// timezone, so it keeps its local time across daylight saving changes
+func (s *reminderService) CreateHabitReminder(habitID, userID int64, req *models.CreateHabitReminderRequest) (*models.Reminder, error) {
+	cue, err := time.Parse("15:04", strings.TrimSpace(req.CueTime))
+	if err != nil {
+		return nil, fmt.Errorf("%w: cue_time must be HH:MM", ErrInvalidReminder)
+	}
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	// "Local" means the server's zone, which the database does not know by that name
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidReminder, timezone)
+	}
+	channel, err := s.reminderChannel(req.Channel)
+	if err != nil {
+		return nil, err
+	}
+	habit, err := s.habitRepo.GetHabitByID(habitID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("habit not found: %w", err)
+	}
+
+	cueTime := cue.Format("15:04")
+	reminder, err := s.reminderRepo.CreateReminder(&models.Reminder{
+		UserID:   userID,
+		HabitID:  &habitID,
+		CueTime:  &cueTime,
+		Timezone: timezone,
+		Channel:  channel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reminder: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"habit_id":    habitID,
+		"reminder_id": reminder.ID,
+		"channel":     reminder.Channel,
+	}
+	s.logRepo.CreateLog(&userID, "reminder_created", fmt.Sprintf("Set a daily cue for habit '%s'", habit.Name), metadata)
+
+	return reminder, nil
+}
+
+func (s *reminderService) GetReminders(userID int64) ([]*models.Reminder, error) {
+	reminders, err := s.reminderRepo.GetReminders(userID, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+func (s *reminderService) GetTaskReminders(taskID, userID int64) ([]*models.Reminder, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+	reminders, err := s.reminderRepo.GetReminders(userID, &taskID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+func (s *reminderService) GetHabitReminders(habitID, userID int64) ([]*models.Reminder, error) {
+	if _, err := s.habitRepo.GetHabitByID(habitID, userID); err != nil {
+		return nil, fmt.Errorf("habit not found: %w", err)
+	}
+	reminders, err := s.reminderRepo.GetReminders(userID, nil, &habitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+func (s *reminderService) DeleteReminder(reminderID, userID int64) error {
+	if err := s.reminderRepo.DeleteReminder(reminderID, userID); err != nil {
+		return reminderError(err, reminderID)
+	}
+	return nil
+}
+
+// SnoozeReminder makes a reminder go off again minutes from now
+func (s *reminderService) SnoozeReminder(reminderID, userID int64, minutes int) (*models.Reminder, error) {
+	until := time.Now().Add(time.Duration(minutes) * time.Minute)
+	reminder, err := s.reminderRepo.SnoozeReminder(reminderID, userID, until)
+	if err != nil {
+		return nil, reminderError(err, reminderID)
+	}
+	return reminder, nil
+}
+
+// DismissReminder turns a task reminder off, or skips a habit's next cue
+func (s *reminderService) DismissReminder(reminderID, userID int64) (*models.Reminder, error) {
+	reminder, err := s.reminderRepo.DismissReminder(reminderID, userID)
+	if err != nil {
+		return nil, reminderError(err, reminderID)
+	}
+	return reminder, nil
+}
+
+// GetNotifications returns the newest notifications and the unread count
+func (s *reminderService) GetNotifications(userID int64, unreadOnly bool, limit int) ([]*models.Notification, int64, error) {
+	notifications, err := s.notificationRepo.GetNotifications(userID, unreadOnly, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	unread, err := s.notificationRepo.GetUnreadCount(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return notifications, unread, nil
+}
+
+func (s *reminderService) MarkNotificationRead(notificationID, userID int64) error {
+	err := s.notificationRepo.MarkNotificationRead(notificationID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrNotificationNotFound, notificationID)
+	}
+	return err
+}
+
+func (s *reminderService) MarkAllNotificationsRead(userID int64) (int64, error) {
+	return s.notificationRepo.MarkAllNotificationsRead(userID)
+}
+
+// reminderMessage composes the message for a delivery, showing the due date
+// in the reminder's timezone
+func reminderMessage(d *models.ReminderDelivery) *notify.Message {
+	msg := &notify.Message{
+		DeliveryID: d.ID,
+		ReminderID: d.ReminderID,
+		UserID:     d.UserID,
+		Title:      "Reminder: " + d.Subject,
+		TaskID:     d.TaskID,
+		HabitID:    d.HabitID,
+		FireAt:     d.FireAt,
+	}
+	if d.Email != nil {
+		msg.Email = *d.Email
+	}
+
+	location, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	switch {
+	case d.HabitID != nil:
+		msg.Body = fmt.Sprintf("Time for your habit '%s'.", d.Subject)
+	case d.DueDate != nil:
+		msg.Body = fmt.Sprintf("Task '%s' is due %s.", d.Subject, d.DueDate.In(location).Format("Mon Jan 2, 2006 15:04 MST"))
+	default:
+		msg.Body = fmt.Sprintf("Task '%s' is waiting for you.", d.Subject)
+	}
+	return msg
+}
+
+// deliver sends one claimed delivery and records the outcome. Failures are
+// retried with a growing delay until deliveryMaxAttempts, except those the
+// notifier reports as permanent.
+func (s *reminderService) deliver(d *models.ReminderDelivery) error {
+	var err error
+	if notifier, ok := s.notifiers[d.Channel]; ok {
+		err = notifier.Notify(reminderMessage(d))
+	} else {
+		err = fmt.Errorf("%w: channel %q is not configured", notify.ErrPermanent, d.Channel)
+	}
+	if err == nil {
+		return s.reminderRepo.CompleteDelivery(d.ID)
+	}
+
+	var retryAt *time.Time
+	if !errors.Is(err, notify.ErrPermanent) && d.Attempts < deliveryMaxAttempts {
+		next := time.Now().Add(time.Duration(d.Attempts*d.Attempts) * time.Minute)
+		retryAt = &next
+	}
+	log.Printf("Reminder delivery %d over %s failed (attempt %d): %v", d.ID, d.Channel, d.Attempts, err)
+	return s.reminderRepo.FailDelivery(d.ID, err.Error(), retryAt)
+}
+
+// ProcessDue turns due reminders into deliveries and sends every delivery
+// that is ready, returning how many were attempted. It is safe to run on
+// several instances at once: reminders and deliveries are locked and leased
+// in the database, so each firing is delivered by one instance only.
+func (s *reminderService) ProcessDue() (int, error) {
+	for {
+		scheduled, err := s.reminderRepo.ScheduleDueReminders(reminderBatch)
+		if err != nil {
+			return 0, fmt.Errorf("failed to schedule reminders: %w", err)
+		}
+		if scheduled < reminderBatch {
+			break
+		}
+	}
+
+	attempted := 0
+	for {
+		deliveries, err := s.reminderRepo.ClaimDeliveries(deliveryBatch, deliveryLease)
+		if err != nil {
+			return attempted, fmt.Errorf("failed to claim deliveries: %w", err)
+		}
+		for _, d := range deliveries {
+			if err := s.deliver(d); err != nil {
+				return attempted, fmt.Errorf("failed to record delivery %d: %w", d.ID, err)
+			}
+			attempted++
+		}
+		if len(deliveries) < deliveryBatch {
+			return attempted, nil
+		}
+	}
+}
+
+// RunScheduler calls ProcessDue now and then every interval. It never
+// returns and is meant to run in its own goroutine.
+func (s *reminderService) RunScheduler(interval time.Duration) {
+	for {
+		if _, err := s.ProcessDue(); err != nil {
+			log.Printf("Reminder scheduler failed: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
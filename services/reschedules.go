@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"todo-backend/models"
+)
+
+// RescheduleTask moves a task to a new due date and records the old date,
+// the new date and the reason in the task's reschedule history
+func (s *taskService) RescheduleTask(taskID, userID int64, req *models.RescheduleTaskRequest) (*models.Task, *models.TaskReschedule, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	reason := req.Reason
+	if reason != nil {
+		trimmed := strings.TrimSpace(*reason)
+		if trimmed == "" {
+			reason = nil
+		} else {
+			reason = &trimmed
+		}
+	}
+
+	task, reschedule, err := s.taskRepo.RescheduleTask(taskID, userID, req.NewDueDate.Time, reason)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reschedule task: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":        task.ID,
+		"task_name":      task.TaskName,
+		"old_due_date":   reschedule.OldDueDate,
+		"new_due_date":   reschedule.NewDueDate,
+		"reason":         reason,
+		"postpone_count": task.PostponeCount,
+	}
+	s.logRepo.CreateLog(&userID, "task_rescheduled", fmt.Sprintf("Task '%s' rescheduled", task.TaskName), metadata)
+
+	return task, reschedule, nil
+}
+
+func (s *taskService) GetTaskReschedules(taskID, userID int64) ([]*models.TaskReschedule, error) {
+	// Make sure the task exists and belongs to the user
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	reschedules, err := s.taskRepo.GetTaskReschedules(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reschedules: %w", err)
+	}
+	return reschedules, nil
+}
+
+// GetPostponementReport lists the open tasks postponed at least minCount
+// times and the average slip per category of the reschedules since a date
+func (s *taskService) GetPostponementReport(userID int64, minCount int, since time.Time) (*models.PostponementReport, error) {
+	tasks, err := s.taskRepo.GetPostponedTasks(userID, minCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get postponed tasks: %w", err)
+	}
+
+	categories, err := s.taskRepo.GetRescheduleSlipByCategory(userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slip by category: %w", err)
+	}
+
+	return &models.PostponementReport{
+		Since:                since,
+		MinPostponements:     minCount,
+		ChronicallyPostponed: tasks,
+		Categories:           categories,
+	}, nil
+}
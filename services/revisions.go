@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrRevisionNotFound is returned when a task, habit or goal has no revision with the requested number
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// snapshot encodes an entity as it is stored in the revision history
+func snapshot(entity interface{}) json.RawMessage {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		log.Printf("Failed to encode revision snapshot: %v", err)
+		return nil
+	}
+	return data
+}
+
+// recordRevision appends a revision to an entity's history. Like the activity
+// log it is best effort: a failure is logged and does not fail the change.
+// baseline is the state before the change, stored only for entities that have
+// no history yet.
+func recordRevision(revisionRepo repositories.RevisionRepository, userID int64, entityType string, entityID int64, action string, baseline, state json.RawMessage, restoredFrom *int) *models.Revision {
+	if state == nil {
+		return nil
+	}
+
+	revision, err := revisionRepo.AddRevision(&models.Revision{
+		EntityType:   entityType,
+		EntityID:     entityID,
+		UserID:       userID,
+		Action:       action,
+		Snapshot:     state,
+		RestoredFrom: restoredFrom,
+	}, baseline)
+	if err != nil {
+		log.Printf("Failed to record %s revision of %s %d: %v", action, entityType, entityID, err)
+		return nil
+	}
+	return revision
+}
+
+// getRevisions returns an entity's history with each revision diffed
+// against the one before it
+func getRevisions(revisionRepo repositories.RevisionRepository, entityType string, entityID, userID int64) ([]*models.Revision, error) {
+	revisions, err := revisionRepo.GetRevisions(entityType, entityID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revisions: %w", err)
+	}
+
+	for i, revision := range revisions {
+		if i == 0 {
+			revision.Changes = []models.FieldChange{}
+			continue
+		}
+		changes, err := diffSnapshots(revisions[i-1].Snapshot, revision.Snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff revision %d: %w", revision.Revision, err)
+		}
+		revision.Changes = changes
+	}
+	return revisions, nil
+}
+
+// getRevision loads one revision and decodes its snapshot into entity
+func getRevision(revisionRepo repositories.RevisionRepository, entityType string, entityID, userID int64, number int, entity interface{}) (*models.Revision, error) {
+	revision, err := revisionRepo.GetRevision(entityType, entityID, userID, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s %d has no revision %d", ErrRevisionNotFound, entityType, entityID, number)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+
+	if err := json.Unmarshal(revision.Snapshot, entity); err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d: %w", number, err)
+	}
+	return revision, nil
+}
+
+// diffSnapshots lists the top-level fields that differ between two
+// snapshots, in field name order
+func diffSnapshots(previous, current json.RawMessage) ([]models.FieldChange, error) {
+	var before, after map[string]json.RawMessage
+	if err := json.Unmarshal(previous, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(current, &after); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(after))
+	for field := range after {
+		fields = append(fields, field)
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []models.FieldChange{}
+	for _, field := range fields {
+		oldValue, newValue := jsonValue(before[field]), jsonValue(after[field])
+		if bytes.Equal(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: field, Old: oldValue, New: newValue})
+	}
+	return changes, nil
+}
+
+// jsonValue compacts a JSON value so that equal values compare equal;
+// a missing field reads as null
+func jsonValue(raw json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return json.RawMessage("null")
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+func (s *taskService) GetTaskRevisions(taskID, userID int64) ([]*models.Revision, error) {
+	// Make sure the task exists and belongs to the user
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+	return getRevisions(s.revisionRepo, models.RevisionTask, taskID, userID)
+}
+
+// RestoreTaskRevision puts a task's fields, tags and status back to what they
+// were at a revision. The restore is itself recorded as a new revision.
+func (s *taskService) RestoreTaskRevision(taskID, userID int64, number int) (*models.Task, *models.Revision, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	var target models.Task
+	if _, err := getRevision(s.revisionRepo, models.RevisionTask, taskID, userID, number, &target); err != nil {
+		return nil, nil, err
+	}
+
+	if target.ParentID != nil && (task.ParentID == nil || *task.ParentID != *target.ParentID) {
+		if err := s.validateParent(taskID, userID, *target.ParentID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if target.Status != task.Status && !canTransition(task.Status, target.Status) {
+		return nil, nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, task.Status, target.Status)
+	}
+
+	before := snapshot(task)
+	currentStatus := task.Status
+	task.TaskName = target.TaskName
+	task.Description = target.Description
+	task.Category = target.Category
+	task.Priority = target.Priority
+	task.DueDate = target.DueDate
+	task.IsRecurring = target.IsRecurring
+	task.RecurringFrequency = target.RecurringFrequency
+	task.ParentID = target.ParentID
+	task.EstimateMinutes = target.EstimateMinutes
+	task.IsImportant = target.IsImportant
+
+	restoredTask, err := s.taskRepo.UpdateTask(task)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to restore task: %w", err)
+	}
+	restoredTask, err = s.setTaskTags(restoredTask, target.Tags)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target.Status != currentStatus {
+		result, err := s.transitionTask(restoredTask, target.Status)
+		if err != nil {
+			return nil, nil, err
+		}
+		restoredTask = result.Task
+	}
+
+	revision := recordRevision(s.revisionRepo, userID, models.RevisionTask, taskID, models.RevisionRestore, before, snapshot(restoredTask), &number)
+
+	metadata := map[string]interface{}{
+		"task_id":   taskID,
+		"task_name": restoredTask.TaskName,
+		"revision":  number,
+	}
+	s.logRepo.CreateLog(&userID, "task_revision_restored", fmt.Sprintf("Task '%s' restored to revision %d", restoredTask.TaskName, number), metadata)
+
+	return restoredTask, revision, nil
+}
+
+func (s *habitService) GetHabitRevisions(habitID, userID int64) ([]*models.Revision, error) {
+	// Make sure the habit exists and belongs to the user
+	if _, err := s.habitRepo.GetHabitByID(habitID, userID); err != nil {
+		return nil, fmt.Errorf("habit not found: %w", err)
+	}
+	return getRevisions(s.revisionRepo, models.RevisionHabit, habitID, userID)
+}
+
+// RestoreHabitRevision puts a habit's editable fields back to what they were
+// at a revision. Tracking data is left as it is.
+func (s *habitService) RestoreHabitRevision(habitID, userID int64, number int) (*models.Habit, *models.Revision, error) {
+	habit, err := s.habitRepo.GetHabitByID(habitID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("habit not found: %w", err)
+	}
+
+	var target models.Habit
+	if _, err := getRevision(s.revisionRepo, models.RevisionHabit, habitID, userID, number, &target); err != nil {
+		return nil, nil, err
+	}
+
+	before := snapshot(habit)
+	habit.Name = target.Name
+	habit.Type = target.Type
+	habit.TargetValue = target.TargetValue
+	habit.IsAchieved = target.IsAchieved
+
+	restoredHabit, err := s.habitRepo.UpdateHabit(habit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to restore habit: %w", err)
+	}
+
+	revision := recordRevision(s.revisionRepo, userID, models.RevisionHabit, habitID, models.RevisionRestore, before, snapshot(restoredHabit), &number)
+
+	metadata := map[string]interface{}{
+		"habit_id":   habitID,
+		"habit_name": restoredHabit.Name,
+		"revision":   number,
+	}
+	s.logRepo.CreateLog(&userID, "habit_revision_restored", fmt.Sprintf("Habit '%s' restored to revision %d", restoredHabit.Name, number), metadata)
+
+	return restoredHabit, revision, nil
+}
+
+func (s *habitService) GetGoalRevisions(goalID, userID int64) ([]*models.Revision, error) {
+	// GetGoal checks that the goal belongs to the user
+	if _, err := s.GetGoal(goalID, userID); err != nil {
+		return nil, err
+	}
+	return getRevisions(s.revisionRepo, models.RevisionGoal, goalID, userID)
+}
+
+// RestoreGoalRevision puts a goal's fields, including its progress, back to
+// what they were at a revision
+func (s *habitService) RestoreGoalRevision(goalID, userID int64, number int) (*models.Goal, *models.Revision, error) {
+	goal, err := s.GetGoal(goalID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var target models.Goal
+	if _, err := getRevision(s.revisionRepo, models.RevisionGoal, goalID, userID, number, &target); err != nil {
+		return nil, nil, err
+	}
+
+	before := snapshot(goal)
+	goal.Title = target.Title
+	goal.Description = target.Description
+	goal.Category = target.Category
+	goal.TargetValue = target.TargetValue
+	goal.CurrentValue = target.CurrentValue
+	goal.Unit = target.Unit
+	goal.DueDate = target.DueDate
+	goal.IsCompleted = target.IsCompleted
+
+	restoredGoal, err := s.goalRepo.UpdateGoal(goal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to restore goal: %w", err)
+	}
+
+	revision := recordRevision(s.revisionRepo, userID, models.RevisionGoal, goalID, models.RevisionRestore, before, snapshot(restoredGoal), &number)
+
+	metadata := map[string]interface{}{
+		"goal_id":  goalID,
+		"title":    restoredGoal.Title,
+		"revision": number,
+	}
+	s.logRepo.CreateLog(&userID, "goal_revision_restored", fmt.Sprintf("Goal '%s' restored to revision %d", restoredGoal.Title, number), metadata)
+
+	return restoredGoal, revision, nil
+}
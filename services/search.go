@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"todo-backend/models"
+	"unicode"
+)
+
+// ErrInvalidSearch is returned for search filters that cannot be applied
+var ErrInvalidSearch = errors.New("invalid search")
+
+const maxSearchPageSize = 100
+
+// searchTSQuery turns free text into a to_tsquery expression that requires
+// every word, each matched as a prefix so results update while typing.
+// Everything except letters and digits separates words, which also strips
+// tsquery operators from user input.
+func searchTSQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+func (s *taskService) SearchTasks(userID int64, req *models.SearchTasksRequest) ([]*models.TaskSearchResult, int64, error) {
+	if req.Status != nil && !models.TaskStatus(*req.Status).IsValid() {
+		return nil, 0, fmt.Errorf("%w: unknown status %q", ErrInvalidSearch, *req.Status)
+	}
+	if req.DueDateFrom != nil && req.DueDateTo != nil && !req.DueDateFrom.Before(*req.DueDateTo) {
+		return nil, 0, fmt.Errorf("%w: due_from must be before due_to", ErrInvalidSearch)
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 {
+		req.PageSize = 10
+	}
+	if req.PageSize > maxSearchPageSize {
+		req.PageSize = maxSearchPageSize
+	}
+
+	tsQuery := searchTSQuery(req.Query)
+	if tsQuery == "" && strings.TrimSpace(req.Query) != "" {
+		// Only punctuation was given; nothing can match
+		return []*models.TaskSearchResult{}, 0, nil
+	}
+
+	results, total, err := s.taskRepo.SearchTasks(userID, tsQuery, req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search tasks: %w", err)
+	}
+
+	go func() {
+		metadata := map[string]interface{}{
+			"user_id": userID,
+			"query":   req.Query,
+			"filters": req,
+			"results": total,
+		}
+		s.logRepo.CreateLog(&userID, "tasks_searched", fmt.Sprintf("Searched tasks with query '%s', found %d results", req.Query, total), metadata)
+	}()
+
+	return results, total, nil
+}
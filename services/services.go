@@ -4,10 +4,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	"todo-backend/filter"
 	"todo-backend/models"
+	"todo-backend/ordering"
+	"todo-backend/quickadd"
 	"todo-backend/repositories"
 	"todo-backend/utils"
 )
@@ -207,11 +211,18 @@ func (s *authService) ChangePassword(userID int64, currentPassword, newPassword
 // Task Service
 type TaskService interface {
 	CreateTask(userID int64, req *models.CreateTaskRequest) (*models.Task, error)
+	CreateTaskWith(userID int64, req *models.CreateTaskRequest, write TaskWriter) (*models.Task, error)
 	GetUserTasks(userID int64, page, pageSize int) ([]*models.Task, int64, error)
+	GetTasksByFilter(userID int64, expr string, page, pageSize int) ([]*models.Task, int64, error)
+	GetTasksByCursor(userID int64, expr string, tags []string, matchAll bool, req *models.CursorRequest) ([]*models.Task, *models.CursorPage, error)
+	BulkUpdateTasks(userID int64, req *models.BulkTaskRequest) (*models.BulkTaskResult, error)
+	RescheduleTask(taskID, userID int64, req *models.RescheduleTaskRequest) (*models.Task, *models.TaskReschedule, error)
+	GetTaskReschedules(taskID, userID int64) ([]*models.TaskReschedule, error)
+	GetPostponementReport(userID int64, minCount int, since time.Time) (*models.PostponementReport, error)
 	GetTaskByID(taskID, userID int64) (*models.Task, error)
 	UpdateTask(taskID, userID int64, req *models.UpdateTaskRequest) (*models.Task, error)
 	DeleteTask(taskID, userID int64) error
-	MarkTaskCompleted(taskID, userID int64, isCompleted bool) error
+	MarkTaskCompleted(taskID, userID int64, isCompleted bool) (*models.TaskStatusResult, error)
 	GetTasksByCategory(userID int64, category string) ([]*models.Task, error)
 	GetTasksByPriority(userID int64, priority int16) ([]*models.Task, error)
 	ExportTasks(userID int64, format string) ([]byte, error)
@@ -221,27 +232,105 @@ type TaskService interface {
 	GetTasksDueToday(userID int64) ([]*models.Task, error)
 	GetTasksDueThisWeek(userID int64) ([]*models.Task, error)
 	GetOverdueTasks(userID int64) ([]*models.Task, error)
-	SearchTasks(userID int64, query string, filters map[string]interface{}) ([]*models.Task, error)
+	SearchTasks(userID int64, req *models.SearchTasksRequest) ([]*models.TaskSearchResult, int64, error)
 	DuplicateTask(taskID, userID int64) (*models.Task, error)
 	GetDashboardSummary(userID int64) (*models.DashboardSummary, error)
 	GetUpcomingTasks(userID int64, limit int) ([]*models.UpcomingTask, error)
 	GetRecentActivity(userID int64, limit int) ([]*models.RecentActivity, error)
 	GetUserCategories(userID int64) ([]string, error)
+
+	// Status state machine
+	UpdateTaskStatus(taskID, userID int64, status models.TaskStatus) (*models.TaskStatusResult, error)
+	UpdateTaskStatusWith(taskID, userID int64, status models.TaskStatus, write StatusWriter) (*models.TaskStatusResult, error)
+	GetTaskStatusHistory(taskID, userID int64) ([]*models.TaskStatusHistory, error)
+	GetTasksByStatus(userID int64, status models.TaskStatus, page, pageSize int) ([]*models.Task, int64, error)
+
+	// Subtasks
+	GetTaskTree(taskID, userID int64) (*models.TaskTree, error)
+
+	// Dependencies
+	AddTaskDependency(taskID, blockedByID, userID int64) error
+	RemoveTaskDependency(taskID, blockedByID, userID int64) error
+	GetTaskBlockers(taskID, userID int64) ([]*models.Task, error)
+	GetTaskDependents(taskID, userID int64) ([]*models.Task, error)
+	GetDependencyGraph(userID int64) (*models.DependencyGraph, error)
+
+	// Tags
+	GetUserTags(userID int64) ([]*models.Tag, error)
+	GetTasksByTags(userID int64, tags []string, matchAll bool, page, pageSize int) ([]*models.Task, int64, error)
+	RenameTag(tagID, userID int64, name string) (*models.Tag, error)
+	MergeTags(userID int64, req *models.MergeTagsRequest) (*models.Tag, error)
+	DeleteTag(tagID, userID int64) error
+
+	// Recurrence
+	PreviewTaskOccurrences(taskID, userID int64, count int) (*models.RecurrencePreview, error)
+	PreviewRecurrence(req *models.PreviewRecurrenceRequest) (*models.RecurrencePreview, error)
+
+	// Quick add
+	QuickAddTask(userID int64, req *models.QuickAddRequest) (*models.Task, *quickadd.Result, error)
+
+	// Revisions
+	GetTaskRevisions(taskID, userID int64) ([]*models.Revision, error)
+	RestoreTaskRevision(taskID, userID int64, number int) (*models.Task, *models.Revision, error)
+
+	// Eisenhower matrix
+	GetTaskMatrix(userID int64, urgentDays *int, importantPriority *int16) (*models.TaskMatrix, error)
+	SetTaskImportance(taskID, userID int64, isImportant *bool) (*models.Task, error)
 }
 
 type taskService struct {
-	taskRepo repositories.TaskRepository
-	logRepo  repositories.LogRepository
+	taskRepo          repositories.TaskRepository
+	logRepo           repositories.LogRepository
+	tagRepo           repositories.TagRepository
+	revisionRepo      repositories.RevisionRepository
+	projectRepo       repositories.ProjectRepository
+	subtaskPolicy     models.SubtaskPolicy
+	blockedCompletion string
+	matrixSettings    models.MatrixSettings
 }
 
-func NewTaskService(taskRepo repositories.TaskRepository, logRepo repositories.LogRepository) TaskService {
+func NewTaskService(taskRepo repositories.TaskRepository, logRepo repositories.LogRepository, tagRepo repositories.TagRepository, revisionRepo repositories.RevisionRepository, projectRepo repositories.ProjectRepository, subtaskPolicy models.SubtaskPolicy, blockedCompletion string, matrixSettings models.MatrixSettings) TaskService {
 	return &taskService{
-		taskRepo: taskRepo,
-		logRepo:  logRepo,
+		taskRepo:          taskRepo,
+		logRepo:           logRepo,
+		tagRepo:           tagRepo,
+		revisionRepo:      revisionRepo,
+		projectRepo:       projectRepo,
+		subtaskPolicy:     normalizeSubtaskPolicy(subtaskPolicy),
+		blockedCompletion: normalizeBlockedCompletion(blockedCompletion),
+		matrixSettings:    matrixSettings,
 	}
 }
 
+// TaskWriter stores a new task, tags included, that passed the validation
+// of CreateTask in place of TaskService's own write
+type TaskWriter func(task *models.Task) (*models.Task, error)
+
 func (s *taskService) CreateTask(userID int64, req *models.CreateTaskRequest) (*models.Task, error) {
+	return s.CreateTaskWith(userID, req, nil)
+}
+
+// CreateTaskWith is CreateTask with write storing the task, so that callers
+// can make it part of a larger write
+func (s *taskService) CreateTaskWith(userID int64, req *models.CreateTaskRequest, write TaskWriter) (*models.Task, error) {
+	if err := validateRecurrence(req.IsRecurring, req.RecurringFrequency); err != nil {
+		return nil, err
+	}
+	if req.ParentID != nil {
+		if err := s.validateParent(0, userID, *req.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProjectID != nil {
+		if err := checkProjectForTasks(s.projectRepo, *req.ProjectID, userID); err != nil {
+			return nil, err
+		}
+	}
+
 	task := &models.Task{
 		UserID:             userID,
 		TaskName:           req.TaskName,
@@ -251,11 +340,29 @@ func (s *taskService) CreateTask(userID int64, req *models.CreateTaskRequest) (*
 		DueDate:            req.DueDate,
 		IsRecurring:        req.IsRecurring,
 		RecurringFrequency: req.RecurringFrequency,
+		ParentID:           req.ParentID,
+		EstimateMinutes:    req.EstimateMinutes,
+		ProjectID:          req.ProjectID,
+		IsImportant:        req.IsImportant,
 	}
 
-	createdTask, err := s.taskRepo.CreateTask(task)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create task: %w", err)
+	var createdTask *models.Task
+	if write != nil {
+		task.Tags = tags
+		if createdTask, err = write(task); err != nil {
+			return nil, fmt.Errorf("failed to create task: %w", err)
+		}
+	} else {
+		createdTask, err = s.taskRepo.CreateTask(task)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create task: %w", err)
+		}
+		if len(req.Tags) > 0 {
+			createdTask, err = s.setTaskTags(createdTask, req.Tags)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Log task creation
@@ -264,8 +371,11 @@ func (s *taskService) CreateTask(userID int64, req *models.CreateTaskRequest) (*
 		"task_name": createdTask.TaskName,
 		"category":  createdTask.Category,
 		"priority":  createdTask.Priority,
+		"parent_id": createdTask.ParentID,
+		"tags":      createdTask.Tags,
 	}
 	s.logRepo.CreateLog(&userID, "task_created", fmt.Sprintf("Task '%s' created", createdTask.TaskName), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionTask, createdTask.ID, models.RevisionCreate, nil, snapshot(createdTask), nil)
 
 	return createdTask, nil
 }
@@ -278,6 +388,20 @@ func (s *taskService) GetUserTasks(userID int64, page, pageSize int) ([]*models.
 	return tasks, total, nil
 }
 
+// GetTasksByFilter lists the tasks matching a filter expression (see package filter)
+func (s *taskService) GetTasksByFilter(userID int64, expr string, page, pageSize int) ([]*models.Task, int64, error) {
+	node, err := filter.Parse(expr, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tasks, total, err := s.taskRepo.GetTasksByFilterPaginated(userID, node, page, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to filter tasks: %w", err)
+	}
+	return tasks, total, nil
+}
+
 func (s *taskService) GetTaskByID(taskID, userID int64) (*models.Task, error) {
 	task, err := s.taskRepo.GetTaskByID(taskID, userID)
 	if err != nil {
@@ -292,6 +416,8 @@ func (s *taskService) UpdateTask(taskID, userID int64, req *models.UpdateTaskReq
 	if err != nil {
 		return nil, fmt.Errorf("task not found: %w", err)
 	}
+	before := snapshot(existingTask)
+	currentStatus := existingTask.Status
 
 	// Update fields
 	if req.TaskName != nil {
@@ -309,20 +435,82 @@ func (s *taskService) UpdateTask(taskID, userID int64, req *models.UpdateTaskReq
 	if req.DueDate != nil {
 		existingTask.DueDate = req.DueDate
 	}
-	if req.IsCompleted != nil {
-		existingTask.IsCompleted = *req.IsCompleted
-	}
 	if req.IsRecurring != nil {
 		existingTask.IsRecurring = *req.IsRecurring
 	}
 	if req.RecurringFrequency != nil {
 		existingTask.RecurringFrequency = req.RecurringFrequency
 	}
+	if req.EstimateMinutes != nil {
+		if *req.EstimateMinutes == 0 {
+			existingTask.EstimateMinutes = nil
+		} else {
+			existingTask.EstimateMinutes = req.EstimateMinutes
+		}
+	}
+	if req.IsImportant != nil {
+		existingTask.IsImportant = req.IsImportant
+	}
+	if err := validateRecurrence(existingTask.IsRecurring, existingTask.RecurringFrequency); err != nil {
+		return nil, err
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			existingTask.ParentID = nil
+		} else {
+			if err := s.validateParent(taskID, userID, *req.ParentID); err != nil {
+				return nil, err
+			}
+			existingTask.ParentID = req.ParentID
+		}
+	}
+	if req.Tags != nil {
+		if _, err := normalizeTags(*req.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	// Status changes go through the state machine; is_completed is kept as a shorthand
+	targetStatus := currentStatus
+	if req.Status != nil {
+		targetStatus = *req.Status
+	} else if req.IsCompleted != nil {
+		if *req.IsCompleted {
+			targetStatus = models.TaskStatusCompleted
+		} else if currentStatus == models.TaskStatusCompleted {
+			targetStatus = models.TaskStatusPending
+		}
+	}
+	if !canTransition(currentStatus, targetStatus) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, currentStatus, targetStatus)
+	}
+	// The completion policies are checked before anything is saved, so a
+	// refused completion leaves the other edits unsaved too
+	var warnings []string
+	if targetStatus != currentStatus && targetStatus == models.TaskStatusCompleted {
+		if warnings, err = s.checkCompletion(existingTask); err != nil {
+			return nil, err
+		}
+	}
 
 	updatedTask, err := s.taskRepo.UpdateTask(existingTask)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update task: %w", err)
 	}
+	if req.Tags != nil {
+		updatedTask, err = s.setTaskTags(updatedTask, *req.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if targetStatus != currentStatus {
+		result, err := s.applyStatus(updatedTask, targetStatus, warnings, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		updatedTask = result.Task
+	}
 
 	// Log task update
 	metadata := map[string]interface{}{
@@ -331,6 +519,7 @@ func (s *taskService) UpdateTask(taskID, userID int64, req *models.UpdateTaskReq
 		"changes":   req,
 	}
 	s.logRepo.CreateLog(&userID, "task_updated", fmt.Sprintf("Task '%s' updated", updatedTask.TaskName), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionTask, updatedTask.ID, models.RevisionUpdate, before, snapshot(updatedTask), nil)
 
 	return updatedTask, nil
 }
@@ -342,6 +531,21 @@ func (s *taskService) DeleteTask(taskID, userID int64) error {
 		return fmt.Errorf("task not found: %w", err)
 	}
 
+	// Subtasks are removed by the ON DELETE CASCADE unless the policy promotes them
+	var subtasksDeleted, subtasksPromoted int64
+	if s.subtaskPolicy.OnDelete == models.SubtaskPromote {
+		subtasksPromoted, err = s.taskRepo.ReparentSubtasks(taskID, userID, task.ParentID)
+		if err != nil {
+			return fmt.Errorf("failed to promote subtasks: %w", err)
+		}
+	} else {
+		descendants, err := s.taskRepo.GetTaskDescendants(taskID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get subtasks: %w", err)
+		}
+		subtasksDeleted = int64(len(descendants))
+	}
+
 	err = s.taskRepo.DeleteTask(taskID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
@@ -349,40 +553,34 @@ func (s *taskService) DeleteTask(taskID, userID int64) error {
 
 	// Log task deletion
 	metadata := map[string]interface{}{
-		"task_id":   taskID,
-		"task_name": task.TaskName,
+		"task_id":           taskID,
+		"task_name":         task.TaskName,
+		"subtasks_deleted":  subtasksDeleted,
+		"subtasks_promoted": subtasksPromoted,
 	}
 	s.logRepo.CreateLog(&userID, "task_deleted", fmt.Sprintf("Task '%s' deleted", task.TaskName), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionTask, taskID, models.RevisionDelete, nil, snapshot(task), nil)
 
 	return nil
 }
 
-func (s *taskService) MarkTaskCompleted(taskID, userID int64, isCompleted bool) error {
-	// Get task details for logging
+// MarkTaskCompleted is the boolean shorthand for the status state machine:
+// true completes the task, false reopens a completed task as pending
+func (s *taskService) MarkTaskCompleted(taskID, userID int64, isCompleted bool) (*models.TaskStatusResult, error) {
 	task, err := s.taskRepo.GetTaskByID(taskID, userID)
 	if err != nil {
-		return fmt.Errorf("task not found: %w", err)
-	}
-
-	err = s.taskRepo.MarkTaskCompleted(taskID, userID, isCompleted)
-	if err != nil {
-		return fmt.Errorf("failed to update task status: %w", err)
+		return nil, fmt.Errorf("task not found: %w", err)
 	}
 
-	// Log task completion status change
-	status := "completed"
+	target := models.TaskStatusCompleted
 	if !isCompleted {
-		status = "uncompleted"
-	}
-
-	metadata := map[string]interface{}{
-		"task_id":      taskID,
-		"task_name":    task.TaskName,
-		"is_completed": isCompleted,
+		if task.Status != models.TaskStatusCompleted {
+			return &models.TaskStatusResult{Task: task}, nil
+		}
+		target = models.TaskStatusPending
 	}
-	s.logRepo.CreateLog(&userID, "task_status_changed", fmt.Sprintf("Task '%s' marked as %s", task.TaskName, status), metadata)
 
-	return nil
+	return s.recordStatusChange(task, target, nil)
 }
 
 func (s *taskService) GetTasksByCategory(userID int64, category string) ([]*models.Task, error) {
@@ -407,6 +605,21 @@ func (s *taskService) ExportTasks(userID int64, format string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to get tasks for export: %w", err)
 	}
 
+	// The JSON export carries the attachment metadata; the files themselves stay in storage
+	if format == "json" {
+		attachments, err := s.taskRepo.GetUserAttachments(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attachments for export: %w", err)
+		}
+		byTask := make(map[int64][]*models.TaskAttachment)
+		for _, attachment := range attachments {
+			byTask[attachment.TaskID] = append(byTask[attachment.TaskID], attachment)
+		}
+		for _, task := range tasks {
+			task.Attachments = byTask[task.ID]
+		}
+	}
+
 	var exportData []byte
 	switch format {
 	case "json":
@@ -440,6 +653,8 @@ func (s *taskService) ImportTasks(userID int64, data []byte, format string) erro
 		tasks, err = utils.ImportTasksFromJSON(data)
 	case "csv":
 		tasks, err = utils.ImportTasksFromCSV(data)
+	case "ics":
+		tasks, err = utils.ImportTasksFromICS(data)
 	default:
 		return errors.New("unsupported import format")
 	}
@@ -448,16 +663,66 @@ func (s *taskService) ImportTasks(userID int64, data []byte, format string) erro
 		return fmt.Errorf("failed to parse import data: %w", err)
 	}
 
-	// Create tasks
-	var importedCount int
+	// Tasks an earlier ICS import created are updated rather than added again
+	icalIDs := s.importedICalIDs(userID, tasks)
+
+	// Create tasks; subtask links are restored once every task has its new ID
+	var importedCount, updatedCount, skippedCount int
+	importedIDs := make(map[int64]int64)
+	projectIDs := make(map[string]*int64)
+	var children []*models.Task
 	for _, task := range tasks {
+		if taskID, ok := icalIDs[task.ICalUID]; ok && task.ICalUID != "" {
+			switch err := s.reimportTask(userID, taskID, task); {
+			case err == nil:
+				importedCount++
+				updatedCount++
+			case errors.Is(err, errImportTrashed):
+				skippedCount++
+			}
+			continue
+		}
+		oldID, oldParentID := task.ID, task.ParentID
 		task.UserID = userID // Ensure task belongs to current user
-		_, err := s.taskRepo.CreateTask(task)
+		task.RecurrenceParentID = nil
+		task.RecurrenceIndex = 1
+		task.ParentID = nil
+		task.ProjectID = s.importProject(userID, task.ProjectName, projectIDs)
+		if task.ProjectID == nil || (task.Position != nil && !ordering.Valid(*task.Position)) {
+			task.Position = nil
+		}
+		createdTask, err := s.taskRepo.CreateTask(task)
 		if err != nil {
 			// Log error but continue with other tasks
 			continue
 		}
+		if len(task.Tags) > 0 {
+			if taggedTask, err := s.setTaskTags(createdTask, task.Tags); err == nil {
+				createdTask = taggedTask
+			}
+		}
+		if format == "ics" {
+			createdTask = s.importStatus(createdTask, task.Status)
+			if task.ICalUID != "" && s.taskRepo.SetTaskICalUID(createdTask.ID, userID, task.ICalUID) == nil {
+				icalIDs[task.ICalUID] = createdTask.ID
+			}
+		}
 		importedCount++
+		if oldID != 0 {
+			importedIDs[oldID] = createdTask.ID
+		}
+		if oldParentID != nil {
+			createdTask.ParentID = oldParentID
+			children = append(children, createdTask)
+		}
+	}
+	for _, child := range children {
+		parentID, ok := importedIDs[*child.ParentID]
+		if !ok {
+			continue
+		}
+		child.ParentID = &parentID
+		s.taskRepo.UpdateTask(child)
 	}
 
 	// Log import
@@ -465,13 +730,39 @@ func (s *taskService) ImportTasks(userID int64, data []byte, format string) erro
 		"format":         format,
 		"total_tasks":    len(tasks),
 		"imported_tasks": importedCount,
-		"failed_imports": len(tasks) - importedCount,
+		"updated_tasks":  updatedCount,
+		"skipped_tasks":  skippedCount, // their earlier import is in the trash
+		"failed_imports": len(tasks) - importedCount - skippedCount,
 	}
 	s.logRepo.CreateLog(&userID, "tasks_imported", fmt.Sprintf("Imported %d/%d tasks from %s", importedCount, len(tasks), format), metadata)
 
 	return nil
 }
 
+// importProject finds the user's project with the given name, creating it
+// if needed, so that imported tasks keep their project. Names already seen
+// are looked up in known. A failure leaves the task without a project.
+func (s *taskService) importProject(userID int64, name *string, known map[string]*int64) *int64 {
+	if name == nil || strings.TrimSpace(*name) == "" {
+		return nil
+	}
+	key := strings.ToLower(strings.TrimSpace(*name))
+	if projectID, ok := known[key]; ok {
+		return projectID
+	}
+
+	project, err := s.projectRepo.GetProjectByName(userID, strings.TrimSpace(*name))
+	if errors.Is(err, sql.ErrNoRows) {
+		project, err = s.projectRepo.CreateProject(&models.Project{UserID: userID, Name: strings.TrimSpace(*name)})
+	}
+	if err != nil {
+		known[key] = nil
+		return nil
+	}
+	known[key] = &project.ID
+	return &project.ID
+}
+
 // Enhanced Task Management Methods Implementation
 func (s *taskService) GetTasksDueToday(userID int64) ([]*models.Task, error) {
 	tasksChan := make(chan []*models.Task)
@@ -598,81 +889,6 @@ func (s *taskService) GetOverdueTasks(userID int64) ([]*models.Task, error) {
 	return overdueTasks, nil
 }
 
-func (s *taskService) SearchTasks(userID int64, query string, filters map[string]interface{}) ([]*models.Task, error) {
-	tasks, _, err := s.taskRepo.GetTasksByUserIDPaginated(userID, 1, 10000)
-	if err != nil {
-		return nil, fmt.Errorf("failed to search tasks: %w", err)
-	}
-
-	filteredTasks := make([]*models.Task, 0, len(tasks))
-	numWorkers := 8
-	taskChan := make(chan *models.Task)
-	resultChan := make(chan *models.Task)
-	doneChan := make(chan struct{})
-
-	// Worker pool for filtering
-	for i := 0; i < numWorkers; i++ {
-		go func() {
-			for task := range taskChan {
-				match := false
-				if query != "" {
-					if strings.Contains(strings.ToLower(task.TaskName), strings.ToLower(query)) {
-						match = true
-					} else if task.Description != nil && strings.Contains(strings.ToLower(*task.Description), strings.ToLower(query)) {
-						match = true
-					}
-				} else {
-					match = true
-				}
-				if match {
-					resultChan <- task
-				}
-			}
-			doneChan <- struct{}{}
-		}()
-	}
-
-	go func() {
-		for _, task := range tasks {
-			taskChan <- task
-		}
-		close(taskChan)
-	}()
-
-	go func() {
-		finished := 0
-		for {
-			select {
-			case t := <-resultChan:
-				filteredTasks = append(filteredTasks, t)
-			case <-doneChan:
-				finished++
-				if finished == numWorkers {
-					close(resultChan)
-					return
-				}
-			}
-		}
-	}()
-
-	// Wait for all workers to finish
-	for i := 0; i < numWorkers; i++ {
-		<-doneChan
-	}
-
-	go func() {
-		metadata := map[string]interface{}{
-			"user_id": userID,
-			"query":   query,
-			"filters": filters,
-			"results": len(filteredTasks),
-		}
-		s.logRepo.CreateLog(&userID, "tasks_searched", fmt.Sprintf("Searched tasks with query '%s', found %d results", query, len(filteredTasks)), metadata)
-	}()
-
-	return filteredTasks, nil
-}
-
 func (s *taskService) DuplicateTask(taskID, userID int64) (*models.Task, error) {
 	// Get original task
 	originalTask, err := s.taskRepo.GetTaskByID(taskID, userID)
@@ -691,12 +907,30 @@ func (s *taskService) DuplicateTask(taskID, userID int64) (*models.Task, error)
 		IsRecurring:        originalTask.IsRecurring,
 		RecurringFrequency: originalTask.RecurringFrequency,
 		IsCompleted:        false, // New task should not be completed
+		ParentID:           originalTask.ParentID,
+		EstimateMinutes:    originalTask.EstimateMinutes,
+		ProjectID:          originalTask.ProjectID,
+		IsImportant:        originalTask.IsImportant,
 	}
 
 	createdTask, err := s.taskRepo.CreateTask(duplicateTask)
 	if err != nil {
 		return nil, fmt.Errorf("failed to duplicate task: %w", err)
 	}
+	if len(originalTask.Tags) > 0 {
+		createdTask, err = s.setTaskTags(createdTask, originalTask.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subtasksCopied := 0
+	if s.subtaskPolicy.OnDuplicate == models.SubtaskDeep {
+		subtasksCopied, err = s.duplicateSubtasks(originalTask.ID, createdTask.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Log duplication
 	metadata := map[string]interface{}{
@@ -704,6 +938,7 @@ func (s *taskService) DuplicateTask(taskID, userID int64) (*models.Task, error)
 		"new_task_id":      createdTask.ID,
 		"original_name":    originalTask.TaskName,
 		"new_name":         createdTask.TaskName,
+		"subtasks_copied":  subtasksCopied,
 	}
 	s.logRepo.CreateLog(&userID, "task_duplicated", fmt.Sprintf("Task '%s' duplicated as '%s'", originalTask.TaskName, createdTask.TaskName), metadata)
 
@@ -784,27 +1019,39 @@ func (s *taskService) GetUpcomingTasks(userID int64, limit int) ([]*models.Upcom
 		return nil, fmt.Errorf("failed to get upcoming tasks: %w", err)
 	}
 
+	openBlockers, err := s.taskRepo.GetOpenBlockers(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocked tasks: %w", err)
+	}
+
 	now := time.Now()
 	upcomingTasks := make([]*models.UpcomingTask, 0)
 	for _, task := range tasks {
 		if !task.IsCompleted && task.DueDate != nil && task.DueDate.After(now) {
 			daysLeft := int(task.DueDate.Sub(now).Hours() / 24)
 			upcomingTask := &models.UpcomingTask{
-				ID:       task.ID,
-				TaskName: task.TaskName,
-				Category: task.Category,
-				Priority: task.Priority,
-				DueDate:  task.DueDate,
-				DaysLeft: daysLeft,
-				IsUrgent: daysLeft <= 3,
+				ID:        task.ID,
+				TaskName:  task.TaskName,
+				Category:  task.Category,
+				Priority:  task.Priority,
+				DueDate:   task.DueDate,
+				DaysLeft:  daysLeft,
+				IsUrgent:  daysLeft <= 3,
+				IsBlocked: len(openBlockers[task.ID]) > 0,
+				BlockedBy: openBlockers[task.ID],
 			}
 			upcomingTasks = append(upcomingTasks, upcomingTask)
-			if len(upcomingTasks) >= limit {
-				break
-			}
 		}
 	}
 
+	// Closest due date first; a limit of 0 returns every upcoming task
+	sort.Slice(upcomingTasks, func(i, j int) bool {
+		return upcomingTasks[i].DueDate.Time.Before(upcomingTasks[j].DueDate.Time)
+	})
+	if limit > 0 && len(upcomingTasks) > limit {
+		upcomingTasks = upcomingTasks[:limit]
+	}
+
 	return upcomingTasks, nil
 }
 
@@ -882,6 +1129,7 @@ func (s *logService) CreateSystemLog(eventType, description string, metadata map
 type HabitService interface {
 	CreateHabit(userID int64, req *models.CreateHabitRequest) (*models.Habit, error)
 	GetUserHabits(userID int64, page, pageSize int) ([]*models.Habit, int64, error)
+	GetHabitsByCursor(userID int64, req *models.CursorRequest) ([]*models.Habit, *models.CursorPage, error)
 	GetHabitByID(habitID, userID int64) (*models.Habit, error)
 	UpdateHabit(habitID, userID int64, req *models.UpdateHabitRequest) (*models.Habit, error)
 	DeleteHabit(habitID, userID int64) error
@@ -903,6 +1151,12 @@ type HabitService interface {
 	UpdateGoal(goalID, userID int64, req *models.UpdateGoalRequest) (*models.Goal, error)
 	DeleteGoal(goalID, userID int64) error
 	UpdateGoalProgress(goalID, userID int64, req *models.UpdateGoalProgressRequest) (*models.Goal, error)
+
+	// Revisions
+	GetHabitRevisions(habitID, userID int64) ([]*models.Revision, error)
+	RestoreHabitRevision(habitID, userID int64, number int) (*models.Habit, *models.Revision, error)
+	GetGoalRevisions(goalID, userID int64) ([]*models.Revision, error)
+	RestoreGoalRevision(goalID, userID int64, number int) (*models.Goal, *models.Revision, error)
 }
 
 type habitService struct {
@@ -910,14 +1164,18 @@ type habitService struct {
 	logRepo      repositories.LogRepository
 	pomodoroRepo repositories.PomodoroRepository
 	goalRepo     repositories.GoalRepository
+	revisionRepo repositories.RevisionRepository
+	taskRepo     repositories.TaskRepository
 }
 
-func NewHabitService(habitRepo repositories.HabitRepository, logRepo repositories.LogRepository, pomodoroRepo repositories.PomodoroRepository, goalRepo repositories.GoalRepository) HabitService {
+func NewHabitService(habitRepo repositories.HabitRepository, logRepo repositories.LogRepository, pomodoroRepo repositories.PomodoroRepository, goalRepo repositories.GoalRepository, revisionRepo repositories.RevisionRepository, taskRepo repositories.TaskRepository) HabitService {
 	return &habitService{
 		habitRepo:    habitRepo,
 		logRepo:      logRepo,
 		pomodoroRepo: pomodoroRepo,
 		goalRepo:     goalRepo,
+		revisionRepo: revisionRepo,
+		taskRepo:     taskRepo,
 	}
 }
 
@@ -942,6 +1200,7 @@ func (s *habitService) CreateHabit(userID int64, req *models.CreateHabitRequest)
 		"target_value": createdHabit.TargetValue,
 	}
 	s.logRepo.CreateLog(&userID, "habit_created", fmt.Sprintf("Habit '%s' created", createdHabit.Name), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionHabit, createdHabit.ID, models.RevisionCreate, nil, snapshot(createdHabit), nil)
 
 	return createdHabit, nil
 }
@@ -968,6 +1227,7 @@ func (s *habitService) UpdateHabit(habitID, userID int64, req *models.UpdateHabi
 	if err != nil {
 		return nil, fmt.Errorf("habit not found: %w", err)
 	}
+	before := snapshot(existingHabit)
 
 	// Update fields
 	if req.Name != nil {
@@ -995,6 +1255,7 @@ func (s *habitService) UpdateHabit(habitID, userID int64, req *models.UpdateHabi
 		"changes":    req,
 	}
 	s.logRepo.CreateLog(&userID, "habit_updated", fmt.Sprintf("Habit '%s' updated", updatedHabit.Name), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionHabit, updatedHabit.ID, models.RevisionUpdate, before, snapshot(updatedHabit), nil)
 
 	return updatedHabit, nil
 }
@@ -1017,6 +1278,7 @@ func (s *habitService) DeleteHabit(habitID, userID int64) error {
 		"habit_name": habit.Name,
 	}
 	s.logRepo.CreateLog(&userID, "habit_deleted", fmt.Sprintf("Habit '%s' deleted", habit.Name), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionHabit, habitID, models.RevisionDelete, nil, snapshot(habit), nil)
 
 	return nil
 }
@@ -1152,6 +1414,12 @@ func (s *habitService) ImportHabits(userID int64, data []byte, format string) er
 
 // Pomodoro Session methods
 func (s *habitService) StartPomodoroSession(userID int64, req *models.StartPomodoroRequest) (*models.PomodoroSession, error) {
+	if req.TaskID != nil {
+		if _, err := s.taskRepo.GetTaskByID(*req.TaskID, userID); err != nil {
+			return nil, fmt.Errorf("task not found: %w", err)
+		}
+	}
+
 	session := &models.PomodoroSession{
 		UserID:    userID,
 		TaskID:    req.TaskID,
@@ -1219,6 +1487,9 @@ func (s *habitService) GetPomodoroStats(userID int64) (*models.PomodoroStats, er
 }
 
 // Goal methods
+// ErrGoalNotFound is returned for goals that do not exist or belong to another user
+var ErrGoalNotFound = errors.New("goal not found")
+
 func (s *habitService) CreateGoal(userID int64, req *models.CreateGoalRequest) (*models.Goal, error) {
 	goal := &models.Goal{
 		UserID:      userID,
@@ -1243,6 +1514,7 @@ func (s *habitService) CreateGoal(userID int64, req *models.CreateGoalRequest) (
 		"unit":         createdGoal.Unit,
 	}
 	s.logRepo.CreateLog(&userID, "goal_created", fmt.Sprintf("Goal '%s' created", createdGoal.Title), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionGoal, createdGoal.ID, models.RevisionCreate, nil, snapshot(createdGoal), nil)
 
 	return createdGoal, nil
 }
@@ -1263,7 +1535,7 @@ func (s *habitService) GetGoal(goalID, userID int64) (*models.Goal, error) {
 	}
 
 	if goal.UserID != userID {
-		return nil, errors.New("goal not found")
+		return nil, ErrGoalNotFound
 	}
 
 	return goal, nil
@@ -1276,8 +1548,9 @@ func (s *habitService) UpdateGoal(goalID, userID int64, req *models.UpdateGoalRe
 	}
 
 	if existingGoal.UserID != userID {
-		return nil, errors.New("goal not found")
+		return nil, ErrGoalNotFound
 	}
+	before := snapshot(existingGoal)
 
 	// Update fields if provided
 	if req.Title != nil {
@@ -1317,6 +1590,7 @@ func (s *habitService) UpdateGoal(goalID, userID int64, req *models.UpdateGoalRe
 		"new_status":      updatedGoal.IsCompleted,
 	}
 	s.logRepo.CreateLog(&userID, "goal_updated", fmt.Sprintf("Goal '%s' updated", updatedGoal.Title), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionGoal, updatedGoal.ID, models.RevisionUpdate, before, snapshot(updatedGoal), nil)
 
 	return updatedGoal, nil
 }
@@ -1328,7 +1602,7 @@ func (s *habitService) DeleteGoal(goalID, userID int64) error {
 	}
 
 	if goal.UserID != userID {
-		return errors.New("goal not found")
+		return ErrGoalNotFound
 	}
 
 	err = s.goalRepo.DeleteGoal(goalID)
@@ -1343,6 +1617,7 @@ func (s *habitService) DeleteGoal(goalID, userID int64) error {
 		"category": goal.Category,
 	}
 	s.logRepo.CreateLog(&userID, "goal_deleted", fmt.Sprintf("Goal '%s' deleted", goal.Title), metadata)
+	recordRevision(s.revisionRepo, userID, models.RevisionGoal, goalID, models.RevisionDelete, nil, snapshot(goal), nil)
 
 	return nil
 }
@@ -1354,7 +1629,7 @@ func (s *habitService) UpdateGoalProgress(goalID, userID int64, req *models.Upda
 	}
 
 	if goal.UserID != userID {
-		return nil, errors.New("goal not found")
+		return nil, ErrGoalNotFound
 	}
 
 	// Update progress
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"todo-backend/models"
+)
+
+// ErrInvalidParent is returned when a task's parent_id does not exist or would create a cycle
+var ErrInvalidParent = errors.New("invalid parent task")
+
+// ErrOpenSubtasks is returned when the subtask policy blocks completing a parent with open children
+var ErrOpenSubtasks = errors.New("task has open subtasks")
+
+// normalizeSubtaskPolicy replaces unknown policy values with the defaults
+func normalizeSubtaskPolicy(policy models.SubtaskPolicy) models.SubtaskPolicy {
+	switch policy.OnComplete {
+	case models.SubtaskCascade, models.SubtaskBlock, models.SubtaskIgnore:
+	default:
+		policy.OnComplete = models.DefaultSubtaskPolicy.OnComplete
+	}
+	switch policy.OnDelete {
+	case models.SubtaskCascade, models.SubtaskPromote:
+	default:
+		policy.OnDelete = models.DefaultSubtaskPolicy.OnDelete
+	}
+	switch policy.OnDuplicate {
+	case models.SubtaskDeep, models.SubtaskShallow:
+	default:
+		policy.OnDuplicate = models.DefaultSubtaskPolicy.OnDuplicate
+	}
+	return policy
+}
+
+func isOpenStatus(status models.TaskStatus) bool {
+	return status == models.TaskStatusPending || status == models.TaskStatusInProgress
+}
+
+// validateParent checks that parentID can become the parent of taskID. Pass
+// taskID 0 for a task that does not exist yet.
+func (s *taskService) validateParent(taskID, userID, parentID int64) error {
+	if parentID == taskID {
+		return fmt.Errorf("%w: a task cannot be its own parent", ErrInvalidParent)
+	}
+
+	if _, err := s.taskRepo.GetTaskByID(parentID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: parent task %d not found", ErrInvalidParent, parentID)
+		}
+		return fmt.Errorf("failed to get parent task: %w", err)
+	}
+
+	if taskID == 0 {
+		return nil
+	}
+
+	descendants, err := s.taskRepo.GetTaskDescendants(taskID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get subtasks: %w", err)
+	}
+	for _, descendant := range descendants {
+		if descendant.ID == parentID {
+			return fmt.Errorf("%w: task %d is a subtask of task %d", ErrInvalidParent, parentID, taskID)
+		}
+	}
+	return nil
+}
+
+// GetTaskTree returns a task with all of its subtasks nested below it and the rolled-up progress
+func (s *taskService) GetTaskTree(taskID, userID int64) (*models.TaskTree, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	descendants, err := s.taskRepo.GetTaskDescendants(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subtasks: %w", err)
+	}
+
+	children := make(map[int64][]*models.Task)
+	for _, descendant := range descendants {
+		children[*descendant.ParentID] = append(children[*descendant.ParentID], descendant)
+	}
+
+	return buildTaskTree(task, children), nil
+}
+
+func buildTaskTree(task *models.Task, children map[int64][]*models.Task) *models.TaskTree {
+	node := &models.TaskTree{Task: task, Subtasks: []*models.TaskTree{}}
+
+	var total float64
+	counted := 0
+	for _, child := range children[task.ID] {
+		subtree := buildTaskTree(child, children)
+		node.Subtasks = append(node.Subtasks, subtree)
+		node.SubtaskCount++
+		if child.Status == models.TaskStatusCompleted {
+			node.CompletedSubtaskCount++
+		}
+		if child.Status == models.TaskStatusCancelled {
+			continue
+		}
+		total += subtree.Progress
+		counted++
+	}
+
+	switch {
+	case task.Status == models.TaskStatusCompleted:
+		node.Progress = 100
+	case counted > 0:
+		node.Progress = math.Round(total/float64(counted)*10) / 10
+	}
+
+	return node
+}
+
+// checkSubtasksBeforeCompletion enforces the block policy
+func (s *taskService) checkSubtasksBeforeCompletion(task *models.Task) error {
+	if s.subtaskPolicy.OnComplete != models.SubtaskBlock {
+		return nil
+	}
+
+	descendants, err := s.taskRepo.GetTaskDescendants(task.ID, task.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get subtasks: %w", err)
+	}
+	open := 0
+	for _, descendant := range descendants {
+		if isOpenStatus(descendant.Status) {
+			open++
+		}
+	}
+	if open > 0 {
+		return fmt.Errorf("%w: %d subtask(s) of '%s' are not done", ErrOpenSubtasks, open, task.TaskName)
+	}
+	return nil
+}
+
+// checkCompletion applies the subtask and blocked-task policies to
+// completing a task and returns the warnings to show. Under the cascade
+// policy the subtasks completed along with the task are checked as well, so
+// that the cascade cannot stop halfway.
+func (s *taskService) checkCompletion(task *models.Task) ([]string, error) {
+	if err := s.checkSubtasksBeforeCompletion(task); err != nil {
+		return nil, err
+	}
+	warnings, err := s.checkBlockersBeforeCompletion(task)
+	if err != nil {
+		return nil, err
+	}
+	if s.subtaskPolicy.OnComplete == models.SubtaskCascade && s.blockedCompletion == models.BlockedCompletionRefuse {
+		if err := s.checkCascadeBlockers(task); err != nil {
+			return nil, err
+		}
+	}
+	return warnings, nil
+}
+
+// checkCascadeBlockers refuses a cascade when a subtask it would complete is
+// waiting on an open task that the cascade does not complete too
+func (s *taskService) checkCascadeBlockers(task *models.Task) error {
+	descendants, err := s.taskRepo.GetTaskDescendants(task.ID, task.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get subtasks: %w", err)
+	}
+
+	// Descendants come after their parents; the cascade does not go below
+	// cancelled subtasks
+	reached := map[int64]bool{task.ID: true}
+	completing := map[int64]bool{task.ID: true}
+	var open []*models.Task
+	for _, descendant := range descendants {
+		if !reached[*descendant.ParentID] || descendant.Status == models.TaskStatusCancelled {
+			continue
+		}
+		reached[descendant.ID] = true
+		if isOpenStatus(descendant.Status) {
+			completing[descendant.ID] = true
+			open = append(open, descendant)
+		}
+	}
+
+	for _, subtask := range open {
+		blockers, err := s.taskRepo.GetTaskBlockers(subtask.ID, subtask.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get blockers: %w", err)
+		}
+		for _, blocker := range blockers {
+			if isOpenStatus(blocker.Status) && !completing[blocker.ID] {
+				return fmt.Errorf("%w: subtask '%s' of '%s' is waiting on '%s'", ErrTaskBlocked, subtask.TaskName, task.TaskName, blocker.TaskName)
+			}
+		}
+	}
+	return nil
+}
+
+// completeSubtasks enforces the cascade policy by completing every open
+// subtask below a task that was just completed
+func (s *taskService) completeSubtasks(task *models.Task) error {
+	if s.subtaskPolicy.OnComplete != models.SubtaskCascade {
+		return nil
+	}
+
+	children, err := s.taskRepo.GetSubtasks(task.ID, task.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get subtasks: %w", err)
+	}
+
+	for _, child := range children {
+		switch {
+		case isOpenStatus(child.Status):
+			// checkCompletion already checked the whole cascade; applyStatus
+			// cascades further down on its own
+			if _, err := s.applyStatus(child, models.TaskStatusCompleted, nil, true, nil); err != nil {
+				return err
+			}
+		case child.Status == models.TaskStatusCompleted:
+			if err := s.completeSubtasks(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// duplicateSubtasks copies every subtask of originalID below copyID, keeping
+// the shape of the tree, and returns the number of tasks copied
+func (s *taskService) duplicateSubtasks(originalID, copyID, userID int64) (int, error) {
+	descendants, err := s.taskRepo.GetTaskDescendants(originalID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get subtasks: %w", err)
+	}
+
+	copies := map[int64]int64{originalID: copyID}
+	for _, original := range descendants {
+		parentID := copies[*original.ParentID]
+		created, err := s.taskRepo.CreateTask(&models.Task{
+			UserID:             userID,
+			TaskName:           original.TaskName,
+			Description:        original.Description,
+			Category:           original.Category,
+			Priority:           original.Priority,
+			DueDate:            original.DueDate,
+			IsRecurring:        original.IsRecurring,
+			RecurringFrequency: original.RecurringFrequency,
+			ParentID:           &parentID,
+			EstimateMinutes:    original.EstimateMinutes,
+			ProjectID:          original.ProjectID,
+			IsImportant:        original.IsImportant,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to duplicate subtask: %w", err)
+		}
+		if len(original.Tags) > 0 {
+			if _, err := s.setTaskTags(created, original.Tags); err != nil {
+				return 0, err
+			}
+		}
+		copies[original.ID] = created.ID
+	}
+
+	return len(descendants), nil
+}
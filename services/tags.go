@@ -0,0 +1,190 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"todo-backend/models"
+)
+
+// ErrInvalidTag is returned for empty, overlong or malformed tag names
+var ErrInvalidTag = errors.New("invalid tag")
+
+// ErrTagExists is returned when renaming a tag to a name the user already uses
+var ErrTagExists = errors.New("tag already exists")
+
+const maxTagLength = 50
+
+// normalizeTags trims tag names and drops blanks and case-insensitive
+// duplicates. Commas are rejected because CSV exports join tags with them.
+func normalizeTags(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if len(name) > maxTagLength {
+			return nil, fmt.Errorf("%w: '%s' is longer than %d characters", ErrInvalidTag, name, maxTagLength)
+		}
+		if strings.Contains(name, ",") {
+			return nil, fmt.Errorf("%w: '%s' contains a comma", ErrInvalidTag, name)
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, name)
+	}
+	return normalized, nil
+}
+
+// setTaskTags replaces the tags of a task and returns the task re-read with its stored tags
+func (s *taskService) setTaskTags(task *models.Task, names []string) (*models.Task, error) {
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tagRepo.SetTaskTags(task.ID, task.UserID, tags); err != nil {
+		return nil, fmt.Errorf("failed to set task tags: %w", err)
+	}
+
+	updatedTask, err := s.taskRepo.GetTaskByID(task.ID, task.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return updatedTask, nil
+}
+
+func (s *taskService) GetUserTags(userID int64) ([]*models.Tag, error) {
+	tags, err := s.tagRepo.GetTagsByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (s *taskService) GetTasksByTags(userID int64, tags []string, matchAll bool, page, pageSize int) ([]*models.Task, int64, error) {
+	names, err := normalizeTags(tags)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(names) == 0 {
+		return nil, 0, fmt.Errorf("%w: no tags given", ErrInvalidTag)
+	}
+
+	tasks, total, err := s.taskRepo.GetTasksByTagsPaginated(userID, names, matchAll, page, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get tasks by tags: %w", err)
+	}
+	return tasks, total, nil
+}
+
+func (s *taskService) RenameTag(tagID, userID int64, name string) (*models.Tag, error) {
+	names, err := normalizeTags([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: name is empty", ErrInvalidTag)
+	}
+	name = names[0]
+
+	tag, err := s.tagRepo.GetTagByID(tagID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tag not found: %w", err)
+	}
+
+	existing, err := s.tagRepo.GetTagByName(userID, name)
+	if err == nil && existing.ID != tagID {
+		return nil, fmt.Errorf("%w: '%s' (merge the tags instead)", ErrTagExists, existing.Name)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to look up tag: %w", err)
+	}
+
+	renamedTag, err := s.tagRepo.RenameTag(tagID, userID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	// Log tag rename
+	metadata := map[string]interface{}{
+		"tag_id":   tagID,
+		"old_name": tag.Name,
+		"new_name": renamedTag.Name,
+	}
+	s.logRepo.CreateLog(&userID, "tag_renamed", fmt.Sprintf("Tag '%s' renamed to '%s'", tag.Name, renamedTag.Name), metadata)
+
+	return renamedTag, nil
+}
+
+func (s *taskService) MergeTags(userID int64, req *models.MergeTagsRequest) (*models.Tag, error) {
+	target, err := s.tagRepo.GetTagByID(req.TargetID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("target tag not found: %w", err)
+	}
+
+	sourceIDs := make([]int64, 0, len(req.SourceIDs))
+	sourceNames := make([]string, 0, len(req.SourceIDs))
+	for _, sourceID := range req.SourceIDs {
+		if sourceID == req.TargetID {
+			continue
+		}
+		source, err := s.tagRepo.GetTagByID(sourceID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("source tag not found: %w", err)
+		}
+		sourceIDs = append(sourceIDs, source.ID)
+		sourceNames = append(sourceNames, source.Name)
+	}
+	if len(sourceIDs) == 0 {
+		return target, nil
+	}
+
+	moved, err := s.tagRepo.MergeTags(sourceIDs, target.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	mergedTag, err := s.tagRepo.GetTagByID(target.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	// Log tag merge
+	metadata := map[string]interface{}{
+		"target_id":    target.ID,
+		"target_name":  target.Name,
+		"source_ids":   sourceIDs,
+		"source_names": sourceNames,
+		"tasks_moved":  moved,
+	}
+	s.logRepo.CreateLog(&userID, "tags_merged", fmt.Sprintf("Merged %s into tag '%s'", strings.Join(sourceNames, ", "), target.Name), metadata)
+
+	return mergedTag, nil
+}
+
+func (s *taskService) DeleteTag(tagID, userID int64) error {
+	tag, err := s.tagRepo.GetTagByID(tagID, userID)
+	if err != nil {
+		return fmt.Errorf("tag not found: %w", err)
+	}
+
+	if err := s.tagRepo.DeleteTag(tagID, userID); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	// Log tag deletion
+	metadata := map[string]interface{}{
+		"tag_id":      tagID,
+		"tag_name":    tag.Name,
+		"usage_count": tag.UsageCount,
+	}
+	s.logRepo.CreateLog(&userID, "tag_deleted", fmt.Sprintf("Tag '%s' deleted", tag.Name), metadata)
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"todo-backend/models"
+)
+
+// ErrInvalidStatusTransition is returned when a status change is not allowed from the task's current status
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// taskStatusTransitions lists, for each status, the statuses a task may move to next
+var taskStatusTransitions = map[models.TaskStatus][]models.TaskStatus{
+	models.TaskStatusPending:    {models.TaskStatusInProgress, models.TaskStatusCompleted, models.TaskStatusCancelled},
+	models.TaskStatusInProgress: {models.TaskStatusPending, models.TaskStatusCompleted, models.TaskStatusCancelled},
+	models.TaskStatusCompleted:  {models.TaskStatusPending, models.TaskStatusInProgress},
+	models.TaskStatusCancelled:  {models.TaskStatusPending},
+}
+
+// StatusWriter stores a status change that passed the status policies in
+// place of TaskService's own write. It returns sql.ErrNoRows when the task no
+// longer has the from status.
+type StatusWriter func(task *models.Task, from, to models.TaskStatus) (*models.Task, error)
+
+func canTransition(from, to models.TaskStatus) bool {
+	if from == to {
+		return true
+	}
+	for _, allowed := range taskStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *taskService) UpdateTaskStatus(taskID, userID int64, status models.TaskStatus) (*models.TaskStatusResult, error) {
+	return s.UpdateTaskStatusWith(taskID, userID, status, nil)
+}
+
+// UpdateTaskStatusWith is UpdateTaskStatus with write storing the change, so
+// that callers can make it part of a larger update
+func (s *taskService) UpdateTaskStatusWith(taskID, userID int64, status models.TaskStatus, write StatusWriter) (*models.TaskStatusResult, error) {
+	if !status.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidStatusTransition, status)
+	}
+
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	return s.recordStatusChange(task, status, write)
+}
+
+// recordStatusChange is changeStatus for a change made on its own, recorded
+// in the task's revision history
+func (s *taskService) recordStatusChange(task *models.Task, to models.TaskStatus, write StatusWriter) (*models.TaskStatusResult, error) {
+	before := snapshot(task)
+	result, err := s.changeStatus(task, to, true, write)
+	if err != nil {
+		return nil, err
+	}
+	if result.Task != task {
+		recordRevision(s.revisionRepo, task.UserID, models.RevisionTask, task.ID, models.RevisionUpdate, before, snapshot(result.Task), nil)
+	}
+	return result, nil
+}
+
+// transitionTask applies a validated status change and records it in the
+// status history. Completing a task also applies the subtask and blocked-task
+// policies and, for recurring tasks, generates the next occurrence.
+func (s *taskService) transitionTask(task *models.Task, to models.TaskStatus) (*models.TaskStatusResult, error) {
+	return s.changeStatus(task, to, true, nil)
+}
+
+// changeStatus is transitionTask; nextOccurrence false leaves out the next
+// occurrence of a recurring task, for imports that describe the series
+// themselves, and a non-nil write stores the change instead of the task
+// repository
+func (s *taskService) changeStatus(task *models.Task, to models.TaskStatus, nextOccurrence bool, write StatusWriter) (*models.TaskStatusResult, error) {
+	from := task.Status
+	if from == to {
+		return &models.TaskStatusResult{Task: task}, nil
+	}
+	if !canTransition(from, to) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, from, to)
+	}
+	var warnings []string
+	if to == models.TaskStatusCompleted {
+		var err error
+		if warnings, err = s.checkCompletion(task); err != nil {
+			return nil, err
+		}
+	}
+	return s.applyStatus(task, to, warnings, nextOccurrence, write)
+}
+
+// applyStatus stores, logs and follows up on a status change that already
+// passed the checks of changeStatus
+func (s *taskService) applyStatus(task *models.Task, to models.TaskStatus, warnings []string, nextOccurrence bool, write StatusWriter) (*models.TaskStatusResult, error) {
+	from := task.Status
+	if write == nil {
+		write = func(task *models.Task, from, to models.TaskStatus) (*models.Task, error) {
+			return s.taskRepo.UpdateTaskStatus(task.ID, task.UserID, from, to)
+		}
+	}
+	updatedTask, err := write(task, from, to)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: task status was changed by another request", ErrInvalidStatusTransition)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task status: %w", err)
+	}
+
+	// Log task status change
+	metadata := map[string]interface{}{
+		"task_id":      task.ID,
+		"task_name":    task.TaskName,
+		"from_status":  from,
+		"to_status":    to,
+		"is_completed": updatedTask.IsCompleted,
+	}
+	if len(warnings) > 0 {
+		metadata["warnings"] = warnings
+	}
+	s.logRepo.CreateLog(&task.UserID, "task_status_changed", fmt.Sprintf("Task '%s' moved from %s to %s", task.TaskName, from, to), metadata)
+
+	result := &models.TaskStatusResult{Task: updatedTask, Warnings: warnings}
+	if to == models.TaskStatusCompleted {
+		if err := s.completeSubtasks(updatedTask); err != nil {
+			return nil, err
+		}
+		if nextOccurrence {
+			nextTask, err := s.createNextOccurrence(updatedTask)
+			if err != nil {
+				return nil, err
+			}
+			result.NextOccurrence = nextTask
+		}
+	}
+
+	return result, nil
+}
+
+func (s *taskService) GetTaskStatusHistory(taskID, userID int64) ([]*models.TaskStatusHistory, error) {
+	// Make sure the task exists and belongs to the user
+	if _, err := s.taskRepo.GetTaskByID(taskID, userID); err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	history, err := s.taskRepo.GetTaskStatusHistory(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	return history, nil
+}
+
+func (s *taskService) GetTasksByStatus(userID int64, status models.TaskStatus, page, pageSize int) ([]*models.Task, int64, error) {
+	tasks, total, err := s.taskRepo.GetTasksByStatusPaginated(userID, status, page, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get tasks by status: %w", err)
+	}
+	return tasks, total, nil
+}
@@ -0,0 +1,542 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrTemplateNotFound is returned when a template does not exist or belongs to another user
+var ErrTemplateNotFound = errors.New("template not found")
+
+// ErrInvalidTemplate is returned for templates with missing names, bad due
+// offsets or placeholders without a value
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// ErrTemplateNameTaken is returned when the user already has a template with the name
+var ErrTemplateNameTaken = errors.New("template name already in use")
+
+// maxTemplateTasks caps the tasks in one template, subtasks included
+const maxTemplateTasks = 200
+
+// placeholderPattern matches {{name}} and {{ name }}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// dueOffsetPattern matches due offsets such as +2d, -1w and 36h
+var dueOffsetPattern = regexp.MustCompile(`^([+-]?)(\d+)([dwh])$`)
+
+// Task Template Service
+type TemplateService interface {
+	CreateTemplate(userID int64, req *models.CreateTaskTemplateRequest) (*models.TaskTemplate, error)
+	CreateTemplateFromTasks(userID int64, req *models.TemplateFromTasksRequest) (*models.TaskTemplate, error)
+	GetTemplates(userID int64) ([]*models.TaskTemplate, error)
+	GetTemplate(templateID, userID int64) (*models.TaskTemplate, error)
+	UpdateTemplate(templateID, userID int64, req *models.UpdateTaskTemplateRequest) (*models.TaskTemplate, error)
+	DeleteTemplate(templateID, userID int64) error
+	InstantiateTemplate(templateID, userID int64, req *models.InstantiateTemplateRequest) (*models.TemplateInstance, error)
+}
+
+type templateService struct {
+	templateRepo repositories.TemplateRepository
+	taskRepo     repositories.TaskRepository
+	logRepo      repositories.LogRepository
+	revisionRepo repositories.RevisionRepository
+}
+
+func NewTemplateService(templateRepo repositories.TemplateRepository, taskRepo repositories.TaskRepository, logRepo repositories.LogRepository, revisionRepo repositories.RevisionRepository) TemplateService {
+	return &templateService{
+		templateRepo: templateRepo,
+		taskRepo:     taskRepo,
+		logRepo:      logRepo,
+		revisionRepo: revisionRepo,
+	}
+}
+
+// describeTemplate fills in the fields derived from a template's tasks
+func describeTemplate(template *models.TaskTemplate) *models.TaskTemplate {
+	if template.Tasks == nil {
+		template.Tasks = []*models.TemplateTask{}
+	}
+	template.Variables = templateVariables(template.Tasks)
+	template.TaskCount = countTemplateTasks(template.Tasks)
+	return template
+}
+
+func countTemplateTasks(tasks []*models.TemplateTask) int {
+	count := 0
+	for _, task := range tasks {
+		count += 1 + countTemplateTasks(task.Subtasks)
+	}
+	return count
+}
+
+// templateVariables lists the placeholder names used anywhere in the tasks, sorted
+func templateVariables(tasks []*models.TemplateTask) []string {
+	seen := make(map[string]bool)
+	var walk func(tasks []*models.TemplateTask)
+	collect := func(text string) {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			seen[match[1]] = true
+		}
+	}
+	walk = func(tasks []*models.TemplateTask) {
+		for _, task := range tasks {
+			collect(task.TaskName)
+			if task.Description != nil {
+				collect(*task.Description)
+			}
+			if task.Category != nil {
+				collect(*task.Category)
+			}
+			for _, tag := range task.Tags {
+				collect(tag)
+			}
+			walk(task.Subtasks)
+		}
+	}
+	walk(tasks)
+
+	variables := make([]string, 0, len(seen))
+	for name := range seen {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// parseDueOffset reads a due offset as a number of days or hours
+func parseDueOffset(offset string) (days, hours int, err error) {
+	match := dueOffsetPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(offset)))
+	if match == nil {
+		return 0, 0, fmt.Errorf("%w: due offset %q (expected e.g. +2d, -1w or +36h)", ErrInvalidTemplate, offset)
+	}
+	n, err := strconv.Atoi(match[2])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: due offset %q is too large", ErrInvalidTemplate, offset)
+	}
+	if match[1] == "-" {
+		n = -n
+	}
+	switch match[3] {
+	case "w":
+		return 7 * n, 0, nil
+	case "h":
+		return 0, n, nil
+	}
+	return n, 0, nil
+}
+
+// formatDueOffset is the inverse of parseDueOffset: whole days are written
+// in days and anything else in hours
+func formatDueOffset(due, anchor time.Time) string {
+	diff := due.Sub(anchor)
+	sign := "+"
+	if diff < 0 {
+		sign, diff = "-", -diff
+	}
+	if diff%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%s%dd", sign, int64(diff/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%s%dh", sign, int64(diff.Round(time.Hour)/time.Hour))
+}
+
+// validateTemplateTasks checks names, due offsets, estimates and tags and
+// trims task names in place
+func validateTemplateTasks(tasks []*models.TemplateTask) error {
+	if len(tasks) == 0 {
+		return fmt.Errorf("%w: a template needs at least one task", ErrInvalidTemplate)
+	}
+	if count := countTemplateTasks(tasks); count > maxTemplateTasks {
+		return fmt.Errorf("%w: %d tasks (at most %d)", ErrInvalidTemplate, count, maxTemplateTasks)
+	}
+
+	var check func(tasks []*models.TemplateTask) error
+	check = func(tasks []*models.TemplateTask) error {
+		for _, task := range tasks {
+			if task == nil {
+				return fmt.Errorf("%w: empty task", ErrInvalidTemplate)
+			}
+			task.TaskName = strings.TrimSpace(task.TaskName)
+			if task.TaskName == "" {
+				return fmt.Errorf("%w: every task needs a task_name", ErrInvalidTemplate)
+			}
+			if task.DueOffset != nil {
+				if _, _, err := parseDueOffset(*task.DueOffset); err != nil {
+					return err
+				}
+			}
+			if task.EstimateMinutes != nil && *task.EstimateMinutes < 1 {
+				return fmt.Errorf("%w: estimate_minutes of '%s' must be at least 1", ErrInvalidTemplate, task.TaskName)
+			}
+			tags, err := normalizeTags(task.Tags)
+			if err != nil {
+				return err
+			}
+			task.Tags = tags
+			if err := check(task.Subtasks); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(tasks)
+}
+
+// checkTemplateName makes sure the user has no other template with the name
+func (s *templateService) checkTemplateName(userID int64, name string, templateID int64) error {
+	existing, err := s.templateRepo.GetTemplateByName(userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check template name: %w", err)
+	}
+	if existing.ID != templateID {
+		return fmt.Errorf("%w: '%s'", ErrTemplateNameTaken, existing.Name)
+	}
+	return nil
+}
+
+func (s *templateService) getTemplate(templateID, userID int64) (*models.TaskTemplate, error) {
+	template, err := s.templateRepo.GetTemplateByID(templateID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrTemplateNotFound, templateID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	return template, nil
+}
+
+func (s *templateService) saveTemplate(userID int64, name string, description *string, tasks []*models.TemplateTask) (*models.TaskTemplate, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if err := validateTemplateTasks(tasks); err != nil {
+		return nil, err
+	}
+	if err := s.checkTemplateName(userID, name, 0); err != nil {
+		return nil, err
+	}
+
+	template, err := s.templateRepo.CreateTemplate(&models.TaskTemplate{
+		UserID:      userID,
+		Name:        name,
+		Description: description,
+		Tasks:       tasks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	describeTemplate(template)
+
+	metadata := map[string]interface{}{
+		"template_id": template.ID,
+		"name":        template.Name,
+		"task_count":  template.TaskCount,
+	}
+	s.logRepo.CreateLog(&userID, "template_created", fmt.Sprintf("Template '%s' created", template.Name), metadata)
+
+	return template, nil
+}
+
+func (s *templateService) CreateTemplate(userID int64, req *models.CreateTaskTemplateRequest) (*models.TaskTemplate, error) {
+	return s.saveTemplate(userID, req.Name, req.Description, req.Tasks)
+}
+
+// CreateTemplateFromTasks saves existing tasks, and by default their
+// subtasks, as a template. Tasks whose parent is also saved stay nested
+// under it; due dates become offsets from the anchor date.
+func (s *templateService) CreateTemplateFromTasks(userID int64, req *models.TemplateFromTasksRequest) (*models.TaskTemplate, error) {
+	selected, err := s.taskRepo.GetTasksByIDs(userID, req.TaskIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	byID := make(map[int64]*models.Task, len(selected))
+	for _, task := range selected {
+		byID[task.ID] = task
+	}
+	for _, id := range req.TaskIDs {
+		if _, ok := byID[id]; !ok {
+			return nil, fmt.Errorf("%w: task %d not found", ErrInvalidTemplate, id)
+		}
+	}
+
+	if req.IncludeSubtasks == nil || *req.IncludeSubtasks {
+		for _, task := range selected {
+			descendants, err := s.taskRepo.GetTaskDescendants(task.ID, userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get subtasks: %w", err)
+			}
+			for _, descendant := range descendants {
+				byID[descendant.ID] = descendant
+			}
+		}
+	}
+
+	tasks := make([]*models.Task, 0, len(byID))
+	for _, task := range byID {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+
+	var anchor *time.Time
+	if req.AnchorDate != nil && !req.AnchorDate.IsZero() {
+		anchor = &req.AnchorDate.Time
+	} else {
+		for _, task := range tasks {
+			if task.DueDate != nil && !task.DueDate.IsZero() && (anchor == nil || task.DueDate.Before(*anchor)) {
+				due := task.DueDate.Time
+				anchor = &due
+			}
+		}
+	}
+
+	children := make(map[int64][]*models.Task)
+	var roots []*models.Task
+	for _, task := range tasks {
+		if task.ParentID != nil {
+			if _, ok := byID[*task.ParentID]; ok {
+				children[*task.ParentID] = append(children[*task.ParentID], task)
+				continue
+			}
+		}
+		roots = append(roots, task)
+	}
+
+	var convert func(tasks []*models.Task) []*models.TemplateTask
+	convert = func(tasks []*models.Task) []*models.TemplateTask {
+		converted := make([]*models.TemplateTask, 0, len(tasks))
+		for _, task := range tasks {
+			templateTask := &models.TemplateTask{
+				TaskName:        task.TaskName,
+				Description:     task.Description,
+				Category:        task.Category,
+				Priority:        task.Priority,
+				EstimateMinutes: task.EstimateMinutes,
+				Tags:            task.Tags,
+				Subtasks:        convert(children[task.ID]),
+			}
+			if task.DueDate != nil && !task.DueDate.IsZero() && anchor != nil {
+				offset := formatDueOffset(task.DueDate.Time, *anchor)
+				templateTask.DueOffset = &offset
+			}
+			converted = append(converted, templateTask)
+		}
+		return converted
+	}
+
+	return s.saveTemplate(userID, req.Name, req.Description, convert(roots))
+}
+
+func (s *templateService) GetTemplates(userID int64) ([]*models.TaskTemplate, error) {
+	templates, err := s.templateRepo.GetTemplates(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get templates: %w", err)
+	}
+	for _, template := range templates {
+		describeTemplate(template)
+	}
+	return templates, nil
+}
+
+func (s *templateService) GetTemplate(templateID, userID int64) (*models.TaskTemplate, error) {
+	template, err := s.getTemplate(templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return describeTemplate(template), nil
+}
+
+func (s *templateService) UpdateTemplate(templateID, userID int64, req *models.UpdateTaskTemplateRequest) (*models.TaskTemplate, error) {
+	template, err := s.getTemplate(templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+		}
+		if err := s.checkTemplateName(userID, name, templateID); err != nil {
+			return nil, err
+		}
+		template.Name = name
+	}
+	if req.Description != nil {
+		template.Description = req.Description
+	}
+	if req.Tasks != nil {
+		if err := validateTemplateTasks(req.Tasks); err != nil {
+			return nil, err
+		}
+		template.Tasks = req.Tasks
+	}
+
+	updatedTemplate, err := s.templateRepo.UpdateTemplate(template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update template: %w", err)
+	}
+	describeTemplate(updatedTemplate)
+
+	metadata := map[string]interface{}{
+		"template_id": templateID,
+		"name":        updatedTemplate.Name,
+		"task_count":  updatedTemplate.TaskCount,
+	}
+	s.logRepo.CreateLog(&userID, "template_updated", fmt.Sprintf("Template '%s' updated", updatedTemplate.Name), metadata)
+
+	return updatedTemplate, nil
+}
+
+func (s *templateService) DeleteTemplate(templateID, userID int64) error {
+	template, err := s.getTemplate(templateID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.templateRepo.DeleteTemplate(templateID, userID); err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"template_id": templateID,
+		"name":        template.Name,
+	}
+	s.logRepo.CreateLog(&userID, "template_deleted", fmt.Sprintf("Template '%s' deleted", template.Name), metadata)
+
+	return nil
+}
+
+// InstantiateTemplate creates a template's tasks in one transaction, with
+// placeholders replaced by the given values and due dates counted from the
+// anchor date
+func (s *templateService) InstantiateTemplate(templateID, userID int64, req *models.InstantiateTemplateRequest) (*models.TemplateInstance, error) {
+	template, err := s.getTemplate(templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(template.Tasks) == 0 {
+		return nil, fmt.Errorf("%w: template has no tasks", ErrInvalidTemplate)
+	}
+
+	var missing []string
+	for _, name := range templateVariables(template.Tasks) {
+		if _, ok := req.Variables[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing values for %s", ErrInvalidTemplate, strings.Join(missing, ", "))
+	}
+
+	if req.ParentID != nil {
+		if _, err := s.taskRepo.GetTaskByID(*req.ParentID, userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%w: parent task %d not found", ErrInvalidParent, *req.ParentID)
+			}
+			return nil, fmt.Errorf("failed to get parent task: %w", err)
+		}
+	}
+
+	anchor := time.Now()
+	if req.AnchorDate != nil && !req.AnchorDate.IsZero() {
+		anchor = req.AnchorDate.Time
+	}
+
+	fill := func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			return req.Variables[placeholderPattern.FindStringSubmatch(placeholder)[1]]
+		})
+	}
+	fillOptional := func(text *string) *string {
+		if text == nil {
+			return nil
+		}
+		filled := strings.TrimSpace(fill(*text))
+		if filled == "" {
+			return nil
+		}
+		return &filled
+	}
+
+	var build func(tasks []*models.TemplateTask) ([]*models.TaskTree, error)
+	build = func(tasks []*models.TemplateTask) ([]*models.TaskTree, error) {
+		trees := make([]*models.TaskTree, 0, len(tasks))
+		for _, templateTask := range tasks {
+			name := strings.TrimSpace(fill(templateTask.TaskName))
+			if name == "" {
+				return nil, fmt.Errorf("%w: '%s' has an empty name once filled in", ErrInvalidTemplate, templateTask.TaskName)
+			}
+			tags := make([]string, len(templateTask.Tags))
+			for i, tag := range templateTask.Tags {
+				tags[i] = fill(tag)
+			}
+			tags, err := normalizeTags(tags)
+			if err != nil {
+				return nil, err
+			}
+
+			task := &models.Task{
+				UserID:          userID,
+				TaskName:        name,
+				Description:     fillOptional(templateTask.Description),
+				Category:        fillOptional(templateTask.Category),
+				Priority:        templateTask.Priority,
+				EstimateMinutes: templateTask.EstimateMinutes,
+				Tags:            tags,
+			}
+			if templateTask.DueOffset != nil {
+				days, hours, err := parseDueOffset(*templateTask.DueOffset)
+				if err != nil {
+					return nil, err
+				}
+				task.DueDate = &models.CustomTime{Time: anchor.AddDate(0, 0, days).Add(time.Duration(hours) * time.Hour)}
+			}
+
+			subtasks, err := build(templateTask.Subtasks)
+			if err != nil {
+				return nil, err
+			}
+			trees = append(trees, &models.TaskTree{Task: task, Subtasks: subtasks})
+		}
+		return trees, nil
+	}
+	trees, err := build(template.Tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.CreateTaskTrees(userID, req.ParentID, trees)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tasks from template: %w", err)
+	}
+	for _, task := range tasks {
+		recordRevision(s.revisionRepo, userID, models.RevisionTask, task.ID, models.RevisionCreate, nil, snapshot(task), nil)
+	}
+
+	metadata := map[string]interface{}{
+		"template_id": templateID,
+		"name":        template.Name,
+		"anchor_date": anchor,
+		"variables":   req.Variables,
+		"created":     len(tasks),
+	}
+	s.logRepo.CreateLog(&userID, "template_instantiated", fmt.Sprintf("Template '%s' instantiated with %d tasks", template.Name, len(tasks)), metadata)
+
+	return &models.TemplateInstance{
+		TemplateID:   templateID,
+		TemplateName: template.Name,
+		Tasks:        tasks,
+		Created:      len(tasks),
+	}, nil
+}
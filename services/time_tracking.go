@@ -0,0 +1,317 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrTimerRunning is returned when a timer is started while another one is running
+var ErrTimerRunning = errors.New("a timer is already running")
+
+// ErrNoRunningTimer is returned when stopping a task's timer that is not running
+var ErrNoRunningTimer = errors.New("no running timer")
+
+// ErrInvalidTimeEntry is returned for time entries with an invalid time range
+var ErrInvalidTimeEntry = errors.New("invalid time entry")
+
+// Time Tracking Service
+type TimeTrackingService interface {
+	StartTimer(taskID, userID int64, req *models.StartTimerRequest) (*models.TimeEntry, error)
+	StopTimer(taskID, userID int64) (*models.TimeEntry, error)
+	GetRunningTimer(userID int64) (*models.TimeEntry, error)
+	AddTimeEntry(taskID, userID int64, req *models.CreateTimeEntryRequest) (*models.TimeEntry, error)
+	UpdateTimeEntry(entryID, userID int64, req *models.UpdateTimeEntryRequest) (*models.TimeEntry, error)
+	DeleteTimeEntry(entryID, userID int64) error
+	GetTaskTimeReport(taskID, userID int64) (*models.TaskTimeReport, error)
+	GetTimeAllocation(userID int64, since *time.Time) (*models.TimeAllocationReport, error)
+}
+
+type timeTrackingService struct {
+	timeEntryRepo repositories.TimeEntryRepository
+	taskRepo      repositories.TaskRepository
+	logRepo       repositories.LogRepository
+}
+
+func NewTimeTrackingService(timeEntryRepo repositories.TimeEntryRepository, taskRepo repositories.TaskRepository, logRepo repositories.LogRepository) TimeTrackingService {
+	return &timeTrackingService{
+		timeEntryRepo: timeEntryRepo,
+		taskRepo:      taskRepo,
+		logRepo:       logRepo,
+	}
+}
+
+// validateTimeRange checks a finished entry: it ends after it starts and
+// neither end lies in the future
+func validateTimeRange(startedAt time.Time, endedAt *time.Time) error {
+	now := time.Now()
+	if startedAt.After(now) {
+		return fmt.Errorf("%w: started_at is in the future", ErrInvalidTimeEntry)
+	}
+	if endedAt == nil {
+		return nil
+	}
+	if !endedAt.After(startedAt) {
+		return fmt.Errorf("%w: ended_at must be after started_at", ErrInvalidTimeEntry)
+	}
+	if endedAt.After(now) {
+		return fmt.Errorf("%w: ended_at is in the future", ErrInvalidTimeEntry)
+	}
+	return nil
+}
+
+// StartTimer starts a timer on a task. A user has at most one running timer;
+// it has to be stopped before another one can start.
+func (s *timeTrackingService) StartTimer(taskID, userID int64, req *models.StartTimerRequest) (*models.TimeEntry, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	entry, err := s.timeEntryRepo.StartTimer(userID, taskID, optionalText(req.Note))
+	if errors.Is(err, sql.ErrNoRows) {
+		running, err := s.timeEntryRepo.GetRunningTimer(userID)
+		if err != nil {
+			return nil, ErrTimerRunning
+		}
+		return nil, fmt.Errorf("%w on task %d since %s", ErrTimerRunning, running.TaskID, running.StartedAt.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start timer: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"task_name":     task.TaskName,
+		"time_entry_id": entry.ID,
+	}
+	s.logRepo.CreateLog(&userID, "timer_started", fmt.Sprintf("Timer started on task '%s'", task.TaskName), metadata)
+
+	return entry, nil
+}
+
+func (s *timeTrackingService) StopTimer(taskID, userID int64) (*models.TimeEntry, error) {
+	entry, err := s.timeEntryRepo.StopTimer(userID, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w on task %d", ErrNoRunningTimer, taskID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop timer: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"time_entry_id": entry.ID,
+		"minutes":       entry.Minutes,
+	}
+	s.logRepo.CreateLog(&userID, "timer_stopped", fmt.Sprintf("Timer stopped after %d minutes", entry.Minutes), metadata)
+
+	return entry, nil
+}
+
+// GetRunningTimer returns the user's running timer, or nil when none is running
+func (s *timeTrackingService) GetRunningTimer(userID int64) (*models.TimeEntry, error) {
+	entry, err := s.timeEntryRepo.GetRunningTimer(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running timer: %w", err)
+	}
+	return entry, nil
+}
+
+func (s *timeTrackingService) AddTimeEntry(taskID, userID int64, req *models.CreateTimeEntryRequest) (*models.TimeEntry, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	startedAt := req.StartedAt.Time
+	var endedAt time.Time
+	switch {
+	case req.EndedAt != nil && req.Minutes != nil:
+		return nil, fmt.Errorf("%w: give either ended_at or minutes, not both", ErrInvalidTimeEntry)
+	case req.EndedAt != nil:
+		endedAt = req.EndedAt.Time
+	case req.Minutes != nil:
+		endedAt = startedAt.Add(time.Duration(*req.Minutes) * time.Minute)
+	default:
+		return nil, fmt.Errorf("%w: ended_at or minutes is required", ErrInvalidTimeEntry)
+	}
+	if err := validateTimeRange(startedAt, &endedAt); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.timeEntryRepo.CreateTimeEntry(&models.TimeEntry{
+		UserID:    userID,
+		TaskID:    taskID,
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+		Note:      optionalText(req.Note),
+		Source:    models.TimeEntryManual,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add time entry: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":       taskID,
+		"task_name":     task.TaskName,
+		"time_entry_id": entry.ID,
+		"minutes":       entry.Minutes,
+	}
+	s.logRepo.CreateLog(&userID, "time_entry_added", fmt.Sprintf("Logged %d minutes on task '%s'", entry.Minutes, task.TaskName), metadata)
+
+	return entry, nil
+}
+
+// UpdateTimeEntry edits the range or note of an entry. Giving ended_at for a
+// running timer stops it.
+func (s *timeTrackingService) UpdateTimeEntry(entryID, userID int64, req *models.UpdateTimeEntryRequest) (*models.TimeEntry, error) {
+	entry, err := s.timeEntryRepo.GetTimeEntryByID(entryID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("time entry not found: %w", err)
+	}
+	previousMinutes := entry.Minutes
+
+	if req.StartedAt != nil {
+		entry.StartedAt = req.StartedAt.Time
+	}
+	if req.EndedAt != nil {
+		endedAt := req.EndedAt.Time
+		entry.EndedAt = &endedAt
+	}
+	if req.Note != nil {
+		entry.Note = optionalText(req.Note)
+	}
+	if err := validateTimeRange(entry.StartedAt, entry.EndedAt); err != nil {
+		return nil, err
+	}
+
+	updatedEntry, err := s.timeEntryRepo.UpdateTimeEntry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update time entry: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":          updatedEntry.TaskID,
+		"time_entry_id":    entryID,
+		"previous_minutes": previousMinutes,
+		"minutes":          updatedEntry.Minutes,
+	}
+	s.logRepo.CreateLog(&userID, "time_entry_updated", fmt.Sprintf("Time entry updated to %d minutes", updatedEntry.Minutes), metadata)
+
+	return updatedEntry, nil
+}
+
+func (s *timeTrackingService) DeleteTimeEntry(entryID, userID int64) error {
+	entry, err := s.timeEntryRepo.GetTimeEntryByID(entryID, userID)
+	if err != nil {
+		return fmt.Errorf("time entry not found: %w", err)
+	}
+
+	if err := s.timeEntryRepo.DeleteTimeEntry(entryID, userID); err != nil {
+		return fmt.Errorf("failed to delete time entry: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"task_id":       entry.TaskID,
+		"time_entry_id": entryID,
+		"minutes":       entry.Minutes,
+	}
+	s.logRepo.CreateLog(&userID, "time_entry_deleted", fmt.Sprintf("Time entry of %d minutes deleted", entry.Minutes), metadata)
+
+	return nil
+}
+
+// GetTaskTimeReport compares a task's estimate with its time entries and
+// completed pomodoros
+func (s *timeTrackingService) GetTaskTimeReport(taskID, userID int64) (*models.TaskTimeReport, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	entries, err := s.timeEntryRepo.GetTaskTimeEntries(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time entries: %w", err)
+	}
+	pomodoroMinutes, err := s.timeEntryRepo.GetTaskPomodoroMinutes(taskID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pomodoro minutes: %w", err)
+	}
+
+	report := &models.TaskTimeReport{
+		TaskID:          task.ID,
+		TaskName:        task.TaskName,
+		EstimateMinutes: task.EstimateMinutes,
+		PomodoroMinutes: pomodoroMinutes,
+		Entries:         entries,
+	}
+	for _, entry := range entries {
+		report.TrackedMinutes += entry.Minutes
+	}
+	report.ActualMinutes = report.TrackedMinutes + report.PomodoroMinutes
+	if task.EstimateMinutes != nil {
+		variance := report.ActualMinutes - int64(*task.EstimateMinutes)
+		report.VarianceMinutes = &variance
+	}
+
+	return report, nil
+}
+
+// GetTimeAllocation reports the minutes spent per task and per category since
+// a given time, or over all time when since is nil
+func (s *timeTrackingService) GetTimeAllocation(userID int64, since *time.Time) (*models.TimeAllocationReport, error) {
+	tasks, err := s.timeEntryRepo.GetTimeAllocation(userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time allocation: %w", err)
+	}
+
+	report := &models.TimeAllocationReport{Since: since, Tasks: tasks}
+	categories := make(map[string]*models.TimeAllocation)
+	for _, task := range tasks {
+		report.TotalMinutes += task.TotalMinutes
+
+		category, ok := categories[task.Category]
+		if !ok {
+			category = &models.TimeAllocation{Category: task.Category}
+			categories[task.Category] = category
+		}
+		if task.TaskID != nil {
+			category.TaskCount++
+		}
+		category.TrackedMinutes += task.TrackedMinutes
+		category.PomodoroMinutes += task.PomodoroMinutes
+		category.TotalMinutes += task.TotalMinutes
+	}
+
+	report.Categories = make([]*models.TimeAllocation, 0, len(categories))
+	for _, category := range categories {
+		report.Categories = append(report.Categories, category)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		a, b := report.Categories[i], report.Categories[j]
+		if a.TotalMinutes != b.TotalMinutes {
+			return a.TotalMinutes > b.TotalMinutes
+		}
+		return a.Category < b.Category
+	})
+
+	if report.TotalMinutes > 0 {
+		total := float64(report.TotalMinutes)
+		for _, category := range report.Categories {
+			category.Percentage = float64(category.TotalMinutes) / total * 100
+		}
+		for _, task := range report.Tasks {
+			task.Percentage = float64(task.TotalMinutes) / total * 100
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"todo-backend/models"
+	"todo-backend/repositories"
+)
+
+// ErrInvalidTrashType is returned for trash item types other than task, habit and goal
+var ErrInvalidTrashType = errors.New("invalid trash item type")
+
+// Trash Service
+type TrashService interface {
+	GetTrash(userID int64, itemType string) ([]*models.TrashItem, error)
+	RestoreItem(itemType string, itemID, userID int64) error
+	PurgeItem(itemType string, itemID, userID int64) error
+	EmptyTrash(userID int64) (int64, error)
+	PurgeExpired() (int64, error)
+	RunPurger(interval time.Duration)
+	RetentionDays() int
+}
+
+type trashService struct {
+	trashRepo         repositories.TrashRepository
+	logRepo           repositories.LogRepository
+	attachmentService AttachmentService
+	retentionDays     int
+}
+
+// NewTrashService creates the trash service. Items stay in the trash for
+// retentionDays; 0 keeps them until they are purged by hand. The attachments
+// of purged tasks are deleted through attachmentService.
+func NewTrashService(trashRepo repositories.TrashRepository, logRepo repositories.LogRepository, attachmentService AttachmentService, retentionDays int) TrashService {
+	return &trashService{
+		trashRepo:         trashRepo,
+		logRepo:           logRepo,
+		attachmentService: attachmentService,
+		retentionDays:     retentionDays,
+	}
+}
+
+// sweepAttachments deletes the attachment blobs of purged tasks. A failure
+// is only logged: the purge itself succeeded and the next sweep retries.
+func (s *trashService) sweepAttachments() {
+	if _, err := s.attachmentService.SweepOrphans(); err != nil {
+		log.Printf("Attachment sweep failed: %v", err)
+	}
+}
+
+func validTrashType(itemType string) error {
+	switch itemType {
+	case models.TrashTask, models.TrashHabit, models.TrashGoal:
+		return nil
+	}
+	return fmt.Errorf("%w: %q (expected task, habit or goal)", ErrInvalidTrashType, itemType)
+}
+
+func (s *trashService) RetentionDays() int {
+	return s.retentionDays
+}
+
+func (s *trashService) GetTrash(userID int64, itemType string) ([]*models.TrashItem, error) {
+	if itemType != "" {
+		if err := validTrashType(itemType); err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := s.trashRepo.GetTrash(userID, itemType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash: %w", err)
+	}
+
+	if s.retentionDays > 0 {
+		for _, item := range items {
+			purgeAt := item.DeletedAt.AddDate(0, 0, s.retentionDays)
+			item.PurgeAt = &purgeAt
+		}
+	}
+	return items, nil
+}
+
+func (s *trashService) RestoreItem(itemType string, itemID, userID int64) error {
+	if err := validTrashType(itemType); err != nil {
+		return err
+	}
+
+	if err := s.trashRepo.RestoreItem(itemType, itemID, userID); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", itemType, err)
+	}
+
+	metadata := map[string]interface{}{
+		"type":    itemType,
+		"item_id": itemID,
+	}
+	s.logRepo.CreateLog(&userID, "trash_restored", fmt.Sprintf("Restored %s %d from the trash", itemType, itemID), metadata)
+
+	return nil
+}
+
+func (s *trashService) PurgeItem(itemType string, itemID, userID int64) error {
+	if err := validTrashType(itemType); err != nil {
+		return err
+	}
+
+	if err := s.trashRepo.PurgeItem(itemType, itemID, userID); err != nil {
+		return fmt.Errorf("failed to purge %s: %w", itemType, err)
+	}
+	s.sweepAttachments()
+
+	metadata := map[string]interface{}{
+		"type":    itemType,
+		"item_id": itemID,
+	}
+	s.logRepo.CreateLog(&userID, "trash_purged", fmt.Sprintf("Permanently deleted %s %d", itemType, itemID), metadata)
+
+	return nil
+}
+
+func (s *trashService) EmptyTrash(userID int64) (int64, error) {
+	purged, err := s.trashRepo.EmptyTrash(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to empty trash: %w", err)
+	}
+	s.sweepAttachments()
+
+	metadata := map[string]interface{}{
+		"purged": purged,
+	}
+	s.logRepo.CreateLog(&userID, "trash_emptied", fmt.Sprintf("Emptied the trash (%d items)", purged), metadata)
+
+	return purged, nil
+}
+
+// PurgeExpired permanently deletes the items of every user that have been
+// in the trash longer than the retention period. Orphaned attachments are
+// swept even when retention is off, as deleting a user orphans theirs.
+func (s *trashService) PurgeExpired() (int64, error) {
+	if s.retentionDays == 0 {
+		s.sweepAttachments()
+		return 0, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.retentionDays)
+	purged, err := s.trashRepo.PurgeDeletedBefore(cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired trash: %w", err)
+	}
+	s.sweepAttachments()
+	return purged, nil
+}
+
+// RunPurger calls PurgeExpired now and then every interval. It never
+// returns and is meant to run in its own goroutine.
+func (s *trashService) RunPurger(interval time.Duration) {
+	for {
+		purged, err := s.PurgeExpired()
+		if err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d expired item(s) from the trash", purged)
+		}
+		time.Sleep(interval)
+	}
+}
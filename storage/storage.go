@@ -0,0 +1,74 @@
+// Package storage keeps file contents (blobs) behind a small interface so
+// that where they live is a deployment choice: a local directory or an
+// S3-compatible object store such as MinIO.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when no blob is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for empty keys and keys that could escape the
+// store, such as ones with ".." segments
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Store saves, reads and deletes blobs by key. Keys are slash-separated
+// paths such as "users/1/tasks/2/abc".
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any blob
+	// already stored there
+	Put(key string, body io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key; the caller closes it
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the blob under key; a missing blob is not an error
+	Delete(key string) error
+}
+
+// Backends accepted by Open
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Config selects and configures a backend. Dir is used by the local
+// backend, the rest by the S3 backend.
+type Config struct {
+	Backend string
+
+	Dir string
+
+	Endpoint  string // e.g. http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// Open returns the store described by cfg
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendLocal, "":
+		return NewLocalStore(cfg.Dir)
+	case BackendS3:
+		return NewS3Store(cfg.Endpoint, cfg.Region, cfg.Bucket, cfg.AccessKey, cfg.SecretKey)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q (expected local or s3)", cfg.Backend)
+}
+
+// checkKey rejects keys that are empty, absolute or step outside the store
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
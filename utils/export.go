@@ -7,7 +7,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"todo-backend/ical"
 	"todo-backend/models"
+	"todo-backend/recurrence"
 )
 
 // Export functions
@@ -22,7 +24,8 @@ func ExportTasksAsCSV(tasks []*models.Task) ([]byte, error) {
 	// Write header
 	header := []string{
 		"ID", "TaskName", "Description", "Category", "Priority",
-		"DueDate", "IsCompleted", "IsRecurring", "RecurringFrequency", "CreatedAt",
+		"DueDate", "IsCompleted", "IsRecurring", "RecurringFrequency", "CreatedAt", "Tags",
+		"Project", "Position",
 	}
 	if err := writer.Write(header); err != nil {
 		return nil, err
@@ -41,6 +44,9 @@ func ExportTasksAsCSV(tasks []*models.Task) ([]byte, error) {
 			strconv.FormatBool(task.IsRecurring),
 			stringValueOrEmpty(task.RecurringFrequency),
 			task.CreatedAt.Format(time.RFC3339),
+			strings.Join(task.Tags, ","),
+			stringValueOrEmpty(task.ProjectName),
+			stringValueOrEmpty(task.Position),
 		}
 		if err := writer.Write(record); err != nil {
 			return nil, err
@@ -134,12 +140,154 @@ func ImportTasksFromCSV(data []byte) ([]*models.Task, error) {
 			task.RecurringFrequency = &freq
 		}
 
+		// Parse Tags (optional column, comma separated)
+		if len(record) > 10 && record[10] != "" {
+			for _, tag := range strings.Split(record[10], ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					task.Tags = append(task.Tags, tag)
+				}
+			}
+		}
+
+		// Parse Project and Position (optional columns)
+		if len(record) > 11 && record[11] != "" {
+			project := record[11]
+			task.ProjectName = &project
+		}
+		if len(record) > 12 && record[12] != "" {
+			position := record[12]
+			task.Position = &position
+		}
+
 		tasks = append(tasks, task)
 	}
 
 	return tasks, nil
 }
 
+// ImportTasksFromICS reads the VTODO and VEVENT components of iCalendar
+// data. Each task keeps its UID in ICalUID so that importing the same file
+// again can update the tasks instead of adding them twice. Occurrences that
+// override one instance of a recurring item (RECURRENCE-ID) are skipped.
+func ImportTasksFromICS(data []byte) ([]*models.Task, error) {
+	components, err := ical.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse iCalendar: %w", err)
+	}
+
+	var tasks []*models.Task
+	for _, cal := range components {
+		if cal.Name != "VCALENDAR" {
+			continue
+		}
+		// Times without a zone are in the calendar's zone when it names one
+		floating, err := time.LoadLocation(cal.GetText("X-WR-TIMEZONE"))
+		if err != nil || cal.GetText("X-WR-TIMEZONE") == "Local" {
+			floating = time.UTC
+		}
+		timezones := ical.NewTimezones(cal, floating)
+
+		for _, item := range cal.Components {
+			if item.Name != "VTODO" && item.Name != "VEVENT" {
+				continue
+			}
+			if item.Get("RECURRENCE-ID") != nil {
+				continue
+			}
+			if task := TaskFromICS(item, timezones); task != nil {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("iCalendar data must contain at least one VTODO or VEVENT with a SUMMARY")
+	}
+	return tasks, nil
+}
+
+// TaskFromICS maps a VTODO or VEVENT onto a task, or returns nil without a
+// SUMMARY. Values that cannot be read are left out rather than failing the
+// import.
+func TaskFromICS(item *ical.Component, timezones *ical.Timezones) *models.Task {
+	name := strings.TrimSpace(item.GetText("SUMMARY"))
+	if name == "" {
+		return nil
+	}
+	task := &models.Task{
+		TaskName: name,
+		Status:   models.TaskStatusPending,
+		ICalUID:  item.GetText("UID"),
+	}
+
+	if desc := item.GetText("DESCRIPTION"); desc != "" {
+		task.Description = &desc
+	}
+
+	// A to-do is due at DUE; an event, or a to-do without DUE, at its start
+	due := item.Get("DUE")
+	if due == nil || item.Name == "VEVENT" {
+		due = item.Get("DTSTART")
+	}
+	if due != nil {
+		if t, _, err := timezones.Time(due); err == nil {
+			task.DueDate = &models.CustomTime{Time: t.UTC()}
+		}
+	}
+
+	// iCalendar runs from 1 (highest) to 9 (lowest), 0 being undefined
+	if priority, err := strconv.Atoi(item.GetText("PRIORITY")); err == nil {
+		switch {
+		case priority >= 1 && priority <= 4:
+			task.Priority = 3
+		case priority == 5:
+			task.Priority = 2
+		case priority >= 6 && priority <= 9:
+			task.Priority = 1
+		}
+	}
+
+	// The first category is the task's category, as the feeds write it; the
+	// rest are its tags
+	for _, categories := range item.All("CATEGORIES") {
+		for _, value := range ical.SplitText(categories.Value) {
+			value = strings.TrimSpace(value)
+			switch {
+			case value == "":
+			case task.Category == nil:
+				category := value
+				task.Category = &category
+			default:
+				task.Tags = append(task.Tags, value)
+			}
+		}
+	}
+
+	switch strings.ToUpper(item.GetText("STATUS")) {
+	case "IN-PROCESS":
+		task.Status = models.TaskStatusInProgress
+	case "COMPLETED":
+		task.Status = models.TaskStatusCompleted
+	case "CANCELLED":
+		task.Status = models.TaskStatusCancelled
+	}
+	if item.Get("COMPLETED") != nil || item.GetText("PERCENT-COMPLETE") == "100" {
+		task.Status = models.TaskStatusCompleted
+	}
+	task.IsCompleted = task.Status == models.TaskStatusCompleted
+
+	// Only rules the recurrence engine understands are kept
+	if rrule := item.Get("RRULE"); rrule != nil {
+		if rule, err := recurrence.Parse(rrule.Value); err == nil {
+			freq := rule.String()
+			task.IsRecurring = true
+			task.RecurringFrequency = &freq
+		}
+	}
+
+	return task
+}
+
 // Helper functions
 func stringValueOrEmpty(s *string) string {
 	if s == nil {